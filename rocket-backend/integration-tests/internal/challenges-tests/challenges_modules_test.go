@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/gomega"
 	"rocket-backend/internal/challenges"
 	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
 	"github.com/google/uuid"
 )
 
@@ -44,9 +45,57 @@ var _ = Describe("Challenges Module", func() {
 		Expect(len(chals)).To(BeNumerically(">=", 1))
 	})
 
+	It("should reject challenge files with invalid definitions", func() {
+		filePath := filepath.Join(GinkgoT().TempDir(), "challenges.json")
+		content := `[{"id": "550e8400-e29b-41d4-a716-446655440000", "text": "Run", "points": 10,
+			"category": "running", "difficulty": "easy", "verification": "run_distance"}]`
+		Expect(os.WriteFile(filePath, []byte(content), 0644)).To(Succeed())
+
+		_, err := challenges.LoadChallengesFromFile(filePath)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should upsert changed challenge definitions", func() {
+		challenge := types.Challenge{
+			ID:           uuid.New().String(),
+			Text:         "Walk 8000 steps",
+			Points:       20,
+			Category:     types.CategorySteps,
+			Difficulty:   types.DifficultyEasy,
+			Verification: types.VerificationSteps,
+			Parameters:   &types.ChallengeParameters{Target: 8000, Unit: "steps"},
+		}
+		Expect(dbService.UpsertChallenge(challenge)).To(Succeed())
+
+		challenge.Parameters.Target = 9000
+		challenge.Difficulty = types.DifficultyMedium
+		Expect(dbService.UpsertChallenge(challenge)).To(Succeed())
+
+		stored, err := dbService.GetChallengeByID(uuid.MustParse(challenge.ID))
+		Expect(err).To(BeNil())
+		Expect(stored.Difficulty).To(Equal(types.DifficultyMedium))
+		Expect(stored.Parameters.Target).To(Equal(float64(9000)))
+	})
+
 	It("should assign daily challenges to a user", func() {
 		dailies, err := manager.GetDailies(userID)
 		Expect(err).To(BeNil())
 		Expect(len(dailies)).To(Equal(5))
 	})
+
+	It("should assign an extra daily challenge for every challenge slot owned", func() {
+		_, err := testDbInstance.Exec(`
+			WITH slot AS (
+				INSERT INTO shop_items (name, description, category, price)
+				VALUES ('Test Challenge Slot', 'One more daily challenge', 'challenge_slot', 400)
+				RETURNING id
+			)
+			INSERT INTO user_inventory (user_id, item_id, quantity) SELECT $1, id, 2 FROM slot
+		`, userID)
+		Expect(err).To(BeNil())
+
+		dailies, err := manager.GetDailies(userID)
+		Expect(err).To(BeNil())
+		Expect(len(dailies)).To(Equal(challenges.DailyChallengeCount + 2))
+	})
 })
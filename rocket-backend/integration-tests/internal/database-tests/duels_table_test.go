@@ -0,0 +1,115 @@
+package server_tests
+
+import (
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Duels Table Integration", func() {
+	var dbService database.Service
+	var challengerID, opponentID uuid.UUID
+
+	createDuel := func(stake int, expiresAt time.Time) uuid.UUID {
+		duelID, err := dbService.CreateDuel(types.Duel{
+			ChallengerID: challengerID,
+			OpponentID:   opponentID,
+			Type:         types.DuelMostSteps,
+			DurationDays: 1,
+			Stake:        stake,
+			ExpiresAt:    expiresAt,
+		})
+		Expect(err).To(BeNil())
+		return duelID
+	}
+
+	accept := func(duelID uuid.UUID) error {
+		now := time.Now()
+		return dbService.AcceptDuel(duelID, opponentID, now, now.Add(24*time.Hour))
+	}
+
+	points := func(userID uuid.UUID) int {
+		var rocketpoints int
+		Expect(testDbInstance.QueryRow(`SELECT rocketpoints FROM users WHERE id = $1`, userID).Scan(&rocketpoints)).To(Succeed())
+		return rocketpoints
+	}
+
+	status := func(duelID uuid.UUID) string {
+		var s string
+		Expect(testDbInstance.QueryRow(`SELECT status FROM duels WHERE id = $1`, duelID).Scan(&s)).To(Succeed())
+		return s
+	}
+
+	BeforeEach(func() {
+		dbService = database.NewWithConfig(connectionString)
+		challengerID = createUser("challenger", 100)
+		opponentID = createUser("opponent", 100)
+	})
+
+	It("should hold back both stakes on accepting and pay them to the winner", func() {
+		duelID := createDuel(40, time.Now().Add(time.Hour))
+
+		Expect(accept(duelID)).To(Succeed())
+		Expect(status(duelID)).To(Equal(types.DuelActive))
+		Expect(points(challengerID)).To(Equal(60))
+		Expect(points(opponentID)).To(Equal(60))
+
+		Expect(dbService.FinishDuel(duelID, &opponentID, 1000, 2000)).To(Succeed())
+		Expect(status(duelID)).To(Equal(types.DuelFinished))
+		Expect(points(challengerID)).To(Equal(60))
+		Expect(points(opponentID)).To(Equal(140))
+
+		// Settling it a second time must not pay out again
+		Expect(dbService.FinishDuel(duelID, &opponentID, 1000, 2000)).To(MatchError(custom_error.ErrDuelNotPending))
+		Expect(points(opponentID)).To(Equal(140))
+	})
+
+	It("should give both stakes back on a draw", func() {
+		duelID := createDuel(40, time.Now().Add(time.Hour))
+		Expect(accept(duelID)).To(Succeed())
+
+		Expect(dbService.FinishDuel(duelID, nil, 1000, 1000)).To(Succeed())
+		Expect(points(challengerID)).To(Equal(100))
+		Expect(points(opponentID)).To(Equal(100))
+	})
+
+	It("should not take any stake when one side cannot afford it", func() {
+		duelID := createDuel(40, time.Now().Add(time.Hour))
+		_, err := testDbInstance.Exec(`UPDATE users SET rocketpoints = 10 WHERE id = $1`, opponentID)
+		Expect(err).To(BeNil())
+
+		Expect(accept(duelID)).To(MatchError(custom_error.ErrInsufficientPoints))
+		Expect(status(duelID)).To(Equal(types.DuelPending))
+		Expect(points(challengerID)).To(Equal(100))
+		Expect(points(opponentID)).To(Equal(10))
+	})
+
+	It("should only let the opponent accept a pending duel once", func() {
+		duelID := createDuel(0, time.Now().Add(time.Hour))
+
+		now := time.Now()
+		err := dbService.AcceptDuel(duelID, challengerID, now, now.Add(24*time.Hour))
+		Expect(err).To(MatchError(custom_error.ErrDuelNotPending))
+
+		Expect(accept(duelID)).To(Succeed())
+		Expect(accept(duelID)).To(MatchError(custom_error.ErrDuelNotPending))
+	})
+
+	It("should expire pending duels that were not accepted in time", func() {
+		expired := createDuel(40, time.Now().Add(-time.Minute))
+		open := createDuel(40, time.Now().Add(time.Hour))
+
+		Expect(accept(expired)).To(MatchError(custom_error.ErrDuelNotPending))
+
+		Expect(dbService.ExpireDuels()).To(Succeed())
+		Expect(status(expired)).To(Equal(types.DuelExpired))
+		Expect(status(open)).To(Equal(types.DuelPending))
+		Expect(points(challengerID)).To(Equal(100))
+	})
+})
@@ -15,6 +15,7 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -183,6 +184,23 @@ func truncateTables(db *sql.DB) error {
 	return err
 }
 
+// createUser inserts the credentials and user row of a new user with the given points
+func createUser(username string, rocketpoints int) uuid.UUID {
+	userID := uuid.New()
+	email := username + "@example.com"
+	_, err := testDbInstance.Exec(`
+		INSERT INTO credentials (id, email, password, created_at, last_login)
+		VALUES ($1, $2, $3, NOW(), NOW())
+	`, userID, email, "hashedpassword")
+	Expect(err).To(BeNil())
+	_, err = testDbInstance.Exec(`
+		INSERT INTO users (id, username, email, rocketpoints)
+		VALUES ($1, $2, $3, $4)
+	`, userID, username, email, rocketpoints)
+	Expect(err).To(BeNil())
+	return userID
+}
+
 func TestDatabaseIntegration(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Database Integration Tests Suite")
@@ -0,0 +1,67 @@
+package server_tests
+
+import (
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OAuth Table Integration", func() {
+	const clientID = "fitness-app"
+	var dbService database.Service
+	var userID uuid.UUID
+
+	createCode := func(codeHash string, expiresAt time.Time) {
+		err := dbService.CreateOAuthCode(codeHash, types.OAuthCode{
+			ClientID:      clientID,
+			UserID:        userID,
+			RedirectURI:   "https://fitness.example.com/callback",
+			Scopes:        []string{"profile", "steps:read"},
+			CodeChallenge: "challenge",
+		}, expiresAt)
+		Expect(err).To(BeNil())
+	}
+
+	BeforeEach(func() {
+		dbService = database.NewWithConfig(connectionString)
+		userID = createUser("oauthuser", 0)
+		_, err := testDbInstance.Exec(`
+			INSERT INTO oauth_clients (id, name, redirect_uris, scopes)
+			VALUES ($1, 'Fitness App', 'https://fitness.example.com/callback', 'profile steps:read')
+		`, clientID)
+		Expect(err).To(BeNil())
+	})
+
+	It("should hand out an authorization code only once", func() {
+		createCode("code-hash", time.Now().Add(time.Minute))
+
+		code, err := dbService.ConsumeOAuthCode("code-hash", clientID)
+		Expect(err).To(BeNil())
+		Expect(code.UserID).To(Equal(userID))
+		Expect(code.Scopes).To(Equal([]string{"profile", "steps:read"}))
+		Expect(code.CodeChallenge).To(Equal("challenge"))
+
+		_, err = dbService.ConsumeOAuthCode("code-hash", clientID)
+		Expect(err).To(MatchError(custom_error.ErrInvalidGrant))
+	})
+
+	It("should refuse codes of other clients and expired codes", func() {
+		createCode("code-hash", time.Now().Add(time.Minute))
+		createCode("expired-hash", time.Now().Add(-time.Minute))
+
+		_, err := dbService.ConsumeOAuthCode("code-hash", "other-app")
+		Expect(err).To(MatchError(custom_error.ErrInvalidGrant))
+		_, err = dbService.ConsumeOAuthCode("expired-hash", clientID)
+		Expect(err).To(MatchError(custom_error.ErrInvalidGrant))
+
+		// A wrong client does not use the code up
+		_, err = dbService.ConsumeOAuthCode("code-hash", clientID)
+		Expect(err).To(BeNil())
+	})
+})
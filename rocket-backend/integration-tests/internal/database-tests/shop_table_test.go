@@ -0,0 +1,100 @@
+package server_tests
+
+import (
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shop Table Integration", func() {
+	var dbService database.Service
+	var userID uuid.UUID
+
+	createItem := func(name, category string, price int, maxPerUser any, active bool) uuid.UUID {
+		var itemID uuid.UUID
+		err := testDbInstance.QueryRow(`
+			INSERT INTO shop_items (name, category, price, max_per_user, active)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, name, category, price, maxPerUser, active).Scan(&itemID)
+		Expect(err).To(BeNil())
+		return itemID
+	}
+
+	count := func(query string, args ...any) int {
+		var n int
+		Expect(testDbInstance.QueryRow(query, args...).Scan(&n)).To(Succeed())
+		return n
+	}
+
+	BeforeEach(func() {
+		dbService = database.NewWithConfig(connectionString)
+		userID = createUser("shopper", 1000)
+	})
+
+	It("should take the price and hand out the item", func() {
+		itemID := createItem("Test Badge", "badge", 400, nil, true)
+
+		purchase, err := dbService.PurchaseShopItem(userID, itemID)
+		Expect(err).To(BeNil())
+		Expect(purchase.ItemID).To(Equal(itemID))
+		Expect(purchase.Price).To(Equal(400))
+
+		Expect(count(`SELECT rocketpoints FROM users WHERE id = $1`, userID)).To(Equal(600))
+		Expect(count(`SELECT quantity FROM user_inventory WHERE user_id = $1 AND item_id = $2`, userID, itemID)).To(Equal(1))
+		Expect(count(`SELECT COUNT(*) FROM shop_purchases WHERE user_id = $1 AND price = 400`, userID)).To(Equal(1))
+	})
+
+	It("should stop at the limit per user without taking points", func() {
+		itemID := createItem("Test Frame", "profile_frame", 100, 1, true)
+
+		_, err := dbService.PurchaseShopItem(userID, itemID)
+		Expect(err).To(BeNil())
+		_, err = dbService.PurchaseShopItem(userID, itemID)
+		Expect(err).To(MatchError(custom_error.ErrLimitReached))
+
+		Expect(count(`SELECT rocketpoints FROM users WHERE id = $1`, userID)).To(Equal(900))
+		Expect(count(`SELECT quantity FROM user_inventory WHERE user_id = $1`, userID)).To(Equal(1))
+	})
+
+	It("should not hand out the item when the user cannot afford it", func() {
+		itemID := createItem("Test Rocket", "badge", 5000, nil, true)
+
+		_, err := dbService.PurchaseShopItem(userID, itemID)
+		Expect(err).To(MatchError(custom_error.ErrInsufficientPoints))
+
+		Expect(count(`SELECT rocketpoints FROM users WHERE id = $1`, userID)).To(Equal(1000))
+		Expect(count(`SELECT COUNT(*) FROM user_inventory WHERE user_id = $1`, userID)).To(Equal(0))
+		Expect(count(`SELECT COUNT(*) FROM shop_purchases WHERE user_id = $1`, userID)).To(Equal(0))
+	})
+
+	It("should not sell inactive items", func() {
+		itemID := createItem("Retired Badge", "badge", 100, nil, false)
+
+		_, err := dbService.PurchaseShopItem(userID, itemID)
+		Expect(err).To(MatchError(custom_error.ErrItemNotFound))
+		Expect(count(`SELECT rocketpoints FROM users WHERE id = $1`, userID)).To(Equal(1000))
+	})
+
+	It("should put streak freezes on the streak", func() {
+		itemID := createItem("Test Freeze", "streak_freeze", 200, 2, true)
+		_, err := testDbInstance.Exec(`
+			INSERT INTO streaks (user_id, last_evaluated) VALUES ($1, CURRENT_DATE - 1)
+		`, userID)
+		Expect(err).To(BeNil())
+
+		for range 2 {
+			_, err = dbService.PurchaseShopItem(userID, itemID)
+			Expect(err).To(BeNil())
+		}
+		_, err = dbService.PurchaseShopItem(userID, itemID)
+		Expect(err).To(MatchError(custom_error.ErrLimitReached))
+
+		Expect(count(`SELECT freezes FROM streaks WHERE user_id = $1`, userID)).To(Equal(2))
+		Expect(count(`SELECT COUNT(*) FROM user_inventory WHERE user_id = $1`, userID)).To(Equal(0))
+		Expect(count(`SELECT rocketpoints FROM users WHERE id = $1`, userID)).To(Equal(600))
+	})
+})
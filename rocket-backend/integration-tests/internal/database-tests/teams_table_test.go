@@ -0,0 +1,103 @@
+package server_tests
+
+import (
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Teams Table Integration", func() {
+	var dbService database.Service
+	var ownerID, memberID uuid.UUID
+	var teamID uuid.UUID
+
+	createChallenge := func(points int) uuid.UUID {
+		now := time.Now()
+		challengeID, err := dbService.CreateTeamChallenge(types.TeamChallenge{
+			TeamID:     teamID,
+			Text:       "Walk 20000 steps together",
+			Metric:     "steps",
+			Parameters: types.ChallengeParameters{Target: 20000, Unit: "steps"},
+			Points:     points,
+			StartsOn:   now,
+			EndsOn:     now.AddDate(0, 0, 2),
+		})
+		Expect(err).To(BeNil())
+		return challengeID
+	}
+
+	points := func(userID uuid.UUID) int {
+		var rocketpoints int
+		Expect(testDbInstance.QueryRow(`SELECT rocketpoints FROM users WHERE id = $1`, userID).Scan(&rocketpoints)).To(Succeed())
+		return rocketpoints
+	}
+
+	BeforeEach(func() {
+		dbService = database.NewWithConfig(connectionString)
+		ownerID = createUser("owner", 0)
+		memberID = createUser("member", 0)
+
+		var err error
+		teamID, err = dbService.CreateTeam("Rocketeers", ownerID, []uuid.UUID{memberID})
+		Expect(err).To(BeNil())
+	})
+
+	It("should set the challenge for the members at creation with their steps so far", func() {
+		_, err := testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date) VALUES (gen_random_uuid(), $1, 3000, CURRENT_DATE)
+		`, ownerID)
+		Expect(err).To(BeNil())
+
+		challengeID := createChallenge(20)
+		latecomerID := createUser("latecomer", 0)
+		_, err = testDbInstance.Exec(`INSERT INTO team_members (team_id, user_id) VALUES ($1, $2)`, teamID, latecomerID)
+		Expect(err).To(BeNil())
+
+		members, err := dbService.GetTeamChallengeMembers(challengeID)
+		Expect(err).To(BeNil())
+		baselines := map[uuid.UUID]float64{}
+		for _, member := range members {
+			baselines[member.UserID] = member.Baseline
+		}
+		Expect(baselines).To(Equal(map[uuid.UUID]float64{ownerID: 3000, memberID: 0}))
+	})
+
+	It("should reward the members once", func() {
+		challengeID := createChallenge(20)
+
+		rewarded, err := dbService.CompleteTeamChallenge(challengeID, 300)
+		Expect(err).To(BeNil())
+		Expect(rewarded).To(ConsistOf(ownerID, memberID))
+		Expect(points(ownerID)).To(Equal(20))
+		Expect(points(memberID)).To(Equal(20))
+
+		_, err = dbService.CompleteTeamChallenge(challengeID, 300)
+		Expect(err).To(MatchError(custom_error.ErrAlreadyCompleted))
+		Expect(points(ownerID)).To(Equal(20))
+	})
+
+	It("should not reward more than the weekly limit", func() {
+		first := createChallenge(20)
+		second := createChallenge(20)
+
+		_, err := dbService.CompleteTeamChallenge(first, 30)
+		Expect(err).To(BeNil())
+		_, err = dbService.CompleteTeamChallenge(second, 30)
+		Expect(err).To(BeNil())
+		Expect(points(ownerID)).To(Equal(30))
+		Expect(points(memberID)).To(Equal(30))
+
+		var awarded int
+		err = testDbInstance.QueryRow(`
+			SELECT points_awarded FROM team_challenge_members WHERE challenge_id = $1 AND user_id = $2
+		`, second, ownerID).Scan(&awarded)
+		Expect(err).To(BeNil())
+		Expect(awarded).To(Equal(10))
+	})
+})
@@ -0,0 +1,71 @@
+package server_tests
+
+import (
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tokens Table Integration", func() {
+	var dbService database.Service
+	var userID, sessionID uuid.UUID
+
+	BeforeEach(func() {
+		dbService = database.NewWithConfig(connectionString)
+		userID = createUser("sessionuser", 0)
+
+		var err error
+		sessionID, err = dbService.CreateSession(types.Session{UserID: userID, DeviceName: "phone", IPAddress: "10.0.0.1"},
+			"first-hash", time.Now().Add(time.Hour))
+		Expect(err).To(BeNil())
+	})
+
+	It("should replace the refresh token and keep the session", func() {
+		creds, familyID, err := dbService.RotateRefreshToken("first-hash", "second-hash", time.Now().Add(2*time.Hour), "10.0.0.2")
+		Expect(err).To(BeNil())
+		Expect(creds.ID).To(Equal(userID))
+		Expect(familyID).To(Equal(sessionID))
+
+		var ipAddress string
+		Expect(testDbInstance.QueryRow(`SELECT ip_address FROM sessions WHERE id = $1`, sessionID).Scan(&ipAddress)).To(Succeed())
+		Expect(ipAddress).To(Equal("10.0.0.2"))
+
+		_, _, err = dbService.RotateRefreshToken("second-hash", "third-hash", time.Now().Add(2*time.Hour), "10.0.0.2")
+		Expect(err).To(BeNil())
+	})
+
+	It("should revoke the session when a rotated token is used again", func() {
+		_, _, err := dbService.RotateRefreshToken("first-hash", "second-hash", time.Now().Add(time.Hour), "10.0.0.1")
+		Expect(err).To(BeNil())
+
+		_, _, err = dbService.RotateRefreshToken("first-hash", "stolen-hash", time.Now().Add(time.Hour), "10.0.0.9")
+		Expect(err).To(MatchError(custom_error.ErrRefreshTokenReused))
+
+		var revoked bool
+		Expect(testDbInstance.QueryRow(`SELECT revoked_at IS NOT NULL FROM sessions WHERE id = $1`, sessionID).Scan(&revoked)).To(Succeed())
+		Expect(revoked).To(BeTrue())
+		// The token that replaced it was revoked along with the session
+		_, _, err = dbService.RotateRefreshToken("second-hash", "third-hash", time.Now().Add(time.Hour), "10.0.0.1")
+		Expect(err).To(MatchError(custom_error.ErrRefreshTokenReused))
+	})
+
+	It("should refuse unknown and expired refresh tokens", func() {
+		_, _, err := dbService.RotateRefreshToken("unknown-hash", "second-hash", time.Now().Add(time.Hour), "10.0.0.1")
+		Expect(err).To(MatchError(custom_error.ErrInvalidRefreshToken))
+
+		_, err = testDbInstance.Exec(`UPDATE refresh_tokens SET expires_at = NOW() - INTERVAL '1 minute'`)
+		Expect(err).To(BeNil())
+		_, _, err = dbService.RotateRefreshToken("first-hash", "second-hash", time.Now().Add(time.Hour), "10.0.0.1")
+		Expect(err).To(MatchError(custom_error.ErrInvalidRefreshToken))
+
+		var tokens int
+		Expect(testDbInstance.QueryRow(`SELECT COUNT(*) FROM refresh_tokens`).Scan(&tokens)).To(Succeed())
+		Expect(tokens).To(Equal(1))
+	})
+})
@@ -3,6 +3,9 @@ package server_tests
 import (
 	"time"
 
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/google/uuid"
@@ -192,4 +195,50 @@ var _ = Describe("Users Table Integration", func() {
 		Expect(err).To(BeNil())
 		Expect(count).To(Equal(0))
 	})
+
+	It("should erase a user with their images and duels and keep their chat messages", func() {
+		dbService := database.NewWithConfig(connectionString)
+		_, err := testDbInstance.Exec(`
+			INSERT INTO users (id, username, email, rocketpoints)
+			VALUES ($1, $2, $3, $4)
+		`, userID, "useruser", "useruser@example.com", 0)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`
+			WITH image AS (
+				INSERT INTO image_store (image_name, image_data) VALUES ('me.png', 'png') RETURNING id
+			)
+			INSERT INTO settings (id, user_id, image_id) SELECT gen_random_uuid(), $1, id FROM image
+		`, userID)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`INSERT INTO chat_messages (user_id, message) VALUES ($1, 'bye')`, userID)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`INSERT INTO login_failures (email, failures) VALUES ('useruser@example.com', 2)`)
+		Expect(err).To(BeNil())
+		otherID := createUser("otheruser", 70)
+		_, err = testDbInstance.Exec(`
+			INSERT INTO duels (challenger_id, opponent_id, type, duration_days, stake, status, expires_at, starts_at, ends_at)
+			VALUES ($1, $2, 'most_steps', 1, 30, 'active', NOW(), NOW(), NOW() + INTERVAL '1 day')
+		`, userID, otherID)
+		Expect(err).To(BeNil())
+
+		Expect(dbService.DeleteUser(userID)).To(Succeed())
+
+		count := func(query string) int {
+			var n int
+			Expect(testDbInstance.QueryRow(query).Scan(&n)).To(Succeed())
+			return n
+		}
+		Expect(count(`SELECT COUNT(*) FROM credentials`)).To(Equal(1))
+		Expect(count(`SELECT COUNT(*) FROM users`)).To(Equal(1))
+		Expect(count(`SELECT COUNT(*) FROM settings`)).To(Equal(0))
+		Expect(count(`SELECT COUNT(*) FROM image_store`)).To(Equal(0))
+		Expect(count(`SELECT COUNT(*) FROM login_failures`)).To(Equal(0))
+		Expect(count(`SELECT COUNT(*) FROM chat_messages WHERE user_id IS NULL AND message = 'bye'`)).To(Equal(1))
+		// The opponent's stake of the duel that went with the user is paid back
+		Expect(count(`SELECT COUNT(*) FROM duels`)).To(Equal(0))
+		Expect(count(`SELECT rocketpoints FROM users`)).To(Equal(100))
+
+		Expect(dbService.DeleteUser(userID)).To(MatchError(custom_error.ErrUserNotFound))
+		Expect(dbService.DeleteUser(otherID)).To(Succeed())
+	})
 })
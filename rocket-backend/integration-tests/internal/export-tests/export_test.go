@@ -0,0 +1,155 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"rocket-backend/internal/export"
+	"rocket-backend/internal/types"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Data export archive", func() {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	sections := []types.ExportSection{
+		{Name: "profile", Records: json.RawMessage(`[{"username": "rocketeer", "email": "r@example.com", "rocketpoints": 42}]`)},
+		{Name: "daily_steps", Records: json.RawMessage(`[{"date": "2024-04-30", "steps_taken": 12000}, {"date": "2024-05-01", "steps_taken": 800}]`)},
+		{Name: "chat_messages", Records: json.RawMessage(`[]`)},
+		{Name: "runs", Records: json.RawMessage(`[
+			{"id": "run-1", "created_at": "2024-04-30T07:15:00.123456", "duration": "00:30:00", "distance": 5.2,
+			 "route": {"type": "LineString", "coordinates": [[13.4, 52.5], [13.41, 52.51]]}},
+			{"id": "run-2", "created_at": "2024-04-30T18:00:00", "duration": "00:10:00", "distance": 1, "route": null}
+		]`)},
+		{Name: "planned_runs", Records: json.RawMessage(`[
+			{"id": "plan-1", "created_at": "2024-04-29T10:00:00", "name": "Park loop", "distance": 3,
+			 "route": {"type": "LineString", "coordinates": [[8.5, 47.3], [8.6, 47.4]]}}
+		]`)},
+	}
+
+	// open builds the archive and returns its files by name
+	open := func(image *types.UserImage) map[string][]byte {
+		archive, err := export.Archive(sections, image, now)
+		Expect(err).NotTo(HaveOccurred())
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		Expect(err).NotTo(HaveOccurred())
+
+		files := map[string][]byte{}
+		for _, file := range reader.File {
+			rc, err := file.Open()
+			Expect(err).NotTo(HaveOccurred())
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			Expect(err).NotTo(HaveOccurred())
+			files[file.Name] = data
+		}
+		return files
+	}
+
+	It("should contain every section as JSON and CSV", func() {
+		files := open(nil)
+		Expect(files).To(HaveKey("README.txt"))
+		for _, name := range []string{"profile", "daily_steps", "chat_messages", "runs", "planned_runs"} {
+			Expect(files).To(HaveKey(name + ".json"))
+			Expect(files).To(HaveKey(name + ".csv"))
+		}
+		Expect(files).NotTo(HaveKey(HavePrefix("profile_image")))
+
+		var profile []map[string]any
+		Expect(json.Unmarshal(files["profile.json"], &profile)).To(Succeed())
+		Expect(profile[0]).To(HaveKeyWithValue("username", "rocketeer"))
+		Expect(files["chat_messages.csv"]).To(BeEmpty())
+	})
+
+	It("should keep the column order of the records in the CSV files", func() {
+		rows, err := csv.NewReader(bytes.NewReader(open(nil)["daily_steps.csv"])).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(Equal([][]string{
+			{"date", "steps_taken"},
+			{"2024-04-30", "12000"},
+			{"2024-05-01", "800"},
+		}))
+
+		rows, err = csv.NewReader(bytes.NewReader(open(nil)["runs.csv"])).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows[0]).To(Equal([]string{"id", "created_at", "duration", "distance", "route"}))
+		Expect(rows[1][4]).To(Equal(`{"type":"LineString","coordinates":[[13.4,52.5],[13.41,52.51]]}`))
+		Expect(rows[2][4]).To(BeEmpty())
+	})
+
+	It("should include runs as GeoJSON", func() {
+		var collection struct {
+			Type     string `json:"type"`
+			Features []struct {
+				Geometry   map[string]any `json:"geometry"`
+				Properties map[string]any `json:"properties"`
+			} `json:"features"`
+		}
+		Expect(json.Unmarshal(open(nil)["runs.geojson"], &collection)).To(Succeed())
+		Expect(collection.Type).To(Equal("FeatureCollection"))
+		Expect(collection.Features).To(HaveLen(2))
+		Expect(collection.Features[0].Geometry).To(HaveKeyWithValue("type", "LineString"))
+		Expect(collection.Features[0].Properties).To(HaveKeyWithValue("distance", 5.2))
+		Expect(collection.Features[0].Properties).NotTo(HaveKey("route"))
+		Expect(collection.Features[1].Geometry).To(BeNil())
+	})
+
+	It("should include runs as GPX tracks and planned runs as GPX routes", func() {
+		files := open(nil)
+		Expect(files).NotTo(HaveKey("runs/run-2.gpx"))
+
+		var run struct {
+			Metadata struct {
+				Name string `xml:"name"`
+				Time string `xml:"time"`
+			} `xml:"metadata"`
+			Points []struct {
+				Lat float64 `xml:"lat,attr"`
+				Lon float64 `xml:"lon,attr"`
+			} `xml:"trk>trkseg>trkpt"`
+		}
+		Expect(xml.Unmarshal(files["runs/run-1.gpx"], &run)).To(Succeed())
+		Expect(run.Metadata.Name).To(Equal("Run on 2024-04-30"))
+		Expect(run.Metadata.Time).To(Equal("2024-04-30T07:15:00Z"))
+		Expect(run.Points).To(HaveLen(2))
+		Expect(run.Points[0].Lat).To(Equal(52.5))
+		Expect(run.Points[0].Lon).To(Equal(13.4))
+
+		var plan struct {
+			Name   string `xml:"rte>name"`
+			Points []struct {
+				Lat float64 `xml:"lat,attr"`
+			} `xml:"rte>rtept"`
+		}
+		Expect(xml.Unmarshal(files["planned_runs/plan-1.gpx"], &plan)).To(Succeed())
+		Expect(plan.Name).To(Equal("Park loop"))
+		Expect(plan.Points).To(HaveLen(2))
+		Expect(plan.Points[1].Lat).To(Equal(47.4))
+	})
+
+	It("should include the profile image", func() {
+		files := open(&types.UserImage{Name: "Me.JPG", Data: []byte("jpeg")})
+		Expect(files).To(HaveKeyWithValue("profile_image.jpg", []byte("jpeg")))
+
+		files = open(&types.UserImage{Name: "../../evil.$x", Data: []byte("png")})
+		Expect(files).To(HaveKeyWithValue("profile_image", []byte("png")))
+	})
+
+	It("should refuse sections that are not arrays of records", func() {
+		_, err := export.Archive([]types.ExportSection{{Name: "broken", Records: json.RawMessage(`{"a": 1}`)}}, nil, now)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+func TestExport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Export Suite")
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"rocket-backend/internal/jobs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cron schedules", func() {
+	at := func(value string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", value)
+		Expect(err).To(BeNil())
+		return t
+	}
+
+	next := func(spec, from string) string {
+		schedule, err := jobs.ParseSchedule(spec)
+		Expect(err).To(BeNil())
+		return schedule.Next(at(from)).Format("2006-01-02 15:04")
+	}
+
+	It("should run daily jobs once per day", func() {
+		Expect(next("5 0 * * *", "2024-03-10 00:04")).To(Equal("2024-03-10 00:05"))
+		Expect(next("5 0 * * *", "2024-03-10 00:05")).To(Equal("2024-03-11 00:05"))
+		Expect(next("5 0 * * *", "2024-12-31 23:59")).To(Equal("2025-01-01 00:05"))
+	})
+
+	It("should support steps, ranges and lists", func() {
+		Expect(next("*/15 * * * *", "2024-03-10 10:16")).To(Equal("2024-03-10 10:30"))
+		Expect(next("0 9-17/4 * * *", "2024-03-10 13:00")).To(Equal("2024-03-10 17:00"))
+		Expect(next("0 8,20 * * *", "2024-03-10 08:00")).To(Equal("2024-03-10 20:00"))
+	})
+
+	It("should match either day field when both are restricted", func() {
+		// 2024-03-10 is a Sunday, the 15th is a Friday
+		Expect(next("0 0 * * 1", "2024-03-10 12:00")).To(Equal("2024-03-11 00:00"))
+		Expect(next("0 0 15 * 1", "2024-03-11 12:00")).To(Equal("2024-03-15 00:00"))
+		Expect(next("0 0 29 2 *", "2024-03-01 00:00")).To(Equal("2028-02-29 00:00"))
+	})
+
+	It("should reject malformed expressions", func() {
+		for _, spec := range []string{"* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+			_, err := jobs.ParseSchedule(spec)
+			Expect(err).NotTo(BeNil(), spec)
+		}
+	})
+})
+
+func TestJobs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Jobs Suite")
+}
@@ -0,0 +1,148 @@
+package selection
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"rocket-backend/internal/challenges"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Personalized daily challenge selection", func() {
+	var (
+		strategy   challenges.PersonalizedStrategy
+		candidates []types.Challenge
+		input      challenges.SelectionInput
+	)
+
+	ids := func(selected []types.Challenge) []string {
+		result := make([]string, len(selected))
+		for i, challenge := range selected {
+			result[i] = challenge.ID
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		strategy = challenges.PersonalizedStrategy{AvoidRepeatDays: 3}
+		candidates = nil
+		categories := []string{
+			types.CategorySteps, types.CategoryRunning, types.CategoryStrength,
+			types.CategoryMindfulness, types.CategoryNutrition,
+		}
+		difficulties := []string{types.DifficultyEasy, types.DifficultyMedium, types.DifficultyHard}
+		for i := 0; i < 30; i++ {
+			candidates = append(candidates, types.Challenge{
+				ID:           fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+				Text:         fmt.Sprintf("Challenge %d", i),
+				Points:       20,
+				Category:     categories[i%len(categories)],
+				Difficulty:   difficulties[i%len(difficulties)],
+				Verification: types.VerificationSelfReport,
+			})
+		}
+		input = challenges.SelectionInput{
+			UserID:       uuid.New(),
+			Date:         time.Date(2025, 5, 12, 0, 0, 0, 0, time.UTC),
+			AverageSteps: 8000,
+			StepGoal:     10000,
+		}
+	})
+
+	It("should pick the requested number of distinct challenges", func() {
+		selected := strategy.Select(input, candidates, 5)
+		Expect(selected).To(HaveLen(5))
+		Expect(ids(selected)).To(HaveLen(5))
+		seen := map[string]bool{}
+		for _, id := range ids(selected) {
+			Expect(seen[id]).To(BeFalse())
+			seen[id] = true
+		}
+	})
+
+	It("should be deterministic per user and day", func() {
+		first := strategy.Select(input, candidates, 5)
+
+		reversed := make([]types.Challenge, len(candidates))
+		for i, challenge := range candidates {
+			reversed[len(candidates)-1-i] = challenge
+		}
+		Expect(ids(strategy.Select(input, reversed, 5))).To(Equal(ids(first)))
+
+		differentDays := 0
+		for day := 1; day <= 5; day++ {
+			other := input
+			other.Date = input.Date.AddDate(0, 0, day)
+			if fmt.Sprint(ids(strategy.Select(other, candidates, 5))) != fmt.Sprint(ids(first)) {
+				differentDays++
+			}
+		}
+		Expect(differentDays).To(BeNumerically(">", 0))
+	})
+
+	It("should avoid challenges assigned in the last days", func() {
+		for _, challenge := range candidates[:20] {
+			input.History = append(input.History, types.ChallengeHistoryEntry{
+				ChallengeID: challenge.ID,
+				Category:    challenge.Category,
+				Date:        input.Date.AddDate(0, 0, -1),
+			})
+		}
+
+		selected := strategy.Select(input, candidates, 5)
+		for _, challenge := range selected {
+			Expect(ids(candidates[:20])).NotTo(ContainElement(challenge.ID))
+		}
+	})
+
+	It("should count the look-back window in days when the server is not on UTC", func() {
+		// Local midnight west of UTC, the history dates come back at UTC midnight
+		input.Date = time.Date(2025, 5, 12, 0, 0, 0, 0, time.FixedZone("UTC-4", -4*60*60))
+		for _, challenge := range candidates[:20] {
+			input.History = append(input.History, types.ChallengeHistoryEntry{
+				ChallengeID: challenge.ID,
+				Category:    challenge.Category,
+				Date:        time.Date(2025, 5, 9, 0, 0, 0, 0, time.UTC),
+			})
+		}
+
+		selected := strategy.Select(input, candidates, 5)
+		for _, challenge := range selected {
+			Expect(ids(candidates[:20])).NotTo(ContainElement(challenge.ID))
+		}
+	})
+
+	It("should fall back to repeats when there are not enough fresh challenges", func() {
+		for _, challenge := range candidates[:28] {
+			input.History = append(input.History, types.ChallengeHistoryEntry{
+				ChallengeID: challenge.ID,
+				Category:    challenge.Category,
+				Date:        input.Date.AddDate(0, 0, -2),
+			})
+		}
+
+		Expect(strategy.Select(input, candidates, 5)).To(HaveLen(5))
+	})
+
+	It("should spread the picks over the categories", func() {
+		for day := 0; day < 20; day++ {
+			other := input
+			other.Date = input.Date.AddDate(0, 0, day)
+			perCategory := map[string]int{}
+			for _, challenge := range strategy.Select(other, candidates, 5) {
+				perCategory[challenge.Category]++
+			}
+			Expect(len(perCategory)).To(BeNumerically(">=", 3))
+		}
+	})
+})
+
+func TestSelection(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Selection Suite")
+}
@@ -0,0 +1,194 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Account Deletion", func() {
+	const apiKey = "test-api-key"
+	var token string
+
+	call := func(method, path, token string) (int, map[string]any) {
+		req, _ := http.NewRequest(method, baseURL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-API-KEY", apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	login := func() (int, map[string]any) {
+		body, _ := json.Marshal(map[string]any{"email": "leaving@example.com", "password": "password123"})
+		resp, err := http.Post(baseURL+"/login", "application/json", bytes.NewReader(body))
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	count := func(query string) int {
+		var n int
+		Expect(testDbInstance.QueryRow(query).Scan(&n)).To(Succeed())
+		return n
+	}
+
+	BeforeEach(func() {
+		os.Setenv("API_KEY", apiKey)
+		token = registerAndLogin("leaving@example.com", "password123", "leaving")
+	})
+
+	It("should deactivate the account and hide the user", func() {
+		other := registerAndLogin("staying@example.com", "password123", "staying")
+
+		status, result := call("DELETE", "/protected/user", token)
+		Expect(status).To(Equal(200))
+		Expect(result["message"]).To(Equal("Account deactivated"))
+		Expect(result["erase_after"]).NotTo(BeEmpty())
+
+		status, _ = call("GET", "/protected/user", token)
+		Expect(status).To(Equal(401))
+
+		req, _ := http.NewRequest("GET", baseURL+"/protected/users", nil)
+		req.Header.Set("Authorization", "Bearer "+other)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var users []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&users)
+		Expect(users).To(BeEmpty())
+	})
+
+	It("should hide the user from friend lists, rankings, the feed and chat", func() {
+		other := registerAndLogin("staying@example.com", "password123", "staying")
+		_, err := testDbInstance.Exec(`
+			INSERT INTO friends (user_id, friend_id)
+			SELECT a.id, b.id FROM users a, users b WHERE a.id <> b.id
+		`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`
+			INSERT INTO activities (id, user_id, time, message)
+			SELECT gen_random_uuid(), id, NOW(), username || ' ran' FROM users
+		`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`INSERT INTO chat_messages (user_id, message) SELECT id, 'hi from ' || username FROM users`)
+		Expect(err).To(BeNil())
+		var otherID string
+		Expect(testDbInstance.QueryRow(`SELECT id FROM users WHERE username = 'staying'`).Scan(&otherID)).To(Succeed())
+
+		// names returns the values of key in the list at path, or in its field when given
+		names := func(path, field, key string) []string {
+			req, _ := http.NewRequest("GET", baseURL+path, nil)
+			req.Header.Set("Authorization", "Bearer "+other)
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).To(BeNil())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(200))
+			var list []map[string]any
+			if field == "" {
+				_ = json.NewDecoder(resp.Body).Decode(&list)
+			} else {
+				var result map[string][]map[string]any
+				_ = json.NewDecoder(resp.Body).Decode(&result)
+				list = result[field]
+			}
+			values := []string{}
+			for _, entry := range list {
+				values = append(values, entry[key].(string))
+			}
+			return values
+		}
+
+		Expect(names("/protected/friends", "", "username")).To(ContainElement("leaving"))
+
+		status, _ := call("DELETE", "/protected/user", token)
+		Expect(status).To(Equal(200))
+
+		Expect(names("/protected/friends", "", "username")).NotTo(ContainElement("leaving"))
+		Expect(names("/protected/followers/"+otherID, "", "username")).NotTo(ContainElement("leaving"))
+		Expect(names("/protected/ranking/friends", "", "username")).NotTo(ContainElement("leaving"))
+		Expect(names("/protected/activites", "activities", "name")).NotTo(ContainElement("leaving"))
+		Expect(names("/protected/chat/history", "messages", "username")).To(ContainElements("Deleted user", "staying"))
+		Expect(names("/protected/chat/history", "messages", "username")).NotTo(ContainElement("leaving"))
+	})
+
+	It("should restore the account when logging in during the grace period", func() {
+		status, _ := call("DELETE", "/protected/user", token)
+		Expect(status).To(Equal(200))
+
+		status, result := login()
+		Expect(status).To(Equal(200))
+		Expect(result["account_restored"]).To(BeTrue())
+		Expect(count(`SELECT COUNT(*) FROM credentials WHERE deactivated_at IS NOT NULL`)).To(Equal(0))
+
+		status, result = login()
+		Expect(status).To(Equal(200))
+		Expect(result).NotTo(HaveKey("account_restored"))
+	})
+
+	It("should refuse logins once the grace period is over", func() {
+		status, _ := call("DELETE", "/protected/user", token)
+		Expect(status).To(Equal(200))
+		_, err := testDbInstance.Exec(`UPDATE credentials SET erase_after = NOW() - INTERVAL '1 minute'`)
+		Expect(err).To(BeNil())
+
+		status, result := login()
+		Expect(status).To(Equal(410))
+		Expect(result["error"]).To(Equal("Account has been deleted"))
+	})
+
+	It("should erase the account and its data after the grace period", func() {
+		_, err := testDbInstance.Exec(`
+			WITH image AS (
+				INSERT INTO image_store (image_name, image_data) VALUES ('me.png', 'png') RETURNING id
+			)
+			UPDATE settings SET image_id = (SELECT id FROM image)
+		`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`INSERT INTO chat_messages (user_id, message) SELECT id, 'bye' FROM users`)
+		Expect(err).To(BeNil())
+		// An accepted duel holds back the stakes of both, the opponent gets theirs back
+		registerAndLogin("staying@example.com", "password123", "staying")
+		_, err = testDbInstance.Exec(`
+			INSERT INTO duels (challenger_id, opponent_id, type, duration_days, stake, status, expires_at, starts_at, ends_at)
+			SELECT c.id, o.id, 'most_steps', 1, 30, 'active', NOW(), NOW(), NOW() + INTERVAL '1 day'
+			FROM users c, users o WHERE c.username = 'leaving' AND o.username = 'staying'
+		`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`UPDATE users SET rocketpoints = 70`)
+		Expect(err).To(BeNil())
+
+		status, _ := call("DELETE", "/protected/user", token)
+		Expect(status).To(Equal(200))
+
+		// Not yet due, nothing is erased
+		status, _ = call("POST", "/admin/jobs/account_erasure/run", "")
+		Expect(status).To(Equal(200))
+		Expect(count(`SELECT COUNT(*) FROM credentials`)).To(Equal(2))
+
+		_, err = testDbInstance.Exec(`UPDATE credentials SET erase_after = NOW() - INTERVAL '1 minute' WHERE deactivated_at IS NOT NULL`)
+		Expect(err).To(BeNil())
+		status, _ = call("POST", "/admin/jobs/account_erasure/run", "")
+		Expect(status).To(Equal(200))
+
+		Expect(count(`SELECT COUNT(*) FROM credentials`)).To(Equal(1))
+		Expect(count(`SELECT COUNT(*) FROM users WHERE username = 'leaving'`)).To(Equal(0))
+		Expect(count(`SELECT COUNT(*) FROM settings WHERE image_id IS NOT NULL`)).To(Equal(0))
+		Expect(count(`SELECT COUNT(*) FROM image_store`)).To(Equal(0))
+		Expect(count(`SELECT COUNT(*) FROM duels`)).To(Equal(0))
+		Expect(count(`SELECT rocketpoints FROM users WHERE username = 'staying'`)).To(Equal(100))
+		Expect(count(`SELECT COUNT(*) FROM chat_messages WHERE user_id IS NULL AND message = 'bye'`)).To(Equal(1))
+
+		status, _ = login()
+		Expect(status).To(Equal(401))
+	})
+})
@@ -0,0 +1,133 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Email Verification and Password Reset", func() {
+	const email = "account@example.com"
+
+	tokenPattern := regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+	post := func(path, token string, payload any) (int, map[string]any) {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	// mailedToken returns the token of the link in the latest email to the address
+	mailedToken := func(to, subject string) string {
+		msg, ok := testMailer.Last(to)
+		Expect(ok).To(BeTrue())
+		Expect(msg.Subject).To(Equal(subject))
+		match := tokenPattern.FindStringSubmatch(msg.Body)
+		Expect(match).To(HaveLen(2))
+		return match[1]
+	}
+
+	login := func(password string) (int, map[string]any) {
+		return post("/login", "", map[string]any{"email": email, "password": password})
+	}
+
+	BeforeEach(func() {
+		registerAndLogin(email, "password123", "account")
+	})
+
+	It("should verify the email with the mailed token", func() {
+		_, result := login("password123")
+		Expect(result["email_verified"]).To(BeFalse())
+
+		token := mailedToken(email, "Verify your email address")
+		status, _ := post("/verify-email", "", map[string]any{"token": token})
+		Expect(status).To(Equal(200))
+
+		_, result = login("password123")
+		Expect(result["email_verified"]).To(BeTrue())
+
+		// Tokens are single-use
+		status, _ = post("/verify-email", "", map[string]any{"token": token})
+		Expect(status).To(Equal(400))
+	})
+
+	It("should only accept the latest verification token", func() {
+		first := mailedToken(email, "Verify your email address")
+		_, result := login("password123")
+
+		status, _ := post("/protected/verify-email/resend", result["token"].(string), nil)
+		Expect(status).To(Equal(200))
+		second := mailedToken(email, "Verify your email address")
+		Expect(second).NotTo(Equal(first))
+
+		status, _ = post("/verify-email", "", map[string]any{"token": first})
+		Expect(status).To(Equal(400))
+		status, _ = post("/verify-email", "", map[string]any{"token": second})
+		Expect(status).To(Equal(200))
+
+		status, _ = post("/protected/verify-email/resend", result["token"].(string), nil)
+		Expect(status).To(Equal(409))
+	})
+
+	It("should reset the password and log out all sessions", func() {
+		_, result := login("password123")
+		oldToken := result["token"].(string)
+
+		status, _ := post("/password-reset/request", "", map[string]any{"email": email})
+		Expect(status).To(Equal(202))
+		token := mailedToken(email, "Reset your password")
+
+		status, _ = post("/password-reset/confirm", "", map[string]any{"token": token, "new_password": "newpassword456"})
+		Expect(status).To(Equal(200))
+
+		status, _ = login("password123")
+		Expect(status).To(Equal(401))
+		status, result = login("newpassword456")
+		Expect(status).To(Equal(200))
+		// Receiving the email proved the address
+		Expect(result["email_verified"]).To(BeTrue())
+
+		req, _ := http.NewRequest("GET", baseURL+"/protected/", nil)
+		req.Header.Set("Authorization", "Bearer "+oldToken)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(401))
+
+		status, _ = post("/password-reset/confirm", "", map[string]any{"token": token, "new_password": "another789"})
+		Expect(status).To(Equal(400))
+	})
+
+	It("should not reveal whether an email is registered", func() {
+		testMailer.Reset()
+
+		status, result := post("/password-reset/request", "", map[string]any{"email": "nobody@example.com"})
+		Expect(status).To(Equal(202))
+		Expect(result["message"]).To(Equal("If the email is registered, a reset link has been sent"))
+		Expect(testMailer.Messages()).To(BeEmpty())
+	})
+
+	It("should reject expired reset tokens", func() {
+		post("/password-reset/request", "", map[string]any{"email": email})
+		token := mailedToken(email, "Reset your password")
+
+		_, err := testDbInstance.Exec("UPDATE email_tokens SET expires_at = CURRENT_TIMESTAMP - INTERVAL '1 minute'")
+		Expect(err).To(BeNil())
+
+		status, _ := post("/password-reset/confirm", "", map[string]any{"token": token, "new_password": "newpassword456"})
+		Expect(status).To(Equal(400))
+	})
+})
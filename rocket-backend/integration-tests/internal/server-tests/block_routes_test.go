@@ -0,0 +1,323 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Blocking and Muting", func() {
+	var alice, bob, carol string
+
+	call := func(method, path, token string, payload any) (int, map[string]any) {
+		raw, _ := json.Marshal(payload)
+		if payload == nil {
+			raw = nil
+		}
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	// feed returns the names of the authors in the activity feed
+	feed := func(token string) []string {
+		status, result := call("GET", "/protected/activites", token, nil)
+		Expect(status).To(Equal(200))
+		names := []string{}
+		activities, _ := result["activities"].([]any)
+		for _, activity := range activities {
+			names = append(names, activity.(map[string]any)["name"].(string))
+		}
+		return names
+	}
+
+	// history returns the messages in the chat history
+	history := func(token string) []string {
+		status, result := call("GET", "/protected/chat/history", token, nil)
+		Expect(status).To(Equal(200))
+		messages := []string{}
+		list, _ := result["messages"].([]any)
+		for _, msg := range list {
+			messages = append(messages, msg.(map[string]any)["message"].(string))
+		}
+		return messages
+	}
+
+	dial := func(token string) *websocket.Conn {
+		u, err := url.Parse(baseURL + "/protected/ws/chat")
+		Expect(err).To(BeNil())
+		u.Scheme = "ws"
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+token)
+		ws, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+		Expect(err).To(BeNil())
+		return ws
+	}
+
+	BeforeEach(func() {
+		alice = registerAndLogin("alice@example.com", "password123", "alice")
+		bob = registerAndLogin("bob@example.com", "password123", "bob")
+		carol = registerAndLogin("carol@example.com", "password123", "carol")
+
+		for _, token := range []string{alice, carol} {
+			status, _ := call("POST", "/protected/friends/add", token, map[string]any{"friend_name": "bob"})
+			Expect(status).To(Equal(200))
+		}
+		status, _ := call("POST", "/protected/friends/add", bob, map[string]any{"friend_name": "alice"})
+		Expect(status).To(Equal(200))
+
+		_, err := testDbInstance.Exec(`
+			INSERT INTO activities (id, user_id, time, message)
+			SELECT gen_random_uuid(), id, NOW(), username || ' ran' FROM users
+		`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`INSERT INTO chat_messages (user_id, message) SELECT id, 'hi from ' || username FROM users`)
+		Expect(err).To(BeNil())
+	})
+
+	It("should list, replace and remove blocks and mutes", func() {
+		status, _ := call("POST", "/protected/mutes", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+		_, result := call("GET", "/protected/mutes", alice, nil)
+		Expect(result["users"]).To(HaveLen(1))
+
+		// Blocking replaces the mute
+		status, _ = call("POST", "/protected/blocks", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+		_, result = call("GET", "/protected/mutes", alice, nil)
+		Expect(result["users"]).To(BeEmpty())
+		_, result = call("GET", "/protected/blocks", alice, nil)
+		Expect(result["users"]).To(HaveLen(1))
+		Expect(result["users"].([]any)[0]).To(HaveKeyWithValue("username", "bob"))
+
+		status, _ = call("DELETE", "/protected/mutes/bob", alice, nil)
+		Expect(status).To(Equal(404))
+		status, _ = call("DELETE", "/protected/blocks/bob", alice, nil)
+		Expect(status).To(Equal(200))
+		_, result = call("GET", "/protected/blocks", alice, nil)
+		Expect(result["users"]).To(BeEmpty())
+
+		status, result = call("POST", "/protected/blocks", alice, map[string]any{"username": "alice"})
+		Expect(status).To(Equal(400))
+		Expect(result["error"]).To(Equal("You cannot block yourself"))
+	})
+
+	It("should keep blocked users away", func() {
+		status, _ := call("POST", "/protected/blocks", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+
+		// Following ended both ways and cannot be picked up again
+		var follows int
+		Expect(testDbInstance.QueryRow(`SELECT COUNT(*) FROM friends`).Scan(&follows)).To(Succeed())
+		Expect(follows).To(Equal(1))
+		status, result := call("POST", "/protected/friends/add", bob, map[string]any{"friend_name": "alice"})
+		Expect(status).To(Equal(403))
+		Expect(result["error"]).To(Equal("You cannot follow this user"))
+		status, _ = call("POST", "/protected/friends/add", alice, map[string]any{"friend_name": "bob"})
+		Expect(status).To(Equal(403))
+
+		status, _ = call("GET", "/protected/user/alice", bob, nil)
+		Expect(status).To(Equal(404))
+		status, _ = call("GET", "/protected/user/bob", alice, nil)
+		Expect(status).To(Equal(200))
+
+		Expect(feed(alice)).NotTo(ContainElement("bob"))
+		Expect(feed(bob)).NotTo(ContainElement("alice"))
+		Expect(feed(carol)).To(ContainElement("bob"))
+
+		Expect(history(alice)).NotTo(ContainElement("hi from bob"))
+		Expect(history(alice)).To(ContainElement("hi from carol"))
+		Expect(history(carol)).To(ContainElement("hi from bob"))
+	})
+
+	It("should hide the blocker's statistics, image and follows from the blocked user", func() {
+		status, _ := call("POST", "/protected/blocks", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+		var aliceID string
+		Expect(testDbInstance.QueryRow(`SELECT id FROM users WHERE username = 'alice'`).Scan(&aliceID)).To(Succeed())
+
+		for _, token := range []string{bob, carol} {
+			expected := 200
+			if token == bob {
+				expected = 404
+			}
+			status, _ = call("POST", "/protected/user/statistics", token, map[string]any{"id": aliceID})
+			Expect(status).To(Equal(expected))
+			status, _ = call("POST", "/protected/user/image", token, map[string]any{"user_id": aliceID})
+			Expect(status).To(Equal(expected))
+			status, _ = call("GET", "/protected/following/"+aliceID, token, nil)
+			Expect(status).To(Equal(expected))
+			status, _ = call("GET", "/protected/followers/"+aliceID, token, nil)
+			Expect(status).To(Equal(expected))
+		}
+	})
+
+	It("should only hide muted users from feeds", func() {
+		status, _ := call("POST", "/protected/mutes", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+
+		Expect(feed(alice)).NotTo(ContainElement("bob"))
+		Expect(history(alice)).NotTo(ContainElement("hi from bob"))
+
+		// Bob can still see and follow alice
+		Expect(feed(bob)).To(ContainElement("alice"))
+		status, _ = call("GET", "/protected/user/alice", bob, nil)
+		Expect(status).To(Equal(200))
+		status, _ = call("POST", "/protected/friends/add", bob, map[string]any{"friend_name": "alice"})
+		Expect(status).To(Equal(200))
+	})
+
+	It("should end duels between the two and give back the stakes", func() {
+		_, err := testDbInstance.Exec(`UPDATE users SET rocketpoints = 100`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`
+			INSERT INTO duels (challenger_id, opponent_id, type, duration_days, stake, status, expires_at, starts_at, ends_at)
+			SELECT c.id, o.id, 'most_steps', 1, 30, d.status, NOW() + INTERVAL '1 day', NOW(), NOW() + INTERVAL '1 day'
+			FROM (VALUES ('bob', 'alice', 'pending'), ('alice', 'bob', 'active'), ('bob', 'carol', 'active')) AS d (challenger, opponent, status)
+			JOIN users c ON c.username = d.challenger
+			JOIN users o ON o.username = d.opponent
+		`)
+		Expect(err).To(BeNil())
+		// The stakes of the active duels were held back when they were accepted
+		_, err = testDbInstance.Exec(`
+			UPDATE users SET rocketpoints = rocketpoints - CASE username WHEN 'bob' THEN 60 ELSE 30 END
+		`)
+		Expect(err).To(BeNil())
+
+		status, _ := call("POST", "/protected/blocks", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+
+		rows, err := testDbInstance.Query(`
+			SELECT o.username, d.status FROM duels d JOIN users o ON o.id = d.opponent_id ORDER BY o.username, d.status
+		`)
+		Expect(err).To(BeNil())
+		defer rows.Close()
+		statuses := []string{}
+		for rows.Next() {
+			var opponent, duelStatus string
+			Expect(rows.Scan(&opponent, &duelStatus)).To(Succeed())
+			statuses = append(statuses, opponent+" "+duelStatus)
+		}
+		Expect(statuses).To(Equal([]string{"alice declined", "bob cancelled", "carol active"}))
+
+		points := map[string]int{}
+		for _, name := range []string{"alice", "bob", "carol"} {
+			var p int
+			Expect(testDbInstance.QueryRow(`SELECT rocketpoints FROM users WHERE username = $1`, name).Scan(&p)).To(Succeed())
+			points[name] = p
+		}
+		Expect(points).To(Equal(map[string]int{"alice": 100, "bob": 70, "carol": 70}))
+	})
+
+	It("should remove the two from each other's teams", func() {
+		_, err := testDbInstance.Exec(`
+			WITH team AS (
+				INSERT INTO teams (name, owner_id) SELECT 'Bobs', id FROM users WHERE username = 'bob' RETURNING id
+			), challenge AS (
+				INSERT INTO team_challenges (team_id, description, metric, target_value, target_unit, points_reward, ends_on)
+				SELECT id, 'Walk together', 'steps', 20000, 'steps', 20, CURRENT_DATE + 2 FROM team
+				RETURNING id
+			), members AS (
+				INSERT INTO team_members (team_id, user_id) SELECT team.id, u.id FROM team, users u
+			)
+			INSERT INTO team_challenge_members (challenge_id, user_id) SELECT challenge.id, u.id FROM challenge, users u
+		`)
+		Expect(err).To(BeNil())
+
+		status, _ := call("POST", "/protected/blocks", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+
+		members := func(table string) []string {
+			rows, err := testDbInstance.Query(`SELECT u.username FROM ` + table + ` m JOIN users u ON u.id = m.user_id ORDER BY u.username`)
+			Expect(err).To(BeNil())
+			defer rows.Close()
+			names := []string{}
+			for rows.Next() {
+				var name string
+				Expect(rows.Scan(&name)).To(Succeed())
+				names = append(names, name)
+			}
+			return names
+		}
+		Expect(members("team_members")).To(Equal([]string{"bob", "carol"}))
+		Expect(members("team_challenge_members")).To(Equal([]string{"bob", "carol"}))
+	})
+
+	It("should not broadcast chat messages of blocked users to the blocker", func() {
+		status, _ := call("POST", "/protected/blocks", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+
+		aliceWS, carolWS, bobWS := dial(alice), dial(carol), dial(bob)
+		defer aliceWS.Close()
+		defer carolWS.Close()
+		defer bobWS.Close()
+		// Let the hub register all three before anyone writes
+		time.Sleep(200 * time.Millisecond)
+
+		msgBytes, _ := json.Marshal(map[string]string{"message": "can you hear me"})
+		Expect(bobWS.WriteMessage(websocket.TextMessage, msgBytes)).To(Succeed())
+
+		var received types.ChatMessage
+		carolWS.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, raw, err := carolWS.ReadMessage()
+		Expect(err).To(BeNil())
+		Expect(json.Unmarshal(raw, &received)).To(Succeed())
+		Expect(received.Message).To(Equal("can you hear me"))
+
+		aliceWS.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		_, _, err = aliceWS.ReadMessage()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should refuse challenge invitations from blocked users", func() {
+		status, _ := call("POST", "/protected/blocks", alice, map[string]any{"username": "bob"})
+		Expect(status).To(Equal(200))
+
+		// Bob still follows alice, e.g. from before blocks were introduced, and owns a challenge
+		challengeID := uuid.New()
+		_, err := testDbInstance.Exec(`
+			INSERT INTO friends (user_id, friend_id)
+			SELECT b.id, a.id FROM users a, users b WHERE a.username = 'alice' AND b.username = 'bob'
+		`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`INSERT INTO challenges (id, description, points_reward) VALUES ($1, 'Do 20 squats', 20)`, challengeID)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`
+			INSERT INTO user_challenges (user_id, challenge_id, date)
+			SELECT id, $1, CURRENT_DATE FROM users WHERE username = 'bob'
+		`, challengeID)
+		Expect(err).To(BeNil())
+		var aliceID string
+		Expect(testDbInstance.QueryRow(`SELECT id FROM users WHERE username = 'alice'`).Scan(&aliceID)).To(Succeed())
+
+		status, result := call("POST", "/protected/challenges/invite", bob, map[string]any{
+			"challenge_id": challengeID.String(),
+			"friend_id":    aliceID,
+		})
+		Expect(status).To(Equal(403))
+		Expect(result["error"]).To(Equal("You cannot invite this user"))
+
+		status, result = call("POST", "/protected/duels", bob, map[string]any{
+			"opponent_name": "alice",
+			"type":          "most_steps",
+			"duration_days": 1,
+		})
+		Expect(status).To(Equal(403))
+		Expect(result["error"]).To(Equal("You cannot duel this user"))
+	})
+})
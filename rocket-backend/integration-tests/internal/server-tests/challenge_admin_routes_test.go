@@ -0,0 +1,126 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Challenge Catalog Admin API", func() {
+	const apiKey = "test-api-key"
+
+	admin := func(method, path string, payload any) *http.Response {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("X-API-KEY", apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	newChallenge := map[string]any{
+		"text":         "Walk 12000 steps",
+		"points":       35,
+		"category":     "steps",
+		"difficulty":   "hard",
+		"verification": "steps",
+		"parameters":   map[string]any{"target": 12000, "unit": "steps"},
+	}
+
+	create := func() string {
+		resp := admin("POST", "/admin/challenges", newChallenge)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(201))
+		var created map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&created)
+		return created["id"].(string)
+	}
+
+	BeforeEach(func() {
+		os.Setenv("API_KEY", apiKey)
+	})
+
+	It("should reject invalid challenge definitions", func() {
+		invalid := map[string]any{
+			"text": "Run", "points": 10, "category": "running", "difficulty": "easy", "verification": "run_distance",
+		}
+		resp := admin("POST", "/admin/challenges", invalid)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(400))
+	})
+
+	It("should create, edit and retire challenges with an audit trail", func() {
+		id := create()
+
+		edited := map[string]any{}
+		for key, value := range newChallenge {
+			edited[key] = value
+		}
+		edited["points"] = 40
+		resp := admin("PUT", "/admin/challenges/"+id, edited)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		resp = admin("DELETE", "/admin/challenges/"+id, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var points int
+		var active, managed bool
+		err := testDbInstance.QueryRow(`
+			SELECT points_reward, active, managed_by_admin FROM challenges WHERE id = $1
+		`, id).Scan(&points, &active, &managed)
+		Expect(err).To(BeNil())
+		Expect(points).To(Equal(40))
+		Expect(active).To(BeFalse())
+		Expect(managed).To(BeTrue())
+
+		resp = admin("GET", "/admin/challenges/audit", nil)
+		defer resp.Body.Close()
+		var entries []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&entries)
+		actions := []any{}
+		for _, entry := range entries {
+			if entry["challenge_id"] == id {
+				actions = append(actions, entry["action"])
+			}
+		}
+		Expect(actions).To(ConsistOf("create", "update", "retire"))
+	})
+
+	It("should return 404 when editing an unknown challenge", func() {
+		resp := admin("PUT", "/admin/challenges/00000000-0000-0000-0000-000000000000", newChallenge)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+	})
+
+	It("should feature a challenge of the day for users", func() {
+		id := create()
+		today := time.Now().Format("2006-01-02")
+
+		resp := admin("PUT", "/admin/featured/"+today+"/9", map[string]any{"challenge_id": id})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(400))
+
+		resp = admin("PUT", "/admin/featured/"+today+"/1", map[string]any{"challenge_id": id})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		token := registerAndLogin("featured@example.com", "password123", "featured")
+		req, _ := http.NewRequest("GET", baseURL+"/protected/challenges/featured", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp2, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp2.Body.Close()
+		var featured []map[string]any
+		_ = json.NewDecoder(resp2.Body).Decode(&featured)
+		Expect(featured).To(HaveLen(1))
+		Expect(featured[0]["id"]).To(Equal(id))
+	})
+})
@@ -0,0 +1,100 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Challenge Handlers API", func() {
+	var token string
+	var userID string
+	var stepsChallenge, plankChallenge uuid.UUID
+	const username = "challenger"
+
+	complete := func(payload map[string]any) *http.Response {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", baseURL+"/protected/challenges/complete", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	BeforeEach(func() {
+		token = registerAndLogin("challenger@example.com", "password123", username)
+		err := testDbInstance.QueryRow("SELECT id FROM users WHERE username = $1", username).Scan(&userID)
+		Expect(err).To(BeNil())
+
+		stepsChallenge = uuid.New()
+		plankChallenge = uuid.New()
+		_, err = testDbInstance.Exec(`
+			INSERT INTO challenges (id, description, points_reward, category, verification_type, target_value, target_unit)
+			VALUES ($1, 'Walk 5000 steps', 30, 'steps', 'steps', 5000, 'steps'),
+				($2, 'Hold a plank for 1 minute', 20, 'strength', 'self_report', 60, 'seconds')
+		`, stepsChallenge, plankChallenge)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`
+			INSERT INTO user_challenges (user_id, challenge_id, date)
+			VALUES ($1, $2, CURRENT_DATE), ($1, $3, CURRENT_DATE)
+		`, userID, stepsChallenge, plankChallenge)
+		Expect(err).To(BeNil())
+	})
+
+	It("should reject a steps challenge that was not reached", func() {
+		resp := complete(map[string]any{"challenge_id": stepsChallenge, "rocket_points": 9999})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(422))
+	})
+
+	It("should complete a verified steps challenge and award its own points", func() {
+		_, err := testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date)
+			VALUES (gen_random_uuid(), $1, 6000, CURRENT_DATE)
+		`, userID)
+		Expect(err).To(BeNil())
+
+		resp := complete(map[string]any{"challenge_id": stepsChallenge, "rocket_points": 9999})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		Expect(result["rocket_points"]).To(Equal(float64(30)))
+
+		var points int
+		err = testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE id = $1", userID).Scan(&points)
+		Expect(err).To(BeNil())
+		Expect(points).To(Equal(30))
+	})
+
+	It("should reject completing a challenge twice", func() {
+		resp := complete(map[string]any{"challenge_id": plankChallenge, "value": 75})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		resp = complete(map[string]any{"challenge_id": plankChallenge, "value": 75})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(409))
+	})
+
+	It("should require a self-reported value that meets the target", func() {
+		resp := complete(map[string]any{"challenge_id": plankChallenge})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(422))
+
+		resp = complete(map[string]any{"challenge_id": plankChallenge, "value": 30})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(422))
+	})
+
+	It("should reject challenges that are not assigned today", func() {
+		resp := complete(map[string]any{"challenge_id": uuid.New()})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+	})
+})
@@ -0,0 +1,161 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Duel Handlers API", func() {
+	var challengerToken, opponentToken string
+	var challengerID, opponentID string
+
+	do := func(method, path, token string, payload any) *http.Response {
+		var body *bytes.Reader
+		if payload != nil {
+			raw, _ := json.Marshal(payload)
+			body = bytes.NewReader(raw)
+		} else {
+			body = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, baseURL+path, body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	createDuel := func(payload map[string]any) map[string]any {
+		resp := do("POST", "/protected/duels", challengerToken, payload)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(201))
+		var duel map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&duel)
+		return duel
+	}
+
+	points := func(userID string) int {
+		var rocketPoints int
+		err := testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE id = $1", userID).Scan(&rocketPoints)
+		Expect(err).To(BeNil())
+		return rocketPoints
+	}
+
+	BeforeEach(func() {
+		challengerToken = registerAndLogin("duelist@example.com", "password123", "duelist")
+		opponentToken = registerAndLogin("rival@example.com", "password123", "rival")
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'duelist'").Scan(&challengerID)).To(Succeed())
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'rival'").Scan(&opponentID)).To(Succeed())
+
+		resp := do("POST", "/protected/friends/add", challengerToken, map[string]any{"friend_name": "rival"})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+	})
+
+	It("should only allow duels between friends", func() {
+		resp := do("POST", "/protected/duels", opponentToken, map[string]any{
+			"opponent_name": "duelist", "type": "most_steps", "duration_days": 3,
+		})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+	})
+
+	It("should require a target for distance duels", func() {
+		resp := do("POST", "/protected/duels", challengerToken, map[string]any{
+			"opponent_name": "rival", "type": "first_to_distance", "duration_days": 3,
+		})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(400))
+	})
+
+	It("should let the opponent decline and list it in the history", func() {
+		duel := createDuel(map[string]any{"opponent_name": "rival", "type": "most_steps", "duration_days": 3})
+		Expect(duel["status"]).To(Equal("pending"))
+
+		resp := do("POST", "/protected/duels/"+duel["id"].(string)+"/accept", challengerToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+
+		resp = do("POST", "/protected/duels/"+duel["id"].(string)+"/decline", opponentToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		resp = do("GET", "/protected/duels/history", challengerToken, nil)
+		defer resp.Body.Close()
+		var history []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&history)
+		Expect(history).To(HaveLen(1))
+		Expect(history[0]["status"]).To(Equal("declined"))
+	})
+
+	It("should expire duels the opponent did not respond to in time", func() {
+		duel := createDuel(map[string]any{"opponent_name": "rival", "type": "most_steps", "duration_days": 3})
+		Expect(duel["expires_at"]).NotTo(BeEmpty())
+		_, err := testDbInstance.Exec("UPDATE duels SET expires_at = NOW() - INTERVAL '1 minute'")
+		Expect(err).To(BeNil())
+
+		resp := do("POST", "/protected/duels/"+duel["id"].(string)+"/accept", opponentToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(409))
+
+		resp = do("GET", "/protected/duels", opponentToken, nil)
+		var open []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&open)
+		resp.Body.Close()
+		Expect(open).To(BeEmpty())
+
+		resp = do("GET", "/protected/duels/history", challengerToken, nil)
+		defer resp.Body.Close()
+		var history []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&history)
+		Expect(history).To(HaveLen(1))
+		Expect(history[0]["status"]).To(Equal("expired"))
+	})
+
+	It("should reject accepting without enough points for the stake", func() {
+		_, err := testDbInstance.Exec("UPDATE users SET rocketpoints = 100 WHERE id = $1", challengerID)
+		Expect(err).To(BeNil())
+		duel := createDuel(map[string]any{"opponent_name": "rival", "type": "most_steps", "duration_days": 3, "stake": 50})
+
+		resp := do("POST", "/protected/duels/"+duel["id"].(string)+"/accept", opponentToken, nil)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(402))
+		Expect(points(challengerID)).To(Equal(100))
+	})
+
+	It("should settle a distance duel and transfer the stake to the winner", func() {
+		_, err := testDbInstance.Exec("UPDATE users SET rocketpoints = 200 WHERE id IN ($1, $2)", challengerID, opponentID)
+		Expect(err).To(BeNil())
+		duel := createDuel(map[string]any{
+			"opponent_name": "rival", "type": "first_to_distance", "target": 5, "duration_days": 3, "stake": 100,
+		})
+
+		resp := do("POST", "/protected/duels/"+duel["id"].(string)+"/accept", opponentToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		Expect(points(challengerID)).To(Equal(100))
+		Expect(points(opponentID)).To(Equal(100))
+
+		_, err = testDbInstance.Exec(`
+			INSERT INTO runs (user_id, duration, distance, created_at)
+			VALUES ($1, '30:00', 6, now() + interval '1 minute'), ($2, '20:00', 3, now() + interval '1 minute')
+		`, challengerID, opponentID)
+		Expect(err).To(BeNil())
+
+		resp = do("GET", "/protected/duels/"+duel["id"].(string), opponentToken, nil)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		Expect(result["status"]).To(Equal("finished"))
+		Expect(result["winner_id"]).To(Equal(challengerID))
+		Expect(result["opponent_progress"]).To(Equal(float64(3)))
+
+		Expect(points(challengerID)).To(Equal(300))
+		Expect(points(opponentID)).To(Equal(100))
+	})
+})
@@ -0,0 +1,166 @@
+package server_tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Data Export", func() {
+	var token string
+
+	call := func(method, path string, payload any) (int, map[string]any) {
+		var body *bytes.Reader
+		if payload != nil {
+			raw, _ := json.Marshal(payload)
+			body = bytes.NewReader(raw)
+		} else {
+			body = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, baseURL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	// download fetches the link without a login, as a browser would
+	download := func(link string) *http.Response {
+		resp, err := http.Get(strings.TrimSuffix(baseURL, "/api/v1") + link)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	// awaitExport polls until the latest export is no longer pending
+	awaitExport := func() map[string]any {
+		var export map[string]any
+		Eventually(func() any {
+			status, result := call("GET", "/protected/user/export", nil)
+			Expect(status).To(Equal(200))
+			export = result["export"].(map[string]any)
+			return export["status"]
+		}, 10*time.Second, 100*time.Millisecond).ShouldNot(Equal("pending"))
+		return export
+	}
+
+	BeforeEach(func() {
+		token = registerAndLogin("export@example.com", "password123", "exporter")
+	})
+
+	It("should build an archive with the user's data behind a download link", func() {
+		status, _ := call("POST", "/protected/runs", map[string]any{
+			"route":    "LINESTRING(13.4 52.5,13.41 52.51)",
+			"duration": "30",
+			"distance": 5.0,
+		})
+		Expect(status).To(Equal(200))
+
+		status, _ = call("GET", "/protected/user/export", nil)
+		Expect(status).To(Equal(404))
+
+		status, result := call("POST", "/protected/user/export", nil)
+		Expect(status).To(Equal(202))
+		link := result["download_url"].(string)
+		Expect(link).To(HavePrefix("/api/v1/exports/"))
+
+		export := awaitExport()
+		Expect(export["status"]).To(Equal("ready"))
+		Expect(export["expires_at"]).NotTo(BeNil())
+
+		resp := download(link)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/zip"))
+		Expect(resp.Header.Get("Content-Disposition")).To(HavePrefix("attachment"))
+		// The token is as good as a password until the link expires
+		Eventually(func() string { return string(accessLog.Contents()) }).Should(ContainSubstring("/api/v1/exports/[redacted]"))
+		Expect(string(accessLog.Contents())).NotTo(ContainSubstring(link))
+
+		archive, err := io.ReadAll(resp.Body)
+		Expect(err).To(BeNil())
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		Expect(err).To(BeNil())
+		files := map[string]*zip.File{}
+		names := []string{}
+		for _, file := range reader.File {
+			files[file.Name] = file
+			names = append(names, file.Name)
+		}
+		for _, name := range []string{"profile.json", "settings.json", "daily_steps.csv", "runs.geojson",
+			"planned_runs.json", "challenges.json", "friends.json", "activities.json", "chat_messages.json",
+			"chat_reactions.csv"} {
+			Expect(files).To(HaveKey(name))
+		}
+		Expect(names).To(ContainElement(And(HavePrefix("runs/"), HaveSuffix(".gpx"))))
+
+		rc, err := files["profile.json"].Open()
+		Expect(err).To(BeNil())
+		var profile []map[string]any
+		Expect(json.NewDecoder(rc).Decode(&profile)).To(Succeed())
+		rc.Close()
+		Expect(profile).To(HaveLen(1))
+		Expect(profile[0]).To(HaveKeyWithValue("username", "exporter"))
+		Expect(profile[0]).NotTo(HaveKey("password"))
+	})
+
+	It("should refuse unknown and expired links", func() {
+		resp := download("/api/v1/exports/not-a-token")
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+
+		_, result := call("POST", "/protected/user/export", nil)
+		link := result["download_url"].(string)
+		awaitExport()
+		_, err := testDbInstance.Exec(`UPDATE data_exports SET expires_at = NOW() - INTERVAL '1 minute'`)
+		Expect(err).To(BeNil())
+
+		resp = download(link)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+	})
+
+	It("should replace the previous archive with a new export", func() {
+		_, result := call("POST", "/protected/user/export", nil)
+		first := result["download_url"].(string)
+		awaitExport()
+
+		status, result := call("POST", "/protected/user/export", nil)
+		Expect(status).To(Equal(202))
+		awaitExport()
+
+		resp := download(first)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+		resp = download(result["download_url"].(string))
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var count int
+		Expect(testDbInstance.QueryRow("SELECT COUNT(*) FROM data_exports").Scan(&count)).To(Succeed())
+		Expect(count).To(Equal(1))
+	})
+
+	It("should only prepare one export at a time", func() {
+		// A build that never finished, e.g. because its replica stopped
+		_, err := testDbInstance.Exec(`
+			INSERT INTO data_exports (id, user_id, status, token_hash, started_at, attempts)
+			SELECT gen_random_uuid(), id, 'pending', 'stuck', NOW(), 1 FROM credentials
+		`)
+		Expect(err).To(BeNil())
+
+		status, result := call("POST", "/protected/user/export", nil)
+		Expect(status).To(Equal(409))
+		Expect(result["error"]).To(Equal("An export is already being prepared"))
+	})
+})
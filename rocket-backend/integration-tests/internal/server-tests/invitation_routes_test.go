@@ -0,0 +1,133 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Challenge Invitation Handlers API", func() {
+	var inviterToken, inviteeToken string
+	var inviterID, inviteeID string
+	var sharedChallenge, otherChallenge uuid.UUID
+
+	do := func(method, path, token string, payload any) *http.Response {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	invite := func(challengeID uuid.UUID) *http.Response {
+		return do("POST", "/protected/challenges/invite", inviterToken, map[string]any{
+			"challenge_id": challengeID.String(), "friend_id": inviteeID,
+		})
+	}
+
+	BeforeEach(func() {
+		inviterToken = registerAndLogin("inviter@example.com", "password123", "inviter")
+		inviteeToken = registerAndLogin("invitee@example.com", "password123", "invitee")
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'inviter'").Scan(&inviterID)).To(Succeed())
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'invitee'").Scan(&inviteeID)).To(Succeed())
+
+		sharedChallenge = uuid.New()
+		otherChallenge = uuid.New()
+		_, err := testDbInstance.Exec(`
+			INSERT INTO challenges (id, description, points_reward)
+			VALUES ($1, 'Do 20 squats', 20), ($2, 'Drink 2 liters of water', 20)
+		`, sharedChallenge, otherChallenge)
+		Expect(err).To(BeNil())
+		// The inviter owns the shared challenge, the invitee already got today's dailies
+		_, err = testDbInstance.Exec(`
+			INSERT INTO user_challenges (user_id, challenge_id, date)
+			VALUES ($1, $3, CURRENT_DATE), ($2, $4, CURRENT_DATE)
+		`, inviterID, inviteeID, sharedChallenge, otherChallenge)
+		Expect(err).To(BeNil())
+	})
+
+	It("should reject invitations to users who are not friends", func() {
+		resp := invite(sharedChallenge)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+	})
+
+	Context("between friends", func() {
+		BeforeEach(func() {
+			resp := do("POST", "/protected/friends/add", inviterToken, map[string]any{"friend_name": "invitee"})
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(200))
+		})
+
+		It("should only allow inviting to own challenges", func() {
+			resp := invite(otherChallenge)
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(404))
+		})
+
+		It("should not assign the challenge before the invitee accepts", func() {
+			resp := invite(sharedChallenge)
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(201))
+
+			var count int
+			err := testDbInstance.QueryRow(`
+				SELECT COUNT(*) FROM user_challenges WHERE user_id = $1 AND challenge_id = $2
+			`, inviteeID, sharedChallenge).Scan(&count)
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(0))
+
+			resp = invite(sharedChallenge)
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(409))
+		})
+
+		It("should list and accept incoming invitations", func() {
+			resp := invite(sharedChallenge)
+			resp.Body.Close()
+
+			resp = do("GET", "/protected/challenges/invitations/incoming", inviteeToken, nil)
+			var incoming []map[string]any
+			_ = json.NewDecoder(resp.Body).Decode(&incoming)
+			resp.Body.Close()
+			Expect(incoming).To(HaveLen(1))
+			Expect(incoming[0]["status"]).To(Equal("pending"))
+			Expect(incoming[0]["inviter_name"]).To(Equal("inviter"))
+
+			resp = do("POST", "/protected/challenges/invitations/"+incoming[0]["id"].(string)+"/accept", inviteeToken, nil)
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(200))
+
+			var count int
+			err := testDbInstance.QueryRow(`
+				SELECT COUNT(*) FROM user_challenges WHERE user_id = $1 AND date = CURRENT_DATE
+			`, inviteeID).Scan(&count)
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(2))
+
+			resp = do("POST", "/protected/challenges/invitations/"+incoming[0]["id"].(string)+"/decline", inviteeToken, nil)
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(409))
+		})
+
+		It("should expire invitations from past days", func() {
+			resp := invite(sharedChallenge)
+			resp.Body.Close()
+			_, err := testDbInstance.Exec(`UPDATE challenge_invitations SET date = CURRENT_DATE - 1`)
+			Expect(err).To(BeNil())
+
+			resp = do("GET", "/protected/challenges/invitations/outgoing", inviterToken, nil)
+			defer resp.Body.Close()
+			var outgoing []map[string]any
+			_ = json.NewDecoder(resp.Body).Decode(&outgoing)
+			Expect(outgoing).To(HaveLen(1))
+			Expect(outgoing[0]["status"]).To(Equal("expired"))
+		})
+	})
+})
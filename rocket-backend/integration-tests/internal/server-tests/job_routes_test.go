@@ -0,0 +1,106 @@
+package server_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Job Admin API", func() {
+	const apiKey = "test-api-key"
+
+	admin := func(method, path string) *http.Response {
+		req, _ := http.NewRequest(method, baseURL+path, nil)
+		req.Header.Set("X-API-KEY", apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	decode := func(resp *http.Response, target any) {
+		defer resp.Body.Close()
+		_ = json.NewDecoder(resp.Body).Decode(target)
+	}
+
+	BeforeEach(func() {
+		os.Setenv("API_KEY", apiKey)
+	})
+
+	It("should list the maintenance jobs as healthy before their first run", func() {
+		resp := admin("GET", "/admin/jobs")
+		Expect(resp.StatusCode).To(Equal(200))
+		var health struct {
+			Status string           `json:"status"`
+			Jobs   []map[string]any `json:"jobs"`
+		}
+		decode(resp, &health)
+		Expect(health.Status).To(Equal("up"))
+		names := []any{}
+		for _, job := range health.Jobs {
+			names = append(names, job["name"])
+			Expect(job["status"]).To(Equal("ok"))
+		}
+		Expect(names).To(ConsistOf("daily_rollover", "streak_evaluation", "cleanup", "data_exports", "account_erasure"))
+	})
+
+	It("should run a job on demand and record it in the history", func() {
+		_, err := testDbInstance.Exec(`
+			INSERT INTO job_runs (job_name, scheduled_for, instance, status, started_at)
+			VALUES ('cleanup', NOW() - INTERVAL '40 days', 'old', 'succeeded', NOW() - INTERVAL '40 days')
+		`)
+		Expect(err).To(BeNil())
+
+		resp := admin("POST", "/admin/jobs/cleanup/run")
+		Expect(resp.StatusCode).To(Equal(200))
+		var run map[string]any
+		decode(resp, &run)
+		Expect(run["status"]).To(Equal("succeeded"))
+
+		resp = admin("GET", "/admin/jobs/cleanup/runs")
+		Expect(resp.StatusCode).To(Equal(200))
+		var runs []map[string]any
+		decode(resp, &runs)
+		Expect(runs).To(HaveLen(1))
+		Expect(runs[0]["id"]).To(Equal(run["id"]))
+	})
+
+	It("should evaluate the streaks of all users", func() {
+		registerAndLogin("streaker@example.com", "password123", "streaker")
+
+		resp := admin("POST", "/admin/jobs/streak_evaluation/run")
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var count int
+		Expect(testDbInstance.QueryRow("SELECT COUNT(*) FROM streaks").Scan(&count)).To(Succeed())
+		Expect(count).To(Equal(1))
+	})
+
+	It("should expire pending duels during the cleanup", func() {
+		registerAndLogin("duelist@example.com", "password123", "duelist")
+		registerAndLogin("rival@example.com", "password123", "rival")
+		_, err := testDbInstance.Exec(`
+			INSERT INTO duels (challenger_id, opponent_id, type, duration_days, expires_at)
+			SELECT c.id, o.id, 'most_steps', 3, NOW() - INTERVAL '1 minute'
+			FROM users c, users o WHERE c.username = 'duelist' AND o.username = 'rival'
+		`)
+		Expect(err).To(BeNil())
+
+		resp := admin("POST", "/admin/jobs/cleanup/run")
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var status string
+		Expect(testDbInstance.QueryRow("SELECT status FROM duels").Scan(&status)).To(Succeed())
+		Expect(status).To(Equal("expired"))
+	})
+
+	It("should return 404 for unknown jobs", func() {
+		resp := admin("POST", "/admin/jobs/unknown/run")
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+	})
+})
@@ -5,12 +5,17 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"rocket-backend/integration-tests/mocks/oidcmock"
 	"rocket-backend/internal/database"
 	"rocket-backend/internal/server"
+	"rocket-backend/internal/validation"
 	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/mailer"
+	"rocket-backend/pkg/oidc"
 	"runtime"
 	"testing"
 	"time"
@@ -21,6 +26,7 @@ import (
 	_ "github.com/jackc/pgx/v5/stdlib"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
@@ -46,7 +52,11 @@ type TestDatabase struct {
 }
 
 var testDB *TestDatabase
+var apiServer *server.Server
 var testServer *http.Server
+var testMailer *mailer.MemoryMailer
+var testOIDC *oidcmock.Provider
+var accessLog *gbytes.Buffer
 var baseURL string
 var port = 8090
 
@@ -57,9 +67,26 @@ var _ = BeforeSuite(func() {
 	// Start API server for all tests in this package
 	connStr := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", DbUser, DbPass, testDB.DbAddress, DbName)
 	dbService := database.NewWithConfig(connStr)
+	apiServer = server.NewServerWithDB(dbService, port, "testsecret")
+	// Specs log in far more often than the policies allow, the rate limit specs turn it on
+	apiServer.SetRateLimiter(nil)
+	// Not the shipped breached password list, it contains the password123 specs log in with
+	apiServer.SetPasswordPolicy(validation.NewPasswordPolicy(8, []string{"letmein123"}))
+	testMailer = mailer.NewMemoryMailer()
+	apiServer.SetMailer(testMailer)
+	testOIDC = oidcmock.New("rocket-test", "rocket-test-secret")
+	apiServer.AddOIDCProvider(oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		Issuer:       testOIDC.Issuer(),
+		ClientID:     testOIDC.ClientID,
+		ClientSecret: testOIDC.ClientSecret,
+		RedirectURL:  fmt.Sprintf("http://localhost:%d/api/v1/oidc/mock/callback", port),
+	}))
+	accessLog = gbytes.NewBuffer()
+	apiServer.SetAccessLog(io.MultiWriter(os.Stdout, accessLog))
 	testServer = &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
-		Handler: server.NewServerWithDB(dbService, port, "testsecret").RegisterRoutes(),
+		Handler: apiServer.RegisterRoutes(),
 	}
 	go testServer.ListenAndServe()
 
@@ -85,12 +112,17 @@ var _ = AfterSuite(func() {
 	if testServer != nil {
 		testServer.Close()
 	}
+	if testOIDC != nil {
+		testOIDC.Close()
+	}
 	testDB.TearDown()
 })
 
 var _ = AfterEach(func() {
 	err := truncateTables(testDbInstance)
 	Expect(err).To(BeNil())
+	testMailer.Reset()
+	testOIDC.SetUser(nil)
 })
 
 func SetupTestDatabase() *TestDatabase {
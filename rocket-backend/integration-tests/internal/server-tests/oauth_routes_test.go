@@ -0,0 +1,292 @@
+package server_tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OAuth Authorization Server", func() {
+	const (
+		apiKey      = "test-api-key"
+		redirectURI = "https://kiosk.example.com/callback"
+		verifier    = "a-code-verifier-that-is-long-enough-for-pkce-s256"
+	)
+	var (
+		token    string
+		clientID string
+	)
+
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	call := func(method, path string, headers map[string]string, payload any) (int, map[string]any) {
+		var body *bytes.Reader
+		if payload != nil {
+			raw, _ := json.Marshal(payload)
+			body = bytes.NewReader(raw)
+		} else {
+			body = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, baseURL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	bearer := func(value string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + value}
+	}
+
+	form := func(path string, values url.Values) (int, map[string]any) {
+		resp, err := http.PostForm(baseURL+path, values)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	authorizeRequest := func(scope string) map[string]any {
+		return map[string]any{
+			"response_type":         "code",
+			"client_id":             clientID,
+			"redirect_uri":          redirectURI,
+			"scope":                 scope,
+			"state":                 "xyz",
+			"code_challenge":        challenge,
+			"code_challenge_method": "S256",
+		}
+	}
+
+	// authorize approves a request and returns the code from the redirect
+	authorize := func(scope string) string {
+		request := authorizeRequest(scope)
+		request["approve"] = true
+		status, result := call("POST", "/protected/oauth/authorize", bearer(token), request)
+		Expect(status).To(Equal(200))
+		target, err := url.Parse(result["redirect_to"].(string))
+		Expect(err).To(BeNil())
+		Expect(target.Query().Get("state")).To(Equal("xyz"))
+		return target.Query().Get("code")
+	}
+
+	exchange := func(code string) (int, map[string]any) {
+		return form("/oauth/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {clientID},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		})
+	}
+
+	BeforeEach(func() {
+		os.Setenv("API_KEY", apiKey)
+		token = registerAndLogin("oauth@example.com", "password123", "oauthuser")
+
+		status, result := call("POST", "/admin/oauth/clients", map[string]string{"X-API-KEY": apiKey}, map[string]any{
+			"name":          "Gym Kiosk",
+			"redirect_uris": []string{redirectURI},
+			"scopes":        []string{"runs:read", "steps:read"},
+		})
+		Expect(status).To(Equal(201))
+		Expect(result).NotTo(HaveKey("client_secret"))
+		clientID = result["client"].(map[string]any)["client_id"].(string)
+	})
+
+	It("should issue scoped tokens for an approved code", func() {
+		status, result := call("GET", "/protected/oauth/authorize?"+url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {redirectURI},
+			"scope":                 {"runs:read"},
+			"code_challenge":        {challenge},
+			"code_challenge_method": {"S256"},
+		}.Encode(), bearer(token), nil)
+		Expect(status).To(Equal(200))
+		Expect(result["client"]).To(HaveKeyWithValue("name", "Gym Kiosk"))
+		Expect(result["consent_required"]).To(BeTrue())
+
+		code := authorize("runs:read")
+		status, result = exchange(code)
+		Expect(status).To(Equal(200))
+		Expect(result["token_type"]).To(Equal("Bearer"))
+		Expect(result["scope"]).To(Equal("runs:read"))
+		access := result["access_token"].(string)
+		Expect(access).To(HavePrefix("rko_"))
+
+		status, _ = call("GET", "/protected/runs", bearer(access), nil)
+		Expect(status).To(Equal(200))
+		status, result = call("POST", "/protected/user/statistics", bearer(access), map[string]any{})
+		Expect(status).To(Equal(403))
+		Expect(result["error"]).To(Equal("Token lacks the steps:read scope"))
+		// Apps cannot approve more access for themselves
+		status, _ = call("GET", "/protected/oauth/apps", bearer(access), nil)
+		Expect(status).To(Equal(403))
+
+		// Codes work once
+		status, result = exchange(code)
+		Expect(status).To(Equal(400))
+		Expect(result["error"]).To(Equal("invalid_grant"))
+
+		// The consent is remembered
+		status, result = call("GET", "/protected/oauth/authorize?"+url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"scope":                 {"runs:read"},
+			"code_challenge":        {challenge},
+			"code_challenge_method": {"S256"},
+		}.Encode(), bearer(token), nil)
+		Expect(status).To(Equal(200))
+		Expect(result["consent_required"]).To(BeFalse())
+	})
+
+	It("should require the matching code verifier", func() {
+		code := authorize("runs:read")
+		status, result := form("/oauth/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {clientID},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {strings.Repeat("x", 50)},
+		})
+		Expect(status).To(Equal(400))
+		Expect(result["error"]).To(Equal("invalid_grant"))
+	})
+
+	It("should refuse invalid authorization requests", func() {
+		request := authorizeRequest("runs:read")
+		request["redirect_uri"] = "https://evil.example.com/callback"
+		status, result := call("POST", "/protected/oauth/authorize", bearer(token), request)
+		Expect(status).To(Equal(400))
+		Expect(result).NotTo(HaveKey("redirect_to"))
+
+		request = authorizeRequest("runs:write")
+		status, result = call("POST", "/protected/oauth/authorize", bearer(token), request)
+		Expect(status).To(Equal(400))
+		Expect(result["redirect_to"]).To(ContainSubstring("error=invalid_scope"))
+
+		request = authorizeRequest("runs:read")
+		delete(request, "code_challenge")
+		status, result = call("POST", "/protected/oauth/authorize", bearer(token), request)
+		Expect(status).To(Equal(400))
+		Expect(result["redirect_to"]).To(ContainSubstring("error=invalid_request"))
+
+		request = authorizeRequest("runs:read")
+		status, result = call("POST", "/protected/oauth/authorize", bearer(token), request)
+		Expect(status).To(Equal(200))
+		Expect(result["redirect_to"]).To(ContainSubstring("error=access_denied"))
+	})
+
+	It("should rotate refresh tokens", func() {
+		_, result := exchange(authorize("runs:read"))
+		access, refresh := result["access_token"].(string), result["refresh_token"].(string)
+
+		status, result := form("/oauth/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {clientID},
+			"refresh_token": {refresh},
+		})
+		Expect(status).To(Equal(200))
+		Expect(result["refresh_token"]).NotTo(Equal(refresh))
+
+		status, _ = call("GET", "/protected/runs", bearer(access), nil)
+		Expect(status).To(Equal(401))
+		status, _ = call("GET", "/protected/runs", bearer(result["access_token"].(string)), nil)
+		Expect(status).To(Equal(200))
+
+		status, result = form("/oauth/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {clientID},
+			"refresh_token": {refresh},
+		})
+		Expect(status).To(Equal(400))
+		Expect(result["error"]).To(Equal("invalid_grant"))
+	})
+
+	It("should stop working once the user revokes the app", func() {
+		_, result := exchange(authorize("runs:read steps:read"))
+		access := result["access_token"].(string)
+
+		status, result := call("GET", "/protected/oauth/apps", bearer(token), nil)
+		Expect(status).To(Equal(200))
+		apps := result["apps"].([]any)
+		Expect(apps).To(HaveLen(1))
+		Expect(apps[0]).To(HaveKeyWithValue("client_name", "Gym Kiosk"))
+
+		status, _ = call("DELETE", "/protected/oauth/apps/"+clientID, bearer(token), nil)
+		Expect(status).To(Equal(200))
+		status, _ = call("GET", "/protected/runs", bearer(access), nil)
+		Expect(status).To(Equal(401))
+		status, _ = call("DELETE", "/protected/oauth/apps/"+clientID, bearer(token), nil)
+		Expect(status).To(Equal(404))
+	})
+
+	It("should authenticate confidential clients and let them revoke tokens", func() {
+		status, result := call("POST", "/admin/oauth/clients", map[string]string{"X-API-KEY": apiKey}, map[string]any{
+			"name":          "Watch Companion",
+			"redirect_uris": []string{redirectURI},
+			"scopes":        []string{"runs:read"},
+			"confidential":  true,
+		})
+		Expect(status).To(Equal(201))
+		clientID = result["client"].(map[string]any)["client_id"].(string)
+		secret := result["client_secret"].(string)
+
+		code := authorize("runs:read")
+		status, result = exchange(code)
+		Expect(status).To(Equal(401))
+		Expect(result["error"]).To(Equal("invalid_client"))
+
+		// The failed attempt did not use up the code
+		status, result = form("/oauth/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		})
+		Expect(status).To(Equal(200))
+		access := result["access_token"].(string)
+
+		status, _ = form("/oauth/revoke", url.Values{"client_id": {clientID}, "client_secret": {secret}, "token": {access}})
+		Expect(status).To(Equal(200))
+		status, _ = call("GET", "/protected/runs", bearer(access), nil)
+		Expect(status).To(Equal(401))
+	})
+
+	It("should only register valid clients", func() {
+		status, result := call("POST", "/admin/oauth/clients", map[string]string{"X-API-KEY": apiKey}, map[string]any{
+			"name":          "Sketchy",
+			"redirect_uris": []string{"http://example.com/callback"},
+			"scopes":        []string{"admin:all"},
+		})
+		Expect(status).To(Equal(400))
+		Expect(result["fields"]).To(HaveKey("redirect_uris"))
+		Expect(result["fields"]).To(HaveKey("scopes"))
+
+		status, _ = call("POST", "/admin/oauth/clients", bearer(token), map[string]any{
+			"name":          "Sketchy",
+			"redirect_uris": []string{redirectURI},
+			"scopes":        []string{"runs:read"},
+		})
+		Expect(status).To(Equal(403))
+	})
+})
@@ -0,0 +1,150 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"rocket-backend/integration-tests/mocks/oidcmock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OIDC Login", func() {
+	// loginWithProvider runs the whole redirect dance against the mock provider
+	loginWithProvider := func(user *oidcmock.User) (int, map[string]any) {
+		testOIDC.SetUser(user)
+		resp, err := http.Get(baseURL + "/oidc/mock/login?device_name=Browser")
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	countRows := func(query string, args ...any) int {
+		var count int
+		Expect(testDbInstance.QueryRow(query, args...).Scan(&count)).To(Succeed())
+		return count
+	}
+
+	It("should list the configured providers", func() {
+		resp, err := http.Get(baseURL + "/oidc/providers")
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		Expect(json.NewDecoder(resp.Body).Decode(&result)).To(Succeed())
+		Expect(result["providers"]).To(ConsistOf("mock"))
+	})
+
+	It("should create an account with profile and settings on the first login", func() {
+		user := &oidcmock.User{Subject: "sub-1", Email: "new@example.com", EmailVerified: true, Name: "New User"}
+		status, result := loginWithProvider(user)
+		Expect(status).To(Equal(200))
+		Expect(result["token"]).NotTo(BeEmpty())
+		Expect(result["email_verified"]).To(BeTrue())
+
+		Expect(countRows("SELECT COUNT(*) FROM users WHERE email = $1 AND username = 'newuser'", user.Email)).To(Equal(1))
+		Expect(countRows("SELECT COUNT(*) FROM settings s JOIN users u ON u.id = s.user_id WHERE u.email = $1", user.Email)).To(Equal(1))
+		Expect(countRows("SELECT COUNT(*) FROM sessions WHERE device_name = 'Browser'")).To(Equal(1))
+
+		// The second login finds the same account
+		status, _ = loginWithProvider(user)
+		Expect(status).To(Equal(200))
+		Expect(countRows("SELECT COUNT(*) FROM credentials WHERE email = $1", user.Email)).To(Equal(1))
+	})
+
+	It("should link an existing account by its verified email", func() {
+		registerAndLogin("linked@example.com", "password123", "linked")
+		_, err := testDbInstance.Exec("UPDATE credentials SET email_verified = TRUE WHERE email = 'linked@example.com'")
+		Expect(err).To(BeNil())
+
+		status, _ := loginWithProvider(&oidcmock.User{Subject: "sub-2", Email: "linked@example.com", EmailVerified: true, Name: "Linked"})
+		Expect(status).To(Equal(200))
+		Expect(countRows("SELECT COUNT(*) FROM users")).To(Equal(1))
+		Expect(countRows("SELECT COUNT(*) FROM oidc_identities WHERE subject = 'sub-2'")).To(Equal(1))
+
+		// The password keeps working
+		raw, _ := json.Marshal(map[string]any{"email": "linked@example.com", "password": "password123"})
+		resp, err := http.Post(baseURL+"/login", "application/json", bytes.NewReader(raw))
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+	})
+
+	It("should take over an unverified account with the same email", func() {
+		squatter := registerAndLogin("victim@example.com", "squatter123", "squatter")
+
+		status, _ := loginWithProvider(&oidcmock.User{Subject: "sub-3", Email: "victim@example.com", EmailVerified: true, Name: "Victim"})
+		Expect(status).To(Equal(200))
+
+		req, _ := http.NewRequest("GET", baseURL+"/protected/", nil)
+		req.Header.Set("Authorization", "Bearer "+squatter)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(401))
+
+		raw, _ := json.Marshal(map[string]any{"email": "victim@example.com", "password": "squatter123"})
+		resp, err = http.Post(baseURL+"/login", "application/json", bytes.NewReader(raw))
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(401))
+	})
+
+	It("should match the provider's email regardless of case", func() {
+		status, result := loginWithProvider(&oidcmock.User{Subject: "sub-7", Email: "Mixed.Case@Example.com", EmailVerified: true, Name: "Mixed"})
+		Expect(status).To(Equal(200))
+		Expect(result["email_verified"]).To(BeTrue())
+		Expect(countRows("SELECT COUNT(*) FROM credentials WHERE email = 'mixed.case@example.com' AND email_verified")).To(Equal(1))
+
+		registerAndLogin("squatted@example.com", "squatter123", "squatter")
+		status, _ = loginWithProvider(&oidcmock.User{Subject: "sub-8", Email: "Squatted@Example.COM", EmailVerified: true, Name: "Owner"})
+		Expect(status).To(Equal(200))
+		Expect(countRows("SELECT COUNT(*) FROM credentials WHERE email = 'squatted@example.com' AND email_verified AND password = ''")).To(Equal(1))
+
+		raw, _ := json.Marshal(map[string]any{"email": "squatted@example.com", "password": "squatter123"})
+		resp, err := http.Post(baseURL+"/login", "application/json", bytes.NewReader(raw))
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(401))
+	})
+
+	It("should refuse identities without a verified email", func() {
+		status, _ := loginWithProvider(&oidcmock.User{Subject: "sub-4", Email: "unverified@example.com", Name: "Unverified"})
+		Expect(status).To(Equal(400))
+		Expect(countRows("SELECT COUNT(*) FROM credentials")).To(Equal(0))
+	})
+
+	It("should report a denied login", func() {
+		status, _ := loginWithProvider(nil)
+		Expect(status).To(Equal(401))
+	})
+
+	It("should refuse unknown states", func() {
+		status, _ := loginWithProvider(&oidcmock.User{Subject: "sub-5", Email: "replay@example.com", EmailVerified: true})
+		Expect(status).To(Equal(200))
+
+		resp, err := http.Get(baseURL + "/oidc/mock/callback?code=whatever&state=unknown")
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(400))
+	})
+
+	It("should ask for the second factor when enabled", func() {
+		user := &oidcmock.User{Subject: "sub-6", Email: "guarded@example.com", EmailVerified: true, Name: "Guarded"}
+		status, _ := loginWithProvider(user)
+		Expect(status).To(Equal(200))
+		_, err := testDbInstance.Exec(`
+			INSERT INTO two_factor (user_id, secret, enabled_at)
+			SELECT id, 'GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ', CURRENT_TIMESTAMP FROM credentials WHERE email = $1
+		`, user.Email)
+		Expect(err).To(BeNil())
+
+		status, result := loginWithProvider(user)
+		Expect(status).To(Equal(200))
+		Expect(result["two_factor_required"]).To(BeTrue())
+		Expect(result).NotTo(HaveKey("token"))
+	})
+})
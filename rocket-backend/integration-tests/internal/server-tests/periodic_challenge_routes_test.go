@@ -0,0 +1,103 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Periodic Challenge Handlers API", func() {
+	var token string
+	var userID string
+	var weeklySteps, monthlyRun uuid.UUID
+	const username = "marathoner"
+
+	getPeriodic := func() []map[string]any {
+		req, _ := http.NewRequest("GET", baseURL+"/protected/challenges/periodic", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		var result []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return result
+	}
+
+	find := func(entries []map[string]any, id uuid.UUID) map[string]any {
+		for _, entry := range entries {
+			if entry["id"] == id.String() {
+				return entry
+			}
+		}
+		Fail("periodic challenge " + id.String() + " not found")
+		return nil
+	}
+
+	BeforeEach(func() {
+		token = registerAndLogin("marathoner@example.com", "password123", username)
+		err := testDbInstance.QueryRow("SELECT id FROM users WHERE username = $1", username).Scan(&userID)
+		Expect(err).To(BeNil())
+
+		weeklySteps = uuid.New()
+		monthlyRun = uuid.New()
+		_, err = testDbInstance.Exec(`
+			INSERT INTO periodic_challenges (id, description, period, metric, target_value, target_unit, points_reward)
+			VALUES ($1, 'Walk 5000 steps this week', 'weekly', 'steps', 5000, 'steps', 50),
+				($2, 'Run 1000 km this month', 'monthly', 'run_distance', 1000, 'km', 500)
+		`, weeklySteps, monthlyRun)
+		Expect(err).To(BeNil())
+	})
+
+	It("should list the current periods without enrolling the user", func() {
+		entries := getPeriodic()
+		Expect(find(entries, weeklySteps)["period"]).To(Equal("weekly"))
+		Expect(find(entries, weeklySteps)["progress"]).To(Equal(float64(0)))
+		Expect(find(entries, monthlyRun)["completed"]).To(BeFalse())
+
+		var enrolled int
+		err := testDbInstance.QueryRow("SELECT COUNT(*) FROM user_periodic_challenges").Scan(&enrolled)
+		Expect(err).To(BeNil())
+		Expect(enrolled).To(Equal(0))
+	})
+
+	It("should not award points for steps recorded outside an upload", func() {
+		_, err := testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date) VALUES (gen_random_uuid(), $1, 6000, CURRENT_DATE)
+		`, userID)
+		Expect(err).To(BeNil())
+
+		entries := getPeriodic()
+		Expect(find(entries, weeklySteps)["completed"]).To(BeFalse())
+		var points int
+		err = testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE id = $1", userID).Scan(&points)
+		Expect(err).To(BeNil())
+		Expect(points).To(Equal(0))
+	})
+
+	It("should track progress from steps and award the reward once", func() {
+		body, _ := json.Marshal(map[string]any{"steps": 6000})
+		req, _ := http.NewRequest("POST", baseURL+"/protected/updateSteps", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		entries := getPeriodic()
+		Expect(find(entries, weeklySteps)["completed"]).To(BeTrue())
+		Expect(find(entries, weeklySteps)["progress"]).To(Equal(float64(6000)))
+
+		// Asking again must not hand out the points a second time
+		getPeriodic()
+		var points int
+		err = testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE id = $1", userID).Scan(&points)
+		Expect(err).To(BeNil())
+		Expect(points).To(Equal(50))
+	})
+})
@@ -0,0 +1,118 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Personal Access Tokens", func() {
+	var token string
+
+	call := func(method, path, bearer string, payload any) (int, map[string]any) {
+		var body *bytes.Reader
+		if payload != nil {
+			raw, _ := json.Marshal(payload)
+			body = bytes.NewReader(raw)
+		} else {
+			body = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, baseURL+path, body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	create := func(scopes ...string) (string, string) {
+		status, result := call("POST", "/protected/tokens", token, map[string]any{"name": "home automation", "scopes": scopes})
+		Expect(status).To(Equal(201))
+		details := result["details"].(map[string]any)
+		return result["token"].(string), details["id"].(string)
+	}
+
+	BeforeEach(func() {
+		token = registerAndLogin("pat@example.com", "password123", "patuser")
+	})
+
+	It("should only reveal the token once and store its hash", func() {
+		secret, id := create("steps:write")
+		Expect(secret).To(HavePrefix("rkt_"))
+
+		status, result := call("GET", "/protected/tokens", token, nil)
+		Expect(status).To(Equal(200))
+		tokens := result["tokens"].([]any)
+		Expect(tokens).To(HaveLen(1))
+		listed := tokens[0].(map[string]any)
+		Expect(listed["id"]).To(Equal(id))
+		Expect(listed["scopes"]).To(ConsistOf("steps:write"))
+		Expect(secret).To(HavePrefix(listed["prefix"].(string)))
+		Expect(listed).NotTo(HaveKey("token"))
+
+		var stored string
+		Expect(testDbInstance.QueryRow("SELECT token_hash FROM personal_access_tokens").Scan(&stored)).To(Succeed())
+		Expect(stored).NotTo(ContainSubstring(secret))
+	})
+
+	It("should authorize routes by scope", func() {
+		secret, _ := create("steps:write")
+
+		status, _ := call("POST", "/protected/updateSteps", secret, map[string]any{"steps": 1234})
+		Expect(status).To(Equal(200))
+
+		var lastUsed *string
+		Expect(testDbInstance.QueryRow("SELECT last_used_at::text FROM personal_access_tokens").Scan(&lastUsed)).To(Succeed())
+		Expect(lastUsed).NotTo(BeNil())
+
+		// A scope the token lacks
+		status, result := call("GET", "/protected/runs", secret, nil)
+		Expect(status).To(Equal(403))
+		Expect(result["error"]).To(Equal("Token lacks the runs:read scope"))
+
+		// Routes without a scope stay with logins, so tokens cannot mint more tokens
+		status, _ = call("POST", "/protected/tokens", secret, map[string]any{"name": "more", "scopes": []string{"runs:read"}})
+		Expect(status).To(Equal(403))
+		status, _ = call("GET", "/admin/users", secret, nil)
+		Expect(status).To(Equal(403))
+	})
+
+	It("should refuse unknown scopes", func() {
+		status, result := call("POST", "/protected/tokens", token, map[string]any{"name": "bad", "scopes": []string{"admin:all"}})
+		Expect(status).To(Equal(400))
+		Expect(result["fields"]).To(HaveKey("scopes"))
+	})
+
+	It("should stop working once deleted or expired", func() {
+		secret, id := create("runs:read")
+		status, _ := call("GET", "/protected/runs", secret, nil)
+		Expect(status).To(Equal(200))
+
+		status, _ = call("DELETE", "/protected/tokens/"+id, token, nil)
+		Expect(status).To(Equal(200))
+		status, _ = call("GET", "/protected/runs", secret, nil)
+		Expect(status).To(Equal(401))
+		status, _ = call("DELETE", "/protected/tokens/"+id, token, nil)
+		Expect(status).To(Equal(404))
+
+		secret, _ = create("runs:read")
+		_, err := testDbInstance.Exec("UPDATE personal_access_tokens SET expires_at = CURRENT_TIMESTAMP - INTERVAL '1 minute'")
+		Expect(err).To(BeNil())
+		status, _ = call("GET", "/protected/runs", secret, nil)
+		Expect(status).To(Equal(401))
+	})
+
+	It("should be revoked when logging out everywhere", func() {
+		secret, _ := create("runs:read")
+		status, _ := call("POST", "/protected/logout-all", token, nil)
+		Expect(status).To(Equal(200))
+		status, _ = call("GET", "/protected/runs", secret, nil)
+		Expect(status).To(Equal(401))
+	})
+})
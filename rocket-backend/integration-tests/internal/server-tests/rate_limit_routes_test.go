@@ -0,0 +1,125 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"rocket-backend/pkg/ratelimit"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate Limiting", func() {
+	const email = "ratelimit@example.com"
+	const password = "password123"
+
+	post := func(path string, payload any) (*http.Response, map[string]any) {
+		raw, _ := json.Marshal(payload)
+		resp, err := http.Post(baseURL+path, "application/json", bytes.NewReader(raw))
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	login := func(pw string) (*http.Response, map[string]any) {
+		return post("/login", map[string]any{"email": email, "password": pw})
+	}
+
+	expectRetryAfter := func(resp *http.Response) int {
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		Expect(err).To(BeNil())
+		Expect(seconds).To(BeNumerically(">", 0))
+		return seconds
+	}
+
+	BeforeEach(func() {
+		registerAndLogin(email, password, "ratelimit")
+	})
+
+	Context("with the limiter on", func() {
+		BeforeEach(func() {
+			apiServer.SetRateLimiter(ratelimit.New(ratelimit.NewMemoryStore()))
+		})
+
+		AfterEach(func() {
+			apiServer.SetRateLimiter(nil)
+		})
+
+		It("should limit logins per account", func() {
+			for range 5 {
+				resp, _ := login(password)
+				Expect(resp.StatusCode).To(Equal(200))
+			}
+			resp, result := login(password)
+			expectRetryAfter(resp)
+			Expect(result["error"]).To(Equal("Too many requests, try again later"))
+
+			// Another account from the same address is not affected
+			resp, _ = post("/login", map[string]any{"email": "other@example.com", "password": password})
+			Expect(resp.StatusCode).NotTo(Equal(http.StatusTooManyRequests))
+		})
+
+		It("should limit the auth endpoints per address", func() {
+			for range 10 {
+				resp, _ := post("/verify-email", map[string]any{"token": "invalid"})
+				Expect(resp.StatusCode).To(Equal(400))
+			}
+			resp, _ := post("/verify-email", map[string]any{"token": "invalid"})
+			expectRetryAfter(resp)
+
+			// The endpoints share the budget of the address
+			resp, _ = login(password)
+			expectRetryAfter(resp)
+		})
+	})
+
+	Context("account lockout", func() {
+		It("should lock the account after repeated failures", func() {
+			for range 5 {
+				resp, _ := login("wrongpassword")
+				Expect(resp.StatusCode).To(Equal(401))
+			}
+
+			// Even the right password is refused while the account is locked
+			resp, result := login(password)
+			Expect(expectRetryAfter(resp)).To(BeNumerically("~", 60, 2))
+			Expect(result["error"]).To(Equal("Too many failed login attempts, try again later"))
+		})
+
+		It("should reset the count after a successful login", func() {
+			for range 4 {
+				resp, _ := login("wrongpassword")
+				Expect(resp.StatusCode).To(Equal(401))
+			}
+			resp, _ := login(password)
+			Expect(resp.StatusCode).To(Equal(200))
+
+			for range 4 {
+				resp, _ := login("wrongpassword")
+				Expect(resp.StatusCode).To(Equal(401))
+			}
+			resp, _ = login(password)
+			Expect(resp.StatusCode).To(Equal(200))
+		})
+
+		It("should back off exponentially", func() {
+			for range 5 {
+				login("wrongpassword")
+			}
+			// Let the first lock run out without waiting for it
+			_, err := testDbInstance.Exec(`UPDATE login_failures SET locked_until = CURRENT_TIMESTAMP`)
+			Expect(err).To(BeNil())
+
+			resp, _ := login("wrongpassword")
+			Expect(resp.StatusCode).To(Equal(401))
+			resp, _ = login(password)
+			Expect(expectRetryAfter(resp)).To(BeNumerically("~", 120, 2))
+		})
+	})
+})
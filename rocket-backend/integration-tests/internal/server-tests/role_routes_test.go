@@ -0,0 +1,143 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Roles and Admin Access", func() {
+	const apiKey = "test-api-key"
+	var userToken string
+	var userID, otherID string
+
+	do := func(method, path string, headers map[string]string, payload any) *http.Response {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	login := func(email string) string {
+		raw, _ := json.Marshal(map[string]any{"email": email, "password": "password123"})
+		resp, err := http.Post(baseURL+"/login", "application/json", bytes.NewReader(raw))
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return result["token"].(string)
+	}
+
+	setRole := func(id, role string) int {
+		resp := do("PUT", "/admin/users/"+id+"/role", map[string]string{"X-API-KEY": apiKey}, map[string]any{"role": role})
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	BeforeEach(func() {
+		os.Setenv("API_KEY", apiKey)
+		userToken = registerAndLogin("member@example.com", "password123", "member")
+		registerAndLogin("other@example.com", "password123", "other")
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'member'").Scan(&userID)).To(Succeed())
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'other'").Scan(&otherID)).To(Succeed())
+	})
+
+	It("should keep regular users out of the admin and moderation endpoints", func() {
+		resp := do("GET", "/admin/users", bearer(userToken), nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+
+		resp = do("DELETE", "/moderation/chat/00000000-0000-0000-0000-000000000000", bearer(userToken), nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+	})
+
+	It("should let admins manage users with their token", func() {
+		Expect(setRole(userID, "admin")).To(Equal(200))
+
+		// The old token still claims the user role
+		resp := do("GET", "/admin/users", bearer(userToken), nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+
+		adminToken := login("member@example.com")
+		resp = do("GET", "/admin/users", bearer(adminToken), nil)
+		Expect(resp.StatusCode).To(Equal(200))
+		var users []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&users)
+		resp.Body.Close()
+		roles := map[any]any{}
+		for _, user := range users {
+			roles[user["username"]] = user["role"]
+		}
+		Expect(roles).To(Equal(map[any]any{"member": "admin", "other": "user"}))
+
+		resp = do("PUT", "/admin/users/"+otherID+"/role", bearer(adminToken), map[string]any{"role": "superuser"})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(400))
+
+		// Catalog edits made with a token are attributed to the admin
+		resp = do("POST", "/admin/challenges", bearer(adminToken), map[string]any{
+			"text": "Meditate for 10 minutes", "points": 10, "category": "mindfulness", "difficulty": "easy", "verification": "self_report",
+		})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(201))
+		var actor string
+		Expect(testDbInstance.QueryRow("SELECT actor FROM challenge_audit_log").Scan(&actor)).To(Succeed())
+		Expect(actor).To(Equal("user:" + userID))
+	})
+
+	It("should never remove the last admin", func() {
+		Expect(setRole(userID, "admin")).To(Equal(200))
+		Expect(setRole(userID, "user")).To(Equal(409))
+
+		resp := do("DELETE", "/admin/users/"+userID, map[string]string{"X-API-KEY": apiKey}, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(409))
+
+		Expect(setRole(otherID, "admin")).To(Equal(200))
+		Expect(setRole(userID, "user")).To(Equal(200))
+	})
+
+	It("should let moderators remove chat messages until they are demoted", func() {
+		var messageID string
+		err := testDbInstance.QueryRow(`
+			INSERT INTO chat_messages (user_id, message, timestamp) VALUES ($1, 'spam', NOW()) RETURNING id
+		`, otherID).Scan(&messageID)
+		Expect(err).To(BeNil())
+
+		Expect(setRole(userID, "moderator")).To(Equal(200))
+		moderatorToken := login("member@example.com")
+
+		resp := do("DELETE", "/admin/users/"+otherID, bearer(moderatorToken), nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+
+		resp = do("DELETE", "/moderation/chat/"+messageID, bearer(moderatorToken), nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		resp = do("DELETE", "/moderation/chat/"+messageID, bearer(moderatorToken), nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+
+		Expect(setRole(userID, "user")).To(Equal(200))
+		resp = do("DELETE", "/moderation/chat/"+messageID, bearer(moderatorToken), nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+	})
+})
@@ -0,0 +1,121 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session Management", func() {
+	const email = "sessions@example.com"
+
+	request := func(method, path, token string, payload any) (int, []byte) {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		buf := new(bytes.Buffer)
+		_, _ = buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+
+	login := func(deviceName string) (string, string) {
+		status, body := request("POST", "/login", "", map[string]any{
+			"email": email, "password": "password123", "device_name": deviceName,
+		})
+		Expect(status).To(Equal(200))
+		var result map[string]any
+		Expect(json.Unmarshal(body, &result)).To(Succeed())
+		return result["token"].(string), result["refresh_token"].(string)
+	}
+
+	sessions := func(token string) []map[string]any {
+		status, body := request("GET", "/protected/sessions", token, nil)
+		Expect(status).To(Equal(200))
+		var result []map[string]any
+		Expect(json.Unmarshal(body, &result)).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		registerAndLogin(email, "password123", "sessions")
+		_, err := testDbInstance.Exec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP")
+		Expect(err).To(BeNil())
+	})
+
+	It("should list the active sessions and flag the current one", func() {
+		phone, _ := login("Phone")
+		login("Laptop")
+
+		list := sessions(phone)
+		Expect(list).To(HaveLen(2))
+		for _, session := range list {
+			Expect(session["current"]).To(Equal(session["device_name"] == "Phone"))
+			Expect(session).To(HaveKey("user_agent"))
+			Expect(session).To(HaveKey("ip_address"))
+			Expect(session).To(HaveKey("last_seen_at"))
+			Expect(session).To(HaveKey("created_at"))
+		}
+	})
+
+	It("should revoke a single device", func() {
+		phone, _ := login("Phone")
+		laptop, laptopRefresh := login("Laptop")
+
+		var laptopID string
+		for _, session := range sessions(phone) {
+			if session["device_name"] == "Laptop" {
+				laptopID = session["id"].(string)
+			}
+		}
+		Expect(laptopID).NotTo(BeEmpty())
+
+		status, _ := request("DELETE", "/protected/sessions/"+laptopID, phone, nil)
+		Expect(status).To(Equal(200))
+
+		status, _ = request("GET", "/protected/", laptop, nil)
+		Expect(status).To(Equal(401))
+		status, _ = request("POST", "/refresh", "", map[string]any{"refresh_token": laptopRefresh})
+		Expect(status).To(Equal(401))
+
+		// The phone stays logged in
+		Expect(sessions(phone)).To(HaveLen(1))
+
+		status, _ = request("DELETE", "/protected/sessions/"+laptopID, phone, nil)
+		Expect(status).To(Equal(404))
+	})
+
+	It("should not revoke sessions of other users", func() {
+		registerAndLogin("other@example.com", "password123", "other")
+		phone, _ := login("Phone")
+
+		status, body := request("POST", "/login", "", map[string]any{"email": "other@example.com", "password": "password123"})
+		Expect(status).To(Equal(200))
+		var other map[string]any
+		Expect(json.Unmarshal(body, &other)).To(Succeed())
+
+		sessionID := sessions(phone)[0]["id"].(string)
+		status, _ = request("DELETE", "/protected/sessions/"+sessionID, other["token"].(string), nil)
+		Expect(status).To(Equal(404))
+	})
+
+	It("should update the last login", func() {
+		_, err := testDbInstance.Exec("UPDATE credentials SET last_login = CURRENT_TIMESTAMP - INTERVAL '3 days' WHERE email = $1", email)
+		Expect(err).To(BeNil())
+
+		login("Phone")
+
+		var lastLogin time.Time
+		Expect(testDbInstance.QueryRow("SELECT last_login FROM credentials WHERE email = $1", email).Scan(&lastLogin)).To(Succeed())
+		Expect(lastLogin).To(BeTemporally("~", time.Now(), time.Minute))
+	})
+})
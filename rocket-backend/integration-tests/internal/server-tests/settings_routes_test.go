@@ -131,7 +131,7 @@ var _ = Describe("Settings Handlers API", func() {
 
 	It("should update user info (name and email)", func() {
 		payload := map[string]any{
-			"name":  "New Name",
+			"name":  "new.name",
 			"email": "newemail@example.com",
 		}
 		body, _ := json.Marshal(payload)
@@ -0,0 +1,143 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shop Handlers API", func() {
+	var token string
+	var frameID string
+	const username = "shopper"
+	const apiKey = "test-api-key"
+
+	redeem := func(itemID string) *http.Response {
+		req, _ := http.NewRequest("POST", baseURL+"/protected/shop/items/"+itemID+"/redeem", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	BeforeEach(func() {
+		os.Setenv("API_KEY", apiKey)
+		token = registerAndLogin("shopper@example.com", "password123", username)
+		err := testDbInstance.QueryRow(`
+			INSERT INTO shop_items (name, description, category, price, max_per_user)
+			VALUES ('Test Frame', 'A frame for testing', 'profile_frame', 300, 1)
+			RETURNING id
+		`).Scan(&frameID)
+		Expect(err).To(BeNil())
+	})
+
+	It("should list active shop items", func() {
+		req, _ := http.NewRequest("GET", baseURL+"/protected/shop/items", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		var items []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&items)
+		names := []any{}
+		for _, item := range items {
+			names = append(names, item["name"])
+		}
+		Expect(names).To(ContainElement("Test Frame"))
+	})
+
+	It("should reject redeeming without enough rocket points", func() {
+		resp := redeem(frameID)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(402))
+	})
+
+	It("should redeem an item and record it in inventory and history", func() {
+		_, err := testDbInstance.Exec("UPDATE users SET rocketpoints = 500 WHERE username = $1", username)
+		Expect(err).To(BeNil())
+
+		resp := redeem(frameID)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var points int
+		err = testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE username = $1", username).Scan(&points)
+		Expect(err).To(BeNil())
+		Expect(points).To(Equal(200))
+
+		req, _ := http.NewRequest("GET", baseURL+"/protected/shop/inventory", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err = http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var inventory []map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&inventory)
+		Expect(inventory).To(HaveLen(1))
+		Expect(inventory[0]["quantity"]).To(Equal(float64(1)))
+
+		req, _ = http.NewRequest("GET", baseURL+"/protected/shop/purchases", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp2, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp2.Body.Close()
+		var purchases []map[string]any
+		_ = json.NewDecoder(resp2.Body).Decode(&purchases)
+		Expect(purchases).To(HaveLen(1))
+		Expect(purchases[0]["price"]).To(Equal(float64(300)))
+	})
+
+	It("should enforce the per-user limit", func() {
+		_, err := testDbInstance.Exec("UPDATE users SET rocketpoints = 1000 WHERE username = $1", username)
+		Expect(err).To(BeNil())
+
+		resp := redeem(frameID)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		resp = redeem(frameID)
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(409))
+	})
+
+	It("should reject admin endpoints without API key", func() {
+		req, _ := http.NewRequest("GET", baseURL+"/admin/shop/items", nil)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+	})
+
+	It("should let admins create and retire items", func() {
+		payload := map[string]any{
+			"name":     "Moon Badge",
+			"category": "badge",
+			"price":    100,
+		}
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", baseURL+"/admin/shop/items", bytes.NewReader(body))
+		req.Header.Set("X-API-KEY", apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(201))
+		var created map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&created)
+
+		req, _ = http.NewRequest("DELETE", baseURL+"/admin/shop/items/"+created["id"].(string), nil)
+		req.Header.Set("X-API-KEY", apiKey)
+		resp2, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp2.Body.Close()
+		Expect(resp2.StatusCode).To(Equal(200))
+
+		resp3 := redeem(created["id"].(string))
+		defer resp3.Body.Close()
+		Expect(resp3.StatusCode).To(Equal(404))
+	})
+})
@@ -0,0 +1,115 @@
+package server_tests
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streak Handlers API", func() {
+	var token string
+	const username = "streakuser"
+
+	getStreak := func() map[string]any {
+		req, _ := http.NewRequest("GET", baseURL+"/protected/streaks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return result
+	}
+
+	BeforeEach(func() {
+		token = registerAndLogin("streak@example.com", "password123", username)
+		_, err := testDbInstance.Exec(`
+			UPDATE settings SET step_goal = 1000
+			WHERE user_id = (SELECT id FROM users WHERE username = $1)
+		`, username)
+		Expect(err).To(BeNil())
+	})
+
+	It("should start with an empty streak", func() {
+		result := getStreak()
+		Expect(result["current_streak"]).To(Equal(float64(0)))
+		Expect(result["longest_streak"]).To(Equal(float64(0)))
+		Expect(result["goal_met_today"]).To(BeFalse())
+	})
+
+	It("should count past days that met the step goal", func() {
+		_ = getStreak()
+		_, err := testDbInstance.Exec(`
+			UPDATE streaks SET last_evaluated = CURRENT_DATE - 3
+			WHERE user_id = (SELECT id FROM users WHERE username = $1)
+		`, username)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date)
+			SELECT gen_random_uuid(), id, 1500, CURRENT_DATE - d
+			FROM users, generate_series(1, 2) AS d
+			WHERE username = $1
+		`, username)
+		Expect(err).To(BeNil())
+
+		result := getStreak()
+		Expect(result["current_streak"]).To(Equal(float64(2)))
+		Expect(result["longest_streak"]).To(Equal(float64(2)))
+	})
+
+	It("should use a streak freeze for a missed day", func() {
+		_ = getStreak()
+		_, err := testDbInstance.Exec(`
+			UPDATE streaks SET last_evaluated = CURRENT_DATE - 2, current_streak = 5, longest_streak = 5, freezes = 1
+			WHERE user_id = (SELECT id FROM users WHERE username = $1)
+		`, username)
+		Expect(err).To(BeNil())
+
+		result := getStreak()
+		Expect(result["current_streak"]).To(Equal(float64(5)))
+		Expect(result["streak_freezes"]).To(Equal(float64(0)))
+	})
+
+	It("should reject buying a streak freeze without enough rocket points", func() {
+		_, err := testDbInstance.Exec(`
+			INSERT INTO shop_items (name, category, price, max_per_user)
+			VALUES ('Streak Freeze', 'streak_freeze', 500, 2)
+			ON CONFLICT (name) DO NOTHING
+		`)
+		Expect(err).To(BeNil())
+
+		req, _ := http.NewRequest("POST", baseURL+"/protected/streaks/freeze", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(402))
+	})
+
+	It("should buy a streak freeze with rocket points", func() {
+		_, err := testDbInstance.Exec(`
+			INSERT INTO shop_items (name, category, price, max_per_user)
+			VALUES ('Streak Freeze', 'streak_freeze', 500, 2)
+			ON CONFLICT (name) DO NOTHING
+		`)
+		Expect(err).To(BeNil())
+		_, err = testDbInstance.Exec("UPDATE users SET rocketpoints = 1000 WHERE username = $1", username)
+		Expect(err).To(BeNil())
+
+		req, _ := http.NewRequest("POST", baseURL+"/protected/streaks/freeze", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		var points int
+		err = testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE username = $1", username).Scan(&points)
+		Expect(err).To(BeNil())
+		Expect(points).To(Equal(500))
+		Expect(getStreak()["streak_freezes"]).To(Equal(float64(1)))
+	})
+})
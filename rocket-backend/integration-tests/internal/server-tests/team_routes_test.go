@@ -0,0 +1,255 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Team Handlers API", func() {
+	var ownerToken, memberToken, outsiderToken string
+	var ownerID, memberID string
+
+	do := func(method, path, token string, payload any) *http.Response {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	decode := func(resp *http.Response, target any) {
+		defer resp.Body.Close()
+		_ = json.NewDecoder(resp.Body).Decode(target)
+	}
+
+	points := func(userID string) int {
+		var rocketPoints int
+		Expect(testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE id = $1", userID).Scan(&rocketPoints)).To(Succeed())
+		return rocketPoints
+	}
+
+	createTeam := func() string {
+		resp := do("POST", "/protected/teams", ownerToken, map[string]any{"name": "Rocketeers", "members": []string{"crew"}})
+		Expect(resp.StatusCode).To(Equal(201))
+		var team map[string]any
+		decode(resp, &team)
+		return team["id"].(string)
+	}
+
+	BeforeEach(func() {
+		ownerToken = registerAndLogin("captain@example.com", "password123", "captain")
+		memberToken = registerAndLogin("crew@example.com", "password123", "crew")
+		outsiderToken = registerAndLogin("stranger@example.com", "password123", "stranger")
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'captain'").Scan(&ownerID)).To(Succeed())
+		Expect(testDbInstance.QueryRow("SELECT id FROM users WHERE username = 'crew'").Scan(&memberID)).To(Succeed())
+
+		resp := do("POST", "/protected/friends/add", ownerToken, map[string]any{"friend_name": "crew"})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+	})
+
+	It("should only form teams with friends", func() {
+		resp := do("POST", "/protected/teams", ownerToken, map[string]any{"name": "Nope", "members": []string{"stranger"}})
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+	})
+
+	It("should not add users who blocked the owner", func() {
+		teamID := createTeam()
+		resp := do("DELETE", "/protected/teams/"+teamID+"/members/crew", memberToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		resp = do("POST", "/protected/blocks", memberToken, map[string]any{"username": "captain"})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		// The owner still follows crew, e.g. from before blocks were introduced
+		_, err := testDbInstance.Exec(`INSERT INTO friends (user_id, friend_id) VALUES ($1, $2)`, ownerID, memberID)
+		Expect(err).To(BeNil())
+
+		resp = do("POST", "/protected/teams/"+teamID+"/members", ownerToken, map[string]any{"username": "crew"})
+		var result map[string]any
+		decode(resp, &result)
+		Expect(resp.StatusCode).To(Equal(403))
+		Expect(result["error"]).To(Equal("You cannot add this user to a team"))
+
+		resp = do("POST", "/protected/teams", ownerToken, map[string]any{"name": "Again", "members": []string{"crew"}})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+	})
+
+	It("should create a team and hide it from outsiders", func() {
+		teamID := createTeam()
+
+		resp := do("GET", "/protected/teams/"+teamID, memberToken, nil)
+		Expect(resp.StatusCode).To(Equal(200))
+		var team map[string]any
+		decode(resp, &team)
+		Expect(team["members"]).To(HaveLen(2))
+
+		resp = do("GET", "/protected/teams/"+teamID, outsiderToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(404))
+	})
+
+	It("should let members leave but not remove others", func() {
+		teamID := createTeam()
+
+		resp := do("DELETE", "/protected/teams/"+teamID+"/members/captain", memberToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+
+		resp = do("DELETE", "/protected/teams/"+teamID+"/members/crew", memberToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		resp = do("GET", "/protected/teams", memberToken, nil)
+		var teams []map[string]any
+		decode(resp, &teams)
+		Expect(teams).To(BeEmpty())
+	})
+
+	It("should aggregate the members' steps and reward everybody on completion", func() {
+		teamID := createTeam()
+
+		resp := do("POST", "/protected/teams/"+teamID+"/challenges", memberToken, map[string]any{
+			"text": "Walk 70000 steps together", "metric": "steps", "target": 70000, "duration_days": 7,
+		})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(403))
+
+		// The reward is derived from the target, whatever the owner asks for
+		resp = do("POST", "/protected/teams/"+teamID+"/challenges", ownerToken, map[string]any{
+			"text": "Walk 70000 steps together", "metric": "steps", "target": 70000, "duration_days": 7, "points": 1000,
+		})
+		Expect(resp.StatusCode).To(Equal(201))
+		var created map[string]any
+		decode(resp, &created)
+		Expect(created["points"]).To(Equal(float64(70)))
+
+		_, err := testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date)
+			VALUES (gen_random_uuid(), $1, 30000, CURRENT_DATE), (gen_random_uuid(), $2, 45000, CURRENT_DATE)
+		`, ownerID, memberID)
+		Expect(err).To(BeNil())
+
+		resp = do("GET", "/protected/teams/"+teamID+"/challenges", memberToken, nil)
+		Expect(resp.StatusCode).To(Equal(200))
+		var challenges []map[string]any
+		decode(resp, &challenges)
+		Expect(challenges).To(HaveLen(1))
+		Expect(challenges[0]["status"]).To(Equal("completed"))
+		Expect(challenges[0]["progress"]).To(Equal(float64(75000)))
+		Expect(challenges[0]["contributions"]).To(HaveLen(2))
+
+		for _, id := range []string{ownerID, memberID} {
+			Expect(points(id)).To(Equal(70))
+		}
+	})
+
+	It("should refuse challenges for teams of one and targets below the minimum", func() {
+		resp := do("POST", "/protected/teams", ownerToken, map[string]any{"name": "Solo", "members": []string{}})
+		Expect(resp.StatusCode).To(Equal(201))
+		var solo map[string]any
+		decode(resp, &solo)
+
+		resp = do("POST", "/protected/teams/"+solo["id"].(string)+"/challenges", ownerToken, map[string]any{
+			"text": "Walk alone", "metric": "steps", "target": 70000, "duration_days": 7,
+		})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(409))
+
+		teamID := createTeam()
+		resp = do("POST", "/protected/teams/"+teamID+"/challenges", ownerToken, map[string]any{
+			"text": "Walk a bit", "metric": "steps", "target": 100, "duration_days": 7,
+		})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(400))
+	})
+
+	It("should only count and reward what the members at creation did afterwards", func() {
+		teamID := createTeam()
+		_, err := testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date)
+			VALUES (gen_random_uuid(), $1, 60000, CURRENT_DATE), (gen_random_uuid(), $2, 5000, CURRENT_DATE)
+		`, ownerID, memberID)
+		Expect(err).To(BeNil())
+
+		resp := do("POST", "/protected/teams/"+teamID+"/challenges", ownerToken, map[string]any{
+			"text": "Walk 10000 steps a day", "metric": "steps", "target": 20000, "duration_days": 2,
+		})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(201))
+
+		// The outsider joins afterwards and walks a lot
+		resp = do("POST", "/protected/friends/add", ownerToken, map[string]any{"friend_name": "stranger"})
+		resp.Body.Close()
+		resp = do("POST", "/protected/teams/"+teamID+"/members", ownerToken, map[string]any{"username": "stranger"})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		_, err = testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date)
+			SELECT gen_random_uuid(), id, 50000, CURRENT_DATE FROM users WHERE username = 'stranger'
+		`)
+		Expect(err).To(BeNil())
+
+		resp = do("GET", "/protected/teams/"+teamID+"/challenges", ownerToken, nil)
+		var challenges []map[string]any
+		decode(resp, &challenges)
+		Expect(challenges[0]["status"]).To(Equal("active"))
+		Expect(challenges[0]["progress"]).To(Equal(float64(0)))
+		Expect(challenges[0]["contributions"]).To(HaveLen(2))
+
+		_, err = testDbInstance.Exec(`UPDATE daily_steps SET steps_taken = steps_taken + 10000 WHERE user_id IN ($1, $2)`, ownerID, memberID)
+		Expect(err).To(BeNil())
+		resp = do("GET", "/protected/teams/"+teamID+"/challenges", ownerToken, nil)
+		var settled []map[string]any
+		decode(resp, &settled)
+		Expect(settled[0]["status"]).To(Equal("completed"))
+		Expect(settled[0]["progress"]).To(Equal(float64(20000)))
+
+		Expect(points(ownerID)).To(Equal(20))
+		Expect(points(memberID)).To(Equal(20))
+		var outsiderPoints int
+		Expect(testDbInstance.QueryRow("SELECT rocketpoints FROM users WHERE username = 'stranger'").Scan(&outsiderPoints)).To(Succeed())
+		Expect(outsiderPoints).To(Equal(0))
+	})
+
+	It("should limit the points a user earns with team challenges in a week", func() {
+		teamID := createTeam()
+		// The owner already earned almost all they can this week
+		_, err := testDbInstance.Exec(`
+			WITH earlier AS (
+				INSERT INTO team_challenges (team_id, description, metric, target_value, target_unit, points_reward, ends_on, completed_at)
+				VALUES ($1, 'Earlier', 'steps', 70000, 'steps', 290, CURRENT_DATE, NOW())
+				RETURNING id
+			)
+			INSERT INTO team_challenge_members (challenge_id, user_id, points_awarded) SELECT id, $2, 290 FROM earlier
+		`, teamID, ownerID)
+		Expect(err).To(BeNil())
+
+		resp := do("POST", "/protected/teams/"+teamID+"/challenges", ownerToken, map[string]any{
+			"text": "Walk 70000 steps together", "metric": "steps", "target": 70000, "duration_days": 7,
+		})
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(201))
+		_, err = testDbInstance.Exec(`
+			INSERT INTO daily_steps (id, user_id, steps_taken, date)
+			VALUES (gen_random_uuid(), $1, 35000, CURRENT_DATE), (gen_random_uuid(), $2, 35000, CURRENT_DATE)
+		`, ownerID, memberID)
+		Expect(err).To(BeNil())
+
+		resp = do("GET", "/protected/teams/"+teamID+"/challenges", ownerToken, nil)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+
+		Expect(points(ownerID)).To(Equal(10))
+		Expect(points(memberID)).To(Equal(70))
+	})
+})
@@ -0,0 +1,134 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token Lifecycle", func() {
+	const email = "tokens@example.com"
+
+	post := func(path, token string, payload any) (int, map[string]any) {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	login := func() (string, string) {
+		status, result := post("/login", "", map[string]any{"email": email, "password": "password123"})
+		Expect(status).To(Equal(200))
+		Expect(result["expires_in"]).To(Equal(float64(900)))
+		return result["token"].(string), result["refresh_token"].(string)
+	}
+
+	authenticated := func(token string) int {
+		req, _ := http.NewRequest("GET", baseURL+"/protected/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	BeforeEach(func() {
+		registerAndLogin(email, "password123", "tokens")
+	})
+
+	It("should rotate refresh tokens and revoke the family on reuse", func() {
+		_, refreshToken := login()
+
+		status, result := post("/refresh", "", map[string]any{"refresh_token": refreshToken})
+		Expect(status).To(Equal(200))
+		rotated := result["refresh_token"].(string)
+		Expect(rotated).NotTo(Equal(refreshToken))
+		Expect(authenticated(result["token"].(string))).To(Equal(200))
+
+		// Replaying the old token looks like theft, the rotated one dies as well
+		status, _ = post("/refresh", "", map[string]any{"refresh_token": refreshToken})
+		Expect(status).To(Equal(401))
+		status, _ = post("/refresh", "", map[string]any{"refresh_token": rotated})
+		Expect(status).To(Equal(401))
+	})
+
+	It("should revoke the presented tokens on logout", func() {
+		token, refreshToken := login()
+		Expect(authenticated(token)).To(Equal(200))
+
+		status, _ := post("/logout", token, map[string]any{"refresh_token": refreshToken})
+		Expect(status).To(Equal(200))
+
+		Expect(authenticated(token)).To(Equal(401))
+		status, _ = post("/refresh", "", map[string]any{"refresh_token": refreshToken})
+		Expect(status).To(Equal(401))
+	})
+
+	It("should log out all devices", func() {
+		phone, _ := login()
+		laptop, laptopRefresh := login()
+
+		status, _ := post("/protected/logout-all", phone, nil)
+		Expect(status).To(Equal(200))
+
+		Expect(authenticated(phone)).To(Equal(401))
+		Expect(authenticated(laptop)).To(Equal(401))
+		status, _ = post("/refresh", "", map[string]any{"refresh_token": laptopRefresh})
+		Expect(status).To(Equal(401))
+	})
+
+	It("should revoke existing tokens when the password changes", func() {
+		other, _ := login()
+		token, _ := login()
+
+		status, result := post("/protected/settings/userinfo", token, map[string]any{
+			"currentPassword": "password123",
+			"newPassword":     "newpass456",
+		})
+		Expect(status).To(Equal(200))
+
+		Expect(authenticated(other)).To(Equal(401))
+		Expect(authenticated(token)).To(Equal(401))
+		Expect(authenticated(result["token"].(string))).To(Equal(200))
+	})
+
+	It("should sign access tokens with a key ID and the standard claims", func() {
+		token, _ := login()
+		parts := strings.Split(token, ".")
+		Expect(parts).To(HaveLen(3))
+		var header, claims map[string]any
+		raw, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		Expect(json.Unmarshal(raw, &header)).To(Succeed())
+		raw, _ = base64.RawURLEncoding.DecodeString(parts[1])
+		Expect(json.Unmarshal(raw, &claims)).To(Succeed())
+
+		Expect(header["kid"]).NotTo(BeEmpty())
+		Expect(header["alg"]).To(Equal("HS256"))
+		Expect(claims["iss"]).To(Equal("rocket-backend"))
+		Expect(claims["aud"]).To(Equal("rocket-app"))
+		Expect(claims).To(HaveKey("iat"))
+		Expect(claims).To(HaveKey("jti"))
+
+		// HS256 secrets stay private, so there is nothing to publish
+		resp, err := http.Get(strings.TrimSuffix(baseURL, "/api/v1") + "/.well-known/jwks.json")
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(200))
+		var jwks map[string]any
+		Expect(json.NewDecoder(resp.Body).Decode(&jwks)).To(Succeed())
+		Expect(jwks["keys"]).To(BeEmpty())
+	})
+})
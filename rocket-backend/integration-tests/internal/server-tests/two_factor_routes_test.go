@@ -0,0 +1,186 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"rocket-backend/pkg/totp"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Two-Factor Authentication", func() {
+	const email = "twofactor@example.com"
+	const password = "password123"
+
+	var token string
+
+	post := func(path, token string, payload any) (int, map[string]any) {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	code := func(secret string, offset int64) string {
+		value, err := totp.Code(secret, totp.Step(time.Now())+offset)
+		Expect(err).To(BeNil())
+		return value
+	}
+
+	// enable enrolls and confirms, returning the secret and the recovery codes
+	enable := func() (string, []any) {
+		status, result := post("/protected/2fa/enroll", token, map[string]any{"password": password})
+		Expect(status).To(Equal(200))
+		secret := result["secret"].(string)
+		uri, err := url.Parse(result["otpauth_uri"].(string))
+		Expect(err).To(BeNil())
+		Expect(uri.Scheme).To(Equal("otpauth"))
+		Expect(uri.Query().Get("secret")).To(Equal(secret))
+
+		status, result = post("/protected/2fa/confirm", token, map[string]any{"code": code(secret, 0)})
+		Expect(status).To(Equal(200))
+		return secret, result["recovery_codes"].([]any)
+	}
+
+	challenge := func() string {
+		status, result := post("/login", "", map[string]any{"email": email, "password": password})
+		Expect(status).To(Equal(200))
+		Expect(result["two_factor_required"]).To(BeTrue())
+		Expect(result).NotTo(HaveKey("token"))
+		return result["challenge_token"].(string)
+	}
+
+	BeforeEach(func() {
+		token = registerAndLogin(email, password, "twofactor")
+	})
+
+	It("should require a code after the password once enabled", func() {
+		secret, _ := enable()
+		challengeToken := challenge()
+
+		// The challenge token grants no access by itself
+		req, _ := http.NewRequest("GET", baseURL+"/protected/", nil)
+		req.Header.Set("Authorization", "Bearer "+challengeToken)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(401))
+
+		status, _ := post("/login/2fa", "", map[string]any{"challenge_token": challengeToken, "code": "000000"})
+		Expect(status).To(Equal(401))
+
+		// The code of the current period was spent on the confirmation
+		status, _ = post("/login/2fa", "", map[string]any{"challenge_token": challengeToken, "code": code(secret, 0)})
+		Expect(status).To(Equal(401))
+
+		status, result := post("/login/2fa", "", map[string]any{"challenge_token": challengeToken, "code": code(secret, 1)})
+		Expect(status).To(Equal(200))
+		Expect(result["token"]).NotTo(BeEmpty())
+
+		// Codes are single-use
+		status, _ = post("/login/2fa", "", map[string]any{"challenge_token": challenge(), "code": code(secret, 1)})
+		Expect(status).To(Equal(401))
+	})
+
+	It("should accept each recovery code once", func() {
+		_, recoveryCodes := enable()
+		Expect(recoveryCodes).To(HaveLen(totp.RecoveryCodeCount))
+		recoveryCode := recoveryCodes[0].(string)
+
+		status, _ := post("/login/2fa", "", map[string]any{"challenge_token": challenge(), "recovery_code": recoveryCode})
+		Expect(status).To(Equal(200))
+		status, _ = post("/login/2fa", "", map[string]any{"challenge_token": challenge(), "recovery_code": recoveryCode})
+		Expect(status).To(Equal(401))
+
+		req, _ := http.NewRequest("GET", baseURL+"/protected/2fa", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var state map[string]any
+		Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+		Expect(state["enabled"]).To(BeTrue())
+		Expect(state["recovery_codes_left"]).To(Equal(float64(totp.RecoveryCodeCount - 1)))
+	})
+
+	It("should gate enrollment and disabling with the current password", func() {
+		status, _ := post("/protected/2fa/enroll", token, map[string]any{"password": "wrong"})
+		Expect(status).To(Equal(401))
+
+		enable()
+
+		status, _ = post("/protected/2fa/disable", token, map[string]any{"password": "wrong"})
+		Expect(status).To(Equal(401))
+		status, _ = post("/protected/2fa/disable", token, map[string]any{"password": password})
+		Expect(status).To(Equal(200))
+
+		status, result := post("/login", "", map[string]any{"email": email, "password": password})
+		Expect(status).To(Equal(200))
+		Expect(result["token"]).NotTo(BeEmpty())
+
+		status, _ = post("/protected/2fa/disable", token, map[string]any{"password": password})
+		Expect(status).To(Equal(409))
+	})
+
+	It("should let accounts without a password confirm with a fresh login or a code", func() {
+		// Like an account created through an identity provider
+		_, err := testDbInstance.Exec("UPDATE credentials SET password = '' WHERE email = $1", email)
+		Expect(err).To(BeNil())
+
+		status, result := post("/protected/2fa/enroll", token, nil)
+		Expect(status).To(Equal(200))
+		secret := result["secret"].(string)
+		status, _ = post("/protected/2fa/confirm", token, map[string]any{"code": code(secret, 0)})
+		Expect(status).To(Equal(200))
+
+		_, err = testDbInstance.Exec("UPDATE sessions SET created_at = NOW() - INTERVAL '1 hour'")
+		Expect(err).To(BeNil())
+		status, _ = post("/protected/2fa/recovery-codes", token, nil)
+		Expect(status).To(Equal(401))
+		status, _ = post("/protected/2fa/recovery-codes", token, map[string]any{"password": ""})
+		Expect(status).To(Equal(401))
+		status, _ = post("/protected/2fa/recovery-codes", token, map[string]any{"code": code(secret, 1)})
+		Expect(status).To(Equal(200))
+
+		// A code is only good once
+		status, _ = post("/protected/2fa/disable", token, map[string]any{"code": code(secret, 1)})
+		Expect(status).To(Equal(401))
+		// As if the next period had come
+		_, err = testDbInstance.Exec("UPDATE two_factor SET last_used_step = last_used_step - 2")
+		Expect(err).To(BeNil())
+		status, _ = post("/protected/2fa/disable", token, map[string]any{"code": code(secret, 0)})
+		Expect(status).To(Equal(200))
+	})
+
+	It("should keep the old secret until a re-enrollment is confirmed", func() {
+		oldSecret, _ := enable()
+
+		status, result := post("/protected/2fa/enroll", token, map[string]any{"password": password})
+		Expect(status).To(Equal(200))
+		newSecret := result["secret"].(string)
+
+		status, _ = post("/login/2fa", "", map[string]any{"challenge_token": challenge(), "code": code(oldSecret, 1)})
+		Expect(status).To(Equal(200))
+
+		status, _ = post("/protected/2fa/confirm", token, map[string]any{"code": code(oldSecret, 0)})
+		Expect(status).To(Equal(401))
+		status, _ = post("/protected/2fa/confirm", token, map[string]any{"code": code(newSecret, 0)})
+		Expect(status).To(Equal(200))
+
+		status, _ = post("/login/2fa", "", map[string]any{"challenge_token": challenge(), "code": code(newSecret, 1)})
+		Expect(status).To(Equal(200))
+	})
+})
@@ -130,7 +130,7 @@ var _ = Describe("Protected Handlers API", func() {
 		Expect(resp.StatusCode).To(Equal(200))
 		var result map[string]any
 		_ = json.NewDecoder(resp.Body).Decode(&result)
-		Expect(result["message"]).To(Equal("User deleted successfully"))
+		Expect(result["message"]).To(Equal("Account deactivated"))
 
 		// Try to access a protected endpoint with the same token, should be unauthorized
 		req2, _ := http.NewRequest("GET", baseURL+"/protected/user", nil)
@@ -0,0 +1,135 @@
+package server_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Input Validation", func() {
+	post := func(path, token string, payload any) (int, map[string]any) {
+		raw, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", baseURL+path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		var result map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	register := func(email, username, password string) (int, map[string]any) {
+		return post("/register", "", map[string]any{"email": email, "username": username, "password": password})
+	}
+
+	Describe("registration", func() {
+		It("should report every invalid field", func() {
+			status, result := register("not-an-email", "a", "short")
+			Expect(status).To(Equal(400))
+			Expect(result["error"]).To(Equal("Invalid request"))
+			fields := result["fields"].(map[string]any)
+			Expect(fields).To(HaveKey("email"))
+			Expect(fields).To(HaveKey("username"))
+			Expect(fields["password"]).To(Equal("must be at least 8 characters"))
+		})
+
+		It("should report missing fields by their JSON name", func() {
+			status, result := post("/register", "", map[string]any{"email": "missing@example.com"})
+			Expect(status).To(Equal(400))
+			fields := result["fields"].(map[string]any)
+			Expect(fields["username"]).To(Equal("is required"))
+			Expect(fields["password"]).To(Equal("is required"))
+		})
+
+		It("should refuse reserved names, odd characters and breached passwords", func() {
+			status, result := register("staff@example.com", "Admin", "password123")
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKeyWithValue("username", "is reserved"))
+
+			status, result = register("spaces@example.com", "two words", "password123")
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKey("username"))
+
+			status, result = register("breached@example.com", "breached", "LetMeIn123")
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKeyWithValue("password", "is too common, it appeared in a data breach"))
+
+			status, result = register("lazy@example.com", "lazybones", "lazybones")
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKeyWithValue("password", "must not be your username or email"))
+		})
+
+		It("should treat usernames and emails case-insensitively", func() {
+			status, _ := register("  Mixed.Case@Example.COM ", "Stardust", "password123")
+			Expect(status).To(Equal(200))
+
+			var email string
+			Expect(testDbInstance.QueryRow("SELECT email FROM credentials").Scan(&email)).To(Succeed())
+			Expect(email).To(Equal("mixed.case@example.com"))
+
+			status, result := register("other@example.com", "STARDUST", "password123")
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKeyWithValue("username", "is already taken"))
+
+			status, result = register("MIXED.case@example.com", "stardust2", "password123")
+			Expect(status).To(Equal(400))
+			Expect(result["error"]).To(Equal("Email already exists"))
+
+			// Logging in does not depend on the case either
+			status, _ = post("/login", "", map[string]any{"email": "Mixed.Case@example.com", "password": "password123"})
+			Expect(status).To(Equal(200))
+		})
+	})
+
+	Describe("profile updates", func() {
+		var token string
+
+		BeforeEach(func() {
+			registerAndLogin("other@example.com", "password123", "taken")
+			token = registerAndLogin("profile@example.com", "password123", "profile")
+		})
+
+		It("should not apply any change when a field is invalid", func() {
+			status, result := post("/protected/settings/userinfo", token, map[string]any{
+				"name":  "renamed",
+				"email": "Other@Example.com",
+			})
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKeyWithValue("email", "is already registered"))
+
+			var username string
+			Expect(testDbInstance.QueryRow("SELECT username FROM users WHERE email = 'profile@example.com'").Scan(&username)).To(Succeed())
+			Expect(username).To(Equal("profile"))
+		})
+
+		It("should check names and new passwords", func() {
+			status, result := post("/protected/settings/userinfo", token, map[string]any{"name": "Taken"})
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKeyWithValue("name", "is already taken"))
+
+			status, result = post("/protected/settings/userinfo", token, map[string]any{
+				"currentPassword": "password123",
+				"newPassword":     "letmein123",
+			})
+			Expect(status).To(Equal(400))
+			Expect(result["fields"]).To(HaveKey("newPassword"))
+
+			// Changing only the case of the own name is fine
+			status, _ = post("/protected/settings/userinfo", token, map[string]any{"name": "Profile"})
+			Expect(status).To(Equal(200))
+		})
+	})
+
+	It("should apply the password policy to resets", func() {
+		status, result := post("/password-reset/confirm", "", map[string]any{"token": "whatever", "new_password": "short"})
+		Expect(status).To(Equal(400))
+		Expect(result["fields"]).To(HaveKeyWithValue("new_password", "must be at least 8 characters"))
+	})
+})
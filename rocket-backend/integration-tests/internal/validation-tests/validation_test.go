@@ -0,0 +1,119 @@
+package validation_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rocket-backend/internal/validation"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	valid := map[string]string{
+		"rocketman":    "rocketman",
+		" Jane.Doe_1 ": "Jane.Doe_1",
+		"abc":          "abc",
+		"a-b":          "a-b",
+	}
+	for input, want := range valid {
+		got, err := validation.NormalizeUsername(input)
+		if err != nil || got != want {
+			t.Errorf("NormalizeUsername(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+
+	invalid := []string{"", "ab", strings.Repeat("a", 33), "two words", "_leading", "trailing.", "dots..here", "émile", "ADMIN", "root"}
+	for _, input := range invalid {
+		if _, err := validation.NormalizeUsername(input); err == nil {
+			t.Errorf("NormalizeUsername(%q) was accepted", input)
+		}
+	}
+}
+
+func TestSanitizeUsername(t *testing.T) {
+	cases := map[string]string{
+		"Jane Doe":              "janedoe",
+		"..Émile--":             "mile",
+		"Admin":                 "",
+		"!!":                    "",
+		strings.Repeat("x", 40): strings.Repeat("x", 28),
+		"a..bbb":                "a.bbb",
+	}
+	for input, want := range cases {
+		if got := validation.SanitizeUsername(input); got != want {
+			t.Errorf("SanitizeUsername(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	got, err := validation.NormalizeEmail("  Jane.Doe@Example.COM ")
+	if err != nil || got != "jane.doe@example.com" {
+		t.Errorf("NormalizeEmail() = %q, %v", got, err)
+	}
+
+	invalid := []string{"", "plain", "@example.com", "jane@", "jane@localhost", "Jane <jane@example.com>", "jane@example.", "a b@example.com"}
+	for _, input := range invalid {
+		if _, err := validation.NormalizeEmail(input); err == nil {
+			t.Errorf("NormalizeEmail(%q) was accepted", input)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := validation.NewPasswordPolicy(8, []string{"Sunshine1"})
+
+	if err := policy.Check("correct horse battery"); err != nil {
+		t.Errorf("strong password refused: %v", err)
+	}
+	refused := map[string][]string{
+		"short":                 nil,
+		strings.Repeat("x", 73): nil,
+		"SUNSHINE1":             nil,
+		"stardust42":            {"Stardust42"},
+		"jane.doe1":             {"rocket", "jane.doe1@example.com"},
+		"jane.doe1@example.com": {"jane.doe1@example.com"},
+	}
+	for password, personal := range refused {
+		if err := policy.Check(password, personal...); err == nil {
+			t.Errorf("Check(%q) was accepted", password)
+		}
+	}
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("# comment\n\nhunter22\n  dragon123  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	passwords, err := validation.LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords() failed: %v", err)
+	}
+	if strings.Join(passwords, ",") != "hunter22,dragon123" {
+		t.Errorf("LoadBreachedPasswords() = %v", passwords)
+	}
+
+	// The shipped list is readable and refuses the usual suspects
+	shipped, err := validation.LoadBreachedPasswords("../../../internal/validation/breached_passwords.txt")
+	if err != nil {
+		t.Fatalf("failed to load the shipped list: %v", err)
+	}
+	if err := validation.NewPasswordPolicy(8, shipped).Check("Password123"); err == nil {
+		t.Error("shipped list does not contain password123")
+	}
+}
+
+func TestBindingErrorsIgnoresOtherErrors(t *testing.T) {
+	if fields := validation.BindingErrors(os.ErrNotExist); fields != nil {
+		t.Errorf("BindingErrors() = %v, want nil", fields)
+	}
+	if err := (validation.FieldErrors{}).Err(); err != nil {
+		t.Errorf("empty FieldErrors.Err() = %v", err)
+	}
+	err := validation.FieldErrors{"name": "is reserved", "email": "is required"}.Err()
+	if err == nil || err.Error() != "invalid email is required, name is reserved" {
+		t.Errorf("FieldErrors.Err() = %v", err)
+	}
+}
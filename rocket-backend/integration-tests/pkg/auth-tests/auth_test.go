@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -24,9 +24,34 @@ var _ = Describe("Auth Service", func() {
 		userID = uuid.New()
 	})
 
+	// craft signs claims with the secret, on top of valid standard claims
+	craft := func(claims jwt.MapClaims) string {
+		now := time.Now()
+		full := jwt.MapClaims{
+			"user_id": userID.String(),
+			"jti":     uuid.New().String(),
+			"iss":     auth.DefaultIssuer,
+			"aud":     auth.DefaultAudience,
+			"iat":     now.Unix(),
+			"exp":     now.Add(time.Hour).Unix(),
+		}
+		for name, value := range claims {
+			if value == nil {
+				delete(full, name)
+			} else {
+				full[name] = value
+			}
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, full)
+		token.Header["kid"] = authService.Keys().SigningKeyID()
+		signed, err := token.SignedString([]byte(jwtSecret))
+		Expect(err).NotTo(HaveOccurred())
+		return signed
+	}
+
 	Describe("GenerateToken", func() {
 		It("should generate a valid token", func() {
-			tokenString, err := authService.GenerateToken(userID)
+			tokenString, err := authService.GenerateToken(userID, auth.TokenClaims{Role: "user"})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(tokenString).NotTo(BeEmpty())
 		})
@@ -34,7 +59,7 @@ var _ = Describe("Auth Service", func() {
 
 	Describe("ParseToken", func() {
 		It("should parse a valid token", func() {
-			tokenString, err := authService.GenerateToken(userID)
+			tokenString, err := authService.GenerateToken(userID, auth.TokenClaims{Role: "user"})
 			Expect(err).NotTo(HaveOccurred())
 
 			token, err := authService.ParseToken(tokenString)
@@ -44,9 +69,56 @@ var _ = Describe("Auth Service", func() {
 		})
 	})
 
+	Describe("StandardClaims", func() {
+		It("should carry the key ID, issuer, audience, issue time and token ID", func() {
+			tokenString, err := authService.GenerateToken(userID, auth.TokenClaims{Role: "user"})
+			Expect(err).NotTo(HaveOccurred())
+
+			token, err := authService.ParseToken(tokenString)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.Header["kid"]).To(Equal(authService.Keys().SigningKeyID()))
+			claims := token.Claims.(jwt.MapClaims)
+			Expect(claims["iss"]).To(Equal(auth.DefaultIssuer))
+			Expect(claims["aud"]).To(Equal(auth.DefaultAudience))
+			Expect(claims["sub"]).To(Equal(userID.String()))
+			Expect(claims).To(HaveKey("iat"))
+			Expect(claims).To(HaveKey("jti"))
+		})
+
+		It("should accept crafted tokens with all standard claims", func() {
+			_, err := authService.ParseToken(craft(nil))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should refuse tokens with wrong or missing standard claims", func() {
+			for _, claims := range []jwt.MapClaims{
+				{"iss": "someone-else"},
+				{"iss": nil},
+				{"aud": "another-app"},
+				{"aud": []string{"another-app"}},
+				{"aud": nil},
+				{"iat": nil},
+				{"iat": time.Now().Add(time.Hour).Unix()},
+				{"jti": nil},
+				{"exp": nil},
+			} {
+				_, err := authService.ParseToken(craft(claims))
+				Expect(err).To(HaveOccurred(), "claims %v", claims)
+			}
+		})
+
+		It("should refuse tokens of unknown keys", func() {
+			other := auth.NewAuthService("othersecret")
+			tokenString, err := other.GenerateToken(userID, auth.TokenClaims{Role: "user"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = authService.ParseToken(tokenString)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("ValidateToken", func() {
 		It("should validate a token and extract the user ID", func() {
-			tokenString, err := authService.GenerateToken(userID)
+			tokenString, err := authService.GenerateToken(userID, auth.TokenClaims{Role: "user"})
 			Expect(err).NotTo(HaveOccurred())
 
 			token, err := authService.ParseToken(tokenString)
@@ -60,6 +132,101 @@ var _ = Describe("Auth Service", func() {
 		})
 	})
 
+	Describe("TokenRole", func() {
+		It("should carry the role in the claims", func() {
+			tokenString, err := authService.GenerateToken(userID, auth.TokenClaims{Role: "admin"})
+			Expect(err).NotTo(HaveOccurred())
+
+			token, err := authService.ParseToken(tokenString)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(authService.TokenRole(token)).To(Equal("admin"))
+		})
+
+		It("should fall back to the user role for tokens without one", func() {
+			token, err := authService.ParseToken(craft(nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(authService.TokenRole(token)).To(Equal("user"))
+		})
+	})
+
+	Describe("Claims", func() {
+		It("should expose the token ID, version, session and expiry", func() {
+			tokenString, err := authService.GenerateToken(userID, auth.TokenClaims{Role: "user", Version: 3, SessionID: "session"})
+			Expect(err).NotTo(HaveOccurred())
+
+			token, err := authService.ParseToken(tokenString)
+			Expect(err).NotTo(HaveOccurred())
+			claims, err := authService.Claims(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(claims.ID).NotTo(BeEmpty())
+			Expect(claims.Version).To(Equal(3))
+			Expect(claims.SessionID).To(Equal("session"))
+			Expect(claims.ExpiresAt).To(BeTemporally("~", time.Now().Add(auth.AccessTokenTTL), time.Second))
+		})
+	})
+
+	Describe("OpaqueToken", func() {
+		It("should generate unique tokens with stable hashes", func() {
+			first, err := auth.GenerateOpaqueToken()
+			Expect(err).NotTo(HaveOccurred())
+			second, err := auth.GenerateOpaqueToken()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first).NotTo(Equal(second))
+			Expect(auth.HashOpaqueToken(first)).To(Equal(auth.HashOpaqueToken(first)))
+			Expect(auth.HashOpaqueToken(first)).NotTo(Equal(auth.HashOpaqueToken(second)))
+		})
+	})
+
+	Describe("PersonalToken", func() {
+		It("should carry the prefix and be unique", func() {
+			first, err := auth.GeneratePersonalToken()
+			Expect(err).NotTo(HaveOccurred())
+			second, _ := auth.GeneratePersonalToken()
+			Expect(first).To(HavePrefix(auth.PersonalTokenPrefix))
+			Expect(first).NotTo(Equal(second))
+		})
+	})
+
+	Describe("VerifyPKCE", func() {
+		// The example of RFC 7636, appendix B
+		verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+		It("should accept the matching verifier", func() {
+			Expect(auth.VerifyPKCE(verifier, challenge)).To(BeTrue())
+		})
+
+		It("should refuse other or too short verifiers", func() {
+			Expect(auth.VerifyPKCE(verifier[1:]+"x", challenge)).To(BeFalse())
+			Expect(auth.VerifyPKCE("short", challenge)).To(BeFalse())
+			Expect(auth.VerifyPKCE(challenge, challenge)).To(BeFalse())
+		})
+	})
+
+	Describe("ChallengeToken", func() {
+		It("should round trip the user", func() {
+			challenge, err := authService.GenerateChallengeToken(userID)
+			Expect(err).NotTo(HaveOccurred())
+			parsed, err := authService.ParseChallengeToken(challenge)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(userID))
+		})
+
+		It("should not be usable as an access token", func() {
+			challenge, _ := authService.GenerateChallengeToken(userID)
+			token, err := authService.ParseToken(challenge)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = authService.Claims(token)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not accept an access token", func() {
+			access, _ := authService.GenerateToken(userID, auth.TokenClaims{Role: "user"})
+			_, err := authService.ParseChallengeToken(access)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("InvalidToken", func() {
 		It("should return an error for an invalid token", func() {
 			invalidTokenString := "invalid.token.string"
@@ -73,13 +240,7 @@ var _ = Describe("Auth Service", func() {
 
 	Describe("ExpiredToken", func() {
 		It("should return an error for an expired token", func() {
-			token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-				"user_id": userID.String(),
-				"exp":     time.Now().Add(time.Second * 1).Unix(),
-			})
-
-			tokenString, err := token.SignedString([]byte(jwtSecret))
-			Expect(err).NotTo(HaveOccurred())
+			tokenString := craft(jwt.MapClaims{"exp": time.Now().Add(time.Second * 1).Unix()})
 
 			time.Sleep(time.Second * 2)
 
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"rocket-backend/pkg/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signing Keys", func() {
+	var (
+		userID     uuid.UUID
+		rsaKey     *rsa.PrivateKey
+		edKey      ed25519.PrivateKey
+		rsaSigning auth.Key
+		edSigning  auth.Key
+	)
+
+	BeforeEach(func() {
+		userID = uuid.New()
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		_, edKey, err = ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		rsaSigning, err = auth.NewKey(rsaKey)
+		Expect(err).NotTo(HaveOccurred())
+		edSigning, err = auth.NewKey(edKey)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	service := func(signing auth.Key, verification ...auth.Key) *auth.AuthService {
+		keys, err := auth.NewKeySet(auth.DefaultIssuer, auth.DefaultAudience, signing, verification...)
+		Expect(err).NotTo(HaveOccurred())
+		return auth.NewAuthServiceWithKeys(keys)
+	}
+
+	roundTrip := func(signer, verifier *auth.AuthService) error {
+		tokenString, err := signer.GenerateToken(userID, auth.TokenClaims{Role: "user"})
+		Expect(err).NotTo(HaveOccurred())
+		token, err := verifier.ParseToken(tokenString)
+		if err != nil {
+			return err
+		}
+		parsed, err := verifier.ValidateToken(token)
+		Expect(parsed).To(Equal(userID))
+		return err
+	}
+
+	It("should sign and verify with RS256 and EdDSA", func() {
+		Expect(rsaSigning.Algorithm).To(Equal(auth.AlgorithmRS256))
+		Expect(edSigning.Algorithm).To(Equal(auth.AlgorithmEdDSA))
+		Expect(roundTrip(service(rsaSigning), service(rsaSigning))).To(Succeed())
+		Expect(roundTrip(service(edSigning), service(edSigning))).To(Succeed())
+	})
+
+	It("should verify with public keys alone", func() {
+		public, err := auth.NewKey(&rsaKey.PublicKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(public.ID).To(Equal(rsaSigning.ID))
+		Expect(public.CanSign()).To(BeFalse())
+
+		// A replica that only knows the public key of the new signing key
+		Expect(roundTrip(service(rsaSigning), service(edSigning, public))).To(Succeed())
+
+		_, err = auth.NewKeySet(auth.DefaultIssuer, auth.DefaultAudience, public)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should keep accepting tokens of rotated keys while they are configured", func() {
+		old := service(rsaSigning)
+		rotated := service(edSigning, rsaSigning)
+		Expect(roundTrip(old, rotated)).To(Succeed())
+		Expect(roundTrip(rotated, rotated)).To(Succeed())
+
+		// Once the old key is removed its tokens are refused
+		Expect(roundTrip(old, service(edSigning))).NotTo(Succeed())
+	})
+
+	It("should refuse HMAC tokens forged with a public key", func() {
+		publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+		Expect(err).NotTo(HaveOccurred())
+		publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": userID.String(),
+			"jti":     uuid.New().String(),
+			"iss":     auth.DefaultIssuer,
+			"aud":     auth.DefaultAudience,
+			"iat":     1,
+			"exp":     4102444800,
+		})
+		forged.Header["kid"] = rsaSigning.ID
+		tokenString, err := forged.SignedString(publicPEM)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = service(rsaSigning).ParseToken(tokenString)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should publish only public keys in the JWKS", func() {
+		hmac, err := auth.NewHMACKey("secret")
+		Expect(err).NotTo(HaveOccurred())
+		keys, err := auth.NewKeySet(auth.DefaultIssuer, auth.DefaultAudience, edSigning, rsaSigning, hmac)
+		Expect(err).NotTo(HaveOccurred())
+
+		jwks := keys.JWKS()
+		Expect(jwks.Keys).To(HaveLen(2))
+		Expect(jwks.Keys[0]).To(HaveKeyWithValue("kid", edSigning.ID))
+		Expect(jwks.Keys[0]).To(HaveKeyWithValue("kty", "OKP"))
+		Expect(jwks.Keys[0]).To(HaveKeyWithValue("alg", "EdDSA"))
+		Expect(jwks.Keys[1]).To(HaveKeyWithValue("kty", "RSA"))
+		Expect(jwks.Keys[1]).To(HaveKeyWithValue("e", "AQAB"))
+		for _, jwk := range jwks.Keys {
+			Expect(jwk).NotTo(HaveKey("d"))
+			Expect(jwk).NotTo(HaveKey("k"))
+		}
+	})
+
+	It("should load keys from PEM files", func() {
+		dir := GinkgoT().TempDir()
+		privateDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+		Expect(err).NotTo(HaveOccurred())
+		privatePath := filepath.Join(dir, "signing.pem")
+		Expect(os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600)).To(Succeed())
+		rsaPath := filepath.Join(dir, "old.pem")
+		Expect(os.WriteFile(rsaPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), 0o600)).To(Succeed())
+
+		loaded, err := auth.LoadKeyFile(privatePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.ID).To(Equal(edSigning.ID))
+		Expect(loaded.CanSign()).To(BeTrue())
+
+		GinkgoT().Setenv("JWT_SIGNING_KEY_FILE", privatePath)
+		GinkgoT().Setenv("JWT_VERIFICATION_KEY_FILES", rsaPath)
+		GinkgoT().Setenv("JWT_VERIFICATION_SECRETS", "previous-secret")
+		GinkgoT().Setenv("JWT_ISSUER", "https://rocket.example.com")
+		keys, err := auth.KeySetFromEnv()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys.SigningKeyID()).To(Equal(edSigning.ID))
+		Expect(keys.Issuer).To(Equal("https://rocket.example.com"))
+		Expect(keys.Audience).To(Equal(auth.DefaultAudience))
+
+		fromEnv := auth.NewAuthServiceWithKeys(keys)
+		Expect(roundTrip(fromEnv, fromEnv)).To(Succeed())
+		previous, err := auth.NewHMACKey("previous-secret")
+		Expect(err).NotTo(HaveOccurred())
+		for _, old := range []auth.Key{rsaSigning, previous} {
+			oldKeys, err := auth.NewKeySet("https://rocket.example.com", auth.DefaultAudience, old)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(roundTrip(auth.NewAuthServiceWithKeys(oldKeys), fromEnv)).To(Succeed())
+		}
+		// Tokens of another issuer are refused even with a known key
+		Expect(roundTrip(service(rsaSigning), fromEnv)).NotTo(Succeed())
+
+		Expect(os.WriteFile(rsaPath, []byte("not a key"), 0o600)).To(Succeed())
+		_, err = auth.KeySetFromEnv()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should refuse weak RSA keys", func() {
+		weak, err := rsa.GenerateKey(rand.Reader, 1024)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = auth.NewKey(weak)
+		Expect(err).To(HaveOccurred())
+	})
+})
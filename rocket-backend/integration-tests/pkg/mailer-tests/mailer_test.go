@@ -0,0 +1,103 @@
+package mailer_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rocket-backend/pkg/mailer"
+)
+
+func TestMemoryMailerLast(t *testing.T) {
+	m := mailer.NewMemoryMailer()
+	m.Send(mailer.Message{To: "a@example.com", Subject: "first"})
+	m.Send(mailer.Message{To: "b@example.com", Subject: "other"})
+	m.Send(mailer.Message{To: "a@example.com", Subject: "second"})
+
+	msg, ok := m.Last("a@example.com")
+	if !ok || msg.Subject != "second" {
+		t.Errorf("Last() = %+v, %v, want the second email", msg, ok)
+	}
+	if _, ok := m.Last("c@example.com"); ok {
+		t.Error("Last() should not find emails for an unknown address")
+	}
+	if len(m.Messages()) != 3 {
+		t.Errorf("Messages() returned %d emails, want 3", len(m.Messages()))
+	}
+
+	m.Reset()
+	if len(m.Messages()) != 0 {
+		t.Error("Reset() should forget all emails")
+	}
+}
+
+func TestMemoryMailerKeepsLatest(t *testing.T) {
+	m := mailer.NewMemoryMailer()
+	for i := range 150 {
+		m.Send(mailer.Message{To: "a@example.com", Subject: fmt.Sprint(i)})
+	}
+
+	messages := m.Messages()
+	if len(messages) != 100 {
+		t.Fatalf("Messages() returned %d emails, want 100", len(messages))
+	}
+	if messages[0].Subject != "50" || messages[99].Subject != "149" {
+		t.Errorf("kept emails %s to %s, want 50 to 149", messages[0].Subject, messages[99].Subject)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := mailer.NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer() failed: %v", err)
+	}
+
+	for range 2 {
+		if err := m.Send(mailer.Message{To: "a@example.com", Subject: "Hello", Body: "line one\nline two"}); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 2 {
+		t.Fatalf("found %d files, want 2", len(files))
+	}
+	raw, _ := os.ReadFile(files[0])
+	content := string(raw)
+	for _, want := range []string{"To: a@example.com\r\n", "Subject: Hello\r\n", "\r\n\r\nline one\r\nline two"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("email %q does not contain %q", content, want)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("MAILER", "")
+	if m, err := mailer.FromEnv(); err != nil {
+		t.Errorf("FromEnv() failed: %v", err)
+	} else if _, ok := m.(*mailer.MemoryMailer); !ok {
+		t.Errorf("FromEnv() = %T, want the memory mailer by default", m)
+	}
+
+	t.Setenv("MAILER", "file")
+	t.Setenv("MAIL_DIR", t.TempDir())
+	if m, err := mailer.FromEnv(); err != nil {
+		t.Errorf("FromEnv() failed: %v", err)
+	} else if _, ok := m.(*mailer.FileMailer); !ok {
+		t.Errorf("FromEnv() = %T, want the file mailer", m)
+	}
+
+	t.Setenv("MAILER", "smtp")
+	t.Setenv("SMTP_PORT", "not a port")
+	if _, err := mailer.FromEnv(); err == nil {
+		t.Error("FromEnv() should fail for an invalid SMTP port")
+	}
+
+	t.Setenv("MAILER", "pigeon")
+	if _, err := mailer.FromEnv(); err == nil {
+		t.Error("FromEnv() should fail for an unknown mailer")
+	}
+}
@@ -0,0 +1,142 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"rocket-backend/integration-tests/mocks/oidcmock"
+	"rocket-backend/pkg/oidc"
+)
+
+const redirectURL = "http://app.example.com/callback"
+
+var alice = &oidcmock.User{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+func newProvider(mock *oidcmock.Provider, secret string) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		Issuer:       mock.Issuer(),
+		ClientID:     mock.ClientID,
+		ClientSecret: secret,
+		RedirectURL:  redirectURL,
+	})
+}
+
+// authorize follows the login to the provider and returns the query it redirects back with.
+func authorize(t *testing.T, provider *oidc.Provider, state, nonce, verifier string) url.Values {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() failed: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Scheme+"://"+location.Host+location.Path != redirectURL {
+		t.Fatalf("unexpected redirect to %q", resp.Header.Get("Location"))
+	}
+	if location.Query().Get("state") != state {
+		t.Errorf("state %q came back as %q", state, location.Query().Get("state"))
+	}
+	return location.Query()
+}
+
+func TestLogin(t *testing.T) {
+	mock := oidcmock.New("client", "secret")
+	defer mock.Close()
+	mock.SetUser(alice)
+	provider := newProvider(mock, "secret")
+
+	verifier, _ := oidc.NewCodeVerifier()
+	nonce, _ := oidc.NewNonce()
+	result := authorize(t, provider, "state-1", nonce, verifier)
+
+	identity, err := provider.Exchange(context.Background(), result.Get("code"), verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+	want := oidc.Identity{Subject: alice.Subject, Email: alice.Email, EmailVerified: true, Name: alice.Name}
+	if identity != want {
+		t.Errorf("Exchange() = %+v, want %+v", identity, want)
+	}
+
+	// Codes are single-use
+	if _, err := provider.Exchange(context.Background(), result.Get("code"), verifier, nonce); err == nil {
+		t.Error("Exchange() should refuse a used code")
+	}
+}
+
+func TestExchangeRejectsWrongVerifierAndNonce(t *testing.T) {
+	mock := oidcmock.New("client", "secret")
+	defer mock.Close()
+	mock.SetUser(alice)
+	provider := newProvider(mock, "secret")
+
+	verifier, _ := oidc.NewCodeVerifier()
+	otherVerifier, _ := oidc.NewCodeVerifier()
+	result := authorize(t, provider, "state-1", "nonce", verifier)
+	if _, err := provider.Exchange(context.Background(), result.Get("code"), otherVerifier, "nonce"); !errors.Is(err, oidc.ErrInvalidIdentity) {
+		t.Errorf("Exchange() with the wrong verifier = %v, want ErrInvalidIdentity", err)
+	}
+
+	result = authorize(t, provider, "state-2", "nonce", verifier)
+	if _, err := provider.Exchange(context.Background(), result.Get("code"), verifier, "other nonce"); !errors.Is(err, oidc.ErrInvalidIdentity) {
+		t.Errorf("Exchange() with the wrong nonce = %v, want ErrInvalidIdentity", err)
+	}
+}
+
+func TestExchangeRejectsWrongClientSecret(t *testing.T) {
+	mock := oidcmock.New("client", "secret")
+	defer mock.Close()
+	mock.SetUser(alice)
+	provider := newProvider(mock, "not the secret")
+
+	verifier, _ := oidc.NewCodeVerifier()
+	result := authorize(t, provider, "state", "nonce", verifier)
+	if _, err := provider.Exchange(context.Background(), result.Get("code"), verifier, "nonce"); err == nil {
+		t.Error("Exchange() should fail for a client with the wrong secret")
+	}
+}
+
+func TestDeniedLogin(t *testing.T) {
+	mock := oidcmock.New("client", "secret")
+	defer mock.Close()
+	provider := newProvider(mock, "secret")
+
+	result := authorize(t, provider, "state", "nonce", "verifier")
+	if result.Get("error") != "access_denied" || result.Get("code") != "" {
+		t.Errorf("unexpected result %v", result)
+	}
+}
+
+func TestProvidersFromEnv(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "google, github")
+	t.Setenv("OIDC_REDIRECT_BASE_URL", "http://localhost/api/v1/oidc/")
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "google-client")
+	t.Setenv("OIDC_GITHUB_CLIENT_ID", "github-client")
+
+	providers, err := oidc.ProvidersFromEnv()
+	if err != nil {
+		t.Fatalf("ProvidersFromEnv() failed: %v", err)
+	}
+	if len(providers) != 2 || providers[0].Name() != "google" || providers[1].Name() != "github" {
+		t.Errorf("unexpected providers %v", providers)
+	}
+
+	t.Setenv("OIDC_PROVIDERS", "keycloak")
+	t.Setenv("OIDC_KEYCLOAK_CLIENT_ID", "client")
+	if _, err := oidc.ProvidersFromEnv(); err == nil {
+		t.Error("ProvidersFromEnv() should require the issuer of unknown providers")
+	}
+}
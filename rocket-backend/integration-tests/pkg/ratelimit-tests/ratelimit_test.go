@@ -0,0 +1,115 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"rocket-backend/pkg/ratelimit"
+)
+
+var policy = ratelimit.Policy{Name: "test", Limit: 1, Period: time.Second, Burst: 3}
+
+func newStore(now *time.Time) *ratelimit.MemoryStore {
+	store := ratelimit.NewMemoryStore()
+	store.Now = func() time.Time { return *now }
+	return store
+}
+
+func TestBurstThenRetryAfter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := ratelimit.New(newStore(&now))
+
+	for i := range policy.Burst {
+		result, err := limiter.Allow(policy, "client")
+		if err != nil || !result.Allowed {
+			t.Fatalf("request %d was refused: %+v, %v", i, result, err)
+		}
+		if result.Remaining != policy.Burst-i-1 {
+			t.Errorf("Remaining = %d, want %d", result.Remaining, policy.Burst-i-1)
+		}
+	}
+
+	result, _ := limiter.Allow(policy, "client")
+	if result.Allowed {
+		t.Fatal("request beyond the burst was allowed")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", result.RetryAfter)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := ratelimit.New(newStore(&now))
+	for range policy.Burst {
+		limiter.Allow(policy, "client")
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if result, _ := limiter.Allow(policy, "client"); !result.Allowed {
+		t.Fatal("refilled token was refused")
+	}
+	result, _ := limiter.Allow(policy, "client")
+	if result.Allowed {
+		t.Fatal("only one token should have refilled")
+	}
+	if result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 500ms", result.RetryAfter)
+	}
+
+	// Never more than the burst, however long the client was away
+	now = now.Add(time.Hour)
+	for range policy.Burst {
+		limiter.Allow(policy, "client")
+	}
+	if result, _ := limiter.Allow(policy, "client"); result.Allowed {
+		t.Fatal("bucket refilled beyond the burst")
+	}
+}
+
+func TestKeysAndPoliciesAreSeparate(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := ratelimit.New(newStore(&now))
+	for range policy.Burst {
+		limiter.Allow(policy, "client")
+	}
+
+	if result, _ := limiter.Allow(policy, "other"); !result.Allowed {
+		t.Error("another client was limited")
+	}
+	other := policy
+	other.Name = "other"
+	if result, _ := limiter.Allow(other, "client"); !result.Allowed {
+		t.Error("another policy was limited")
+	}
+}
+
+func TestStoreFunc(t *testing.T) {
+	var gotKey string
+	limiter := ratelimit.New(ratelimit.StoreFunc(func(key string, policy ratelimit.Policy) (ratelimit.Result, error) {
+		gotKey = key
+		return ratelimit.Result{Allowed: true}, nil
+	}))
+	limiter.Allow(policy, "client")
+	if gotKey != "test:client" {
+		t.Errorf("key = %q, want test:client", gotKey)
+	}
+}
+
+func TestLockoutBacksOffExponentially(t *testing.T) {
+	lockout := ratelimit.Lockout{Threshold: 5, Base: time.Minute, Max: time.Hour}
+	want := map[int]time.Duration{
+		1:  0,
+		4:  0,
+		5:  time.Minute,
+		6:  2 * time.Minute,
+		8:  8 * time.Minute,
+		11: time.Hour,
+		50: time.Hour,
+	}
+	for failures, expected := range want {
+		if got := lockout.Duration(failures); got != expected {
+			t.Errorf("Duration(%d) = %v, want %v", failures, got, expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package totp_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"rocket-backend/pkg/totp"
+)
+
+// Base32 of the ASCII secret "12345678901234567890" from the RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFCVectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		got, err := totp.Code(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("Code() at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidateAllowsClockDrift(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() failed: %v", err)
+	}
+	now := time.Now()
+	step := totp.Step(now)
+
+	for offset, accepted := range map[int64]bool{-2: false, -1: true, 0: true, 1: true, 2: false} {
+		code, _ := totp.Code(secret, step+offset)
+		got, ok := totp.Validate(secret, code, now)
+		if ok != accepted {
+			t.Errorf("Validate() for offset %d = %v, want %v", offset, ok, accepted)
+		}
+		if ok && got != step+offset {
+			t.Errorf("Validate() for offset %d returned step %d, want %d", offset, got, step+offset)
+		}
+	}
+
+	if _, ok := totp.Validate(secret, "12345", now); ok {
+		t.Error("Validate() should refuse codes of the wrong length")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("ABC", "Rocket", "a@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Rocket:a@example.com?") {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	for _, want := range []string{"secret=ABC", "issuer=Rocket", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("URI %s does not contain %s", uri, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() failed: %v", err)
+	}
+	if len(codes) != totp.RecoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), totp.RecoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[2-9a-z]{5}-[2-9a-z]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q has the wrong format", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q is not unique", code)
+		}
+		seen[code] = true
+		if totp.NormalizeRecoveryCode(" "+strings.ToUpper(strings.Replace(code, "-", "", 1))+" ") != code {
+			t.Errorf("NormalizeRecoveryCode() does not restore %q", code)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package challenges
+
+import (
+	"fmt"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// Number of "challenge of the day" slots per day
+const MaxFeaturedSlots = 2
+
+// CatalogChallengeFromDTO builds and validates a catalog entry from admin input.
+func CatalogChallengeFromDTO(id string, dto types.CatalogChallengeDTO) (types.CatalogChallenge, error) {
+	challenge := types.CatalogChallenge{
+		Challenge: types.Challenge{
+			ID:           id,
+			Text:         dto.Text,
+			Points:       dto.Points,
+			Category:     dto.Category,
+			Difficulty:   dto.Difficulty,
+			Verification: dto.Verification,
+			Parameters:   dto.Parameters,
+		},
+		Active: true,
+	}
+	if dto.Active != nil {
+		challenge.Active = *dto.Active
+	}
+
+	if err := ValidateChallenge(challenge.Challenge); err != nil {
+		return challenge, fmt.Errorf("%w: %v", custom_error.ErrInvalidChallenge, err)
+	}
+
+	var err error
+	if challenge.AvailableFrom, err = parseDate(dto.AvailableFrom); err != nil {
+		return challenge, err
+	}
+	if challenge.AvailableUntil, err = parseDate(dto.AvailableUntil); err != nil {
+		return challenge, err
+	}
+	if challenge.AvailableFrom != nil && challenge.AvailableUntil != nil && challenge.AvailableUntil.Before(*challenge.AvailableFrom) {
+		return challenge, fmt.Errorf("%w: available_until is before available_from", custom_error.ErrInvalidChallenge)
+	}
+
+	return challenge, nil
+}
+
+func parseDate(value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", *value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrInvalidChallenge, err)
+	}
+	return &date, nil
+}
+
+func (cm *ChallengeManager) CreateCatalogChallenge(dto types.CatalogChallengeDTO, actor string) (*types.CatalogChallenge, error) {
+	challenge, err := CatalogChallengeFromDTO(uuid.New().String(), dto)
+	if err != nil {
+		return nil, err
+	}
+	if err := cm.db.SaveCatalogChallenge(challenge, true, actor); err != nil {
+		return nil, err
+	}
+	challenge.ManagedByAdmin = true
+	return &challenge, nil
+}
+
+func (cm *ChallengeManager) UpdateCatalogChallenge(challengeID uuid.UUID, dto types.CatalogChallengeDTO, actor string) (*types.CatalogChallenge, error) {
+	challenge, err := CatalogChallengeFromDTO(challengeID.String(), dto)
+	if err != nil {
+		return nil, err
+	}
+	if err := cm.db.SaveCatalogChallenge(challenge, false, actor); err != nil {
+		return nil, err
+	}
+	challenge.ManagedByAdmin = true
+	return &challenge, nil
+}
+
+// Feature makes an active challenge a "challenge of the day" for the given date.
+func (cm *ChallengeManager) Feature(date time.Time, slot int, challengeID uuid.UUID, actor string) error {
+	if slot < 1 || slot > MaxFeaturedSlots {
+		return fmt.Errorf("%w: slot must be between 1 and %d", custom_error.ErrInvalidChallenge, MaxFeaturedSlots)
+	}
+
+	catalog, err := cm.db.GetChallengeCatalog()
+	if err != nil {
+		return err
+	}
+	for _, challenge := range catalog {
+		if challenge.ID != challengeID.String() {
+			continue
+		}
+		if !challenge.Active {
+			return fmt.Errorf("%w: retired challenges cannot be featured", custom_error.ErrInvalidChallenge)
+		}
+		return cm.db.SetFeaturedChallenge(date, slot, challenge.ID, actor)
+	}
+
+	return custom_error.ErrChallengeNotFound
+}
+
+// Featured returns the featured challenges of a single day.
+func (cm *ChallengeManager) Featured(date time.Time) ([]types.Challenge, error) {
+	featured, err := cm.db.GetFeaturedChallenges(date, date)
+	if err != nil {
+		return nil, err
+	}
+	challenges := make([]types.Challenge, 0, len(featured))
+	for _, entry := range featured {
+		challenges = append(challenges, entry.Challenge)
+	}
+	return challenges, nil
+}
@@ -0,0 +1,105 @@
+package challenges
+
+import (
+	"fmt"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// Invite asks a friend to take on one of the inviter's challenges for today.
+// The friend only gets the challenge once they accept.
+func (cm *ChallengeManager) Invite(inviterID, friendID, challengeID uuid.UUID) (*types.ChallengeInvitation, error) {
+	if inviterID == friendID {
+		return nil, custom_error.ErrNotFriends
+	}
+
+	isFriend, err := cm.db.IsFriend(inviterID, friendID)
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, custom_error.ErrNotFriends
+	}
+
+	blocked, err := cm.db.IsBlocked(friendID, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, custom_error.ErrBlocked
+	}
+
+	// Only challenges the inviter got today can be shared
+	owns, err := cm.db.HasChallengeToday(inviterID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if !owns {
+		return nil, custom_error.ErrChallengeNotFound
+	}
+
+	assigned, err := cm.db.HasChallengeToday(friendID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if assigned {
+		return nil, custom_error.ErrAlreadyAssigned
+	}
+
+	id, err := cm.db.CreateChallengeInvitation(inviterID, friendID, challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cm.db.GetChallengeInvitationByID(id)
+}
+
+// Invitations returns the user's incoming or outgoing invitations with expired ones marked as such.
+func (cm *ChallengeManager) Invitations(userID uuid.UUID, incoming bool) ([]types.ChallengeInvitation, error) {
+	if err := cm.db.ExpireChallengeInvitations(); err != nil {
+		return nil, err
+	}
+	return cm.db.GetChallengeInvitations(userID, incoming)
+}
+
+// RespondToInvitation accepts or declines an invitation addressed to the user.
+func (cm *ChallengeManager) RespondToInvitation(userID, invitationID uuid.UUID, accept bool) error {
+	if err := cm.db.ExpireChallengeInvitations(); err != nil {
+		return err
+	}
+
+	invitation, err := cm.db.GetChallengeInvitationByID(invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation.InviteeID != userID {
+		return custom_error.ErrInvitationNotFound
+	}
+	if invitation.Status != types.InvitationPending {
+		return fmt.Errorf("%w: invitation is %s", custom_error.ErrInvitationNotPending, invitation.Status)
+	}
+
+	if !accept {
+		return cm.db.DeclineChallengeInvitation(invitationID, userID)
+	}
+
+	// Hand out today's dailies first, otherwise the accepted challenge would count as them
+	isNewDay, err := cm.db.IsNewDayForUser(userID)
+	if err != nil {
+		return err
+	}
+	if isNewDay {
+		if _, err := cm.GetDailies(userID); err != nil {
+			return err
+		}
+	}
+	if err := cm.db.AcceptChallengeInvitation(invitationID, userID); err != nil {
+		return err
+	}
+
+	_ = cm.db.SaveActivity(userID, fmt.Sprintf("🤝 Accepted %s's challenge: %s", invitation.InviterName, invitation.ChallengeText))
+	return nil
+}
@@ -4,34 +4,95 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"sync"
 
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
 )
 
 var (
-	once    sync.Once
-	cached  []types.Challenge
-	loadErr error
+	validCategories = map[string]bool{
+		types.CategorySteps:       true,
+		types.CategoryRunning:     true,
+		types.CategoryStrength:    true,
+		types.CategoryMindfulness: true,
+		types.CategoryNutrition:   true,
+	}
+	validDifficulties = map[string]bool{
+		types.DifficultyEasy:   true,
+		types.DifficultyMedium: true,
+		types.DifficultyHard:   true,
+	}
+	validVerifications = map[string]bool{
+		types.VerificationSteps:       true,
+		types.VerificationRunDistance: true,
+		types.VerificationSelfReport:  true,
+	}
 )
 
+// LoadChallengesFromFile reads the challenge catalog and validates every entry.
 func LoadChallengesFromFile(path string) ([]types.Challenge, error) {
-	once.Do(func() {
-		var file *os.File
-		file, loadErr = os.Open(path)
-		if loadErr != nil {
-			loadErr = fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, loadErr)
-			return
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer file.Close()
+
+	var challenges []types.Challenge
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&challenges); err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+
+	seen := make(map[string]bool, len(challenges))
+	for i, challenge := range challenges {
+		if err := ValidateChallenge(challenge); err != nil {
+			return nil, fmt.Errorf("%w: challenge #%d (%s): %v", custom_error.ErrFailedToLoad, i, challenge.ID, err)
+		}
+		if seen[challenge.ID] {
+			return nil, fmt.Errorf("%w: challenge #%d: duplicate id %s", custom_error.ErrFailedToLoad, i, challenge.ID)
 		}
-		defer file.Close()
+		seen[challenge.ID] = true
+	}
 
-		decoder := json.NewDecoder(file)
-		loadErr = decoder.Decode(&cached)
-		if loadErr != nil {
-			loadErr = fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, loadErr)
+	return challenges, nil
+}
+
+// ValidateChallenge checks a single challenge definition for completeness and consistency.
+func ValidateChallenge(challenge types.Challenge) error {
+	if _, err := uuid.Parse(challenge.ID); err != nil {
+		return fmt.Errorf("invalid id: %v", err)
+	}
+	if challenge.Text == "" {
+		return fmt.Errorf("text is required")
+	}
+	if challenge.Points <= 0 {
+		return fmt.Errorf("points must be greater than 0")
+	}
+	if !validCategories[challenge.Category] {
+		return fmt.Errorf("unknown category %q", challenge.Category)
+	}
+	if !validDifficulties[challenge.Difficulty] {
+		return fmt.Errorf("unknown difficulty %q", challenge.Difficulty)
+	}
+	if !validVerifications[challenge.Verification] {
+		return fmt.Errorf("unknown verification %q", challenge.Verification)
+	}
+
+	// Automatically verified challenges need something to compare against
+	if challenge.Verification != types.VerificationSelfReport && challenge.Parameters == nil {
+		return fmt.Errorf("verification %q requires parameters", challenge.Verification)
+	}
+	if challenge.Parameters != nil {
+		if challenge.Parameters.Target <= 0 {
+			return fmt.Errorf("parameters.target must be greater than 0")
+		}
+		if challenge.Parameters.Unit == "" {
+			return fmt.Errorf("parameters.unit is required")
 		}
-	})
+	}
 
-	return cached, loadErr
+	return nil
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
+
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/database"
 	"rocket-backend/internal/types"
@@ -12,12 +15,23 @@ import (
 	"github.com/google/uuid"
 )
 
+// Number of challenges every user gets per day
+const DailyChallengeCount = 5
+
+// Days of step data used to judge a user's current activity level
+const activityWindowDays = 7
+
 type ChallengeManager struct {
-	db database.Service
+	db       database.Service
+	strategy SelectionStrategy
 }
 
 func NewChallengeManager(db database.Service) *ChallengeManager {
-	return &ChallengeManager{db: db}
+	return NewChallengeManagerWithStrategy(db, PersonalizedStrategy{AvoidRepeatDays: 3})
+}
+
+func NewChallengeManagerWithStrategy(db database.Service, strategy SelectionStrategy) *ChallengeManager {
+	return &ChallengeManager{db: db, strategy: strategy}
 }
 
 func (cm *ChallengeManager) GetDailies(userID uuid.UUID) ([]types.Challenge, error) {
@@ -28,7 +42,8 @@ func (cm *ChallengeManager) GetDailies(userID uuid.UUID) ([]types.Challenge, err
         return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
     }
 
-    // Check if it's a new day and clean up old challenges
+    // The daily rollover job assigns the challenges right after midnight. Users
+    // that registered later or were skipped by a failed run get them here.
     isNewDay, err := cm.db.IsNewDayForUser(userID)
     if err != nil {
         logger.Error("Failed to check if it's a new day for the user", err)
@@ -36,27 +51,42 @@ func (cm *ChallengeManager) GetDailies(userID uuid.UUID) ([]types.Challenge, err
     }
 
     if isNewDay {
-        // Clean up old challenges for the user
-        err := cm.db.CleanUpChallengesForUser(userID)
-        if err != nil {
-            logger.Error("Failed to clean up old challenges for the user", err)
-            return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
-        }
-
         // Assign new challenges for the user
         allChallenges, err := cm.db.GetAllChallenges()
         if err != nil {
             return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
         }
 
-        if len(allChallenges) < 5 {
+        if len(allChallenges) < DailyChallengeCount {
             logger.Warn("Less than 5 challenges available, returning all")
-            return nil, fmt.Errorf("%w: not enough challenges available: got %d, need at least 5", custom_error.ErrChallengeNotFound, len(allChallenges))
+            return nil, fmt.Errorf("%w: not enough challenges available: got %d, need at least %d", custom_error.ErrChallengeNotFound, len(allChallenges), DailyChallengeCount)
+        }
+
+        input, err := cm.selectionInput(userID)
+        if err != nil {
+            return nil, err
         }
 
-        // Shuffle and pick 5 random challenges
-        shuffledChallenges := database.ShuffleChallenges(allChallenges)
-        dailyChallenges := shuffledChallenges[:5]
+        // Everybody gets the featured challenges, the strategy fills the remaining slots
+        dailyChallenges, err := cm.Featured(input.Date)
+        if err != nil {
+            return nil, err
+        }
+        featured := make(map[string]bool, len(dailyChallenges))
+        for _, challenge := range dailyChallenges {
+            featured[challenge.ID] = true
+        }
+        candidates := make([]types.Challenge, 0, len(allChallenges))
+        for _, challenge := range allChallenges {
+            if !featured[challenge.ID] {
+                candidates = append(candidates, challenge)
+            }
+        }
+        count, err := cm.dailyChallengeCount(userID)
+        if err != nil {
+            return nil, err
+        }
+        dailyChallenges = append(dailyChallenges, cm.strategy.Select(input, candidates, count-len(dailyChallenges))...)
 
         err = cm.db.AssignChallengesToUser(userID, dailyChallenges)
         if err != nil {
@@ -76,14 +106,65 @@ func (cm *ChallengeManager) GetDailies(userID uuid.UUID) ([]types.Challenge, err
     return dailies, nil
 }
 
-func (cm *ChallengeManager) ensureChallengesLoaded() error {
-	existingChallenges, err := cm.db.GetAllChallenges()
+// dailyChallengeCount is how many challenges the user gets a day, one more for every
+// extra challenge slot bought in the shop.
+func (cm *ChallengeManager) dailyChallengeCount(userID uuid.UUID) (int, error) {
+	inventory, err := cm.db.GetInventory(userID)
 	if err != nil {
-		logger.Error("Failed to fetch challenges from database", err)
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+		return 0, err
 	}
+	count := DailyChallengeCount
+	for _, owned := range inventory {
+		if owned.Item.Category == types.ItemCategoryChallengeSlot {
+			count += owned.Quantity
+		}
+	}
+	return count, nil
+}
+
+// selectionInput collects the recent activity the selection strategy works with.
+func (cm *ChallengeManager) selectionInput(userID uuid.UUID) (SelectionInput, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	input := SelectionInput{UserID: userID, Date: today}
+
+	settings, err := cm.db.GetSettingsByUserID(userID)
+	if err != nil {
+		return input, err
+	}
+	input.StepGoal = settings.StepGoal
+
+	// Only finished days count, today's steps are still growing
+	steps, err := cm.db.GetStepsInRange(userID, today.AddDate(0, 0, -activityWindowDays), today.AddDate(0, 0, -1))
+	if err != nil {
+		return input, err
+	}
+	total := 0
+	for _, daySteps := range steps {
+		total += daySteps
+	}
+	input.AverageSteps = float64(total) / activityWindowDays
+
+	input.History, err = cm.db.GetChallengeHistory(userID, today.AddDate(0, 0, -database.ChallengeHistoryDays))
+	if err != nil {
+		return input, err
+	}
+
+	return input, nil
+}
+
+var (
+	syncMu sync.Mutex
+	synced bool
+)
+
+// ensureChallengesLoaded syncs challenges.json into the database once per process.
+// Entries are upserted, so edits to the file reach existing databases as well.
+func (cm *ChallengeManager) ensureChallengesLoaded() error {
+	syncMu.Lock()
+	defer syncMu.Unlock()
 
-	if len(existingChallenges) > 0 {
+	if synced {
 		return nil
 	}
 
@@ -101,13 +182,14 @@ func (cm *ChallengeManager) ensureChallengesLoaded() error {
 	}
 
 	for _, challenge := range challenges {
-		err := cm.db.InsertChallenge(challenge)
+		err := cm.db.UpsertChallenge(challenge)
 		if err != nil {
-			logger.Error("Failed to insert challenge into database", err)
+			logger.Error("Failed to upsert challenge into database", err)
 			return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
 		}
 	}
 
-	logger.Info("Challenges successfully loaded into the database.")
+	synced = true
+	logger.Info("Challenges successfully synced into the database.")
 	return nil
 }
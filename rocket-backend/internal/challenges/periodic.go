@@ -0,0 +1,176 @@
+package challenges
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// PeriodWindow returns the first and last day of the week (Monday to Sunday)
+// or month that contains date.
+func PeriodWindow(period string, date time.Time) (time.Time, time.Time) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	switch period {
+	case types.PeriodMonthly:
+		start := day.AddDate(0, 0, 1-day.Day())
+		return start, start.AddDate(0, 1, -1)
+	default:
+		// time.Weekday starts on Sunday, weeks here start on Monday
+		offset := (int(day.Weekday()) + 6) % 7
+		start := day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 6)
+	}
+}
+
+// GetPeriodicProgress returns the user's current weekly and monthly challenges with
+// the progress recorded by the last step or run upload. Challenges the user was not
+// enrolled into yet are listed without progress. Nothing is written.
+func (cm *ChallengeManager) GetPeriodicProgress(userID uuid.UUID) ([]types.PeriodicChallengeProgress, error) {
+	now := time.Now()
+
+	current, err := cm.db.GetUserPeriodicChallenges(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	enrolled := make(map[string]bool, len(current))
+	for _, entry := range current {
+		enrolled[entry.ID] = true
+	}
+
+	active, err := cm.db.GetActivePeriodicChallenges()
+	if err != nil {
+		return nil, err
+	}
+	for _, challenge := range active {
+		if enrolled[challenge.ID] {
+			continue
+		}
+		start, end := PeriodWindow(challenge.Period, now)
+		current = append(current, types.PeriodicChallengeProgress{
+			PeriodicChallenge: challenge,
+			PeriodStart:       start,
+			PeriodEnd:         end,
+		})
+	}
+
+	sort.SliceStable(current, func(i, j int) bool {
+		if !current[i].PeriodEnd.Equal(current[j].PeriodEnd) {
+			return current[i].PeriodEnd.Before(current[j].PeriodEnd)
+		}
+		return current[i].Text < current[j].Text
+	})
+	return current, nil
+}
+
+// UpdatePeriodicProgress enrolls the user into the current week's and month's
+// challenges, recalculates the progress from the recorded steps and runs and
+// awards the points for every challenge that reached its target.
+func (cm *ChallengeManager) UpdatePeriodicProgress(userID uuid.UUID) ([]types.PeriodicChallengeProgress, error) {
+	now := time.Now()
+
+	active, err := cm.db.GetActivePeriodicChallenges()
+	if err != nil {
+		return nil, err
+	}
+	for _, challenge := range active {
+		start, end := PeriodWindow(challenge.Period, now)
+		if err := cm.db.EnrollPeriodicChallenge(userID, challenge.ID, start, end); err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := cm.db.GetUserPeriodicChallenges(userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range current {
+		entry := &current[i]
+		if entry.Completed {
+			continue
+		}
+
+		progress, err := cm.measure(userID, entry, now)
+		if err != nil {
+			return nil, err
+		}
+		entry.Progress = progress
+
+		if err := cm.db.UpdatePeriodicProgress(userID, entry.ID, entry.PeriodStart, progress); err != nil {
+			return nil, err
+		}
+		if progress < entry.Parameters.Target {
+			continue
+		}
+
+		if _, err := cm.db.CompletePeriodicChallenge(userID, entry.ID, entry.PeriodStart); err != nil {
+			// A concurrent update already handed out the reward
+			if errors.Is(err, custom_error.ErrAlreadyCompleted) {
+				entry.Completed = true
+				continue
+			}
+			return nil, err
+		}
+		completedAt := time.Now()
+		entry.Completed = true
+		entry.CompletedAt = &completedAt
+
+		message := fmt.Sprintf("🏆 Completed the %s challenge: %s", entry.Period, entry.Text)
+		_ = cm.db.SaveActivity(userID, message)
+	}
+
+	return current, nil
+}
+
+// measure calculates the progress of a periodic challenge from the window start up to today.
+func (cm *ChallengeManager) measure(userID uuid.UUID, entry *types.PeriodicChallengeProgress, now time.Time) (float64, error) {
+	to := entry.PeriodEnd
+	if now.Before(to) {
+		to = now
+	}
+
+	switch entry.Metric {
+	case types.MetricRunDistance:
+		distance, err := cm.db.GetRunDistanceInRange(userID, entry.PeriodStart, to)
+		if err != nil {
+			logger.Error("Failed to sum up runs for periodic challenge", err)
+			return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+		}
+		return distance, nil
+
+	case types.MetricSteps, types.MetricStepGoalDays:
+		steps, err := cm.db.GetStepsInRange(userID, entry.PeriodStart, to)
+		if err != nil {
+			return 0, err
+		}
+		if entry.Metric == types.MetricSteps {
+			total := 0
+			for _, daySteps := range steps {
+				total += daySteps
+			}
+			return float64(total), nil
+		}
+
+		settings, err := cm.db.GetSettingsByUserID(userID)
+		if err != nil {
+			return 0, err
+		}
+		days := 0
+		for _, daySteps := range steps {
+			if daySteps >= settings.StepGoal {
+				days++
+			}
+		}
+		return float64(days), nil
+
+	default:
+		return 0, fmt.Errorf("%w: unknown metric %q", custom_error.ErrFailedToRetrieveData, entry.Metric)
+	}
+}
@@ -0,0 +1,234 @@
+package challenges
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"time"
+
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// SelectionInput is everything a strategy may use to pick a user's dailies.
+type SelectionInput struct {
+	UserID       uuid.UUID
+	Date         time.Time
+	AverageSteps float64
+	StepGoal     int
+	History      []types.ChallengeHistoryEntry
+}
+
+// SelectionStrategy picks count challenges out of the candidates.
+// Implementations must be deterministic for the same input.
+type SelectionStrategy interface {
+	Select(input SelectionInput, candidates []types.Challenge, count int) []types.Challenge
+}
+
+// NewRand returns a random source seeded by user and day, so the same user
+// gets the same result for the same day.
+func NewRand(userID uuid.UUID, date time.Time) *rand.Rand {
+	h := fnv.New64a()
+	h.Write(userID[:])
+	h.Write([]byte(date.Format("2006-01-02")))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// RandomStrategy picks uniformly at random, the selection used before personalization.
+type RandomStrategy struct{}
+
+func (RandomStrategy) Select(input SelectionInput, candidates []types.Challenge, count int) []types.Challenge {
+	shuffled := sortedByID(candidates)
+	rng := NewRand(input.UserID, input.Date)
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	if count > len(shuffled) {
+		count = len(shuffled)
+	}
+	return shuffled[:count]
+}
+
+// PersonalizedStrategy spreads the dailies over the categories, matches the
+// difficulty to how active and successful the user has recently been and
+// avoids challenges the user already got in the last AvoidRepeatDays days.
+type PersonalizedStrategy struct {
+	AvoidRepeatDays int
+}
+
+const (
+	// Weight multiplier for every further pick of an already picked category
+	sameCategoryPenalty = 0.3
+	// Step based challenges far above the user's usual steps are unlikely to be completed
+	unreachableTargetFactor = 1.5
+	unreachableTargetWeight = 0.3
+)
+
+func (p PersonalizedStrategy) Select(input SelectionInput, candidates []types.Challenge, count int) []types.Challenge {
+	pool := p.withoutRecent(input, sortedByID(candidates), count)
+	if count > len(pool) {
+		count = len(pool)
+	}
+
+	preferred := preferredDifficulty(input)
+	categoryRates := completionRates(input.History)
+
+	weights := make([]float64, len(pool))
+	for i, challenge := range pool {
+		weights[i] = difficultyWeight(preferred, challenge.Difficulty) * categoryWeight(categoryRates, challenge.Category)
+		if challenge.Verification == types.VerificationSteps && challenge.Parameters != nil && input.AverageSteps > 0 &&
+			challenge.Parameters.Target > input.AverageSteps*unreachableTargetFactor {
+			weights[i] *= unreachableTargetWeight
+		}
+	}
+
+	rng := NewRand(input.UserID, input.Date)
+	selected := make([]types.Challenge, 0, count)
+	for len(selected) < count {
+		i := weightedIndex(rng, weights)
+		selected = append(selected, pool[i])
+		picked := pool[i].Category
+		weights[i] = 0
+		for j := range pool {
+			if pool[j].Category == picked {
+				weights[j] *= sameCategoryPenalty
+			}
+		}
+	}
+
+	return selected
+}
+
+// withoutRecent drops challenges seen in the look-back window, unless that leaves too few.
+// History dates come from the database at UTC midnight while the input date is local, so
+// both are compared as calendar days.
+func (p PersonalizedStrategy) withoutRecent(input SelectionInput, candidates []types.Challenge, count int) []types.Challenge {
+	cutoff := input.Date.AddDate(0, 0, -p.AvoidRepeatDays).Format(time.DateOnly)
+	recent := make(map[string]bool)
+	for _, entry := range input.History {
+		if entry.Date.Format(time.DateOnly) >= cutoff {
+			recent[entry.ChallengeID] = true
+		}
+	}
+
+	fresh := make([]types.Challenge, 0, len(candidates))
+	for _, challenge := range candidates {
+		if !recent[challenge.ID] {
+			fresh = append(fresh, challenge)
+		}
+	}
+
+	if len(fresh) < count {
+		return candidates
+	}
+	return fresh
+}
+
+// preferredDifficulty moves users up when they complete most of their
+// challenges and hit their step goal, and down when they struggle.
+func preferredDifficulty(input SelectionInput) string {
+	completed := 0
+	for _, entry := range input.History {
+		if entry.Completed {
+			completed++
+		}
+	}
+
+	rate := 0.5
+	if len(input.History) > 0 {
+		rate = float64(completed) / float64(len(input.History))
+	}
+
+	goalRatio := 1.0
+	if input.StepGoal > 0 {
+		goalRatio = input.AverageSteps / float64(input.StepGoal)
+	}
+
+	switch {
+	case rate >= 0.7 && goalRatio >= 1:
+		return types.DifficultyHard
+	case rate < 0.3 || goalRatio < 0.5:
+		return types.DifficultyEasy
+	default:
+		return types.DifficultyMedium
+	}
+}
+
+var difficultyLevel = map[string]int{
+	types.DifficultyEasy:   0,
+	types.DifficultyMedium: 1,
+	types.DifficultyHard:   2,
+}
+
+func difficultyWeight(preferred, difficulty string) float64 {
+	distance := difficultyLevel[preferred] - difficultyLevel[difficulty]
+	if distance < 0 {
+		distance = -distance
+	}
+	switch distance {
+	case 0:
+		return 3
+	case 1:
+		return 1.5
+	default:
+		return 0.5
+	}
+}
+
+func completionRates(history []types.ChallengeHistoryEntry) map[string]float64 {
+	assigned := make(map[string]int)
+	completed := make(map[string]int)
+	for _, entry := range history {
+		assigned[entry.Category]++
+		if entry.Completed {
+			completed[entry.Category]++
+		}
+	}
+
+	rates := make(map[string]float64, len(assigned))
+	for category, total := range assigned {
+		rates[category] = float64(completed[category]) / float64(total)
+	}
+	return rates
+}
+
+// categoryWeight favours categories the user actually completes, unknown categories count as average.
+func categoryWeight(rates map[string]float64, category string) float64 {
+	rate, ok := rates[category]
+	if !ok {
+		rate = 0.5
+	}
+	return 0.5 + rate
+}
+
+func weightedIndex(rng *rand.Rand, weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	r := rng.Float64() * total
+	last := 0
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		last = i
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	// Floating point leftovers land on the last selectable entry
+	return last
+}
+
+func sortedByID(challenges []types.Challenge) []types.Challenge {
+	sorted := make([]types.Challenge, len(challenges))
+	copy(sorted, challenges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
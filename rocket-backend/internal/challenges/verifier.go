@@ -0,0 +1,72 @@
+package challenges
+
+import (
+	"fmt"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// Complete verifies the challenge criteria on the server and awards the
+// challenge's own points. It returns the awarded points.
+func (cm *ChallengeManager) Complete(userID uuid.UUID, dto types.CompleteChallengesDTO) (int, error) {
+	challenge, err := cm.db.GetChallengeByID(dto.ChallengeID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cm.verify(userID, challenge, dto.Value); err != nil {
+		return 0, err
+	}
+
+	points, err := cm.db.CompleteChallenge(userID, dto.ChallengeID)
+	if err != nil {
+		return 0, err
+	}
+
+	message := "Completed a daily challenge: " + challenge.Text
+	_ = cm.db.SaveActivity(userID, message)
+
+	return points, nil
+}
+
+func (cm *ChallengeManager) verify(userID uuid.UUID, challenge *types.Challenge, value *float64) error {
+	switch challenge.Verification {
+	case types.VerificationSteps:
+		steps, err := cm.db.GetDailySteps(userID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+		}
+		return checkTarget(float64(steps), challenge.Parameters)
+
+	case types.VerificationRunDistance:
+		distance, err := cm.db.GetLongestRunOnDate(userID, time.Now())
+		if err != nil {
+			return fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+		}
+		return checkTarget(distance, challenge.Parameters)
+
+	case types.VerificationSelfReport, "":
+		// Nothing to measure, so the user's own report is all we have
+		if challenge.Parameters == nil {
+			return nil
+		}
+		if value == nil {
+			return fmt.Errorf("%w: a value is required for this challenge", custom_error.ErrChallengeNotMet)
+		}
+		return checkTarget(*value, challenge.Parameters)
+
+	default:
+		return fmt.Errorf("%w: unknown verification type %q", custom_error.ErrChallengeNotMet, challenge.Verification)
+	}
+}
+
+func checkTarget(actual float64, params *types.ChallengeParameters) error {
+	if params == nil || actual >= params.Target {
+		return nil
+	}
+	return fmt.Errorf("%w: reached %.2f of %.2f %s", custom_error.ErrChallengeNotMet, actual, params.Target, params.Unit)
+}
@@ -4,6 +4,7 @@ import "errors"
 
 var (
 	ErrEmailAlreadyExists   = errors.New("email already exists")
+	ErrUsernameTaken        = errors.New("username already taken")
 	ErrDatabaseQuery        = errors.New("database query error")
 	ErrFailedToSave         = errors.New("failed to save data")
 	ErrFailedToRetrieveData = errors.New("failed to retrieve data")
@@ -14,4 +15,43 @@ var (
 	ErrSettingsNotFound     = errors.New("settings not found")
 	ErrFailedToDelete       = errors.New("failed to delete data")
 	ErrFailedToLoad         = errors.New("failed to load data")
+	ErrStreakNotFound       = errors.New("streak not found")
+	ErrInsufficientPoints   = errors.New("insufficient rocket points")
+	ErrLimitReached         = errors.New("limit reached")
+	ErrItemNotFound         = errors.New("item not found")
+	ErrChallengeNotMet      = errors.New("challenge criteria not met")
+	ErrAlreadyCompleted     = errors.New("challenge already completed")
+	ErrNotFriends           = errors.New("users are not friends")
+	ErrDuelNotFound         = errors.New("duel not found")
+	ErrDuelNotPending       = errors.New("duel is not pending")
+	ErrInvalidDuel          = errors.New("invalid duel")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationNotPending = errors.New("invitation is not pending")
+	ErrAlreadyInvited       = errors.New("friend already invited")
+	ErrAlreadyAssigned      = errors.New("challenge already assigned")
+	ErrTeamNotFound         = errors.New("team not found")
+	ErrNotTeamOwner         = errors.New("only the team owner can do this")
+	ErrTeamFull             = errors.New("team is full")
+	ErrTeamTooSmall         = errors.New("team needs at least two members")
+	ErrInvalidTeamChallenge = errors.New("invalid team challenge")
+	ErrInvalidChallenge     = errors.New("invalid challenge")
+	ErrJobNotFound          = errors.New("job not found")
+	ErrJobRunning           = errors.New("job is already running")
+	ErrLastAdmin            = errors.New("cannot remove the last admin")
+	ErrMessageNotFound      = errors.New("message not found")
+	ErrInvalidRefreshToken  = errors.New("invalid refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrInvalidEmailToken    = errors.New("invalid or expired email token")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication not enabled")
+	ErrNoPendingEnrollment  = errors.New("no pending two-factor enrollment")
+	ErrInvalidOIDCState     = errors.New("invalid or expired login state")
+	ErrTokenNotFound        = errors.New("token not found")
+	ErrClientNotFound       = errors.New("oauth client not found")
+	ErrInvalidGrant         = errors.New("invalid or expired grant")
+	ErrExportNotFound       = errors.New("data export not found")
+	ErrExportInProgress     = errors.New("data export already in progress")
+	ErrAccountDeleted       = errors.New("account has been deleted")
+	ErrBlocked              = errors.New("user is blocked")
+	ErrBlockNotFound        = errors.New("block not found")
 )
@@ -40,6 +40,8 @@ func (s *service) GetActivitiesForUserAndFriends(userID uuid.UUID) ([]types.Acti
 			OR a.user_id IN (
 				SELECT friend_id FROM friends WHERE user_id = $1
 			))
+			AND a.user_id NOT IN (` + hiddenFromFeed + `)
+			AND ` + activeUser("a.user_id") + `
 			AND a.time::date = CURRENT_DATE
 		ORDER BY a.time DESC
 	`
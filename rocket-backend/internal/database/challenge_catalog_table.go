@@ -0,0 +1,321 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+)
+
+func dateOrNil(date *time.Time) any {
+	if date == nil {
+		return nil
+	}
+	return date.Format("2006-01-02")
+}
+
+// writeChallengeAudit records an admin change to the catalog inside the caller's transaction.
+func writeChallengeAudit(tx *sql.Tx, challengeID, action, actor string, details any) error {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO challenge_audit_log (challenge_id, action, actor, details)
+		VALUES ($1, $2, $3, $4)
+	`, challengeID, action, actor, string(raw))
+	if err != nil {
+		logger.Error("Failed to write challenge audit log", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// GetChallengeCatalog returns every challenge, retired and scheduled ones included.
+func (s *service) GetChallengeCatalog() ([]types.CatalogChallenge, error) {
+	query := `
+		SELECT ` + challengeColumns + `, c.active, c.available_from, c.available_until, c.managed_by_admin
+		FROM challenges c
+		ORDER BY c.category, c.description
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		logger.Error("Failed to fetch challenge catalog", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	catalog := []types.CatalogChallenge{}
+	for rows.Next() {
+		var entry types.CatalogChallenge
+		var target sql.NullFloat64
+		var unit sql.NullString
+		var from, until sql.NullTime
+		err := rows.Scan(
+			&entry.ID, &entry.Text, &entry.Points, &entry.Category, &entry.Difficulty, &entry.Verification,
+			&target, &unit, &entry.Active, &from, &until, &entry.ManagedByAdmin,
+		)
+		if err != nil {
+			logger.Error("Failed to scan catalog challenge", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		if target.Valid {
+			entry.Parameters = &types.ChallengeParameters{Target: target.Float64, Unit: unit.String}
+		}
+		if from.Valid {
+			entry.AvailableFrom = &from.Time
+		}
+		if until.Valid {
+			entry.AvailableUntil = &until.Time
+		}
+		catalog = append(catalog, entry)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over catalog challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return catalog, nil
+}
+
+// SaveCatalogChallenge creates or updates a challenge on behalf of an admin and
+// records the change in the audit log. Updating an unknown ID fails with ErrChallengeNotFound.
+func (s *service) SaveCatalogChallenge(challenge types.CatalogChallenge, create bool, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var target, unit any
+	if challenge.Parameters != nil {
+		target = challenge.Parameters.Target
+		unit = challenge.Parameters.Unit
+	}
+	args := []any{
+		challenge.ID, challenge.Text, challenge.Points, challenge.Category, challenge.Difficulty, challenge.Verification,
+		target, unit, challenge.Active, dateOrNil(challenge.AvailableFrom), dateOrNil(challenge.AvailableUntil),
+	}
+
+	action := types.AuditUpdate
+	query := `
+		UPDATE challenges
+		SET description = $2, points_reward = $3, category = $4, difficulty = $5, verification_type = $6,
+			target_value = $7, target_unit = $8, active = $9, available_from = $10, available_until = $11,
+			managed_by_admin = TRUE, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	if create {
+		action = types.AuditCreate
+		query = `
+			INSERT INTO challenges (id, description, points_reward, category, difficulty, verification_type,
+				target_value, target_unit, active, available_from, available_until, managed_by_admin)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, TRUE)
+		`
+	}
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		logger.Error("Failed to save catalog challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return custom_error.ErrChallengeNotFound
+	}
+
+	if err := writeChallengeAudit(tx, challenge.ID, action, actor, challenge); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RetireChallenge stops a challenge from being handed out. Assigned ones stay untouched.
+func (s *service) RetireChallenge(challengeID string, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE challenges
+		SET active = FALSE, managed_by_admin = TRUE, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, challengeID)
+	if err != nil {
+		logger.Error("Failed to retire challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return custom_error.ErrChallengeNotFound
+	}
+
+	// Retired challenges must not show up as challenge of the day anymore
+	_, err = tx.Exec(`DELETE FROM featured_challenges WHERE challenge_id = $1 AND date >= CURRENT_DATE`, challengeID)
+	if err != nil {
+		logger.Error("Failed to unfeature retired challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	if err := writeChallengeAudit(tx, challengeID, types.AuditRetire, actor, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SetFeaturedChallenge puts a challenge into a featured slot, replacing whatever was there.
+func (s *service) SetFeaturedChallenge(date time.Time, slot int, challengeID string, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	day := date.Format("2006-01-02")
+	// The same challenge may only fill one slot per day, moving it frees the old one
+	_, err = tx.Exec(`DELETE FROM featured_challenges WHERE date = $1 AND challenge_id = $2`, day, challengeID)
+	if err != nil {
+		logger.Error("Failed to clear featured challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO featured_challenges (date, slot, challenge_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (date, slot) DO UPDATE SET challenge_id = EXCLUDED.challenge_id
+	`, day, slot, challengeID)
+	if err != nil {
+		logger.Error("Failed to feature challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	details := map[string]any{"date": day, "slot": slot}
+	if err := writeChallengeAudit(tx, challengeID, types.AuditFeature, actor, details); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *service) RemoveFeaturedChallenge(date time.Time, slot int, actor string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	day := date.Format("2006-01-02")
+	var challengeID string
+	err = tx.QueryRow(`
+		DELETE FROM featured_challenges WHERE date = $1 AND slot = $2
+		RETURNING challenge_id
+	`, day, slot).Scan(&challengeID)
+	if err == sql.ErrNoRows {
+		return custom_error.ErrChallengeNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to remove featured challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+
+	details := map[string]any{"date": day, "slot": slot}
+	if err := writeChallengeAudit(tx, challengeID, types.AuditUnfeature, actor, details); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetFeaturedChallenges returns the featured slots between from and to, both days inclusive.
+func (s *service) GetFeaturedChallenges(from, to time.Time) ([]types.FeaturedChallenge, error) {
+	query := `
+		SELECT f.date, f.slot, ` + challengeColumns + `
+		FROM featured_challenges f
+		JOIN challenges c ON c.id = f.challenge_id
+		WHERE f.date BETWEEN $1 AND $2
+		ORDER BY f.date, f.slot
+	`
+	rows, err := s.db.Query(query, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		logger.Error("Failed to fetch featured challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	featured := []types.FeaturedChallenge{}
+	for rows.Next() {
+		var entry types.FeaturedChallenge
+		var target sql.NullFloat64
+		var unit sql.NullString
+		challenge := &entry.Challenge
+		err := rows.Scan(
+			&entry.Date, &entry.Slot, &challenge.ID, &challenge.Text, &challenge.Points, &challenge.Category,
+			&challenge.Difficulty, &challenge.Verification, &target, &unit,
+		)
+		if err != nil {
+			logger.Error("Failed to scan featured challenge", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		if target.Valid {
+			challenge.Parameters = &types.ChallengeParameters{Target: target.Float64, Unit: unit.String}
+		}
+		featured = append(featured, entry)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over featured challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return featured, nil
+}
+
+// GetChallengeAuditLog returns the latest admin changes to the catalog, newest first.
+func (s *service) GetChallengeAuditLog(limit int) ([]types.ChallengeAuditEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, challenge_id, action, actor, details, created_at
+		FROM challenge_audit_log
+		ORDER BY created_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		logger.Error("Failed to fetch challenge audit log", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	entries := []types.ChallengeAuditEntry{}
+	for rows.Next() {
+		var entry types.ChallengeAuditEntry
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.ChallengeID, &entry.Action, &entry.Actor, &details, &entry.CreatedAt); err != nil {
+			logger.Error("Failed to scan challenge audit entry", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		if len(details) > 0 {
+			entry.Details = details
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over challenge audit log", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const invitationColumns = `
+	ci.id, ci.inviter_id, iu.username, ci.invitee_id, eu.username, ci.challenge_id, c.description,
+	ci.date, ci.status, ci.created_at, ci.responded_at
+`
+
+const invitationJoins = `
+	FROM challenge_invitations ci
+	JOIN users iu ON iu.id = ci.inviter_id
+	JOIN users eu ON eu.id = ci.invitee_id
+	JOIN challenges c ON c.id = ci.challenge_id
+`
+
+func scanInvitation(row rowScanner) (types.ChallengeInvitation, error) {
+	var invitation types.ChallengeInvitation
+	var respondedAt sql.NullTime
+	err := row.Scan(
+		&invitation.ID, &invitation.InviterID, &invitation.InviterName, &invitation.InviteeID, &invitation.InviteeName,
+		&invitation.ChallengeID, &invitation.ChallengeText, &invitation.Date, &invitation.Status,
+		&invitation.CreatedAt, &respondedAt,
+	)
+	if respondedAt.Valid {
+		invitation.RespondedAt = &respondedAt.Time
+	}
+	return invitation, err
+}
+
+// CreateChallengeInvitation invites a user to one of today's challenges.
+// Inviting the same user to the same challenge twice a day fails with ErrAlreadyInvited.
+func (s *service) CreateChallengeInvitation(inviterID, inviteeID, challengeID uuid.UUID) (uuid.UUID, error) {
+	query := `
+		INSERT INTO challenge_invitations (inviter_id, invitee_id, challenge_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (inviter_id, invitee_id, challenge_id, date) DO NOTHING
+		RETURNING id
+	`
+	var id uuid.UUID
+	err := s.db.QueryRow(query, inviterID, inviteeID, challengeID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, custom_error.ErrAlreadyInvited
+	}
+	if err != nil {
+		logger.Error("Failed to create challenge invitation", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return id, nil
+}
+
+func (s *service) GetChallengeInvitationByID(invitationID uuid.UUID) (*types.ChallengeInvitation, error) {
+	query := `SELECT ` + invitationColumns + invitationJoins + ` WHERE ci.id = $1`
+	invitation, err := scanInvitation(s.db.QueryRow(query, invitationID))
+	if err == sql.ErrNoRows {
+		return nil, custom_error.ErrInvitationNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to fetch challenge invitation", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return &invitation, nil
+}
+
+// GetChallengeInvitations returns the invitations the user received (incoming) or sent, newest first.
+func (s *service) GetChallengeInvitations(userID uuid.UUID, incoming bool) ([]types.ChallengeInvitation, error) {
+	column := "ci.inviter_id"
+	if incoming {
+		column = "ci.invitee_id"
+	}
+	query := `SELECT ` + invitationColumns + invitationJoins + ` WHERE ` + column + ` = $1 ORDER BY ci.created_at DESC`
+
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		logger.Error("Failed to fetch challenge invitations", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	invitations := []types.ChallengeInvitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			logger.Error("Failed to scan challenge invitation", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over challenge invitations", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return invitations, nil
+}
+
+// ExpireChallengeInvitations marks pending invitations from past days as expired.
+func (s *service) ExpireChallengeInvitations() error {
+	_, err := s.db.Exec(`
+		UPDATE challenge_invitations
+		SET status = 'expired'
+		WHERE status = 'pending' AND date < CURRENT_DATE
+	`)
+	if err != nil {
+		logger.Error("Failed to expire challenge invitations", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+// AcceptChallengeInvitation accepts a pending invitation and adds the challenge
+// to the invitee's challenges for today, unless they already have it.
+func (s *service) AcceptChallengeInvitation(invitationID, inviteeID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var challengeID uuid.UUID
+	err = tx.QueryRow(`
+		UPDATE challenge_invitations
+		SET status = 'accepted', responded_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND invitee_id = $2 AND status = 'pending' AND date = CURRENT_DATE
+		RETURNING challenge_id
+	`, invitationID, inviteeID).Scan(&challengeID)
+	if err == sql.ErrNoRows {
+		return custom_error.ErrInvitationNotPending
+	}
+	if err != nil {
+		logger.Error("Failed to accept challenge invitation", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_challenges (user_id, challenge_id, date)
+		VALUES ($1, $2, CURRENT_DATE)
+		ON CONFLICT (user_id, challenge_id, date) DO NOTHING
+	`, inviteeID, challengeID)
+	if err != nil {
+		logger.Error("Failed to assign invited challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *service) DeclineChallengeInvitation(invitationID, inviteeID uuid.UUID) error {
+	result, err := s.db.Exec(`
+		UPDATE challenge_invitations
+		SET status = 'declined', responded_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND invitee_id = $2 AND status = 'pending'
+	`, invitationID, inviteeID)
+	if err != nil {
+		logger.Error("Failed to decline challenge invitation", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return custom_error.ErrInvitationNotPending
+	}
+	return nil
+}
@@ -1,8 +1,8 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
-	"math/rand"
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
 	"rocket-backend/pkg/logger"
@@ -11,9 +11,47 @@ import (
 	"github.com/google/uuid"
 )
 
+// challengeColumns lists the columns read by scanChallenge, in order.
+const challengeColumns = `c.id, c.description, c.points_reward, c.category, c.difficulty, c.verification_type, c.target_value, c.target_unit`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanChallenge(row rowScanner) (types.Challenge, error) {
+	var challenge types.Challenge
+	var target sql.NullFloat64
+	var unit sql.NullString
+	err := row.Scan(
+		&challenge.ID,
+		&challenge.Text,
+		&challenge.Points,
+		&challenge.Category,
+		&challenge.Difficulty,
+		&challenge.Verification,
+		&target,
+		&unit,
+	)
+	if err != nil {
+		return challenge, err
+	}
+	if target.Valid {
+		challenge.Parameters = &types.ChallengeParameters{Target: target.Float64, Unit: unit.String}
+	}
+	return challenge, nil
+}
+
+// GetAllChallenges returns the challenges that can be handed out today:
+// active ones whose availability window, if any, contains today.
 func (s *service) GetAllChallenges() ([]types.Challenge, error) {
 	var challenges []types.Challenge
-	query := `SELECT id, description AS text, points_reward AS points FROM challenges`
+	query := `
+		SELECT ` + challengeColumns + `
+		FROM challenges c
+		WHERE c.active
+			AND (c.available_from IS NULL OR c.available_from <= CURRENT_DATE)
+			AND (c.available_until IS NULL OR c.available_until >= CURRENT_DATE)
+	`
 	rows, err := s.db.Query(query)
 	if err != nil {
 		logger.Error("Failed to fetch challenges from database", err)
@@ -22,8 +60,8 @@ func (s *service) GetAllChallenges() ([]types.Challenge, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var challenge types.Challenge
-		if err := rows.Scan(&challenge.ID, &challenge.Text, &challenge.Points); err != nil {
+		challenge, err := scanChallenge(rows)
+		if err != nil {
 			logger.Error("Failed to scan challenge row", err)
 			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
 		}
@@ -63,7 +101,7 @@ func (s *service) AssignChallengesToUser(userID uuid.UUID, challenges []types.Ch
 func (s *service) GetUserDailyChallenges(userID uuid.UUID) ([]types.Challenge, error) {
 	var challenges []types.Challenge
 	query := `
-		SELECT c.id, c.description AS text, c.points_reward AS points
+		SELECT ` + challengeColumns + `
 		FROM user_challenges uc
 		JOIN challenges c ON uc.challenge_id = c.id
 		WHERE uc.user_id = $1 AND uc.date = CURRENT_DATE AND uc.is_completed = FALSE
@@ -76,8 +114,8 @@ func (s *service) GetUserDailyChallenges(userID uuid.UUID) ([]types.Challenge, e
 	defer rows.Close()
 
 	for rows.Next() {
-		var challenge types.Challenge
-		if err := rows.Scan(&challenge.ID, &challenge.Text, &challenge.Points); err != nil {
+		challenge, err := scanChallenge(rows)
+		if err != nil {
 			logger.Error("Failed to scan user challenge row", err)
 			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
 		}
@@ -92,101 +130,87 @@ func (s *service) GetUserDailyChallenges(userID uuid.UUID) ([]types.Challenge, e
 	return challenges, nil
 }
 
-func (s *service) ResetDailyChallenges() error {
-	_, err := s.db.Exec(`DELETE FROM user_challenges WHERE date < CURRENT_DATE`)
-	if err != nil {
-		logger.Error("Failed to delete old challenges", err)
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
-	}
+// UpsertChallenge inserts a challenge or overwrites the stored one with the same ID,
+// unless that one has been edited through the admin API since.
+func (s *service) UpsertChallenge(challenge types.Challenge) error {
+	query := `
+		INSERT INTO challenges (id, description, points_reward, category, difficulty, verification_type, target_value, target_unit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE
+		SET description = EXCLUDED.description,
+			points_reward = EXCLUDED.points_reward,
+			category = EXCLUDED.category,
+			difficulty = EXCLUDED.difficulty,
+			verification_type = EXCLUDED.verification_type,
+			target_value = EXCLUDED.target_value,
+			target_unit = EXCLUDED.target_unit
+		WHERE challenges.managed_by_admin = FALSE
+	`
 
-	var userIDs []uuid.UUID
-	query := `SELECT id FROM users`
-	rows, err := s.db.Query(query)
-	if err != nil {
-		logger.Error("Failed to fetch user IDs", err)
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	var target, unit interface{}
+	if challenge.Parameters != nil {
+		target = challenge.Parameters.Target
+		unit = challenge.Parameters.Unit
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var userID uuid.UUID
-		if err := rows.Scan(&userID); err != nil {
-			logger.Error("Failed to scan user ID", err)
-			return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
-		}
-		userIDs = append(userIDs, userID)
+	_, err := s.db.Exec(query, challenge.ID, challenge.Text, challenge.Points, challenge.Category, challenge.Difficulty, challenge.Verification, target, unit)
+	if err != nil {
+		logger.Error("Failed to upsert challenge into database", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
 	}
 
-	if err := rows.Err(); err != nil {
-		logger.Error("Error iterating over user IDs", err)
-		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
-	}
+	return nil
+}
 
-	allChallenges, err := s.GetAllChallenges()
+// CompleteChallenge marks today's challenge as completed and awards its points_reward.
+// It returns the awarded points and refuses to complete the same challenge twice.
+func (s *service) CompleteChallenge(userID uuid.UUID, challengeID uuid.UUID) (int, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	for _, userID := range userIDs {
-		if len(allChallenges) < 5 {
-			logger.Error("Not enough challenges in the database to assign")
-			return fmt.Errorf("not enough challenges to assign")
+	var points int
+	err = tx.QueryRow(`
+		UPDATE user_challenges uc
+		SET is_completed = TRUE
+		FROM challenges c
+		WHERE uc.challenge_id = c.id
+			AND uc.user_id = $1 AND uc.challenge_id = $2 AND uc.date = CURRENT_DATE
+			AND uc.is_completed = FALSE
+		RETURNING c.points_reward
+	`, userID, challengeID).Scan(&points)
+	if err == sql.ErrNoRows {
+		// Nothing to update, find out whether the challenge is missing or already done
+		var completed bool
+		err = tx.QueryRow(`
+			SELECT is_completed FROM user_challenges
+			WHERE user_id = $1 AND challenge_id = $2 AND date = CURRENT_DATE
+		`, userID, challengeID).Scan(&completed)
+		if err == sql.ErrNoRows || (err == nil && !completed) {
+			return 0, custom_error.ErrChallengeNotFound
 		}
-
-		shuffledChallenges := ShuffleChallenges(allChallenges)
-		dailyChallenges := shuffledChallenges[:5]
-
-		err := s.AssignChallengesToUser(userID, dailyChallenges)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Failed to assign challenges to user %s", userID), err)
-			return err
+		if err == nil {
+			return 0, custom_error.ErrAlreadyCompleted
 		}
 	}
-
-	return nil
-}
-
-func ShuffleChallenges(challenges []types.Challenge) []types.Challenge {
-	source := rand.NewSource(time.Now().UnixNano())
-	rng := rand.New(source)
-	shuffled := make([]types.Challenge, len(challenges))
-	copy(shuffled, challenges)
-	rng.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-	})
-	return shuffled
-}
-
-func (s *service) InsertChallenge(challenge types.Challenge) error {
-	query := `
-		INSERT INTO challenges (id, description, points_reward)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (id) DO NOTHING
-	`
-
-	_, err := s.db.Exec(query, challenge.ID, challenge.Text, challenge.Points)
 	if err != nil {
-		logger.Error("Failed to insert challenge into database", err)
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+		logger.Error("Failed to mark challenge as completed", err)
+		return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
 	}
 
-	return nil
-}
-
-func (s *service) CompleteChallenge(UserID uuid.UUID, dto types.CompleteChallengesDTO) error {
-	query := `
-		UPDATE user_challenges
-		SET is_completed = TRUE
-		WHERE user_id = $1 AND challenge_id = $2 AND date = CURRENT_DATE
-	`
-
-	_, err := s.db.Exec(query, UserID, dto.ChallengeID)
+	_, err = tx.Exec(`UPDATE users SET rocketpoints = rocketpoints + $2 WHERE id = $1`, userID, points)
 	if err != nil {
-		logger.Error("Failed to mark challenge as completed", err)
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		logger.Error("Failed to award challenge points", err)
+		return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return points, nil
 }
 
 func (s *service) IsNewDayForUser(userID uuid.UUID) (bool, error) {
@@ -206,29 +230,70 @@ func (s *service) IsNewDayForUser(userID uuid.UUID) (bool, error) {
 	return count == 0, nil
 }
 
-func (s *service) CleanUpChallengesForUser(userID uuid.UUID) error {
+// ChallengeHistoryDays is how long assigned challenges are kept. The history
+// feeds the daily selection, so it has to cover its look-back window.
+const ChallengeHistoryDays = 30
+
+// CleanUpChallenges deletes the assigned challenges of all users that are older than ChallengeHistoryDays.
+func (s *service) CleanUpChallenges() error {
 	query := `
     DELETE FROM user_challenges
-    WHERE user_id = $1 AND date < CURRENT_DATE
+    WHERE date < CURRENT_DATE - $1::int
     `
-	_, err := s.db.Exec(query, userID)
+	_, err := s.db.Exec(query, ChallengeHistoryDays)
 	if err != nil {
-		logger.Error("Failed to clean up old challenges for the user", err)
+		logger.Error("Failed to clean up old challenges", err)
 		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
 	}
 
 	return nil
 }
 
+// GetChallengeHistory returns the challenges assigned to the user since the given day, excluding today.
+func (s *service) GetChallengeHistory(userID uuid.UUID, since time.Time) ([]types.ChallengeHistoryEntry, error) {
+	query := `
+		SELECT uc.challenge_id, c.category, uc.date, uc.is_completed
+		FROM user_challenges uc
+		JOIN challenges c ON uc.challenge_id = c.id
+		WHERE uc.user_id = $1 AND uc.date >= $2 AND uc.date < CURRENT_DATE
+		ORDER BY uc.date DESC
+	`
+	rows, err := s.db.Query(query, userID, since.Format("2006-01-02"))
+	if err != nil {
+		logger.Error("Failed to fetch challenge history", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	var history []types.ChallengeHistoryEntry
+	for rows.Next() {
+		var entry types.ChallengeHistoryEntry
+		if err := rows.Scan(&entry.ChallengeID, &entry.Category, &entry.Date, &entry.Completed); err != nil {
+			logger.Error("Failed to scan challenge history row", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		history = append(history, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over challenge history rows", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return history, nil
+}
+
 func (s *service) GetChallengeByID(challengeID uuid.UUID) (*types.Challenge, error) {
 	query := `
-		SELECT id, description AS text, points_reward AS points
-		FROM challenges
-		WHERE id = $1
+		SELECT ` + challengeColumns + `
+		FROM challenges c
+		WHERE c.id = $1
 	`
-	var challenge types.Challenge
-	err := s.db.QueryRow(query, challengeID).Scan(&challenge.ID, &challenge.Text, &challenge.Points)
+	challenge, err := scanChallenge(s.db.QueryRow(query, challengeID))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrChallengeNotFound, err)
+		}
 		logger.Error("Failed to fetch challenge by ID", err)
 		return nil, fmt.Errorf("failed to fetch challenge by ID: %v", err)
 	}
@@ -236,20 +301,20 @@ func (s *service) GetChallengeByID(challengeID uuid.UUID) (*types.Challenge, err
 	return &challenge, nil
 }
 
-func (s *service) InviteFriendToChallenge(challengeID uuid.UUID, friendID uuid.UUID) error {
-	query := `
-		INSERT INTO user_challenges (user_id, challenge_id, date)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (user_id, challenge_id, date) DO NOTHING
-	`
-
-	_, err := s.db.Exec(query, friendID, challengeID, time.Now())
+// HasChallengeToday reports whether the challenge is among the user's challenges for today.
+func (s *service) HasChallengeToday(userID uuid.UUID, challengeID uuid.UUID) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_challenges
+			WHERE user_id = $1 AND challenge_id = $2 AND date = CURRENT_DATE
+		)
+	`, userID, challengeID).Scan(&exists)
 	if err != nil {
-		logger.Error("Failed to invite friend to challenge", err)
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		logger.Error("Failed to check challenge assignment", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
 	}
-
-	return nil
+	return exists, nil
 }
 
 func (s *service) GetAllChallengesAmount(userID uuid.UUID) (int, error) {
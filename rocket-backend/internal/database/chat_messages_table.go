@@ -26,12 +26,15 @@ func (s *service) SaveChatMessage(userID uuid.UUID, message string, timestamp st
 
 func (s *service) GetChatMessages(userID uuid.UUID) ([]types.ChatMessage, error) {
 	query := `
-		SELECT cm.id, cm.user_id, u.username, cm.message, cm.timestamp
+		SELECT cm.id, cm.user_id,
+			CASE WHEN u.id IS NOT NULL AND ` + activeUser("u.id") + ` THEN u.username ELSE 'Deleted user' END,
+			cm.message, cm.timestamp
 		FROM chat_messages cm
-		JOIN users u ON cm.user_id = u.id
+		LEFT JOIN users u ON cm.user_id = u.id -- Erased users leave their messages without an author
+		WHERE NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.user_id = $1 AND b.blocked_id = cm.user_id)
 		ORDER BY cm.timestamp ASC
 	`
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, userID)
 	if err != nil {
 		logger.Error("Failed to load chat messages", err)
 		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
@@ -102,3 +105,16 @@ func (s *service) GetIDByMessageID(messageID uuid.UUID) (uuid.UUID, error) {
 	}
 	return userID, nil
 }
+
+// DeleteChatMessage removes a message together with its reactions.
+func (s *service) DeleteChatMessage(messageID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM chat_messages WHERE id = $1`, messageID)
+	if err != nil {
+		logger.Error("Failed to delete chat message", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return custom_error.ErrMessageNotFound
+	}
+	return nil
+}
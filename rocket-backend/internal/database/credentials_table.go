@@ -1,18 +1,24 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
 	"rocket-backend/pkg/logger"
 
+	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 func (s *service) SaveCredentials(creds types.Credentials) error {
-	query := `INSERT INTO credentials (id, email, password, created_at, last_login) VALUES ($1, $2, $3, $4, $5)`
-	_, err := s.db.Exec(query, creds.ID, creds.Email, creds.Password, creds.CreatedAt, creds.LastLogin)
+	role := creds.Role
+	if role == "" {
+		role = types.RoleUser
+	}
+	query := `INSERT INTO credentials (id, email, password, created_at, last_login, role) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := s.db.Exec(query, creds.ID, creds.Email, creds.Password, creds.CreatedAt, creds.LastLogin, role)
 	if err != nil {
 		logger.Error("Failed to save credentials", err)
 		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
@@ -22,8 +28,8 @@ func (s *service) SaveCredentials(creds types.Credentials) error {
 
 func (s *service) GetUserByEmail(email string) (types.Credentials, error) {
 	var creds types.Credentials
-	query := `SELECT id, email, password, created_at, last_login FROM credentials WHERE email = $1`
-	err := s.db.QueryRow(query, email).Scan(&creds.ID, &creds.Email, &creds.Password, &creds.CreatedAt, &creds.LastLogin)
+	query := `SELECT id, email, password, created_at, last_login, role, token_version, email_verified FROM credentials WHERE LOWER(email) = LOWER($1)`
+	err := s.db.QueryRow(query, email).Scan(&creds.ID, &creds.Email, &creds.Password, &creds.CreatedAt, &creds.LastLogin, &creds.Role, &creds.TokenVersion, &creds.EmailVerified)
 	if err != nil {
 		logger.Error("Failed to get user by email", err)
 		return creds, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
@@ -32,7 +38,7 @@ func (s *service) GetUserByEmail(email string) (types.Credentials, error) {
 }
 
 func (s *service) CheckEmail(email string) error {
-	query := `SELECT COUNT(*) FROM credentials WHERE email = $1`
+	query := `SELECT COUNT(*) FROM credentials WHERE LOWER(email) = LOWER($1)`
 	var count int
 	err := s.db.QueryRow(query, email).Scan(&count)
 	if err != nil {
@@ -44,3 +50,60 @@ func (s *service) CheckEmail(email string) error {
 	}
 	return nil
 }
+
+func (s *service) GetUserRole(userID uuid.UUID) (string, error) {
+	var role string
+	err := s.db.QueryRow(`SELECT role FROM credentials WHERE id = $1`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", custom_error.ErrUserNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to get user role", err)
+		return "", fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return role, nil
+}
+
+// SetUserRole changes the role of a user. Demoting the last admin fails with ErrLastAdmin,
+// so there is always somebody left who can manage roles.
+func (s *service) SetUserRole(userID uuid.UUID, role string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the admins so two concurrent demotions cannot both pass the check
+	var admins int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM (SELECT id FROM credentials WHERE role = $1 FOR UPDATE) a
+	`, types.RoleAdmin).Scan(&admins)
+	if err != nil {
+		logger.Error("Failed to count admins", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	var previous string
+	err = tx.QueryRow(`SELECT role FROM credentials WHERE id = $1 FOR UPDATE`, userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return custom_error.ErrUserNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to get user role", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	if previous == types.RoleAdmin && role != types.RoleAdmin && admins <= 1 {
+		return custom_error.ErrLastAdmin
+	}
+
+	_, err = tx.Exec(`UPDATE credentials SET role = $2 WHERE id = $1`, userID, role)
+	if err != nil {
+		logger.Error("Failed to update user role", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
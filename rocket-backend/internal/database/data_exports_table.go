@@ -0,0 +1,298 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Failed exports are kept this long, so support can look into them
+const failedExportRetention = "7 days"
+
+// exportSections select everything stored about the user $1, one query per file of
+// the archive. Secrets such as password and token hashes are left out.
+var exportSections = []struct {
+	name  string
+	query string
+}{
+	{"profile", `
+		SELECT u.id, u.username, u.email, u.rocketpoints, c.role, c.email_verified, c.created_at, c.last_login
+		FROM users u JOIN credentials c ON c.id = u.id
+		WHERE u.id = $1`},
+	{"settings", `SELECT step_goal, image_id FROM settings WHERE user_id = $1`},
+	{"daily_steps", `SELECT date, steps_taken FROM daily_steps WHERE user_id = $1 ORDER BY date`},
+	{"runs", `
+		SELECT id, created_at, duration, distance, ST_AsGeoJSON(route)::json AS route
+		FROM runs WHERE user_id = $1 ORDER BY created_at`},
+	{"planned_runs", `
+		SELECT id, created_at, name, distance, ST_AsGeoJSON(route)::json AS route
+		FROM planned_runs WHERE user_id = $1 ORDER BY created_at`},
+	{"challenges", `
+		SELECT uc.date, c.description, c.points_reward, uc.is_completed
+		FROM user_challenges uc JOIN challenges c ON c.id = uc.challenge_id
+		WHERE uc.user_id = $1 ORDER BY uc.date, c.description`},
+	{"periodic_challenges", `
+		SELECT pc.description, upc.period_start, upc.period_end, upc.progress, upc.is_completed, upc.completed_at
+		FROM user_periodic_challenges upc JOIN periodic_challenges pc ON pc.id = upc.challenge_id
+		WHERE upc.user_id = $1 ORDER BY upc.period_start, pc.description`},
+	{"challenge_invitations", `
+		SELECT ci.date, c.description, inviter.username AS inviter, invitee.username AS invitee,
+			ci.status, ci.created_at, ci.responded_at
+		FROM challenge_invitations ci
+		JOIN challenges c ON c.id = ci.challenge_id
+		JOIN users inviter ON inviter.id = ci.inviter_id
+		JOIN users invitee ON invitee.id = ci.invitee_id
+		WHERE $1 IN (ci.inviter_id, ci.invitee_id) ORDER BY ci.created_at`},
+	{"friends", `
+		SELECT u.username, 'following' AS relation FROM friends f JOIN users u ON u.id = f.friend_id WHERE f.user_id = $1
+		UNION ALL
+		SELECT u.username, 'follower' AS relation FROM friends f JOIN users u ON u.id = f.user_id WHERE f.friend_id = $1
+		ORDER BY relation, username`},
+	// Only whom the user blocked, not who blocked them
+	{"blocked_users", `
+		SELECT u.username, b.kind, b.created_at FROM user_blocks b JOIN users u ON u.id = b.blocked_id
+		WHERE b.user_id = $1 ORDER BY b.created_at`},
+	{"activities", `SELECT time, message FROM activities WHERE user_id = $1 ORDER BY time`},
+	{"chat_messages", `SELECT id, timestamp, message FROM chat_messages WHERE user_id = $1 ORDER BY timestamp`},
+	// The messages reacted to belong to others, only their IDs are included
+	{"chat_reactions", `SELECT message_id, created_at FROM chat_messages_reactions WHERE user_id = $1 ORDER BY created_at`},
+	{"streak", `SELECT current_streak, longest_streak, freezes, last_evaluated FROM streaks WHERE user_id = $1`},
+	{"shop_purchases", `
+		SELECT p.purchased_at, i.name AS item, p.price
+		FROM shop_purchases p JOIN shop_items i ON i.id = p.item_id
+		WHERE p.user_id = $1 ORDER BY p.purchased_at`},
+	{"inventory", `
+		SELECT i.name AS item, inv.quantity, inv.acquired_at
+		FROM user_inventory inv JOIN shop_items i ON i.id = inv.item_id
+		WHERE inv.user_id = $1 ORDER BY inv.acquired_at`},
+	{"duels", `
+		SELECT d.id, challenger.username AS challenger, opponent.username AS opponent, d.type, d.target_value,
+			d.duration_days, d.stake, d.status, d.challenger_progress, d.opponent_progress,
+			winner.username AS winner, d.created_at, d.starts_at, d.ends_at, d.finished_at
+		FROM duels d
+		JOIN users challenger ON challenger.id = d.challenger_id
+		JOIN users opponent ON opponent.id = d.opponent_id
+		LEFT JOIN users winner ON winner.id = d.winner_id
+		WHERE $1 IN (d.challenger_id, d.opponent_id) ORDER BY d.created_at`},
+	{"teams", `
+		SELECT t.name, owner.username AS owner, tm.joined_at
+		FROM team_members tm
+		JOIN teams t ON t.id = tm.team_id
+		JOIN users owner ON owner.id = t.owner_id
+		WHERE tm.user_id = $1 ORDER BY tm.joined_at`},
+	{"sessions", `
+		SELECT device_name, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions WHERE user_id = $1 ORDER BY created_at`},
+	{"two_factor", `SELECT secret IS NOT NULL AS enabled, enabled_at FROM two_factor WHERE user_id = $1`},
+	{"linked_accounts", `SELECT provider, email, created_at FROM oidc_identities WHERE user_id = $1 ORDER BY created_at`},
+	{"personal_access_tokens", `
+		SELECT name, prefix, scopes, created_at, expires_at, last_used_at
+		FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at`},
+	{"connected_apps", `
+		SELECT c.name AS app, oc.scopes, oc.granted_at
+		FROM oauth_consents oc JOIN oauth_clients c ON c.id = oc.client_id
+		WHERE oc.user_id = $1 ORDER BY oc.granted_at`},
+}
+
+// GetUserDataSections returns everything stored about the user, as one JSON array of
+// records per section. The profile image is left to GetUserImage.
+func (s *service) GetUserDataSections(userID uuid.UUID) ([]types.ExportSection, error) {
+	// One snapshot, so the sections agree with each other
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	sections := make([]types.ExportSection, 0, len(exportSections))
+	for _, section := range exportSections {
+		var records []byte
+		err := tx.QueryRow(`SELECT COALESCE(json_agg(t), '[]'::json) FROM (`+section.query+`) t`, userID).Scan(&records)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to export %s", section.name), err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		sections = append(sections, types.ExportSection{Name: section.name, Records: json.RawMessage(records)})
+	}
+	return sections, nil
+}
+
+// CreateDataExport stores a pending export, replacing the user's previous archives.
+// It fails with ErrExportInProgress while another export of the user is pending.
+func (s *service) CreateDataExport(export types.DataExport, tokenHash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM data_exports WHERE user_id = $1 AND status <> $2`, export.UserID, types.ExportStatusPending)
+	if err != nil {
+		logger.Error("Failed to delete previous data exports", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO data_exports (id, user_id, status, token_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+	`, export.ID, export.UserID, types.ExportStatusPending, tokenHash, export.CreatedAt)
+	if err != nil {
+		logger.Error("Failed to save data export", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return custom_error.ErrExportInProgress
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetLatestDataExport returns the newest export of the user.
+func (s *service) GetLatestDataExport(userID uuid.UUID) (types.DataExport, error) {
+	row := s.db.QueryRow(`
+		SELECT `+dataExportColumns+`
+		FROM data_exports
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID)
+	export, err := scanDataExport(row)
+	if err == sql.ErrNoRows {
+		return export, custom_error.ErrExportNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to load data export", err)
+		return export, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	return export, nil
+}
+
+// ClaimDataExport starts an attempt at building a pending export. It returns false
+// when the export is not pending or another attempt started after staleBefore.
+func (s *service) ClaimDataExport(exportID uuid.UUID, staleBefore time.Time) (types.DataExport, bool, error) {
+	row := s.db.QueryRow(`
+		UPDATE data_exports
+		SET started_at = CURRENT_TIMESTAMP, attempts = attempts + 1
+		WHERE id = $1 AND status = $2 AND (started_at IS NULL OR started_at < $3)
+		RETURNING `+dataExportColumns+`
+	`, exportID, types.ExportStatusPending, staleBefore)
+	export, err := scanDataExport(row)
+	if err == sql.ErrNoRows {
+		return export, false, nil
+	}
+	if err != nil {
+		logger.Error("Failed to claim data export", err)
+		return export, false, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return export, true, nil
+}
+
+// GetStaleDataExports returns the pending exports without an attempt started after
+// staleBefore, e.g. because the replica building them stopped.
+func (s *service) GetStaleDataExports(staleBefore time.Time) ([]uuid.UUID, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM data_exports
+		WHERE status = $1 AND (started_at IS NULL OR started_at < $2)
+		ORDER BY created_at
+	`, types.ExportStatusPending, staleBefore)
+	if err != nil {
+		logger.Error("Failed to load stale data exports", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			logger.Error("Failed to scan data export", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CompleteDataExport stores the archive of an export, its download link works until
+// expiresAt.
+func (s *service) CompleteDataExport(exportID uuid.UUID, archive []byte, expiresAt time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE data_exports
+		SET status = $2, archive = $3, finished_at = CURRENT_TIMESTAMP, expires_at = $4
+		WHERE id = $1
+	`, exportID, types.ExportStatusReady, archive, expiresAt)
+	if err != nil {
+		logger.Error("Failed to save data export archive", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// FailDataExport marks an export as failed for the reason.
+func (s *service) FailDataExport(exportID uuid.UUID, reason string) error {
+	_, err := s.db.Exec(`
+		UPDATE data_exports SET status = $2, error = $3, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, exportID, types.ExportStatusFailed, reason)
+	if err != nil {
+		logger.Error("Failed to mark data export as failed", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+// GetDataExportArchive returns the export with the download token hash and its
+// archive, which is nil until the export is ready. Expired exports are not found.
+func (s *service) GetDataExportArchive(tokenHash string) (types.DataExport, []byte, error) {
+	var archive []byte
+	row := s.db.QueryRow(`
+		SELECT `+dataExportColumns+`, archive
+		FROM data_exports
+		WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+	`, tokenHash)
+	export, err := scanDataExport(row, &archive)
+	if err == sql.ErrNoRows {
+		return export, nil, custom_error.ErrExportNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to load data export archive", err)
+		return export, nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	return export, archive, nil
+}
+
+// CleanUpDataExports deletes the archives whose download link expired and old failed
+// exports.
+func (s *service) CleanUpDataExports() error {
+	_, err := s.db.Exec(`
+		DELETE FROM data_exports
+		WHERE expires_at < CURRENT_TIMESTAMP
+			OR (status = $1 AND created_at < CURRENT_TIMESTAMP - INTERVAL '`+failedExportRetention+`')
+	`, types.ExportStatusFailed)
+	if err != nil {
+		logger.Error("Failed to clean up data exports", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	return nil
+}
+
+const dataExportColumns = `id, user_id, status, COALESCE(octet_length(archive), 0), attempts, created_at, finished_at, expires_at`
+
+func scanDataExport(row rowScanner, extra ...any) (types.DataExport, error) {
+	var export types.DataExport
+	dest := []any{&export.ID, &export.UserID, &export.Status, &export.Size, &export.Attempts,
+		&export.CreatedAt, &export.FinishedAt, &export.ExpiresAt}
+	err := row.Scan(append(dest, extra...)...)
+	return export, err
+}
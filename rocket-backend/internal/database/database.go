@@ -11,6 +11,7 @@ import (
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
 	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/ratelimit"
 
 	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -33,6 +34,83 @@ type Service interface {
 	SaveCredentials(creds types.Credentials) error
 	GetUserByEmail(username string) (types.Credentials, error)
 	CheckEmail(email string) error
+	GetUserRole(userID uuid.UUID) (string, error)
+	SetUserRole(userID uuid.UUID, role string) error
+
+	// tokens
+	GetCredentialsByID(userID uuid.UUID) (types.Credentials, error)
+	IsAccessTokenValid(userID uuid.UUID, tokenID uuid.UUID, version int, sessionID string) (bool, error)
+	RevokeAccessToken(userID uuid.UUID, tokenID uuid.UUID, expiresAt time.Time) error
+	RevokeAllTokens(userID uuid.UUID) (int, error)
+	CreateSession(session types.Session, tokenHash string, expiresAt time.Time) (uuid.UUID, error)
+	RotateRefreshToken(tokenHash string, newTokenHash string, expiresAt time.Time, ipAddress string) (types.Credentials, uuid.UUID, error)
+	RevokeRefreshToken(tokenHash string) error
+	CleanUpTokens() error
+
+	// sessions
+	GetSessions(userID uuid.UUID) ([]types.Session, error)
+	RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error
+	TouchSession(sessionID uuid.UUID, ipAddress string) error
+	UpdateLastLogin(userID uuid.UUID) error
+
+	// email tokens
+	CreateEmailToken(userID uuid.UUID, purpose string, email string, tokenHash string, expiresAt time.Time) error
+	VerifyEmail(tokenHash string) (uuid.UUID, error)
+	ResetPassword(tokenHash string, newPassword string) (uuid.UUID, error)
+
+	// two-factor authentication
+	GetTwoFactor(userID uuid.UUID) (*types.TwoFactor, error)
+	SavePendingTwoFactorSecret(userID uuid.UUID, secret string) error
+	ConfirmTwoFactor(userID uuid.UUID, step int64, codeHashes []string) error
+	UseTwoFactorStep(userID uuid.UUID, step int64) (bool, error)
+	UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	DisableTwoFactor(userID uuid.UUID) error
+
+	// personal access tokens
+	CreatePersonalAccessToken(token types.PersonalAccessToken, tokenHash string, maxTokens int) error
+	GetPersonalAccessTokens(userID uuid.UUID) ([]types.PersonalAccessToken, error)
+	DeletePersonalAccessToken(userID uuid.UUID, tokenID uuid.UUID) error
+	AuthenticatePersonalAccessToken(tokenHash string) (types.PersonalAccessToken, error)
+
+	// oauth
+	CreateOAuthClient(client types.OAuthClient) error
+	GetOAuthClients() ([]types.OAuthClient, error)
+	GetOAuthClient(clientID string) (types.OAuthClient, error)
+	DeleteOAuthClient(clientID string) error
+	GetOAuthConsent(userID uuid.UUID, clientID string) ([]string, error)
+	GetOAuthConsents(userID uuid.UUID) ([]types.OAuthConsent, error)
+	RevokeOAuthConsent(userID uuid.UUID, clientID string) error
+	CreateOAuthCode(codeHash string, code types.OAuthCode, expiresAt time.Time) error
+	ConsumeOAuthCode(codeHash string, clientID string) (types.OAuthCode, error)
+	CreateOAuthToken(token types.OAuthToken, accessHash string, refreshHash string) error
+	RefreshOAuthToken(refreshHash string, clientID string, newAccessHash string, newRefreshHash string, accessExpiresAt time.Time, refreshExpiresAt time.Time) (types.OAuthToken, error)
+	AuthenticateOAuthToken(accessHash string) (types.OAuthToken, error)
+	RevokeOAuthToken(tokenHash string, clientID string) error
+
+	// data exports
+	CreateDataExport(export types.DataExport, tokenHash string) error
+	GetLatestDataExport(userID uuid.UUID) (types.DataExport, error)
+	ClaimDataExport(exportID uuid.UUID, staleBefore time.Time) (types.DataExport, bool, error)
+	GetStaleDataExports(staleBefore time.Time) ([]uuid.UUID, error)
+	CompleteDataExport(exportID uuid.UUID, archive []byte, expiresAt time.Time) error
+	FailDataExport(exportID uuid.UUID, reason string) error
+	GetDataExportArchive(tokenHash string) (types.DataExport, []byte, error)
+	GetUserDataSections(userID uuid.UUID) ([]types.ExportSection, error)
+	CleanUpDataExports() error
+
+	// oidc
+	SaveOIDCState(stateHash string, state types.OIDCState, expiresAt time.Time) error
+	ConsumeOIDCState(stateHash string, provider string) (types.OIDCState, error)
+	GetUserByOIDCIdentity(provider string, subject string) (types.Credentials, error)
+	LinkOIDCIdentity(userID uuid.UUID, provider string, subject string, email string) error
+
+	// rate limits
+	TakeRateLimitToken(key string, policy ratelimit.Policy) (ratelimit.Result, error)
+	GetLoginLockout(email string) (time.Duration, error)
+	RecordLoginFailure(email string, lockout ratelimit.Lockout) (time.Duration, error)
+	ResetLoginFailures(email string) error
+	CleanUpRateLimits() error
 
 	// users
 	SaveUserProfile(user types.User) error
@@ -42,7 +120,12 @@ type Service interface {
 	GetUserIDByName(name string) (uuid.UUID, error)
 	GetTopUsers(limit int) ([]types.User, error)
 	GetAllUsers(excludeUserID *uuid.UUID) ([]types.User, error)
+	GetUsersWithRoles() ([]types.UserWithRole, error)
 	DeleteUser(userID uuid.UUID) error
+	DeactivateUser(userID uuid.UUID, eraseAfter time.Time) (time.Time, error)
+	RestoreUser(userID uuid.UUID) (bool, error)
+	GetUsersDueForErasure() ([]uuid.UUID, error)
+	CheckUsername(username string, excludeUserID uuid.UUID) error
 	UpdateUserName(userID uuid.UUID, newName string) error
 	UpdateUserEmail(userID uuid.UUID, newEmail string) error
 	CheckUserPassword(userID uuid.UUID, currentPassword string) (bool, error)
@@ -70,27 +153,91 @@ type Service interface {
 	GetAllChallenges() ([]types.Challenge, error)
 	AssignChallengesToUser(userID uuid.UUID, challenges []types.Challenge) error
 	GetUserDailyChallenges(userID uuid.UUID) ([]types.Challenge, error)
-	ResetDailyChallenges() error
-	InsertChallenge(challenge types.Challenge) error
-	CompleteChallenge(UserID uuid.UUID, dto types.CompleteChallengesDTO) error
+	UpsertChallenge(challenge types.Challenge) error
+	CompleteChallenge(userID uuid.UUID, challengeID uuid.UUID) (int, error)
 	IsNewDayForUser(userID uuid.UUID) (bool, error)
-	CleanUpChallengesForUser(userID uuid.UUID) error
+	CleanUpChallenges() error
 	GetChallengeByID(challengeID uuid.UUID) (*types.Challenge, error)
-	InviteFriendToChallenge(challengeID uuid.UUID, friendID uuid.UUID) error
+	GetChallengeHistory(userID uuid.UUID, since time.Time) ([]types.ChallengeHistoryEntry, error)
+	HasChallengeToday(userID uuid.UUID, challengeID uuid.UUID) (bool, error)
 	GetCompletedChallengesAmount(userID uuid.UUID) (int, error)
 	GetAllChallengesAmount(userID uuid.UUID) (int, error)
 
+	// challenge catalog
+	GetChallengeCatalog() ([]types.CatalogChallenge, error)
+	SaveCatalogChallenge(challenge types.CatalogChallenge, create bool, actor string) error
+	RetireChallenge(challengeID string, actor string) error
+	SetFeaturedChallenge(date time.Time, slot int, challengeID string, actor string) error
+	RemoveFeaturedChallenge(date time.Time, slot int, actor string) error
+	GetFeaturedChallenges(from, to time.Time) ([]types.FeaturedChallenge, error)
+	GetChallengeAuditLog(limit int) ([]types.ChallengeAuditEntry, error)
+
+	// challenge invitations
+	CreateChallengeInvitation(inviterID, inviteeID, challengeID uuid.UUID) (uuid.UUID, error)
+	GetChallengeInvitationByID(invitationID uuid.UUID) (*types.ChallengeInvitation, error)
+	GetChallengeInvitations(userID uuid.UUID, incoming bool) ([]types.ChallengeInvitation, error)
+	ExpireChallengeInvitations() error
+	AcceptChallengeInvitation(invitationID, inviteeID uuid.UUID) error
+	DeclineChallengeInvitation(invitationID, inviteeID uuid.UUID) error
+
+	// periodic challenges
+	GetActivePeriodicChallenges() ([]types.PeriodicChallenge, error)
+	EnrollPeriodicChallenge(userID uuid.UUID, challengeID string, start, end time.Time) error
+	GetUserPeriodicChallenges(userID uuid.UUID, date time.Time) ([]types.PeriodicChallengeProgress, error)
+	UpdatePeriodicProgress(userID uuid.UUID, challengeID string, periodStart time.Time, progress float64) error
+	CompletePeriodicChallenge(userID uuid.UUID, challengeID string, periodStart time.Time) (int, error)
+
+	// duels
+	CreateDuel(duel types.Duel) (uuid.UUID, error)
+	GetDuelByID(duelID uuid.UUID) (*types.Duel, error)
+	GetDuelsForUser(userID uuid.UUID, closed bool) ([]types.Duel, error)
+	AcceptDuel(duelID uuid.UUID, opponentID uuid.UUID, startsAt, endsAt time.Time) error
+	DeclineDuel(duelID uuid.UUID, opponentID uuid.UUID) error
+	ExpireDuels() error
+	FinishDuel(duelID uuid.UUID, winnerID *uuid.UUID, challengerProgress, opponentProgress float64) error
+
+	// teams
+	CreateTeam(name string, ownerID uuid.UUID, memberIDs []uuid.UUID) (uuid.UUID, error)
+	GetTeamByID(teamID uuid.UUID) (*types.Team, error)
+	GetTeamsForUser(userID uuid.UUID) ([]types.Team, error)
+	AddTeamMember(teamID, userID uuid.UUID) error
+	RemoveTeamMember(teamID, userID uuid.UUID) error
+	DeleteTeam(teamID uuid.UUID) error
+	CreateTeamChallenge(challenge types.TeamChallenge) (uuid.UUID, error)
+	GetTeamChallenges(teamID uuid.UUID) ([]types.TeamChallenge, error)
+	GetTeamChallengeMembers(challengeID uuid.UUID) ([]types.TeamChallengeMember, error)
+	CompleteTeamChallenge(challengeID uuid.UUID, weeklyLimit int) ([]uuid.UUID, error)
+
+	// jobs
+	WithAdvisoryLock(key int64, fn func() error) (bool, error)
+	StartJobRun(jobName string, scheduledFor time.Time, instance string) (uuid.UUID, bool, error)
+	FinishJobRun(runID uuid.UUID, runErr error) error
+	GetJobRuns(jobName string, limit int) ([]types.JobRun, error)
+	GetLastJobRun(jobName string, status string) (*types.JobRun, error)
+	CleanUpJobRuns() error
+
 	// friends
 	AddFriend(userID, friendID uuid.UUID) error
 	GetFriends(userID uuid.UUID) ([]types.User, error)
 	GetFriendsRankedByPoints(userID uuid.UUID) ([]types.User, error)
 	DeleteFriend(userID, friendID uuid.UUID) error
 	GetFollowers(userID uuid.UUID) ([]types.User, error)
+	IsFriend(userID, friendID uuid.UUID) (bool, error)
+
+	// user blocks
+	BlockUser(userID, blockedID uuid.UUID, kind string) error
+	UnblockUser(userID, blockedID uuid.UUID, kind string) error
+	GetBlockedUsers(userID uuid.UUID, kind string) ([]types.BlockedUser, error)
+	IsBlocked(userID, otherID uuid.UUID) (bool, error)
+	GetUsersHiding(authorID uuid.UUID) ([]uuid.UUID, error)
 
 	// runs
 	SaveRun(userID uuid.UUID, route string, duration string, distance float64) error
 	GetAllRunsByUser(userID uuid.UUID) ([]types.RunDTO, error)
 	DeleteRun(runID uuid.UUID) error
+	GetLongestRunOnDate(userID uuid.UUID, date time.Time) (float64, error)
+	GetRunDistanceInRange(userID uuid.UUID, from, to time.Time) (float64, error)
+	GetRunTotalInWindow(userID uuid.UUID, from, to time.Time, target float64) (float64, *time.Time, error)
 	SavePlannedRun(userID uuid.UUID, route string, name string, distance float64) error
 	GetAllPlannedRunsByUser(userID uuid.UUID) ([]types.PlannedRunDTO, error)
 	DeletePlannedRun(runID uuid.UUID) error
@@ -105,6 +252,21 @@ type Service interface {
 	AddReactionToChatMessage(userID uuid.UUID, messageID uuid.UUID) error
 	CountReactionsForMessage(messageID uuid.UUID) (int, error)
 	GetIDByMessageID(messageID uuid.UUID) (uuid.UUID, error)
+	DeleteChatMessage(messageID uuid.UUID) error
+
+	// streaks
+	GetStreak(userID uuid.UUID) (*types.Streak, error)
+	SaveStreak(streak types.Streak) error
+	GetStepsInRange(userID uuid.UUID, from, to time.Time) (map[string]int, error)
+
+	// shop
+	GetShopItems(includeInactive bool) ([]types.ShopItem, error)
+	GetShopItemByID(itemID uuid.UUID) (*types.ShopItem, error)
+	CreateShopItem(item types.ShopItem) (uuid.UUID, error)
+	UpdateShopItem(item types.ShopItem) error
+	PurchaseShopItem(userID uuid.UUID, itemID uuid.UUID) (*types.Purchase, error)
+	GetInventory(userID uuid.UUID) ([]types.InventoryItem, error)
+	GetPurchaseHistory(userID uuid.UUID) ([]types.Purchase, error)
 }
 
 type service struct {
@@ -0,0 +1,281 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const duelColumns = `
+	d.id, d.challenger_id, cu.username, d.opponent_id, ou.username, d.type, d.target_value,
+	d.duration_days, d.stake, d.status, d.challenger_progress, d.opponent_progress, d.winner_id,
+	d.created_at, d.expires_at, d.starts_at, d.ends_at, d.finished_at
+`
+
+const duelJoins = `
+	FROM duels d
+	JOIN users cu ON cu.id = d.challenger_id
+	JOIN users ou ON ou.id = d.opponent_id
+`
+
+func scanDuel(row rowScanner) (types.Duel, error) {
+	var duel types.Duel
+	var target sql.NullFloat64
+	var winner uuid.NullUUID
+	var startsAt, endsAt, finishedAt sql.NullTime
+	err := row.Scan(
+		&duel.ID, &duel.ChallengerID, &duel.ChallengerName, &duel.OpponentID, &duel.OpponentName,
+		&duel.Type, &target, &duel.DurationDays, &duel.Stake, &duel.Status,
+		&duel.ChallengerProgress, &duel.OpponentProgress, &winner,
+		&duel.CreatedAt, &duel.ExpiresAt, &startsAt, &endsAt, &finishedAt,
+	)
+	if err != nil {
+		return duel, err
+	}
+	if target.Valid {
+		duel.Target = &target.Float64
+	}
+	if winner.Valid {
+		duel.WinnerID = &winner.UUID
+	}
+	if startsAt.Valid {
+		duel.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		duel.EndsAt = &endsAt.Time
+	}
+	if finishedAt.Valid {
+		duel.FinishedAt = &finishedAt.Time
+	}
+	return duel, nil
+}
+
+func (s *service) CreateDuel(duel types.Duel) (uuid.UUID, error) {
+	query := `
+		INSERT INTO duels (challenger_id, opponent_id, type, target_value, duration_days, stake, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	var id uuid.UUID
+	err := s.db.QueryRow(query, duel.ChallengerID, duel.OpponentID, duel.Type, duel.Target, duel.DurationDays, duel.Stake,
+		duel.ExpiresAt).Scan(&id)
+	if err != nil {
+		logger.Error("Failed to create duel", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return id, nil
+}
+
+func (s *service) GetDuelByID(duelID uuid.UUID) (*types.Duel, error) {
+	query := `SELECT ` + duelColumns + duelJoins + ` WHERE d.id = $1`
+	duel, err := scanDuel(s.db.QueryRow(query, duelID))
+	if err == sql.ErrNoRows {
+		return nil, custom_error.ErrDuelNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to fetch duel", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return &duel, nil
+}
+
+// GetDuelsForUser returns the open (pending and active) or the closed (finished,
+// declined, expired and cancelled) duels the user takes part in, newest first.
+func (s *service) GetDuelsForUser(userID uuid.UUID, closed bool) ([]types.Duel, error) {
+	statuses := `d.status IN ('pending', 'active')`
+	if closed {
+		statuses = `d.status IN ('finished', 'declined', 'expired', 'cancelled')`
+	}
+	query := `SELECT ` + duelColumns + duelJoins + `
+		WHERE (d.challenger_id = $1 OR d.opponent_id = $1) AND ` + statuses + `
+		ORDER BY d.created_at DESC
+	`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		logger.Error("Failed to fetch duels", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	duels := []types.Duel{}
+	for rows.Next() {
+		duel, err := scanDuel(rows)
+		if err != nil {
+			logger.Error("Failed to scan duel", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		duels = append(duels, duel)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over duels", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return duels, nil
+}
+
+// AcceptDuel starts a pending duel and holds back the stake of both users
+// until the duel is settled.
+func (s *service) AcceptDuel(duelID uuid.UUID, opponentID uuid.UUID, startsAt, endsAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var challengerID uuid.UUID
+	var stake int
+	err = tx.QueryRow(`
+		UPDATE duels
+		SET status = 'active', starts_at = $3, ends_at = $4
+		WHERE id = $1 AND opponent_id = $2 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP
+		RETURNING challenger_id, stake
+	`, duelID, opponentID, startsAt, endsAt).Scan(&challengerID, &stake)
+	if err == sql.ErrNoRows {
+		return custom_error.ErrDuelNotPending
+	}
+	if err != nil {
+		logger.Error("Failed to accept duel", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	for _, userID := range []uuid.UUID{challengerID, opponentID} {
+		result, err := tx.Exec(`
+			UPDATE users SET rocketpoints = rocketpoints - $2
+			WHERE id = $1 AND rocketpoints >= $2
+		`, userID, stake)
+		if err != nil {
+			logger.Error("Failed to hold back duel stake", err)
+			return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return custom_error.ErrInsufficientPoints
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *service) DeclineDuel(duelID uuid.UUID, opponentID uuid.UUID) error {
+	result, err := s.db.Exec(`
+		UPDATE duels
+		SET status = 'declined', finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND opponent_id = $2 AND status = 'pending'
+	`, duelID, opponentID)
+	if err != nil {
+		logger.Error("Failed to decline duel", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return custom_error.ErrDuelNotPending
+	}
+	return nil
+}
+
+// ExpireDuels marks pending duels the opponent did not respond to in time as expired.
+// No stakes are held back before acceptance, so there is nothing to pay back.
+func (s *service) ExpireDuels() error {
+	_, err := s.db.Exec(`
+		UPDATE duels
+		SET status = 'expired', finished_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND expires_at <= CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		logger.Error("Failed to expire duels", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+// cancelDuels ends the pending and active duels matching condition, which refers to
+// the duels' columns and args. Pending duels are declined, active duels are cancelled
+// and both stakes are paid back.
+func cancelDuels(tx *sql.Tx, condition string, args ...any) error {
+	_, err := tx.Exec(`
+		UPDATE duels
+		SET status = 'declined', finished_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND (`+condition+`)
+	`, args...)
+	if err != nil {
+		logger.Error("Failed to decline duels", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	_, err = tx.Exec(`
+		WITH cancelled AS (
+			UPDATE duels
+			SET status = 'cancelled', finished_at = CURRENT_TIMESTAMP
+			WHERE status = 'active' AND (`+condition+`)
+			RETURNING challenger_id, opponent_id, stake
+		), refunds AS (
+			SELECT user_id, SUM(stake) AS stake
+			FROM (
+				SELECT challenger_id AS user_id, stake FROM cancelled
+				UNION ALL
+				SELECT opponent_id, stake FROM cancelled
+			) stakes
+			GROUP BY user_id
+		)
+		UPDATE users u SET rocketpoints = u.rocketpoints + r.stake
+		FROM refunds r
+		WHERE u.id = r.user_id
+	`, args...)
+	if err != nil {
+		logger.Error("Failed to cancel duels", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+// FinishDuel settles an active duel. The winner gets both stakes, on a draw
+// (winnerID nil) every user gets their own stake back.
+func (s *service) FinishDuel(duelID uuid.UUID, winnerID *uuid.UUID, challengerProgress, opponentProgress float64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var challengerID, opponentID uuid.UUID
+	var stake int
+	err = tx.QueryRow(`
+		UPDATE duels
+		SET status = 'finished', winner_id = $2, challenger_progress = $3, opponent_progress = $4,
+			finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'active'
+		RETURNING challenger_id, opponent_id, stake
+	`, duelID, winnerID, challengerProgress, opponentProgress).Scan(&challengerID, &opponentID, &stake)
+	if err == sql.ErrNoRows {
+		// Someone else settled it in the meantime
+		return custom_error.ErrDuelNotPending
+	}
+	if err != nil {
+		logger.Error("Failed to finish duel", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	payouts := map[uuid.UUID]int{challengerID: stake, opponentID: stake}
+	if winnerID != nil {
+		payouts = map[uuid.UUID]int{*winnerID: 2 * stake}
+	}
+	for userID, points := range payouts {
+		_, err := tx.Exec(`UPDATE users SET rocketpoints = rocketpoints + $2 WHERE id = $1`, userID, points)
+		if err != nil {
+			logger.Error("Failed to pay out duel stake", err)
+			return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
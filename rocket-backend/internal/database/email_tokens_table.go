@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CreateEmailToken stores the hash of a token sent to the user by email. Earlier
+// unused tokens of the same purpose stop working, only the latest email counts.
+func (s *service) CreateEmailToken(userID uuid.UUID, purpose string, email string, tokenHash string, expiresAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM email_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose)
+	if err != nil {
+		logger.Error("Failed to delete previous email tokens", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO email_tokens (user_id, purpose, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, purpose, email, tokenHash, expiresAt)
+	if err != nil {
+		logger.Error("Failed to save email token", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// VerifyEmail uses up a verification token and marks the address it was sent to as
+// verified. Tokens sent to an address the user has changed since are rejected.
+func (s *service) VerifyEmail(tokenHash string) (uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, email, err := consumeEmailToken(tx, types.EmailTokenVerify, tokenHash)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	result, err := tx.Exec(`UPDATE credentials SET email_verified = TRUE WHERE id = $1 AND email = $2`, userID, email)
+	if err != nil {
+		logger.Error("Failed to verify email", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return uuid.Nil, custom_error.ErrInvalidEmailToken
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return userID, nil
+}
+
+// ResetPassword uses up a password reset token and sets the new password. Following
+// the link proves the user owns the address, so it counts as verified as well.
+func (s *service) ResetPassword(tokenHash string, newPassword string) (uuid.UUID, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to hash new password: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, email, err := consumeEmailToken(tx, types.EmailTokenReset, tokenHash)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE credentials SET password = $3, email_verified = TRUE
+		WHERE id = $1 AND email = $2
+	`, userID, email, string(hashedPassword))
+	if err != nil {
+		logger.Error("Failed to reset password", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return uuid.Nil, custom_error.ErrInvalidEmailToken
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return userID, nil
+}
+
+// consumeEmailToken marks an unused, unexpired token as used and returns its user and address.
+func consumeEmailToken(tx *sql.Tx, purpose string, tokenHash string) (uuid.UUID, string, error) {
+	var userID uuid.UUID
+	var email string
+	err := tx.QueryRow(`
+		UPDATE email_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id, email
+	`, tokenHash, purpose).Scan(&userID, &email)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, "", custom_error.ErrInvalidEmailToken
+	}
+	if err != nil {
+		logger.Error("Failed to consume email token", err)
+		return uuid.Nil, "", fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return userID, email, nil
+}
@@ -29,6 +29,19 @@ func (s *service) AddFriend(userID, friendID uuid.UUID) error {
 	return nil
 }
 
+// IsFriend reports whether userID has added friendID as a friend.
+func (s *service) IsFriend(userID, friendID uuid.UUID) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM friends WHERE user_id = $1 AND friend_id = $2)
+	`, userID, friendID).Scan(&exists)
+	if err != nil {
+		logger.Error("Failed to check friendship", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return exists, nil
+}
+
 func (s *service) DeleteFriend(userID, friendID uuid.UUID) error {
 	result, err := s.db.Exec(`
 		DELETE FROM friends
@@ -58,7 +71,7 @@ func (s *service) GetFriends(userID uuid.UUID) ([]types.User, error) {
 		SELECT u.id, u.username, u.email, u.rocketpoints
 		FROM friends f
 		JOIN users u ON f.friend_id = u.id
-		WHERE f.user_id = $1
+		WHERE f.user_id = $1 AND ` + activeUser("u.id") + `
 	`
 
 	rows, err := s.db.Query(query, userID)
@@ -95,7 +108,7 @@ func (s *service) GetFriendsRankedByPoints(userID uuid.UUID) ([]types.User, erro
 		SELECT u.id, u.username, u.email, u.rocketpoints
 		FROM friends f
 		JOIN users u ON f.friend_id = u.id
-		WHERE f.user_id = $1
+		WHERE f.user_id = $1 AND ` + activeUser("u.id") + `
 	`
 	rows, err := s.db.Query(query, userID)
 	if err != nil {
@@ -145,7 +158,7 @@ func (s *service) GetFollowers(userID uuid.UUID) ([]types.User, error) {
 		SELECT u.id, u.username, u.email, u.rocketpoints
 		FROM friends f
 		JOIN users u ON f.user_id = u.id
-		WHERE f.friend_id = $1
+		WHERE f.friend_id = $1 AND ` + activeUser("u.id") + `
 	`
 	rows, err := s.db.Query(query, userID)
 	if err != nil {
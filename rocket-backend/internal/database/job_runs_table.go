@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobRunRetentionDays is how long the job run history is kept.
+const JobRunRetentionDays = 30
+
+// WithAdvisoryLock runs fn while holding the Postgres advisory lock with the given key.
+// Advisory locks belong to a session, so the lock is taken on a dedicated connection.
+// It returns false without calling fn when another session holds the lock.
+func (s *service) WithAdvisoryLock(key int64, fn func() error) (bool, error) {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		logger.Error("Failed to get a connection for the advisory lock", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil {
+		logger.Error("Failed to acquire advisory lock", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			logger.Error("Failed to release advisory lock", err)
+		}
+	}()
+
+	return true, fn()
+}
+
+// StartJobRun claims the run of a job for the given slot. It returns false when
+// the slot has already been claimed, e.g. by another replica.
+func (s *service) StartJobRun(jobName string, scheduledFor time.Time, instance string) (uuid.UUID, bool, error) {
+	var runID uuid.UUID
+	err := s.db.QueryRow(`
+		INSERT INTO job_runs (job_name, scheduled_for, instance)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (job_name, scheduled_for) DO NOTHING
+		RETURNING id
+	`, jobName, scheduledFor, instance).Scan(&runID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		logger.Error("Failed to start job run", err)
+		return uuid.Nil, false, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return runID, true, nil
+}
+
+// FinishJobRun records the outcome of a run, a nil runErr marks it as succeeded.
+func (s *service) FinishJobRun(runID uuid.UUID, runErr error) error {
+	status := types.JobRunSucceeded
+	var message any
+	if runErr != nil {
+		status = types.JobRunFailed
+		message = runErr.Error()
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE job_runs
+		SET status = $2, error = $3, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, runID, status, message)
+	if err != nil {
+		logger.Error("Failed to finish job run", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+func scanJobRun(row rowScanner) (*types.JobRun, error) {
+	var run types.JobRun
+	var message sql.NullString
+	var finishedAt sql.NullTime
+	err := row.Scan(&run.ID, &run.JobName, &run.ScheduledFor, &run.Instance, &run.Status, &message, &run.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	run.Error = message.String
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+const jobRunColumns = `id, job_name, scheduled_for, instance, status, error, started_at, finished_at`
+
+// GetJobRuns returns the latest runs of a job, newest first.
+func (s *service) GetJobRuns(jobName string, limit int) ([]types.JobRun, error) {
+	rows, err := s.db.Query(`
+		SELECT `+jobRunColumns+`
+		FROM job_runs
+		WHERE job_name = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`, jobName, limit)
+	if err != nil {
+		logger.Error("Failed to fetch job runs", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	runs := []types.JobRun{}
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			logger.Error("Failed to scan job run", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		runs = append(runs, *run)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over job runs", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return runs, nil
+}
+
+// GetLastJobRun returns the latest run of a job, limited to the given status unless
+// it is empty. It returns nil when there is no such run.
+func (s *service) GetLastJobRun(jobName string, status string) (*types.JobRun, error) {
+	row := s.db.QueryRow(`
+		SELECT `+jobRunColumns+`
+		FROM job_runs
+		WHERE job_name = $1 AND ($2 = '' OR status = $2)
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, jobName, status)
+	run, err := scanJobRun(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("Failed to fetch last job run", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return run, nil
+}
+
+// CleanUpJobRuns deletes the run history older than JobRunRetentionDays.
+func (s *service) CleanUpJobRuns() error {
+	_, err := s.db.Exec(`DELETE FROM job_runs WHERE started_at < CURRENT_TIMESTAMP - make_interval(days => $1)`, JobRunRetentionDays)
+	if err != nil {
+		logger.Error("Failed to clean up job runs", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	return nil
+}
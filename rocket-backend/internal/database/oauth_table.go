@@ -0,0 +1,326 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateOAuthClient registers a partner app.
+func (s *service) CreateOAuthClient(client types.OAuthClient) error {
+	var secretHash sql.NullString
+	if client.SecretHash != "" {
+		secretHash = sql.NullString{String: client.SecretHash, Valid: true}
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, scopes, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, client.ID, client.Name, secretHash, strings.Join(client.RedirectURIs, " "), strings.Join(client.Scopes, " "),
+		client.CreatedBy, client.CreatedAt)
+	if err != nil {
+		logger.Error("Failed to save OAuth client", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// GetOAuthClients returns all registered clients, the newest first.
+func (s *service) GetOAuthClients() ([]types.OAuthClient, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, secret_hash, redirect_uris, scopes, created_by, created_at
+		FROM oauth_clients
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		logger.Error("Failed to load OAuth clients", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	clients := []types.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			logger.Error("Failed to scan OAuth client", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// GetOAuthClient returns a client, ErrClientNotFound if it is not registered.
+func (s *service) GetOAuthClient(clientID string) (types.OAuthClient, error) {
+	row := s.db.QueryRow(`
+		SELECT id, name, secret_hash, redirect_uris, scopes, created_by, created_at
+		FROM oauth_clients
+		WHERE id = $1
+	`, clientID)
+	client, err := scanOAuthClient(row)
+	if err == sql.ErrNoRows {
+		return client, custom_error.ErrClientNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to get OAuth client", err)
+		return client, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return client, nil
+}
+
+// DeleteOAuthClient removes a client, together with its consents, codes and tokens.
+func (s *service) DeleteOAuthClient(clientID string) error {
+	result, err := s.db.Exec(`DELETE FROM oauth_clients WHERE id = $1`, clientID)
+	if err != nil {
+		logger.Error("Failed to delete OAuth client", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return custom_error.ErrClientNotFound
+	}
+	return nil
+}
+
+// GetOAuthConsent returns the scopes the user allowed the client, none if the user
+// never did.
+func (s *service) GetOAuthConsent(userID uuid.UUID, clientID string) ([]string, error) {
+	var scopes string
+	err := s.db.QueryRow(`SELECT scopes FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID).Scan(&scopes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("Failed to get OAuth consent", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return strings.Fields(scopes), nil
+}
+
+// GetOAuthConsents returns the apps the user gave access, the most recent first.
+func (s *service) GetOAuthConsents(userID uuid.UUID) ([]types.OAuthConsent, error) {
+	rows, err := s.db.Query(`
+		SELECT oc.client_id, cl.name, oc.scopes, oc.granted_at
+		FROM oauth_consents oc
+		JOIN oauth_clients cl ON cl.id = oc.client_id
+		WHERE oc.user_id = $1
+		ORDER BY oc.granted_at DESC
+	`, userID)
+	if err != nil {
+		logger.Error("Failed to load OAuth consents", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	consents := []types.OAuthConsent{}
+	for rows.Next() {
+		var consent types.OAuthConsent
+		var scopes string
+		if err := rows.Scan(&consent.ClientID, &consent.ClientName, &scopes, &consent.GrantedAt); err != nil {
+			logger.Error("Failed to scan OAuth consent", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		consent.Scopes = strings.Fields(scopes)
+		consents = append(consents, consent)
+	}
+	return consents, rows.Err()
+}
+
+// RevokeOAuthConsent takes back the access of a client, its tokens stop working right
+// away. Returns ErrClientNotFound if the user never allowed the client anything.
+func (s *service) RevokeOAuthConsent(userID uuid.UUID, clientID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID)
+	if err != nil {
+		logger.Error("Failed to delete OAuth consent", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return custom_error.ErrClientNotFound
+	}
+	for _, query := range []string{
+		`DELETE FROM oauth_authorization_codes WHERE user_id = $1 AND client_id = $2`,
+		`DELETE FROM oauth_tokens WHERE user_id = $1 AND client_id = $2`,
+	} {
+		if _, err := tx.Exec(query, userID, clientID); err != nil {
+			logger.Error("Failed to revoke OAuth tokens", err)
+			return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CreateOAuthCode stores an authorization code and adds its scopes to the consent of
+// the user.
+func (s *service) CreateOAuthCode(codeHash string, code types.OAuthCode, expiresAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var existing string
+	err = tx.QueryRow(`
+		SELECT scopes FROM oauth_consents WHERE user_id = $1 AND client_id = $2 FOR UPDATE
+	`, code.UserID, code.ClientID).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Error("Failed to get OAuth consent", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	scopes := append(strings.Fields(existing), code.Scopes...)
+	slices.Sort(scopes)
+	_, err = tx.Exec(`
+		INSERT INTO oauth_consents (user_id, client_id, scopes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, granted_at = CURRENT_TIMESTAMP
+	`, code.UserID, code.ClientID, strings.Join(slices.Compact(scopes), " "))
+	if err != nil {
+		logger.Error("Failed to save OAuth consent", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, codeHash, code.ClientID, code.UserID, code.RedirectURI, strings.Join(code.Scopes, " "), code.CodeChallenge, expiresAt)
+	if err != nil {
+		logger.Error("Failed to save authorization code", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthCode returns and deletes an unexpired code of the client, so every code
+// works only once.
+func (s *service) ConsumeOAuthCode(codeHash string, clientID string) (types.OAuthCode, error) {
+	var code types.OAuthCode
+	var scopes string
+	err := s.db.QueryRow(`
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = $1 AND client_id = $2 AND expires_at > CURRENT_TIMESTAMP
+		RETURNING client_id, user_id, redirect_uri, scopes, code_challenge
+	`, codeHash, clientID).Scan(&code.ClientID, &code.UserID, &code.RedirectURI, &scopes, &code.CodeChallenge)
+	if err == sql.ErrNoRows {
+		return code, custom_error.ErrInvalidGrant
+	}
+	if err != nil {
+		logger.Error("Failed to consume authorization code", err)
+		return code, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	code.Scopes = strings.Fields(scopes)
+	return code, nil
+}
+
+// CreateOAuthToken stores a new grant of a client.
+func (s *service) CreateOAuthToken(token types.OAuthToken, accessHash string, refreshHash string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO oauth_tokens (id, client_id, user_id, scopes, access_token_hash, refresh_token_hash, access_expires_at, refresh_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, token.ID, token.ClientID, token.UserID, strings.Join(token.Scopes, " "), accessHash, refreshHash,
+		token.AccessExpiresAt, token.RefreshExpiresAt)
+	if err != nil {
+		logger.Error("Failed to save OAuth token", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// RefreshOAuthToken replaces both tokens of the grant with the refresh token, the old
+// ones stop working. Returns ErrInvalidGrant for unknown or expired refresh tokens.
+func (s *service) RefreshOAuthToken(refreshHash string, clientID string, newAccessHash string, newRefreshHash string, accessExpiresAt time.Time, refreshExpiresAt time.Time) (types.OAuthToken, error) {
+	row := s.db.QueryRow(`
+		UPDATE oauth_tokens
+		SET access_token_hash = $3, refresh_token_hash = $4, access_expires_at = $5, refresh_expires_at = $6
+		WHERE refresh_token_hash = $1 AND client_id = $2 AND refresh_expires_at > CURRENT_TIMESTAMP
+		RETURNING id, client_id, user_id, scopes, access_expires_at, refresh_expires_at
+	`, refreshHash, clientID, newAccessHash, newRefreshHash, accessExpiresAt, refreshExpiresAt)
+	token, err := scanOAuthToken(row)
+	if err == sql.ErrNoRows {
+		return token, custom_error.ErrInvalidGrant
+	}
+	if err != nil {
+		logger.Error("Failed to refresh OAuth token", err)
+		return token, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return token, nil
+}
+
+// AuthenticateOAuthToken returns the grant with the unexpired access token and records
+// that it was used.
+func (s *service) AuthenticateOAuthToken(accessHash string) (types.OAuthToken, error) {
+	row := s.db.QueryRow(`
+		SELECT id, client_id, user_id, scopes, access_expires_at, refresh_expires_at
+		FROM oauth_tokens
+		WHERE access_token_hash = $1 AND access_expires_at > CURRENT_TIMESTAMP
+	`, accessHash)
+	token, err := scanOAuthToken(row)
+	if err == sql.ErrNoRows {
+		return token, custom_error.ErrTokenNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to look up OAuth token", err)
+		return token, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE oauth_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '`+sessionTouchInterval+`')
+	`, token.ID)
+	if err != nil {
+		// Not worth failing the request for
+		logger.Error("Failed to update OAuth token usage", err)
+	}
+	return token, nil
+}
+
+// RevokeOAuthToken ends the grant of the client with the access or refresh token.
+// Unknown tokens are ignored.
+func (s *service) RevokeOAuthToken(tokenHash string, clientID string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM oauth_tokens
+		WHERE client_id = $2 AND (access_token_hash = $1 OR refresh_token_hash = $1)
+	`, tokenHash, clientID)
+	if err != nil {
+		logger.Error("Failed to revoke OAuth token", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	return nil
+}
+
+func scanOAuthClient(row rowScanner) (types.OAuthClient, error) {
+	var client types.OAuthClient
+	var secretHash sql.NullString
+	var redirectURIs, scopes string
+	err := row.Scan(&client.ID, &client.Name, &secretHash, &redirectURIs, &scopes, &client.CreatedBy, &client.CreatedAt)
+	client.SecretHash = secretHash.String
+	client.Confidential = secretHash.Valid
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	client.Scopes = strings.Fields(scopes)
+	return client, err
+}
+
+func scanOAuthToken(row rowScanner) (types.OAuthToken, error) {
+	var token types.OAuthToken
+	var scopes string
+	err := row.Scan(&token.ID, &token.ClientID, &token.UserID, &scopes, &token.AccessExpiresAt, &token.RefreshExpiresAt)
+	token.Scopes = strings.Fields(scopes)
+	return token, err
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SaveOIDCState remembers a login attempt until the provider redirects back.
+func (s *service) SaveOIDCState(stateHash string, state types.OIDCState, expiresAt time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO oidc_states (state_hash, provider, nonce, code_verifier, device_name, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, stateHash, state.Provider, state.Nonce, state.CodeVerifier, state.DeviceName, expiresAt)
+	if err != nil {
+		logger.Error("Failed to save login state", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// ConsumeOIDCState returns and deletes a login attempt, so every callback works only once.
+func (s *service) ConsumeOIDCState(stateHash string, provider string) (types.OIDCState, error) {
+	var state types.OIDCState
+	err := s.db.QueryRow(`
+		DELETE FROM oidc_states
+		WHERE state_hash = $1 AND provider = $2 AND expires_at > CURRENT_TIMESTAMP
+		RETURNING provider, nonce, code_verifier, device_name
+	`, stateHash, provider).Scan(&state.Provider, &state.Nonce, &state.CodeVerifier, &state.DeviceName)
+	if err == sql.ErrNoRows {
+		return state, custom_error.ErrInvalidOIDCState
+	}
+	if err != nil {
+		logger.Error("Failed to consume login state", err)
+		return state, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return state, nil
+}
+
+// GetUserByOIDCIdentity returns the credentials linked to a provider's user, ErrUserNotFound if none are.
+func (s *service) GetUserByOIDCIdentity(provider string, subject string) (types.Credentials, error) {
+	var userID uuid.UUID
+	err := s.db.QueryRow(`SELECT user_id FROM oidc_identities WHERE provider = $1 AND subject = $2`, provider, subject).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return types.Credentials{}, custom_error.ErrUserNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to get linked identity", err)
+		return types.Credentials{}, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return s.GetCredentialsByID(userID)
+}
+
+// LinkOIDCIdentity connects a provider's user to an account. The provider vouched
+// for the address, so the account counts as verified if it has that address. An
+// unverified account may have been registered by someone else with that address,
+// so its password is removed; the owner can set a new one with a password reset.
+// Linking fails if the account does not have that address.
+func (s *service) LinkOIDCIdentity(userID uuid.UUID, provider string, subject string, email string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO oidc_identities (provider, subject, user_id, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, subject) DO NOTHING
+	`, provider, subject, userID, email)
+	if err != nil {
+		logger.Error("Failed to link identity", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	result, err := tx.Exec(`
+		UPDATE credentials
+		SET email_verified = TRUE, password = CASE WHEN email_verified THEN password ELSE '' END
+		WHERE id = $1 AND LOWER(email) = LOWER($2)
+	`, userID, email)
+	if err != nil {
+		logger.Error("Failed to verify email", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("%w: account does not have the address %s", custom_error.ErrFailedToUpdate, email)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
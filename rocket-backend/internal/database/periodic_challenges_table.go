@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *service) GetActivePeriodicChallenges() ([]types.PeriodicChallenge, error) {
+	query := `
+		SELECT id, description, period, metric, target_value, target_unit, points_reward
+		FROM periodic_challenges
+		WHERE active = TRUE
+		ORDER BY period, description
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		logger.Error("Failed to fetch periodic challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	var challenges []types.PeriodicChallenge
+	for rows.Next() {
+		var challenge types.PeriodicChallenge
+		if err := rows.Scan(&challenge.ID, &challenge.Text, &challenge.Period, &challenge.Metric,
+			&challenge.Parameters.Target, &challenge.Parameters.Unit, &challenge.Points); err != nil {
+			logger.Error("Failed to scan periodic challenge", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		challenges = append(challenges, challenge)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over periodic challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return challenges, nil
+}
+
+// EnrollPeriodicChallenge starts tracking a challenge for the given window. Enrolling twice is a no-op.
+func (s *service) EnrollPeriodicChallenge(userID uuid.UUID, challengeID string, start, end time.Time) error {
+	query := `
+		INSERT INTO user_periodic_challenges (user_id, challenge_id, period_start, period_end)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, challenge_id, period_start) DO NOTHING
+	`
+	_, err := s.db.Exec(query, userID, challengeID, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		logger.Error("Failed to enroll user into periodic challenge", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// GetUserPeriodicChallenges returns the user's periodic challenges whose window contains date.
+func (s *service) GetUserPeriodicChallenges(userID uuid.UUID, date time.Time) ([]types.PeriodicChallengeProgress, error) {
+	query := `
+		SELECT pc.id, pc.description, pc.period, pc.metric, pc.target_value, pc.target_unit, pc.points_reward,
+			upc.period_start, upc.period_end, upc.progress, upc.is_completed, upc.completed_at
+		FROM user_periodic_challenges upc
+		JOIN periodic_challenges pc ON pc.id = upc.challenge_id
+		WHERE upc.user_id = $1 AND $2 BETWEEN upc.period_start AND upc.period_end
+		ORDER BY upc.period_end, pc.description
+	`
+	rows, err := s.db.Query(query, userID, date.Format("2006-01-02"))
+	if err != nil {
+		logger.Error("Failed to fetch user periodic challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	progress := []types.PeriodicChallengeProgress{}
+	for rows.Next() {
+		var entry types.PeriodicChallengeProgress
+		var completedAt sql.NullTime
+		if err := rows.Scan(&entry.ID, &entry.Text, &entry.Period, &entry.Metric,
+			&entry.Parameters.Target, &entry.Parameters.Unit, &entry.Points,
+			&entry.PeriodStart, &entry.PeriodEnd, &entry.Progress, &entry.Completed, &completedAt); err != nil {
+			logger.Error("Failed to scan user periodic challenge", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		if completedAt.Valid {
+			entry.CompletedAt = &completedAt.Time
+		}
+		progress = append(progress, entry)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over user periodic challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return progress, nil
+}
+
+func (s *service) UpdatePeriodicProgress(userID uuid.UUID, challengeID string, periodStart time.Time, progress float64) error {
+	query := `
+		UPDATE user_periodic_challenges
+		SET progress = $4
+		WHERE user_id = $1 AND challenge_id = $2 AND period_start = $3 AND is_completed = FALSE
+	`
+	_, err := s.db.Exec(query, userID, challengeID, periodStart.Format("2006-01-02"), progress)
+	if err != nil {
+		logger.Error("Failed to update periodic challenge progress", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+// CompletePeriodicChallenge marks the challenge as completed and awards its points
+// in one transaction. It returns the awarded points.
+func (s *service) CompletePeriodicChallenge(userID uuid.UUID, challengeID string, periodStart time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var points int
+	err = tx.QueryRow(`
+		UPDATE user_periodic_challenges upc
+		SET is_completed = TRUE, completed_at = CURRENT_TIMESTAMP
+		FROM periodic_challenges pc
+		WHERE upc.challenge_id = pc.id
+			AND upc.user_id = $1 AND upc.challenge_id = $2 AND upc.period_start = $3
+			AND upc.is_completed = FALSE
+		RETURNING pc.points_reward
+	`, userID, challengeID, periodStart.Format("2006-01-02")).Scan(&points)
+	if err == sql.ErrNoRows {
+		return 0, custom_error.ErrAlreadyCompleted
+	}
+	if err != nil {
+		logger.Error("Failed to mark periodic challenge as completed", err)
+		return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	_, err = tx.Exec(`UPDATE users SET rocketpoints = rocketpoints + $2 WHERE id = $1`, userID, points)
+	if err != nil {
+		logger.Error("Failed to award periodic challenge points", err)
+		return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return points, nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// CreatePersonalAccessToken stores a new token of the user, unless the user already
+// has maxTokens of them.
+func (s *service) CreatePersonalAccessToken(token types.PersonalAccessToken, tokenHash string, maxTokens int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serializes concurrent creations of the same user
+	if _, err := tx.Exec(`SELECT id FROM credentials WHERE id = $1 FOR UPDATE`, token.UserID); err != nil {
+		logger.Error("Failed to lock user", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM personal_access_tokens WHERE user_id = $1`, token.UserID).Scan(&count)
+	if err != nil {
+		logger.Error("Failed to count personal access tokens", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	if count >= maxTokens {
+		return custom_error.ErrLimitReached
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO personal_access_tokens (id, user_id, name, prefix, token_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, token.ID, token.UserID, token.Name, token.Prefix, tokenHash, strings.Join(token.Scopes, " "), token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		logger.Error("Failed to save personal access token", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetPersonalAccessTokens returns the tokens of a user, the newest first.
+func (s *service) GetPersonalAccessTokens(userID uuid.UUID) ([]types.PersonalAccessToken, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, name, prefix, scopes, created_at, expires_at, last_used_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		logger.Error("Failed to load personal access tokens", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	tokens := []types.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			logger.Error("Failed to scan personal access token", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeletePersonalAccessToken revokes a token of the user.
+func (s *service) DeletePersonalAccessToken(userID uuid.UUID, tokenID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		logger.Error("Failed to delete personal access token", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return custom_error.ErrTokenNotFound
+	}
+	return nil
+}
+
+// AuthenticatePersonalAccessToken returns the unexpired token with the hash and records
+// that it was used.
+func (s *service) AuthenticatePersonalAccessToken(tokenHash string) (types.PersonalAccessToken, error) {
+	row := s.db.QueryRow(`
+		SELECT id, user_id, name, prefix, scopes, created_at, expires_at, last_used_at
+		FROM personal_access_tokens
+		WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP
+	`, tokenHash)
+	token, err := scanPersonalAccessToken(row)
+	if err == sql.ErrNoRows {
+		return token, custom_error.ErrTokenNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to look up personal access token", err)
+		return token, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE personal_access_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '`+sessionTouchInterval+`')
+	`, token.ID)
+	if err != nil {
+		// Not worth failing the request for
+		logger.Error("Failed to update personal access token usage", err)
+	}
+	return token, nil
+}
+
+func scanPersonalAccessToken(row rowScanner) (types.PersonalAccessToken, error) {
+	var token types.PersonalAccessToken
+	var scopes string
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &scopes,
+		&token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt)
+	token.Scopes = strings.Fields(scopes)
+	return token, err
+}
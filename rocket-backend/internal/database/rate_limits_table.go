@@ -0,0 +1,158 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/ratelimit"
+	"strings"
+	"time"
+)
+
+// Failed logins further apart than this do not add up
+const loginFailureWindow = "1 day"
+
+// TakeRateLimitToken takes a token from the bucket under key, see ratelimit.Policy.
+// Buckets are locked while they are updated, so concurrent requests on different
+// replicas are counted correctly.
+func (s *service) TakeRateLimitToken(key string, policy ratelimit.Policy) (ratelimit.Result, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO NOTHING
+	`, key, policy.Burst)
+	if err != nil {
+		logger.Error("Failed to create rate limit bucket", err)
+		return ratelimit.Result{}, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	var tokens, elapsed float64
+	err = tx.QueryRow(`
+		SELECT tokens, GREATEST(EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - updated_at), 0)
+		FROM rate_limit_buckets
+		WHERE key = $1
+		FOR UPDATE
+	`, key).Scan(&tokens, &elapsed)
+	if err != nil {
+		logger.Error("Failed to load rate limit bucket", err)
+		return ratelimit.Result{}, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	tokens, result := policy.Take(tokens, time.Duration(elapsed*float64(time.Second)))
+	_, err = tx.Exec(`
+		UPDATE rate_limit_buckets SET tokens = $2, updated_at = CURRENT_TIMESTAMP WHERE key = $1
+	`, key, tokens)
+	if err != nil {
+		logger.Error("Failed to update rate limit bucket", err)
+		return ratelimit.Result{}, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ratelimit.Result{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// GetLoginLockout returns how long logins to the email stay blocked, zero if they are not.
+func (s *service) GetLoginLockout(email string) (time.Duration, error) {
+	var remaining float64
+	err := s.db.QueryRow(`
+		SELECT EXTRACT(EPOCH FROM locked_until - CURRENT_TIMESTAMP)
+		FROM login_failures
+		WHERE email = $1 AND locked_until > CURRENT_TIMESTAMP
+	`, normalizeEmail(email)).Scan(&remaining)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		logger.Error("Failed to load login lockout", err)
+		return 0, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return time.Duration(remaining * float64(time.Second)), nil
+}
+
+// RecordLoginFailure counts a failed login for the email and locks further logins once
+// the lockout threshold is reached. It returns how long the email is locked.
+func (s *service) RecordLoginFailure(email string, lockout ratelimit.Lockout) (time.Duration, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failures int
+	err = tx.QueryRow(`
+		INSERT INTO login_failures (email, failures, last_failed_at)
+		VALUES ($1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (email) DO UPDATE SET
+			failures = CASE
+				WHEN login_failures.last_failed_at < CURRENT_TIMESTAMP - INTERVAL '`+loginFailureWindow+`' THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			last_failed_at = CURRENT_TIMESTAMP
+		RETURNING failures
+	`, normalizeEmail(email)).Scan(&failures)
+	if err != nil {
+		logger.Error("Failed to record login failure", err)
+		return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	locked := lockout.Duration(failures)
+	if locked > 0 {
+		_, err = tx.Exec(`
+			UPDATE login_failures SET locked_until = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+			WHERE email = $1
+		`, normalizeEmail(email), locked.Seconds())
+		if err != nil {
+			logger.Error("Failed to lock login", err)
+			return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return locked, nil
+}
+
+// ResetLoginFailures forgets the failed logins of the email after a successful one.
+func (s *service) ResetLoginFailures(email string) error {
+	_, err := s.db.Exec(`DELETE FROM login_failures WHERE email = $1`, normalizeEmail(email))
+	if err != nil {
+		logger.Error("Failed to reset login failures", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	return nil
+}
+
+// CleanUpRateLimits deletes buckets and failed logins that were not touched for a day.
+// Buckets refill long before that, so dropping them changes nothing.
+func (s *service) CleanUpRateLimits() error {
+	_, err := s.db.Exec(`DELETE FROM rate_limit_buckets WHERE updated_at < CURRENT_TIMESTAMP - INTERVAL '1 day'`)
+	if err != nil {
+		logger.Error("Failed to clean up rate limit buckets", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`
+		DELETE FROM login_failures
+		WHERE last_failed_at < CURRENT_TIMESTAMP - INTERVAL '` + loginFailureWindow + `'
+		AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		logger.Error("Failed to clean up login failures", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	return nil
+}
+
+// normalizeEmail makes failed logins count for an email however it is typed.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
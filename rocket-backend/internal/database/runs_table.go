@@ -1,7 +1,9 @@
 package database
 
 import (
+	"database/sql"
 	"rocket-backend/internal/types"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -54,6 +56,60 @@ func (s *service) DeleteRun(runID uuid.UUID) error {
 	return nil
 }
 
+// GetLongestRunOnDate returns the distance of the user's longest run on the given day, 0 if there was none.
+func (s *service) GetLongestRunOnDate(userID uuid.UUID, date time.Time) (float64, error) {
+	query := `
+		SELECT COALESCE(MAX(distance), 0)
+		FROM runs
+		WHERE user_id = $1 AND created_at::date = $2
+	`
+	var distance float64
+	err := s.db.QueryRow(query, userID, date.Format("2006-01-02")).Scan(&distance)
+	if err != nil {
+		return 0, err
+	}
+	return distance, nil
+}
+
+// GetRunDistanceInRange returns the total distance the user ran between from and to, both days inclusive.
+func (s *service) GetRunDistanceInRange(userID uuid.UUID, from, to time.Time) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(distance), 0)
+		FROM runs
+		WHERE user_id = $1 AND created_at::date BETWEEN $2 AND $3
+	`
+	var distance float64
+	err := s.db.QueryRow(query, userID, from.Format("2006-01-02"), to.Format("2006-01-02")).Scan(&distance)
+	if err != nil {
+		return 0, err
+	}
+	return distance, nil
+}
+
+// GetRunTotalInWindow sums up the distance of the user's runs recorded in
+// [from, to) and returns when the running total first reached target, nil if it never did.
+func (s *service) GetRunTotalInWindow(userID uuid.UUID, from, to time.Time, target float64) (float64, *time.Time, error) {
+	query := `
+		WITH window_runs AS (
+			SELECT created_at, SUM(distance) OVER (ORDER BY created_at) AS running_total
+			FROM runs
+			WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		)
+		SELECT COALESCE(MAX(running_total), 0), MIN(created_at) FILTER (WHERE running_total >= $4)
+		FROM window_runs
+	`
+	var distance float64
+	var reachedAt sql.NullTime
+	err := s.db.QueryRow(query, userID, from, to, target).Scan(&distance, &reachedAt)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !reachedAt.Valid {
+		return distance, nil, nil
+	}
+	return distance, &reachedAt.Time, nil
+}
+
 func (s *service) SavePlannedRun(userID uuid.UUID, route string, name string, distance float64) error {
     query := `
         INSERT INTO planned_runs (user_id, route, name, distance)
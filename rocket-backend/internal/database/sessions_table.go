@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// How often last_seen_at of a session is written at most, so every request does not cause a write
+const sessionTouchInterval = "5 minutes"
+
+// GetSessions returns the active sessions of a user, the most recently used first.
+func (s *service) GetSessions(userID uuid.UUID) ([]types.Session, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, device_name, user_agent, ip_address, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		logger.Error("Failed to load sessions", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	sessions := []types.Session{}
+	for rows.Next() {
+		var session types.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.DeviceName, &session.UserAgent,
+			&session.IPAddress, &session.CreatedAt, &session.LastSeenAt); err != nil {
+			logger.Error("Failed to scan session", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession signs a device of the user out. Access tokens of the session stop working
+// right away and its refresh token can no longer be used.
+func (s *service) RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id uuid.UUID
+	err = tx.QueryRow(`
+		SELECT id FROM sessions
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		FOR UPDATE
+	`, sessionID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return custom_error.ErrSessionNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to look up session", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	if err := revokeSession(tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// TouchSession records that the session was just used from the given address.
+func (s *service) TouchSession(sessionID uuid.UUID, ipAddress string) error {
+	_, err := s.db.Exec(`
+		UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, ip_address = $2
+		WHERE id = $1 AND last_seen_at < CURRENT_TIMESTAMP - INTERVAL '`+sessionTouchInterval+`'
+	`, sessionID, ipAddress)
+	if err != nil {
+		logger.Error("Failed to touch session", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+// UpdateLastLogin sets the last login of the user to now.
+func (s *service) UpdateLastLogin(userID uuid.UUID) error {
+	_, err := s.db.Exec(`UPDATE credentials SET last_login = CURRENT_TIMESTAMP WHERE id = $1`, userID)
+	if err != nil {
+		logger.Error("Failed to update last login", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
+
+// revokeSession marks a session and all its refresh tokens as revoked.
+func revokeSession(tx *sql.Tx, sessionID uuid.UUID) error {
+	_, err := tx.Exec(`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, sessionID)
+	if err != nil {
+		logger.Error("Failed to revoke session", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	_, err = tx.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`, sessionID)
+	if err != nil {
+		logger.Error("Failed to revoke refresh tokens of session", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+func (s *service) GetShopItems(includeInactive bool) ([]types.ShopItem, error) {
+	query := `
+		SELECT id, name, description, category, price, max_per_user, active
+		FROM shop_items
+		WHERE active = TRUE OR $1
+		ORDER BY category, price
+	`
+	rows, err := s.db.Query(query, includeInactive)
+	if err != nil {
+		logger.Error("Failed to fetch shop items", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	items := []types.ShopItem{}
+	for rows.Next() {
+		var item types.ShopItem
+		if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.Category, &item.Price, &item.MaxPerUser, &item.Active); err != nil {
+			logger.Error("Failed to scan shop item row", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over shop item rows", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return items, nil
+}
+
+func (s *service) GetShopItemByID(itemID uuid.UUID) (*types.ShopItem, error) {
+	query := `
+		SELECT id, name, description, category, price, max_per_user, active
+		FROM shop_items
+		WHERE id = $1
+	`
+	var item types.ShopItem
+	err := s.db.QueryRow(query, itemID).Scan(&item.ID, &item.Name, &item.Description, &item.Category, &item.Price, &item.MaxPerUser, &item.Active)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrItemNotFound, err)
+		}
+		logger.Error("Failed to fetch shop item", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return &item, nil
+}
+
+func (s *service) CreateShopItem(item types.ShopItem) (uuid.UUID, error) {
+	query := `
+		INSERT INTO shop_items (name, description, category, price, max_per_user, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	var id uuid.UUID
+	err := s.db.QueryRow(query, item.Name, item.Description, item.Category, item.Price, item.MaxPerUser, item.Active).Scan(&id)
+	if err != nil {
+		logger.Error("Failed to create shop item", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return id, nil
+}
+
+func (s *service) UpdateShopItem(item types.ShopItem) error {
+	query := `
+		UPDATE shop_items
+		SET name = $2, description = $3, category = $4, price = $5, max_per_user = $6, active = $7
+		WHERE id = $1
+	`
+	result, err := s.db.Exec(query, item.ID, item.Name, item.Description, item.Category, item.Price, item.MaxPerUser, item.Active)
+	if err != nil {
+		logger.Error("Failed to update shop item", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return custom_error.ErrItemNotFound
+	}
+	return nil
+}
+
+// PurchaseShopItem redeems rocket points for an item. The balance check, the
+// per-user limit and the purchase record all happen in one transaction, so
+// concurrent purchases can never overdraw the balance.
+func (s *service) PurchaseShopItem(userID uuid.UUID, itemID uuid.UUID) (*types.Purchase, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var item types.ShopItem
+	err = tx.QueryRow(`
+		SELECT id, name, category, price, max_per_user
+		FROM shop_items
+		WHERE id = $1 AND active = TRUE
+		FOR SHARE
+	`, itemID).Scan(&item.ID, &item.Name, &item.Category, &item.Price, &item.MaxPerUser)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, custom_error.ErrItemNotFound
+		}
+		logger.Error("Failed to fetch shop item for purchase", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+
+	var result sql.Result
+	if item.Category == types.ItemCategoryStreakFreeze {
+		// Streak freezes are consumed by the streak evaluation, so they live on the streak itself
+		result, err = tx.Exec(`
+			UPDATE streaks SET freezes = freezes + 1
+			WHERE user_id = $1 AND ($2::int IS NULL OR freezes < $2)
+		`, userID, item.MaxPerUser)
+	} else {
+		result, err = tx.Exec(`
+			INSERT INTO user_inventory (user_id, item_id, quantity)
+			VALUES ($1, $2, 1)
+			ON CONFLICT (user_id, item_id) DO UPDATE
+			SET quantity = user_inventory.quantity + 1
+			WHERE $3::int IS NULL OR user_inventory.quantity < $3
+		`, userID, item.ID, item.MaxPerUser)
+	}
+	if err != nil {
+		logger.Error("Failed to add item to inventory", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, custom_error.ErrLimitReached
+	}
+
+	result, err = tx.Exec(`
+		UPDATE users SET rocketpoints = rocketpoints - $2
+		WHERE id = $1 AND rocketpoints >= $2
+	`, userID, item.Price)
+	if err != nil {
+		logger.Error("Failed to deduct rocket points", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, custom_error.ErrInsufficientPoints
+	}
+
+	purchase := types.Purchase{ItemID: item.ID, ItemName: item.Name, Price: item.Price}
+	err = tx.QueryRow(`
+		INSERT INTO shop_purchases (user_id, item_id, price)
+		VALUES ($1, $2, $3)
+		RETURNING id, purchased_at
+	`, userID, item.ID, item.Price).Scan(&purchase.ID, &purchase.PurchasedAt)
+	if err != nil {
+		logger.Error("Failed to save purchase", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &purchase, nil
+}
+
+func (s *service) GetInventory(userID uuid.UUID) ([]types.InventoryItem, error) {
+	query := `
+		SELECT i.id, i.name, i.description, i.category, i.price, i.max_per_user, i.active, ui.quantity, ui.acquired_at
+		FROM user_inventory ui
+		JOIN shop_items i ON ui.item_id = i.id
+		WHERE ui.user_id = $1
+		ORDER BY ui.acquired_at DESC
+	`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		logger.Error("Failed to fetch inventory", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	inventory := []types.InventoryItem{}
+	for rows.Next() {
+		var entry types.InventoryItem
+		if err := rows.Scan(
+			&entry.Item.ID, &entry.Item.Name, &entry.Item.Description, &entry.Item.Category,
+			&entry.Item.Price, &entry.Item.MaxPerUser, &entry.Item.Active, &entry.Quantity, &entry.AcquiredAt,
+		); err != nil {
+			logger.Error("Failed to scan inventory row", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		inventory = append(inventory, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over inventory rows", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return inventory, nil
+}
+
+func (s *service) GetPurchaseHistory(userID uuid.UUID) ([]types.Purchase, error) {
+	query := `
+		SELECT p.id, p.item_id, i.name, p.price, p.purchased_at
+		FROM shop_purchases p
+		JOIN shop_items i ON p.item_id = i.id
+		WHERE p.user_id = $1
+		ORDER BY p.purchased_at DESC
+	`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		logger.Error("Failed to fetch purchase history", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	purchases := []types.Purchase{}
+	for rows.Next() {
+		var purchase types.Purchase
+		if err := rows.Scan(&purchase.ID, &purchase.ItemID, &purchase.ItemName, &purchase.Price, &purchase.PurchasedAt); err != nil {
+			logger.Error("Failed to scan purchase row", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		purchases = append(purchases, purchase)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over purchase rows", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return purchases, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *service) GetStreak(userID uuid.UUID) (*types.Streak, error) {
+	query := `
+		SELECT user_id, current_streak, longest_streak, freezes, last_evaluated
+		FROM streaks
+		WHERE user_id = $1
+	`
+	var streak types.Streak
+	err := s.db.QueryRow(query, userID).Scan(
+		&streak.UserID,
+		&streak.CurrentStreak,
+		&streak.LongestStreak,
+		&streak.Freezes,
+		&streak.LastEvaluated,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrStreakNotFound, err)
+		}
+		logger.Error("Failed to get streak", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return &streak, nil
+}
+
+func (s *service) SaveStreak(streak types.Streak) error {
+	query := `
+		INSERT INTO streaks (user_id, current_streak, longest_streak, freezes, last_evaluated)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET current_streak = EXCLUDED.current_streak,
+			longest_streak = EXCLUDED.longest_streak,
+			freezes = EXCLUDED.freezes,
+			last_evaluated = EXCLUDED.last_evaluated
+	`
+	_, err := s.db.Exec(query, streak.UserID, streak.CurrentStreak, streak.LongestStreak, streak.Freezes, streak.LastEvaluated.Format("2006-01-02"))
+	if err != nil {
+		logger.Error("Failed to save streak", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// GetStepsInRange returns the steps per day (keyed by "2006-01-02") between from and to, both inclusive.
+func (s *service) GetStepsInRange(userID uuid.UUID, from, to time.Time) (map[string]int, error) {
+	query := `
+		SELECT date, steps_taken
+		FROM daily_steps
+		WHERE user_id = $1 AND date BETWEEN $2 AND $3
+	`
+	rows, err := s.db.Query(query, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		logger.Error("Failed to get steps in range", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	stepsByDate := make(map[string]int)
+	for rows.Next() {
+		var date time.Time
+		var steps int
+		if err := rows.Scan(&date, &steps); err != nil {
+			logger.Error("Failed to scan daily steps row", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		stepsByDate[date.Format("2006-01-02")] = steps
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over daily steps rows", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return stepsByDate, nil
+}
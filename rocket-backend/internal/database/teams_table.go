@@ -0,0 +1,359 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// CreateTeam creates a team owned by ownerID. The owner is always a member.
+func (s *service) CreateTeam(name string, ownerID uuid.UUID, memberIDs []uuid.UUID) (uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var teamID uuid.UUID
+	err = tx.QueryRow(`INSERT INTO teams (name, owner_id) VALUES ($1, $2) RETURNING id`, name, ownerID).Scan(&teamID)
+	if err != nil {
+		logger.Error("Failed to create team", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	for _, memberID := range append([]uuid.UUID{ownerID}, memberIDs...) {
+		_, err := tx.Exec(`
+			INSERT INTO team_members (team_id, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, teamID, memberID)
+		if err != nil {
+			logger.Error("Failed to add team member", err)
+			return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return teamID, nil
+}
+
+func (s *service) GetTeamByID(teamID uuid.UUID) (*types.Team, error) {
+	var team types.Team
+	err := s.db.QueryRow(`
+		SELECT id, name, owner_id, created_at FROM teams WHERE id = $1
+	`, teamID).Scan(&team.ID, &team.Name, &team.OwnerID, &team.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, custom_error.ErrTeamNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to fetch team", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+
+	team.Members, err = s.getTeamMembers(teamID)
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (s *service) GetTeamsForUser(userID uuid.UUID) ([]types.Team, error) {
+	rows, err := s.db.Query(`
+		SELECT t.id, t.name, t.owner_id, t.created_at
+		FROM teams t
+		JOIN team_members tm ON tm.team_id = t.id
+		WHERE tm.user_id = $1
+		ORDER BY t.created_at DESC
+	`, userID)
+	if err != nil {
+		logger.Error("Failed to fetch teams", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	teams := []types.Team{}
+	for rows.Next() {
+		var team types.Team
+		if err := rows.Scan(&team.ID, &team.Name, &team.OwnerID, &team.CreatedAt); err != nil {
+			logger.Error("Failed to scan team", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		teams = append(teams, team)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over teams", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	for i := range teams {
+		teams[i].Members, err = s.getTeamMembers(teams[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return teams, nil
+}
+
+func (s *service) getTeamMembers(teamID uuid.UUID) ([]types.TeamMember, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.username, tm.joined_at
+		FROM team_members tm
+		JOIN users u ON u.id = tm.user_id
+		WHERE tm.team_id = $1
+		ORDER BY tm.joined_at, u.username
+	`, teamID)
+	if err != nil {
+		logger.Error("Failed to fetch team members", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	members := []types.TeamMember{}
+	for rows.Next() {
+		var member types.TeamMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.JoinedAt); err != nil {
+			logger.Error("Failed to scan team member", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over team members", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return members, nil
+}
+
+func (s *service) AddTeamMember(teamID, userID uuid.UUID) error {
+	_, err := s.db.Exec(`
+		INSERT INTO team_members (team_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, teamID, userID)
+	if err != nil {
+		logger.Error("Failed to add team member", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+func (s *service) RemoveTeamMember(teamID, userID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`, teamID, userID)
+	if err != nil {
+		logger.Error("Failed to remove team member", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return custom_error.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *service) DeleteTeam(teamID uuid.UUID) error {
+	_, err := s.db.Exec(`DELETE FROM teams WHERE id = $1`, teamID)
+	if err != nil {
+		logger.Error("Failed to delete team", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	return nil
+}
+
+// CreateTeamChallenge stores the challenge for the team's current members. What they
+// recorded on the first day so far is kept as their baseline.
+func (s *service) CreateTeamChallenge(challenge types.TeamChallenge) (uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO team_challenges (team_id, description, metric, target_value, target_unit, points_reward, starts_on, ends_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, challenge.TeamID, challenge.Text, challenge.Metric, challenge.Parameters.Target, challenge.Parameters.Unit,
+		challenge.Points, challenge.StartsOn.Format("2006-01-02"), challenge.EndsOn.Format("2006-01-02")).Scan(&id)
+	if err != nil {
+		logger.Error("Failed to create team challenge", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO team_challenge_members (challenge_id, user_id, baseline)
+		SELECT $1, tm.user_id, CASE WHEN $3 = 'run_distance'
+			THEN (SELECT COALESCE(SUM(r.distance), 0) FROM runs r WHERE r.user_id = tm.user_id AND r.created_at::date = $4)
+			ELSE (SELECT COALESCE(SUM(d.steps_taken), 0) FROM daily_steps d WHERE d.user_id = tm.user_id AND d.date = $4)
+		END
+		FROM team_members tm
+		WHERE tm.team_id = $2
+	`, id, challenge.TeamID, challenge.Metric, challenge.StartsOn.Format("2006-01-02"))
+	if err != nil {
+		logger.Error("Failed to add team challenge members", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return id, nil
+}
+
+func (s *service) GetTeamChallenges(teamID uuid.UUID) ([]types.TeamChallenge, error) {
+	rows, err := s.db.Query(`
+		SELECT id, team_id, description, metric, target_value, target_unit, points_reward, starts_on, ends_on, completed_at
+		FROM team_challenges
+		WHERE team_id = $1
+		ORDER BY ends_on DESC, description
+	`, teamID)
+	if err != nil {
+		logger.Error("Failed to fetch team challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	challenges := []types.TeamChallenge{}
+	for rows.Next() {
+		var challenge types.TeamChallenge
+		var completedAt sql.NullTime
+		if err := rows.Scan(&challenge.ID, &challenge.TeamID, &challenge.Text, &challenge.Metric,
+			&challenge.Parameters.Target, &challenge.Parameters.Unit, &challenge.Points,
+			&challenge.StartsOn, &challenge.EndsOn, &completedAt); err != nil {
+			logger.Error("Failed to scan team challenge", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		if completedAt.Valid {
+			challenge.CompletedAt = &completedAt.Time
+		}
+		challenges = append(challenges, challenge)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over team challenges", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return challenges, nil
+}
+
+// GetTeamChallengeMembers returns the users the challenge was set for.
+func (s *service) GetTeamChallengeMembers(challengeID uuid.UUID) ([]types.TeamChallengeMember, error) {
+	rows, err := s.db.Query(`
+		SELECT m.user_id, u.username, m.baseline
+		FROM team_challenge_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.challenge_id = $1
+		ORDER BY u.username
+	`, challengeID)
+	if err != nil {
+		logger.Error("Failed to fetch team challenge members", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	members := []types.TeamChallengeMember{}
+	for rows.Next() {
+		var member types.TeamChallengeMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Baseline); err != nil {
+			logger.Error("Failed to scan team challenge member", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating over team challenge members", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return members, nil
+}
+
+// CompleteTeamChallenge marks the challenge as completed and awards its points to
+// every member it was set for in one transaction. Nobody gets more than weeklyLimit
+// points from team challenges completed in the same week. It returns the members.
+func (s *service) CompleteTeamChallenge(challengeID uuid.UUID, weeklyLimit int) ([]uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var points int
+	err = tx.QueryRow(`
+		UPDATE team_challenges
+		SET completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND completed_at IS NULL
+		RETURNING points_reward
+	`, challengeID).Scan(&points)
+	if err == sql.ErrNoRows {
+		return nil, custom_error.ErrAlreadyCompleted
+	}
+	if err != nil {
+		logger.Error("Failed to complete team challenge", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	// Locking the users in a fixed order keeps concurrent completions from exceeding the
+	// weekly limit or deadlocking
+	rows, err := tx.Query(`
+		SELECT u.id
+		FROM team_challenge_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.challenge_id = $1
+		ORDER BY u.id
+		FOR UPDATE OF u
+	`, challengeID)
+	if err != nil {
+		logger.Error("Failed to fetch team challenge members", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	var rewarded []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		rewarded = append(rewarded, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	for _, userID := range rewarded {
+		var earned int
+		err := tx.QueryRow(`
+			SELECT COALESCE(SUM(m.points_awarded), 0)
+			FROM team_challenge_members m
+			JOIN team_challenges c ON c.id = m.challenge_id
+			WHERE m.user_id = $1 AND c.completed_at >= date_trunc('week', CURRENT_TIMESTAMP)
+		`, userID).Scan(&earned)
+		if err != nil {
+			logger.Error("Failed to sum up team challenge points", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		award := min(points, max(weeklyLimit-earned, 0))
+
+		_, err = tx.Exec(`
+			UPDATE team_challenge_members SET points_awarded = $3 WHERE challenge_id = $1 AND user_id = $2
+		`, challengeID, userID, award)
+		if err != nil {
+			logger.Error("Failed to record team challenge points", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		}
+		_, err = tx.Exec(`UPDATE users SET rocketpoints = rocketpoints + $2 WHERE id = $1`, userID, award)
+		if err != nil {
+			logger.Error("Failed to award team challenge points", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return rewarded, nil
+}
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetCredentialsByID returns the credentials of a user, ErrUserNotFound if there are none.
+func (s *service) GetCredentialsByID(userID uuid.UUID) (types.Credentials, error) {
+	var creds types.Credentials
+	query := `SELECT id, email, password, created_at, last_login, role, token_version, email_verified FROM credentials WHERE id = $1`
+	err := s.db.QueryRow(query, userID).Scan(&creds.ID, &creds.Email, &creds.Password, &creds.CreatedAt, &creds.LastLogin, &creds.Role, &creds.TokenVersion, &creds.EmailVerified)
+	if err == sql.ErrNoRows {
+		return creds, custom_error.ErrUserNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to get credentials by ID", err)
+		return creds, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	return creds, nil
+}
+
+// IsAccessTokenValid checks an access token against the user's token version, the
+// revocation list and, for tokens bound to one, the session.
+func (s *service) IsAccessTokenValid(userID uuid.UUID, tokenID uuid.UUID, version int, sessionID string) (bool, error) {
+	var session any
+	if sessionID != "" {
+		session = sessionID
+	}
+
+	var valid bool
+	err := s.db.QueryRow(`
+		SELECT c.token_version = $3
+			AND NOT EXISTS (SELECT 1 FROM revoked_tokens r WHERE r.token_id = $2)
+			AND ($4::uuid IS NULL OR EXISTS (
+				SELECT 1 FROM sessions se WHERE se.id = $4::uuid AND se.user_id = c.id AND se.revoked_at IS NULL
+			))
+		FROM credentials c
+		WHERE c.id = $1
+	`, userID, tokenID, version, session).Scan(&valid)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		logger.Error("Failed to check access token", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return valid, nil
+}
+
+// RevokeAccessToken puts a single access token on the revocation list until it expires.
+func (s *service) RevokeAccessToken(userID uuid.UUID, tokenID uuid.UUID, expiresAt time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO revoked_tokens (token_id, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (token_id) DO NOTHING
+	`, tokenID, userID, expiresAt)
+	if err != nil {
+		logger.Error("Failed to revoke access token", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// RevokeAllTokens invalidates every access and refresh token of the user, deletes the
+// personal access tokens and those of OAuth clients, and returns the new token version,
+// for issuing fresh tokens to the current client.
+func (s *service) RevokeAllTokens(userID uuid.UUID) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRow(`
+		UPDATE credentials SET token_version = token_version + 1
+		WHERE id = $1
+		RETURNING token_version
+	`, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, custom_error.ErrUserNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to bump token version", err)
+		return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	for _, query := range []string{
+		`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`,
+		`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`,
+		`DELETE FROM personal_access_tokens WHERE user_id = $1`,
+		`DELETE FROM oauth_tokens WHERE user_id = $1`,
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			logger.Error("Failed to revoke sessions", err)
+			return 0, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return version, nil
+}
+
+// CreateSession records a new login and stores the hash of its first refresh token.
+func (s *service) CreateSession(session types.Session, tokenHash string, expiresAt time.Time) (uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sessionID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO sessions (user_id, device_name, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, session.UserID, session.DeviceName, session.UserAgent, session.IPAddress, expiresAt).Scan(&sessionID)
+	if err != nil {
+		logger.Error("Failed to create session", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, session.UserID, sessionID, tokenHash, expiresAt)
+	if err != nil {
+		logger.Error("Failed to save refresh token", err)
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return sessionID, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one of the same session and
+// returns the owner's credentials and the session ID. Presenting a token that was already
+// rotated means it was copied, so the session is revoked and ErrRefreshTokenReused returned.
+func (s *service) RotateRefreshToken(tokenHash string, newTokenHash string, expiresAt time.Time, ipAddress string) (types.Credentials, uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return types.Credentials{}, uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID, familyID uuid.UUID
+	var tokenExpiresAt time.Time
+	var revokedAt, sessionRevokedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT rt.id, rt.user_id, rt.family_id, rt.expires_at, rt.revoked_at, se.revoked_at
+		FROM refresh_tokens rt
+		JOIN sessions se ON se.id = rt.family_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt
+	`, tokenHash).Scan(&tokenID, &userID, &familyID, &tokenExpiresAt, &revokedAt, &sessionRevokedAt)
+	if err == sql.ErrNoRows {
+		return types.Credentials{}, uuid.Nil, custom_error.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		logger.Error("Failed to look up refresh token", err)
+		return types.Credentials{}, uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	if revokedAt.Valid {
+		if err := revokeSession(tx, familyID); err != nil {
+			return types.Credentials{}, uuid.Nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return types.Credentials{}, uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		logger.Warn("Refresh token reused, revoked its session", "userID", userID)
+		return types.Credentials{}, uuid.Nil, custom_error.ErrRefreshTokenReused
+	}
+	if sessionRevokedAt.Valid || time.Now().After(tokenExpiresAt) {
+		return types.Credentials{}, uuid.Nil, custom_error.ErrInvalidRefreshToken
+	}
+
+	var newTokenID uuid.UUID
+	err = tx.QueryRow(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, userID, familyID, newTokenHash, expiresAt).Scan(&newTokenID)
+	if err != nil {
+		logger.Error("Failed to save rotated refresh token", err)
+		return types.Credentials{}, uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	_, err = tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $2
+		WHERE id = $1
+	`, tokenID, newTokenID)
+	if err != nil {
+		logger.Error("Failed to revoke rotated refresh token", err)
+		return types.Credentials{}, uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, ip_address = $2, expires_at = $3
+		WHERE id = $1
+	`, familyID, ipAddress, expiresAt)
+	if err != nil {
+		logger.Error("Failed to update session", err)
+		return types.Credentials{}, uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return types.Credentials{}, uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	creds, err := s.GetCredentialsByID(userID)
+	return creds, familyID, err
+}
+
+// RevokeRefreshToken revokes the session of a refresh token, i.e. ends that login.
+func (s *service) RevokeRefreshToken(tokenHash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sessionID uuid.UUID
+	err = tx.QueryRow(`SELECT family_id FROM refresh_tokens WHERE token_hash = $1`, tokenHash).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		logger.Error("Failed to look up refresh token", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	if err := revokeSession(tx, sessionID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CleanUpTokens deletes expired sessions and refresh tokens, the revocation entries
+// of expired access tokens, email tokens that were used or expired, abandoned
+// provider logins and expired personal access tokens.
+func (s *service) CleanUpTokens() error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up sessions", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up refresh tokens", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up revoked tokens", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`DELETE FROM email_tokens WHERE used_at IS NOT NULL OR expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up email tokens", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`DELETE FROM oidc_states WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up login states", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`DELETE FROM personal_access_tokens WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up personal access tokens", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`DELETE FROM oauth_authorization_codes WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up authorization codes", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	_, err = s.db.Exec(`DELETE FROM oauth_tokens WHERE refresh_expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		logger.Error("Failed to clean up OAuth tokens", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// GetTwoFactor returns the two-factor state of a user, nil if the user never enrolled.
+func (s *service) GetTwoFactor(userID uuid.UUID) (*types.TwoFactor, error) {
+	var tf types.TwoFactor
+	var secret, pendingSecret sql.NullString
+	var enabledAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT tf.user_id, tf.secret, tf.pending_secret, tf.enabled_at, tf.last_used_step,
+			(SELECT COUNT(*) FROM recovery_codes rc WHERE rc.user_id = tf.user_id AND rc.used_at IS NULL)
+		FROM two_factor tf
+		WHERE tf.user_id = $1
+	`, userID).Scan(&tf.UserID, &secret, &pendingSecret, &enabledAt, &tf.LastUsedStep, &tf.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("Failed to get two-factor state", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+
+	tf.Secret = secret.String
+	tf.PendingSecret = pendingSecret.String
+	if enabledAt.Valid {
+		tf.Enabled = true
+		tf.EnabledAt = &enabledAt.Time
+	}
+	return &tf, nil
+}
+
+// SavePendingTwoFactorSecret starts an enrollment. An active secret keeps working
+// until the new one is confirmed.
+func (s *service) SavePendingTwoFactorSecret(userID uuid.UUID, secret string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO two_factor (user_id, pending_secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET pending_secret = EXCLUDED.pending_secret
+	`, userID, secret)
+	if err != nil {
+		logger.Error("Failed to save pending two-factor secret", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// ConfirmTwoFactor activates the pending secret and replaces the recovery codes.
+// The step of the confirming code counts as used.
+func (s *service) ConfirmTwoFactor(userID uuid.UUID, step int64, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE two_factor
+		SET secret = pending_secret, pending_secret = NULL, enabled_at = CURRENT_TIMESTAMP, last_used_step = $2
+		WHERE user_id = $1 AND pending_secret IS NOT NULL
+	`, userID, step)
+	if err != nil {
+		logger.Error("Failed to confirm two-factor enrollment", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return custom_error.ErrNoPendingEnrollment
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UseTwoFactorStep records the time step of an accepted code. It returns false if
+// that step or a later one was used already, i.e. the code is replayed.
+func (s *service) UseTwoFactorStep(userID uuid.UUID, step int64) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE two_factor SET last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		logger.Error("Failed to record two-factor code", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// UseRecoveryCode uses up a recovery code. It returns false if the code is unknown or used.
+func (s *service) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		logger.Error("Failed to use recovery code", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// ReplaceRecoveryCodes invalidates all recovery codes of the user and stores new ones.
+func (s *service) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	err = tx.QueryRow(`SELECT enabled_at IS NOT NULL FROM two_factor WHERE user_id = $1 FOR UPDATE`, userID).Scan(&enabled)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Error("Failed to get two-factor state", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	if !enabled {
+		return custom_error.ErrTwoFactorNotEnabled
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DisableTwoFactor removes the secret and the recovery codes of the user.
+func (s *service) DisableTwoFactor(userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM two_factor WHERE user_id = $1 AND enabled_at IS NOT NULL`, userID)
+	if err != nil {
+		logger.Error("Failed to disable two-factor authentication", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return custom_error.ErrTwoFactorNotEnabled
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		logger.Error("Failed to delete recovery codes", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		logger.Error("Failed to delete recovery codes", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			logger.Error("Failed to save recovery code", err)
+			return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+		}
+	}
+	return nil
+}
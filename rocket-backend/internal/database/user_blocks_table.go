@@ -0,0 +1,150 @@
+package database
+
+import (
+	"fmt"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Authors the viewer ($1) hides from their feeds: those they blocked or muted and
+// those who blocked them
+const hiddenFromFeed = `
+	SELECT blocked_id FROM user_blocks WHERE user_id = $1
+	UNION
+	SELECT user_id FROM user_blocks WHERE blocked_id = $1 AND kind = 'block'
+`
+
+// BlockUser blocks or mutes another user, replacing what was set before. Blocking
+// also ends following in both directions, declines pending challenge invitations,
+// ends duels between the two and removes each from the teams the other owns.
+func (s *service) BlockUser(userID, blockedID uuid.UUID, kind string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO user_blocks (user_id, blocked_id, kind)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, blocked_id) DO UPDATE SET kind = EXCLUDED.kind, created_at = CURRENT_TIMESTAMP
+	`, userID, blockedID, kind)
+	if err != nil {
+		logger.Error("Failed to block user", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+	}
+
+	if kind == types.BlockKindBlock {
+		for _, query := range []string{
+			`DELETE FROM friends WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)`,
+			`UPDATE challenge_invitations SET status = 'declined', responded_at = CURRENT_TIMESTAMP
+			 WHERE status = 'pending'
+			 AND ((inviter_id = $1 AND invitee_id = $2) OR (inviter_id = $2 AND invitee_id = $1))`,
+			// Leaving a team also drops out of its open challenges, so they no longer count
+			// or reward the removed member
+			`DELETE FROM team_challenge_members m
+			 USING team_challenges c, teams t
+			 WHERE c.id = m.challenge_id AND t.id = c.team_id AND c.completed_at IS NULL
+			 AND ((t.owner_id = $1 AND m.user_id = $2) OR (t.owner_id = $2 AND m.user_id = $1))`,
+			`DELETE FROM team_members m
+			 USING teams t
+			 WHERE t.id = m.team_id
+			 AND ((t.owner_id = $1 AND m.user_id = $2) OR (t.owner_id = $2 AND m.user_id = $1))`,
+		} {
+			if _, err := tx.Exec(query, userID, blockedID); err != nil {
+				logger.Error("Failed to separate blocked users", err)
+				return fmt.Errorf("%w: %v", custom_error.ErrFailedToSave, err)
+			}
+		}
+		err := cancelDuels(tx, `(challenger_id = $1 AND opponent_id = $2) OR (challenger_id = $2 AND opponent_id = $1)`,
+			userID, blockedID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UnblockUser removes a block or mute of the given kind.
+func (s *service) UnblockUser(userID, blockedID uuid.UUID, kind string) error {
+	result, err := s.db.Exec(`
+		DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2 AND kind = $3
+	`, userID, blockedID, kind)
+	if err != nil {
+		logger.Error("Failed to unblock user", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return custom_error.ErrBlockNotFound
+	}
+	return nil
+}
+
+// GetBlockedUsers returns the users someone blocked or muted, newest first.
+func (s *service) GetBlockedUsers(userID uuid.UUID, kind string) ([]types.BlockedUser, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.username, b.kind, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.user_id = $1 AND b.kind = $2
+		ORDER BY b.created_at DESC
+	`, userID, kind)
+	if err != nil {
+		logger.Error("Failed to load blocked users", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	blocked := []types.BlockedUser{}
+	for rows.Next() {
+		var b types.BlockedUser
+		if err := rows.Scan(&b.ID, &b.Username, &b.Kind, &b.CreatedAt); err != nil {
+			logger.Error("Failed to scan blocked user", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		blocked = append(blocked, b)
+	}
+	return blocked, rows.Err()
+}
+
+// IsBlocked reports whether userID has blocked otherID. Mutes do not count.
+func (s *service) IsBlocked(userID, otherID uuid.UUID) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM user_blocks WHERE user_id = $1 AND blocked_id = $2 AND kind = 'block')
+	`, userID, otherID).Scan(&exists)
+	if err != nil {
+		logger.Error("Failed to check block", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	return exists, nil
+}
+
+// GetUsersHiding returns the users who blocked or muted the author, they do not get
+// the author's chat messages.
+func (s *service) GetUsersHiding(authorID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := s.db.Query(`SELECT user_id FROM user_blocks WHERE blocked_id = $1`, authorID)
+	if err != nil {
+		logger.Error("Failed to load users hiding author", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			logger.Error("Failed to scan user", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
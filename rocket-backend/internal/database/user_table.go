@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -12,6 +14,12 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// activeUser is a condition on the user ID in column that leaves out deactivated users.
+// They are hidden from others until they restore their account.
+func activeUser(column string) string {
+	return `NOT EXISTS (SELECT 1 FROM credentials dc WHERE dc.id = ` + column + ` AND dc.deactivated_at IS NOT NULL)`
+}
+
 func (s *service) SaveUserProfile(user types.User) error {
 	// Assuming user.ID is the UUID linked to the credentials table
 	query := `INSERT INTO users (id, username, email, rocketpoints) VALUES ($1, $2, $3, $4)`
@@ -53,7 +61,7 @@ func (s *service) GetRocketPointsByUserID(userID uuid.UUID) (int, error) {
 
 func (s *service) GetUserIDByName(name string) (uuid.UUID, error) {
 	var userID uuid.UUID
-	query := `SELECT id FROM users WHERE username = $1`
+	query := `SELECT id FROM users WHERE username = $1 AND ` + activeUser("users.id")
 	err := s.db.QueryRow(query, name).Scan(&userID)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to get user ID by name: %w", err)
@@ -63,7 +71,7 @@ func (s *service) GetUserIDByName(name string) (uuid.UUID, error) {
 
 func (s *service) GetTopUsers(limit int) ([]types.User, error) {
 	var users []types.User
-	query := `SELECT id, username, email, rocketpoints FROM users ORDER BY rocketpoints DESC LIMIT $1`
+	query := `SELECT id, username, email, rocketpoints FROM users WHERE ` + activeUser("users.id") + ` ORDER BY rocketpoints DESC LIMIT $1`
 	rows, err := s.db.Query(query, limit)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
@@ -86,6 +94,20 @@ func (s *service) GetTopUsers(limit int) ([]types.User, error) {
 }
 
 // UpdateUserName updates the username for a given user UUID.
+// CheckUsername returns ErrUsernameTaken when another user than excludeUserID has the
+// name, in any case.
+func (s *service) CheckUsername(username string, excludeUserID uuid.UUID) error {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE LOWER(username) = LOWER($1) AND id <> $2`, username, excludeUserID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+	if count > 0 {
+		return custom_error.ErrUsernameTaken
+	}
+	return nil
+}
+
 func (s *service) UpdateUserName(userID uuid.UUID, newName string) error {
 	query := `UPDATE users SET username = $2 WHERE id = $1`
 	_, err := s.db.Exec(query, userID, newName)
@@ -109,8 +131,8 @@ func (s *service) UpdateUserEmail(userID uuid.UUID, newEmail string) error {
 		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
 	}
 
-	// Update in credentials table
-	queryCreds := `UPDATE credentials SET email = $2 WHERE id = $1`
+	// Update in credentials table, the new address has to be verified again
+	queryCreds := `UPDATE credentials SET email = $2, email_verified = email_verified AND email = $2 WHERE id = $1`
 	if _, err := tx.Exec(queryCreds, userID, newEmail); err != nil {
 		return fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
 	}
@@ -157,10 +179,10 @@ func (s *service) GetAllUsers(excludeUserID *uuid.UUID) ([]types.User, error) {
 	var err error
 
 	if excludeUserID != nil {
-		query := `SELECT id, username, email, rocketpoints FROM users WHERE id != $1`
+		query := `SELECT id, username, email, rocketpoints FROM users WHERE id != $1 AND ` + activeUser("users.id")
 		rows, err = s.db.Query(query, *excludeUserID)
 	} else {
-		query := `SELECT id, username, email, rocketpoints FROM users`
+		query := `SELECT id, username, email, rocketpoints FROM users WHERE ` + activeUser("users.id")
 		rows, err = s.db.Query(query)
 	}
 
@@ -184,20 +206,144 @@ func (s *service) GetAllUsers(excludeUserID *uuid.UUID) ([]types.User, error) {
 	return users, nil
 }
 
+// GetUsersWithRoles lists all users together with their role, for admins.
+func (s *service) GetUsersWithRoles() ([]types.UserWithRole, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.username, u.email, u.rocketpoints, c.role, c.created_at
+		FROM users u
+		JOIN credentials c ON c.id = u.id
+		ORDER BY u.username
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+	defer rows.Close()
+
+	users := []types.UserWithRole{}
+	for rows.Next() {
+		var user types.UserWithRole
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.RocketPoints, &user.Role, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	return users, nil
+}
+
+// DeactivateUser hides the account of a user and schedules its erasure. Deactivating
+// again keeps the original schedule.
+func (s *service) DeactivateUser(userID uuid.UUID, eraseAfter time.Time) (time.Time, error) {
+	var scheduled time.Time
+	err := s.db.QueryRow(`
+		UPDATE credentials
+		SET deactivated_at = COALESCE(deactivated_at, CURRENT_TIMESTAMP), erase_after = COALESCE(erase_after, $2)
+		WHERE id = $1
+		RETURNING erase_after
+	`, userID, eraseAfter).Scan(&scheduled)
+	if err == sql.ErrNoRows {
+		return scheduled, custom_error.ErrUserNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to deactivate user", err)
+		return scheduled, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	return scheduled, nil
+}
+
+// RestoreUser reactivates the account of a user in its grace period and returns
+// whether it was deactivated. Accounts due for erasure fail with ErrAccountDeleted.
+func (s *service) RestoreUser(userID uuid.UUID) (bool, error) {
+	var deactivated, restored bool
+	// The outer query still sees the row as it was before the update
+	err := s.db.QueryRow(`
+		WITH restored AS (
+			UPDATE credentials SET deactivated_at = NULL, erase_after = NULL
+			WHERE id = $1 AND erase_after > CURRENT_TIMESTAMP
+			RETURNING id
+		)
+		SELECT c.deactivated_at IS NOT NULL, EXISTS (SELECT 1 FROM restored)
+		FROM credentials c
+		WHERE c.id = $1
+	`, userID).Scan(&deactivated, &restored)
+	if err == sql.ErrNoRows {
+		return false, custom_error.ErrUserNotFound
+	}
+	if err != nil {
+		logger.Error("Failed to restore user", err)
+		return false, fmt.Errorf("%w: %v", custom_error.ErrFailedToUpdate, err)
+	}
+	if deactivated && !restored {
+		return false, custom_error.ErrAccountDeleted
+	}
+	return restored, nil
+}
+
+// GetUsersDueForErasure returns the deactivated users whose grace period is over.
+func (s *service) GetUsersDueForErasure() ([]uuid.UUID, error) {
+	rows, err := s.db.Query(`SELECT id FROM credentials WHERE erase_after <= CURRENT_TIMESTAMP ORDER BY erase_after`)
+	if err != nil {
+		logger.Error("Failed to load users due for erasure", err)
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			logger.Error("Failed to scan user", err)
+			return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToLoad, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteUser erases a user for good. Deleting the credentials cascades to the profile
+// and all data of the user, chat messages stay without an author. The profile image
+// is not referenced from the user's side, so it is deleted explicitly.
 func (s *service) DeleteUser(userID uuid.UUID) error {
-	// First, delete from users (this will cascade to all dependent tables)
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := s.db.Exec(query, userID)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRow(`SELECT email FROM credentials WHERE id = $1 FOR UPDATE`, userID).Scan(&email)
+	if err == sql.ErrNoRows {
+		return custom_error.ErrUserNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+		logger.Error("Failed to lock user", err)
+		return fmt.Errorf("%w: %v", custom_error.ErrDatabaseQuery, err)
+	}
+
+	// Erasing the user takes their duels with them, the stakes held back for them go back first
+	if err := cancelDuels(tx, `challenger_id = $1 OR opponent_id = $1`, userID); err != nil {
+		return err
 	}
 
-	// Then, delete from credentials (since users references credentials, not the other way around)
-	credQuery := `DELETE FROM credentials WHERE id = $1`
-	_, credErr := s.db.Exec(credQuery, userID)
-	if credErr != nil {
-		return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, credErr)
+	for _, step := range []struct {
+		query string
+		arg   any
+	}{
+		{`DELETE FROM image_store WHERE id IN (SELECT image_id FROM settings WHERE user_id = $1)`, userID},
+		{`DELETE FROM login_failures WHERE email = $1`, email},
+		{`DELETE FROM credentials WHERE id = $1`, userID},
+	} {
+		if _, err := tx.Exec(step.query, step.arg); err != nil {
+			logger.Error("Failed to delete user", err)
+			return fmt.Errorf("%w: %v", custom_error.ErrFailedToDelete, err)
+		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
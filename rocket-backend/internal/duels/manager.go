@@ -0,0 +1,296 @@
+package duels
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// PendingDuelLifetime is how long the opponent has to accept or decline a duel.
+const PendingDuelLifetime = 3 * 24 * time.Hour
+
+type DuelManager struct {
+	db  database.Service
+	now func() time.Time
+}
+
+func NewDuelManager(db database.Service) *DuelManager {
+	return &DuelManager{db: db, now: time.Now}
+}
+
+// Create challenges a friend to a duel. The duel stays pending until the opponent responds
+// or expires after PendingDuelLifetime.
+func (dm *DuelManager) Create(challengerID uuid.UUID, dto types.CreateDuelDTO) (*types.Duel, error) {
+	opponentID, err := dm.db.GetUserIDByName(dto.OpponentName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrUserNotFound, err)
+	}
+	if opponentID == challengerID {
+		return nil, fmt.Errorf("%w: you cannot duel yourself", custom_error.ErrInvalidDuel)
+	}
+
+	isFriend, err := dm.db.IsFriend(challengerID, opponentID)
+	if err != nil {
+		return nil, err
+	}
+	if !isFriend {
+		return nil, custom_error.ErrNotFriends
+	}
+
+	blocked, err := dm.db.IsBlocked(opponentID, challengerID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, custom_error.ErrBlocked
+	}
+
+	switch dto.Type {
+	case types.DuelFirstToDistance:
+		if dto.Target == nil || *dto.Target <= 0 {
+			return nil, fmt.Errorf("%w: a target distance is required", custom_error.ErrInvalidDuel)
+		}
+	case types.DuelMostSteps:
+		dto.Target = nil
+	}
+
+	// The stake is only held back on acceptance, but there is no point in offering what you don't have
+	points, err := dm.db.GetRocketPointsByUserID(challengerID)
+	if err != nil {
+		return nil, err
+	}
+	if points < dto.Stake {
+		return nil, custom_error.ErrInsufficientPoints
+	}
+
+	id, err := dm.db.CreateDuel(types.Duel{
+		ChallengerID: challengerID,
+		OpponentID:   opponentID,
+		Type:         dto.Type,
+		Target:       dto.Target,
+		DurationDays: dto.DurationDays,
+		Stake:        dto.Stake,
+		ExpiresAt:    dm.now().Add(PendingDuelLifetime),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dm.db.GetDuelByID(id)
+}
+
+// Accept starts a pending duel. Only the challenged user can accept.
+func (dm *DuelManager) Accept(userID, duelID uuid.UUID) (*types.Duel, error) {
+	duel, err := dm.pendingFor(userID, duelID)
+	if err != nil {
+		return nil, err
+	}
+
+	startsAt := dm.now()
+	// Duels run over whole days, ending at midnight after the last one
+	start := time.Date(startsAt.Year(), startsAt.Month(), startsAt.Day(), 0, 0, 0, 0, startsAt.Location())
+	endsAt := start.AddDate(0, 0, duel.DurationDays)
+
+	if err := dm.db.AcceptDuel(duelID, userID, startsAt, endsAt); err != nil {
+		return nil, err
+	}
+
+	_ = dm.db.SaveActivity(duel.ChallengerID, fmt.Sprintf("⚔️ Started a duel against %s", duel.OpponentName))
+	_ = dm.db.SaveActivity(duel.OpponentID, fmt.Sprintf("⚔️ Accepted a duel from %s", duel.ChallengerName))
+
+	return dm.db.GetDuelByID(duelID)
+}
+
+func (dm *DuelManager) Decline(userID, duelID uuid.UUID) error {
+	if _, err := dm.pendingFor(userID, duelID); err != nil {
+		return err
+	}
+	return dm.db.DeclineDuel(duelID, userID)
+}
+
+// Get returns a duel of the user with its live progress.
+func (dm *DuelManager) Get(userID, duelID uuid.UUID) (*types.Duel, error) {
+	duel, err := dm.db.GetDuelByID(duelID)
+	if err != nil {
+		return nil, err
+	}
+	if duel.ChallengerID != userID && duel.OpponentID != userID {
+		return nil, custom_error.ErrDuelNotFound
+	}
+	if err := dm.update(duel); err != nil {
+		return nil, err
+	}
+	return duel, nil
+}
+
+// Open returns the user's pending and active duels with their live progress.
+// Active duels that are decided by now are settled and expired ones closed on the way.
+func (dm *DuelManager) Open(userID uuid.UUID) ([]types.Duel, error) {
+	if err := dm.db.ExpireDuels(); err != nil {
+		return nil, err
+	}
+	duels, err := dm.db.GetDuelsForUser(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	for i := range duels {
+		if err := dm.update(&duels[i]); err != nil {
+			return nil, err
+		}
+	}
+	return duels, nil
+}
+
+// History returns the user's finished and declined duels.
+func (dm *DuelManager) History(userID uuid.UUID) ([]types.Duel, error) {
+	// Settle everything that is due first, so it shows up here
+	if _, err := dm.Open(userID); err != nil {
+		return nil, err
+	}
+	return dm.db.GetDuelsForUser(userID, true)
+}
+
+func (dm *DuelManager) pendingFor(userID, duelID uuid.UUID) (*types.Duel, error) {
+	if err := dm.db.ExpireDuels(); err != nil {
+		return nil, err
+	}
+	duel, err := dm.db.GetDuelByID(duelID)
+	if err != nil {
+		return nil, err
+	}
+	if duel.OpponentID != userID {
+		return nil, custom_error.ErrDuelNotFound
+	}
+	if duel.Status != types.DuelPending {
+		return nil, custom_error.ErrDuelNotPending
+	}
+	return duel, nil
+}
+
+// update fills in the live progress of an active duel and settles it once it is decided.
+func (dm *DuelManager) update(duel *types.Duel) error {
+	if duel.Status != types.DuelActive {
+		return nil
+	}
+
+	now := dm.now()
+	winner, decided, err := dm.measure(duel, now)
+	if err != nil {
+		return err
+	}
+	if !decided {
+		return nil
+	}
+
+	err = dm.db.FinishDuel(duel.ID, winner, duel.ChallengerProgress, duel.OpponentProgress)
+	if errors.Is(err, custom_error.ErrDuelNotPending) {
+		// Settled concurrently, show the stored result
+		settled, err := dm.db.GetDuelByID(duel.ID)
+		if err != nil {
+			return err
+		}
+		*duel = *settled
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	duel.Status = types.DuelFinished
+	duel.WinnerID = winner
+	duel.FinishedAt = &now
+	dm.postResult(duel)
+	return nil
+}
+
+// measure sets both users' progress and reports whether and by whom the duel is won.
+// A decided duel without winner is a draw.
+func (dm *DuelManager) measure(duel *types.Duel, now time.Time) (*uuid.UUID, bool, error) {
+	over := !now.Before(*duel.EndsAt)
+
+	switch duel.Type {
+	case types.DuelFirstToDistance:
+		challengerKm, challengerAt, err := dm.db.GetRunTotalInWindow(duel.ChallengerID, *duel.StartsAt, *duel.EndsAt, *duel.Target)
+		if err != nil {
+			logger.Error("Failed to measure duel runs", err)
+			return nil, false, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+		}
+		opponentKm, opponentAt, err := dm.db.GetRunTotalInWindow(duel.OpponentID, *duel.StartsAt, *duel.EndsAt, *duel.Target)
+		if err != nil {
+			logger.Error("Failed to measure duel runs", err)
+			return nil, false, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+		}
+		duel.ChallengerProgress = challengerKm
+		duel.OpponentProgress = opponentKm
+
+		switch {
+		case challengerAt != nil && (opponentAt == nil || challengerAt.Before(*opponentAt)):
+			return &duel.ChallengerID, true, nil
+		case opponentAt != nil && (challengerAt == nil || opponentAt.Before(*challengerAt)):
+			return &duel.OpponentID, true, nil
+		case challengerAt != nil:
+			// Both reached the target at the very same moment
+			return nil, true, nil
+		case !over:
+			return nil, false, nil
+		}
+		// Nobody made it in time, the longer distance wins
+
+	default:
+		// The last day of the window is the day before ends_at
+		last := duel.EndsAt.AddDate(0, 0, -1)
+		challengerSteps, err := dm.db.GetStepsInRange(duel.ChallengerID, *duel.StartsAt, last)
+		if err != nil {
+			return nil, false, err
+		}
+		opponentSteps, err := dm.db.GetStepsInRange(duel.OpponentID, *duel.StartsAt, last)
+		if err != nil {
+			return nil, false, err
+		}
+		duel.ChallengerProgress = float64(sum(challengerSteps))
+		duel.OpponentProgress = float64(sum(opponentSteps))
+
+		if !over {
+			return nil, false, nil
+		}
+	}
+
+	switch {
+	case duel.ChallengerProgress > duel.OpponentProgress:
+		return &duel.ChallengerID, true, nil
+	case duel.OpponentProgress > duel.ChallengerProgress:
+		return &duel.OpponentID, true, nil
+	default:
+		return nil, true, nil
+	}
+}
+
+func (dm *DuelManager) postResult(duel *types.Duel) {
+	if duel.WinnerID == nil {
+		_ = dm.db.SaveActivity(duel.ChallengerID, fmt.Sprintf("🤝 Drew a duel against %s", duel.OpponentName))
+		_ = dm.db.SaveActivity(duel.OpponentID, fmt.Sprintf("🤝 Drew a duel against %s", duel.ChallengerName))
+		return
+	}
+
+	winnerName, loserID, loserName := duel.ChallengerName, duel.OpponentID, duel.OpponentName
+	if *duel.WinnerID == duel.OpponentID {
+		winnerName, loserID, loserName = duel.OpponentName, duel.ChallengerID, duel.ChallengerName
+	}
+	_ = dm.db.SaveActivity(*duel.WinnerID, fmt.Sprintf("🏆 Won a duel against %s (+%d rocket points)", loserName, duel.Stake))
+	_ = dm.db.SaveActivity(loserID, fmt.Sprintf("⚔️ Lost a duel against %s", winnerName))
+}
+
+func sum(steps map[string]int) int {
+	total := 0
+	for _, daySteps := range steps {
+		total += daySteps
+	}
+	return total
+}
@@ -0,0 +1,205 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// How long the download link of a ready export works
+	LinkTTL = 48 * time.Hour
+	// Attempts running longer are assumed to have died with their replica and retried
+	StaleAfter = 15 * time.Minute
+	// Exports still failing after this many attempts are given up
+	maxAttempts = 3
+)
+
+// Sections with routes, they also get GeoJSON and GPX files. Planned runs become GPX
+// routes, runs tracks.
+var routeSections = map[string]bool{"runs": false, "planned_runs": true}
+
+const readme = `Your Rocket data, exported on %s.
+
+Every kind of data comes as JSON and as CSV file, e.g. profile.json and profile.csv.
+Runs and planned runs are also included as GeoJSON feature collection (runs.geojson,
+planned_runs.geojson) and as one GPX file each in the runs and planned_runs folders.
+Your profile image, if you set one, is included as profile_image.
+`
+
+// Exporter builds the archives users request of their data.
+type Exporter struct {
+	db  database.Service
+	now func() time.Time
+}
+
+func NewExporter(db database.Service) *Exporter {
+	return &Exporter{db: db, now: time.Now}
+}
+
+// Process builds the archive of a pending export and stores it, or the reason it
+// failed. Exports another attempt is working on are left alone.
+func (e *Exporter) Process(exportID uuid.UUID) error {
+	export, claimed, err := e.db.ClaimDataExport(exportID, e.now().Add(-StaleAfter))
+	if err != nil || !claimed {
+		return err
+	}
+	if export.Attempts > maxAttempts {
+		return e.db.FailDataExport(exportID, fmt.Sprintf("gave up after %d attempts", maxAttempts))
+	}
+
+	archive, err := e.Build(export.UserID)
+	if err != nil {
+		if failErr := e.db.FailDataExport(exportID, err.Error()); failErr != nil {
+			logger.Error("Failed to record data export failure", failErr)
+		}
+		return err
+	}
+	return e.db.CompleteDataExport(exportID, archive, e.now().Add(LinkTTL))
+}
+
+// ProcessStale retries the pending exports nobody is working on, e.g. because the
+// replica building them stopped.
+func (e *Exporter) ProcessStale(ctx context.Context) error {
+	ids, err := e.db.GetStaleDataExports(e.now().Add(-StaleAfter))
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := e.Process(id); err != nil {
+			logger.Error(fmt.Sprintf("Data export %s failed", id), err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d data exports failed", failed, len(ids))
+	}
+	return nil
+}
+
+// Build assembles the archive with everything stored about the user.
+func (e *Exporter) Build(userID uuid.UUID) ([]byte, error) {
+	sections, err := e.db.GetUserDataSections(userID)
+	if err != nil {
+		return nil, err
+	}
+	image, err := e.db.GetUserImage(userID)
+	if err != nil && !errors.Is(err, custom_error.ErrImageNotFound) {
+		return nil, err
+	}
+	return Archive(sections, image, e.now())
+}
+
+// Archive writes the sections and the profile image, which may be nil, to a ZIP file.
+func Archive(sections []types.ExportSection, image *types.UserImage, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, data []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	if err := add("README.txt", []byte(fmt.Sprintf(readme, now.UTC().Format(time.RFC1123)))); err != nil {
+		return nil, err
+	}
+	for _, section := range sections {
+		if err := addSection(add, section); err != nil {
+			return nil, fmt.Errorf("%s: %w", section.Name, err)
+		}
+	}
+	if image != nil {
+		if err := add("profile_image"+imageExtension(image.Name), image.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func addSection(add func(name string, data []byte) error, section types.ExportSection) error {
+	records, err := decodeRecords(section.Records)
+	if err != nil {
+		return err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, section.Records, "", "  "); err != nil {
+		return err
+	}
+	if err := add(section.Name+".json", indented.Bytes()); err != nil {
+		return err
+	}
+	table, err := toCSV(records)
+	if err != nil {
+		return err
+	}
+	if err := add(section.Name+".csv", table); err != nil {
+		return err
+	}
+
+	planned, ok := routeSections[section.Name]
+	if !ok {
+		return nil
+	}
+	collection, err := toGeoJSON(records)
+	if err != nil {
+		return err
+	}
+	if err := add(section.Name+".geojson", collection); err != nil {
+		return err
+	}
+	for _, r := range records {
+		var id string
+		_ = json.Unmarshal(r.get("id"), &id)
+		gpx, err := toGPX(r, planned)
+		if err != nil {
+			return err
+		}
+		if gpx == nil || id == "" {
+			continue
+		}
+		if err := add(section.Name+"/"+id+".gpx", gpx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageExtension keeps the extension of the uploaded file name, if it is a plain one.
+func imageExtension(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if len(ext) < 2 || len(ext) > 5 {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return ext
+}
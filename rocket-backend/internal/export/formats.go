@@ -0,0 +1,240 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// Field of runs and planned runs with their GeoJSON geometry
+const routeField = "route"
+
+// record is a JSON object with its fields in their original order, so the columns of
+// the CSV files follow the queries.
+type record []field
+
+type field struct {
+	name  string
+	value json.RawMessage
+}
+
+func (r record) get(name string) json.RawMessage {
+	for _, f := range r {
+		if f.name == name {
+			return f.value
+		}
+	}
+	return nil
+}
+
+// decodeRecords reads a JSON array of objects.
+func decodeRecords(raw json.RawMessage) ([]record, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, err
+	}
+	records := []record{}
+	for dec.More() {
+		if err := expectDelim(dec, '{'); err != nil {
+			return nil, err
+		}
+		var r record
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := token.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a field name, got %v", token)
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			r = append(r, field{name: name, value: value})
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// toCSV writes the records with a header row. Nested values, such as routes, stay JSON.
+func toCSV(records []record) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if len(records) > 0 {
+		header := make([]string, len(records[0]))
+		for i, f := range records[0] {
+			header[i] = f.name
+		}
+		if err := w.Write(header); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range records {
+		row := make([]string, len(r))
+		for i, f := range r {
+			row[i] = cell(f.value)
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func cell(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	if string(value) == "null" {
+		return ""
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, value) != nil {
+		return string(value)
+	}
+	return compact.String()
+}
+
+// toGeoJSON returns a GeoJSON feature collection with a feature per record, the route
+// is the geometry and the other fields are the properties.
+func toGeoJSON(records []record) ([]byte, error) {
+	type feature struct {
+		Type       string                     `json:"type"`
+		Geometry   json.RawMessage            `json:"geometry"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	collection := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{Type: "FeatureCollection", Features: []feature{}}
+
+	for _, r := range records {
+		f := feature{Type: "Feature", Geometry: json.RawMessage("null"), Properties: map[string]json.RawMessage{}}
+		for _, field := range r {
+			if field.name == routeField {
+				f.Geometry = field.value
+			} else {
+				f.Properties[field.name] = field.value
+			}
+		}
+		collection.Features = append(collection.Features, f)
+	}
+	return json.MarshalIndent(collection, "", "  ")
+}
+
+type gpxDocument struct {
+	XMLName  xml.Name    `xml:"gpx"`
+	Version  string      `xml:"version,attr"`
+	Creator  string      `xml:"creator,attr"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Metadata gpxMetadata `xml:"metadata"`
+	Tracks   []gpxTrack  `xml:"trk,omitempty"`
+	Routes   []gpxRoute  `xml:"rte,omitempty"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Time string `xml:"time,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxRoute struct {
+	Name   string     `xml:"name"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// toGPX returns the route of a run as GPX 1.1 track, or of a planned run as GPX route.
+// Records without a line string route return nil.
+func toGPX(r record, planned bool) ([]byte, error) {
+	var geometry struct {
+		Type        string      `json:"type"`
+		Coordinates [][]float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal(r.get(routeField), &geometry); err != nil || geometry.Type != "LineString" {
+		return nil, nil
+	}
+	// GeoJSON orders the coordinates longitude first
+	points := make([]gpxPoint, 0, len(geometry.Coordinates))
+	for _, position := range geometry.Coordinates {
+		if len(position) >= 2 {
+			points = append(points, gpxPoint{Lat: position[1], Lon: position[0]})
+		}
+	}
+
+	var name, created string
+	_ = json.Unmarshal(r.get("name"), &name)
+	_ = json.Unmarshal(r.get("created_at"), &created)
+	createdAt, ok := parseTime(created)
+	if name == "" {
+		name = "Run"
+		if ok {
+			name = "Run on " + createdAt.Format(time.DateOnly)
+		}
+	}
+
+	doc := gpxDocument{
+		Version:  "1.1",
+		Creator:  "Rocket",
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		Metadata: gpxMetadata{Name: name},
+	}
+	if ok {
+		doc.Metadata.Time = createdAt.UTC().Format(time.RFC3339)
+	}
+	if planned {
+		doc.Routes = []gpxRoute{{Name: name, Points: points}}
+	} else {
+		doc.Tracks = []gpxTrack{{Name: name, Segments: []gpxSegment{{Points: points}}}}
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// parseTime reads timestamps as Postgres writes them to JSON, with or without zone.
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
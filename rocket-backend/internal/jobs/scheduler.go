@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+)
+
+// How late a job may be before its health turns to overdue
+const overdueGrace = time.Hour
+
+// Task is the work of a job. It should stop early once ctx is cancelled.
+type Task func(ctx context.Context) error
+
+type job struct {
+	name     string
+	schedule Schedule
+	task     Task
+}
+
+// Scheduler runs the registered jobs on their cron schedules. Every replica runs
+// a scheduler, a Postgres advisory lock and the job run history make sure every
+// scheduled run is executed by exactly one of them.
+type Scheduler struct {
+	db       database.Service
+	jobs     []*job
+	instance string
+	now      func() time.Time
+	started  time.Time
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(db database.Service) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		db:       db,
+		instance: fmt.Sprintf("%s/%d", hostname, os.Getpid()),
+		now:      time.Now,
+		started:  time.Now(),
+	}
+}
+
+// Register adds a job. Names identify jobs across replicas and in the run history.
+func (s *Scheduler) Register(name string, spec string, task Task) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	for _, existing := range s.jobs {
+		if existing.name == name {
+			return fmt.Errorf("job %q is already registered", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{name: name, schedule: schedule, task: task})
+	return nil
+}
+
+// Start runs every job in its own goroutine until Stop is called.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.started = s.now()
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+	logger.Info(fmt.Sprintf("Job scheduler started with %d jobs on %s", len(s.jobs), s.instance))
+}
+
+// Stop cancels the running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	s.wg.Wait()
+	logger.Info("Job scheduler stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	defer s.wg.Done()
+	for {
+		next := j.schedule.Next(s.now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.execute(ctx, j, next); err != nil && !errors.Is(err, custom_error.ErrJobRunning) {
+			logger.Error(fmt.Sprintf("Job %s failed", j.name), err)
+		}
+	}
+}
+
+// execute runs the job for the given slot unless another replica holds its lock or
+// has already run that slot. It returns nil, nil when the slot was claimed elsewhere.
+func (s *Scheduler) execute(ctx context.Context, j *job, slot time.Time) (*types.JobRun, error) {
+	var run *types.JobRun
+	var taskErr error
+	locked, err := s.db.WithAdvisoryLock(lockKey(j.name), func() error {
+		runID, claimed, err := s.db.StartJobRun(j.name, slot, s.instance)
+		if err != nil || !claimed {
+			return err
+		}
+
+		logger.Info(fmt.Sprintf("Running job %s for %s", j.name, slot.Format(time.RFC3339)))
+		taskErr = j.task(ctx)
+		if err := s.db.FinishJobRun(runID, taskErr); err != nil {
+			return err
+		}
+
+		run, err = s.db.GetLastJobRun(j.name, "")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, custom_error.ErrJobRunning
+	}
+	return run, taskErr
+}
+
+// RunNow runs a job immediately, outside of its schedule.
+func (s *Scheduler) RunNow(name string) (*types.JobRun, error) {
+	j, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := s.execute(context.Background(), j, s.now().Truncate(time.Second))
+	if run == nil && err == nil {
+		// Another replica claimed the very same second
+		return nil, custom_error.ErrJobRunning
+	}
+	if run != nil {
+		// The failure is part of the run, callers look at its status
+		return run, nil
+	}
+	return nil, err
+}
+
+// Runs returns the latest runs of a job, newest first.
+func (s *Scheduler) Runs(name string, limit int) ([]types.JobRun, error) {
+	if _, err := s.find(name); err != nil {
+		return nil, err
+	}
+	return s.db.GetJobRuns(name, limit)
+}
+
+// Health reports for every job whether its last run failed or a run is overdue.
+func (s *Scheduler) Health() ([]types.JobHealth, error) {
+	now := s.now()
+	health := make([]types.JobHealth, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		lastRun, err := s.db.GetLastJobRun(j.name, "")
+		if err != nil {
+			return nil, err
+		}
+		lastSuccess, err := s.db.GetLastJobRun(j.name, types.JobRunSucceeded)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := types.JobHealth{
+			Name:      j.name,
+			Schedule:  j.schedule.String(),
+			Status:    types.JobHealthOK,
+			LastRun:   lastRun,
+			NextRunAt: j.schedule.Next(now),
+		}
+
+		// Without a success yet the job is judged from the moment the scheduler started
+		reference := s.started
+		if lastSuccess != nil {
+			entry.LastSuccessAt = lastSuccess.FinishedAt
+			reference = lastSuccess.ScheduledFor
+		}
+
+		switch {
+		case lastRun != nil && lastRun.Status == types.JobRunFailed:
+			entry.Status = types.JobHealthFailing
+		case j.schedule.Next(reference).Add(overdueGrace).Before(now):
+			entry.Status = types.JobHealthOverdue
+		}
+		health = append(health, entry)
+	}
+	return health, nil
+}
+
+func (s *Scheduler) find(name string) (*job, error) {
+	for _, j := range s.jobs {
+		if j.name == name {
+			return j, nil
+		}
+	}
+	return nil, custom_error.ErrJobNotFound
+}
+
+// lockKey maps a job name onto the 64 bit key space of Postgres advisory locks.
+func lockKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte("rocket-job:" + name))
+	return int64(hash.Sum64())
+}
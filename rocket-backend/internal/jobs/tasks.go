@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rocket-backend/internal/challenges"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/export"
+	"rocket-backend/internal/streaks"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Names of the built-in jobs
+const (
+	JobDailyRollover    = "daily_rollover"
+	JobStreakEvaluation = "streak_evaluation"
+	JobCleanup          = "cleanup"
+	JobDataExports      = "data_exports"
+	JobAccountErasure   = "account_erasure"
+)
+
+// RegisterDefaultJobs adds the daily maintenance jobs to the scheduler.
+func RegisterDefaultJobs(s *Scheduler, db database.Service) error {
+	defaults := []struct {
+		name string
+		spec string
+		task Task
+	}{
+		// Shortly after midnight, so users find their challenges ready in the morning
+		{JobDailyRollover, "5 0 * * *", dailyRollover(db)},
+		{JobStreakEvaluation, "15 0 * * *", streakEvaluation(db)},
+		{JobCleanup, "30 3 * * *", cleanup(db)},
+		// Exports are built right away, this only picks up those of stopped replicas
+		{JobDataExports, "*/5 * * * *", dataExports(db)},
+		{JobAccountErasure, "0 * * * *", accountErasure(db)},
+	}
+
+	for _, job := range defaults {
+		if err := s.Register(job.name, job.spec, job.task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dailyRollover assigns today's challenges to every user who has none yet.
+func dailyRollover(db database.Service) Task {
+	return func(ctx context.Context) error {
+		challengeManager := challenges.NewChallengeManager(db)
+		return forEachUser(ctx, db, func(userID uuid.UUID) error {
+			_, err := challengeManager.GetDailies(userID)
+			return err
+		})
+	}
+}
+
+// streakEvaluation settles yesterday for every streak, so broken streaks and used
+// freezes show up in the activity feed without the user opening the app.
+func streakEvaluation(db database.Service) Task {
+	return func(ctx context.Context) error {
+		streakManager := streaks.NewStreakManager(db)
+		return forEachUser(ctx, db, func(userID uuid.UUID) error {
+			_, err := streakManager.Evaluate(userID)
+			return err
+		})
+	}
+}
+
+// dataExports retries the data exports whose build stopped halfway.
+func dataExports(db database.Service) Task {
+	return func(ctx context.Context) error {
+		return export.NewExporter(db).ProcessStale(ctx)
+	}
+}
+
+// accountErasure erases the deactivated accounts whose grace period is over.
+func accountErasure(db database.Service) Task {
+	return func(ctx context.Context) error {
+		users, err := db.GetUsersDueForErasure()
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		var firstErr error
+		for _, userID := range users {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Already erased, e.g. by another replica
+			if err := db.DeleteUser(userID); err != nil && !errors.Is(err, custom_error.ErrUserNotFound) {
+				logger.Error(fmt.Sprintf("Failed to erase user %s", userID), err)
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to erase %d of %d users: %w", failed, len(users), firstErr)
+		}
+		return nil
+	}
+}
+
+func cleanup(db database.Service) Task {
+	return func(ctx context.Context) error {
+		if err := db.CleanUpChallenges(); err != nil {
+			return err
+		}
+		if err := db.ExpireChallengeInvitations(); err != nil {
+			return err
+		}
+		if err := db.ExpireDuels(); err != nil {
+			return err
+		}
+		if err := db.CleanUpTokens(); err != nil {
+			return err
+		}
+		if err := db.CleanUpRateLimits(); err != nil {
+			return err
+		}
+		if err := db.CleanUpDataExports(); err != nil {
+			return err
+		}
+		return db.CleanUpJobRuns()
+	}
+}
+
+// forEachUser calls fn for all users. A failing user does not stop the others,
+// the returned error tells how many failed.
+func forEachUser(ctx context.Context, db database.Service, fn func(userID uuid.UUID) error) error {
+	users, err := db.GetAllUsers(nil)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	var firstErr error
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(user.ID); err != nil {
+			logger.Error(fmt.Sprintf("Job failed for user %s", user.ID), err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed for %d of %d users: %w", failed, len(users), firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/internal/validation"
+	"rocket-backend/pkg/auth"
+	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/mailer"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sendEmailToken creates a single-use token for the user and mails a link containing it.
+func (s *Server) sendEmailToken(creds types.Credentials, purpose string) error {
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	ttl, path, subject, text := auth.EmailVerificationTTL, "verify-email", "Verify your email address",
+		"Welcome to Rocket! Please confirm your email address by opening this link:"
+	if purpose == types.EmailTokenReset {
+		ttl, path, subject, text = auth.PasswordResetTTL, "reset-password", "Reset your password",
+			"Someone asked to reset the password of your Rocket account. If that was you, open this link to choose a new one:"
+	}
+
+	if err := s.db.CreateEmailToken(creds.ID, purpose, creds.Email, auth.HashOpaqueToken(token), time.Now().Add(ttl)); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/%s?token=%s", os.Getenv("APP_URL"), path, token)
+	return s.mailer.Send(mailer.Message{
+		To:      creds.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf("%s\n\n%s\n\nThe link expires in %s.\n", text, link, formatTTL(ttl)),
+	})
+}
+
+func formatTTL(ttl time.Duration) string {
+	if hours := int(ttl.Hours()); hours > 1 {
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return fmt.Sprintf("%d minutes", int(ttl.Minutes()))
+}
+
+// VerifyEmailHandler confirms the email address with the token from the verification email.
+func (s *Server) VerifyEmailHandler(c *gin.Context) {
+	var req types.VerifyEmailDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+
+	if _, err := s.db.VerifyEmail(auth.HashOpaqueToken(req.Token)); err != nil {
+		if errors.Is(err, custom_error.ErrInvalidEmailToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationHandler sends a new verification email, the previous link stops working.
+func (s *Server) ResendVerificationHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	creds, err := s.db.GetCredentialsByID(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if creds.EmailVerified {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	if err := s.sendEmailToken(creds, types.EmailTokenVerify); err != nil {
+		logger.Error("Failed to send verification email", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// RequestPasswordResetHandler mails a reset link if the address belongs to a user. The
+// response is the same either way, so it cannot be used to find out who is registered.
+func (s *Server) RequestPasswordResetHandler(c *gin.Context) {
+	var req types.PasswordResetRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+
+	if creds, err := s.db.GetUserByEmail(req.Email); err == nil {
+		if err := s.sendEmailToken(creds, types.EmailTokenReset); err != nil {
+			logger.Error("Failed to send password reset email", err)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// ConfirmPasswordResetHandler sets a new password with the token from the reset email
+// and logs the user out everywhere.
+func (s *Server) ConfirmPasswordResetHandler(c *gin.Context) {
+	var req types.PasswordResetConfirmDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+
+	if err := s.passwordPolicy.Check(req.NewPassword); err != nil {
+		respondInvalid(c, validation.FieldErrors{"new_password": err.Error()})
+		return
+	}
+
+	userID, err := s.db.ResetPassword(auth.HashOpaqueToken(req.Token), req.NewPassword)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrInvalidEmailToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		}
+		return
+	}
+
+	if _, err := s.db.RevokeAllTokens(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (s *Server) AdminGetUsersHandler(c *gin.Context) {
+	users, err := s.db.GetUsersWithRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (s *Server) AdminUpdateUserRoleHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var dto types.UpdateRoleDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of user, moderator or admin"})
+		return
+	}
+
+	if err := s.db.SetUserRole(userID, dto.Role); err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+
+	logger.Info("User role changed", "userID", userID, "role", dto.Role, "by", adminActor(c))
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
+}
+
+func (s *Server) AdminDeleteUserHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	// Demoting first applies the last admin check to deletions as well
+	if err := s.db.SetUserRole(userID, types.RoleUser); err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+	if err := s.db.DeleteUser(userID); err != nil {
+		respondUserAdminError(c, err)
+		return
+	}
+
+	logger.Info("User deleted", "userID", userID, "by", adminActor(c))
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+func respondUserAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, custom_error.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, custom_error.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": "The last admin cannot be removed"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	}
+}
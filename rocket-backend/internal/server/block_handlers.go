@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Blocked users can no longer follow, invite or duel the blocker, see their profile
+// or activities, and their chat messages are hidden from the blocker. Muting only
+// hides a user's activities and chat messages.
+
+func (s *Server) GetBlockedUsersHandler(c *gin.Context) {
+	s.getBlockedUsers(c, types.BlockKindBlock)
+}
+
+func (s *Server) BlockUserHandler(c *gin.Context) {
+	s.blockUser(c, types.BlockKindBlock)
+}
+
+func (s *Server) UnblockUserHandler(c *gin.Context) {
+	s.unblockUser(c, types.BlockKindBlock)
+}
+
+func (s *Server) GetMutedUsersHandler(c *gin.Context) {
+	s.getBlockedUsers(c, types.BlockKindMute)
+}
+
+func (s *Server) MuteUserHandler(c *gin.Context) {
+	s.blockUser(c, types.BlockKindMute)
+}
+
+func (s *Server) UnmuteUserHandler(c *gin.Context) {
+	s.unblockUser(c, types.BlockKindMute)
+}
+
+func (s *Server) getBlockedUsers(c *gin.Context, kind string) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	blocked, err := s.db.GetBlockedUsers(userUUID, kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": custom_error.ErrFailedToRetrieveData.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": blocked})
+}
+
+func (s *Server) blockUser(c *gin.Context, kind string) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username is required"})
+		return
+	}
+
+	blockedID, err := s.db.GetUserIDByName(req.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if blockedID == userUUID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot " + kind + " yourself"})
+		return
+	}
+
+	if err := s.db.BlockUser(userUUID, blockedID, kind); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": custom_error.ErrFailedToSave.Error()})
+		return
+	}
+
+	message := "User blocked"
+	if kind == types.BlockKindMute {
+		message = "User muted"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func (s *Server) unblockUser(c *gin.Context, kind string) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	blockedID, err := s.db.GetUserIDByName(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	notFound, message := "User is not blocked", "User unblocked"
+	if kind == types.BlockKindMute {
+		notFound, message = "User is not muted", "User unmuted"
+	}
+
+	if err := s.db.UnblockUser(userUUID, blockedID, kind); err != nil {
+		if errors.Is(err, custom_error.ErrBlockNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": custom_error.ErrFailedToDelete.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// isBlockedEitherWay reports whether one of the two users blocked the other.
+func (s *Server) isBlockedEitherWay(userID, otherID uuid.UUID) (bool, error) {
+	blocked, err := s.db.IsBlocked(userID, otherID)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return s.db.IsBlocked(otherID, userID)
+}
+
+// hiddenByBlock responds as if the user did not exist and reports true when they
+// blocked the viewer.
+func (s *Server) hiddenByBlock(c *gin.Context, userID uuid.UUID) bool {
+	viewerID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return true
+	}
+	blocked, err := s.db.IsBlocked(userID, viewerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return true
+	}
+	if blocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return true
+	}
+	return false
+}
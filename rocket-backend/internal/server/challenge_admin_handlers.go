@@ -0,0 +1,205 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rocket-backend/internal/challenges"
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Entries returned by the audit log endpoint
+const challengeAuditLimit = 200
+
+// adminActor names who performed an admin request, for the audit log.
+func adminActor(c *gin.Context) string {
+	if userID, exists := c.Get("userID"); exists {
+		return "user:" + userID.(string)
+	}
+	return "api_key"
+}
+
+func (s *Server) AdminGetChallengesHandler(c *gin.Context) {
+	catalog, err := s.db.GetChallengeCatalog()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve challenges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, catalog)
+}
+
+func (s *Server) AdminCreateChallengeHandler(c *gin.Context) {
+	var dto types.CatalogChallengeDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	challengeManager := challenges.NewChallengeManager(s.db)
+	challenge, err := challengeManager.CreateCatalogChallenge(dto, adminActor(c))
+	if err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, challenge)
+}
+
+func (s *Server) AdminUpdateChallengeHandler(c *gin.Context) {
+	challengeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid challenge ID format"})
+		return
+	}
+
+	var dto types.CatalogChallengeDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	challengeManager := challenges.NewChallengeManager(s.db)
+	challenge, err := challengeManager.UpdateCatalogChallenge(challengeID, dto, adminActor(c))
+	if err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, challenge)
+}
+
+// AdminRetireChallengeHandler only retires the challenge so history and completions stay intact.
+func (s *Server) AdminRetireChallengeHandler(c *gin.Context) {
+	challengeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid challenge ID format"})
+		return
+	}
+
+	if err := s.db.RetireChallenge(challengeID.String(), adminActor(c)); err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Challenge retired successfully"})
+}
+
+func (s *Server) AdminGetChallengeAuditLogHandler(c *gin.Context) {
+	entries, err := s.db.GetChallengeAuditLog(challengeAuditLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// AdminGetFeaturedChallengesHandler lists the featured slots, by default for the next 14 days.
+func (s *Server) AdminGetFeaturedChallengesHandler(c *gin.Context) {
+	from := time.Now()
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	to := from.AddDate(0, 0, 14)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+
+	featured, err := s.db.GetFeaturedChallenges(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve featured challenges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, featured)
+}
+
+func (s *Server) AdminSetFeaturedChallengeHandler(c *gin.Context) {
+	date, slot, ok := featuredSlot(c)
+	if !ok {
+		return
+	}
+
+	var dto types.FeatureChallengeDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	challengeManager := challenges.NewChallengeManager(s.db)
+	if err := challengeManager.Feature(date, slot, dto.ChallengeID, adminActor(c)); err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Challenge featured successfully"})
+}
+
+func (s *Server) AdminRemoveFeaturedChallengeHandler(c *gin.Context) {
+	date, slot, ok := featuredSlot(c)
+	if !ok {
+		return
+	}
+
+	if err := s.db.RemoveFeaturedChallenge(date, slot, adminActor(c)); err != nil {
+		respondCatalogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Featured slot cleared"})
+}
+
+func (s *Server) GetFeaturedChallengesHandler(c *gin.Context) {
+	challengeManager := challenges.NewChallengeManager(s.db)
+	featured, err := challengeManager.Featured(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve featured challenges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, featured)
+}
+
+func featuredSlot(c *gin.Context) (time.Time, int, bool) {
+	date, err := time.Parse("2006-01-02", c.Param("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return time.Time{}, 0, false
+	}
+
+	slot, err := strconv.Atoi(c.Param("slot"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot"})
+		return time.Time{}, 0, false
+	}
+
+	return date, slot, true
+}
+
+func respondCatalogError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, custom_error.ErrChallengeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+	case errors.Is(err, custom_error.ErrInvalidChallenge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the challenge catalog"})
+	}
+}
@@ -54,45 +54,45 @@ func (s *Server) CompleteChallengeHandler(c *gin.Context) {
 		return
 	}
 
-	var pointsDTO types.CompleteChallengesDTO
-	if err := c.ShouldBindJSON(&pointsDTO); err != nil {
+	var completeDTO types.CompleteChallengesDTO
+	if err := c.ShouldBindJSON(&completeDTO); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	err = s.db.UpdateRocketPoints(userUUID, pointsDTO.RocketPoints)
+	challengeManager := challenges.NewChallengeManager(s.db)
+	points, err := challengeManager.Complete(userUUID, completeDTO)
 	if err != nil {
-		if errors.Is(err, custom_error.ErrFailedToUpdate) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rocket points"})
+		if errors.Is(err, custom_error.ErrChallengeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Challenge is not assigned for today"})
+		} else if errors.Is(err, custom_error.ErrAlreadyCompleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Challenge already completed"})
+		} else if errors.Is(err, custom_error.ErrChallengeNotMet) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		} else if errors.Is(err, custom_error.ErrFailedToUpdate) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete challenge"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		}
 		return
 	}
 
-	err = s.db.CompleteChallenge(userUUID, pointsDTO)
-	if err != nil {
-		if errors.Is(err, custom_error.ErrFailedToUpdate) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete challenge"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		}
+	c.JSON(http.StatusOK, gin.H{"message": "Challenge completed successfully", "rocket_points": points})
+}
+
+func (s *Server) InviteFriendChallenge(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// save activity
-	challenge, err := s.db.GetChallengeByID(pointsDTO.ChallengeID)
+	userUUID, err := uuid.Parse(userID.(string))
 	if err != nil {
-		challenge.Text = "Unknown challenge"
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
 	}
 
-	message := "Completed a daily challenge: " + challenge.Text
-	_ = s.db.SaveActivity(userUUID, message)
-
-	c.JSON(http.StatusOK, gin.H{"message": "Challenge completed successfully"})
-}
-
-func (s *Server) InviteFriendChallenge(c *gin.Context) {
 	var req struct {
 		ChallengeID string `json:"challenge_id" binding:"required"`
 		FriendID    string `json:"friend_id" binding:"required"`
@@ -115,17 +115,106 @@ func (s *Server) InviteFriendChallenge(c *gin.Context) {
 		return
 	}
 
-	err = s.db.InviteFriendToChallenge(challengeUUID, friendUUID)
+	challengeManager := challenges.NewChallengeManager(s.db)
+	invitation, err := challengeManager.Invite(userUUID, friendUUID, challengeUUID)
 	if err != nil {
-		if errors.Is(err, custom_error.ErrFailedToUpdate) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite friend"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		}
+		respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func (s *Server) GetIncomingInvitationsHandler(c *gin.Context) {
+	s.getInvitations(c, true)
+}
+
+func (s *Server) GetOutgoingInvitationsHandler(c *gin.Context) {
+	s.getInvitations(c, false)
+}
+
+func (s *Server) getInvitations(c *gin.Context, incoming bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	challengeManager := challenges.NewChallengeManager(s.db)
+	invitations, err := challengeManager.Invitations(userUUID, incoming)
+	if err != nil {
+		respondInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func (s *Server) AcceptInvitationHandler(c *gin.Context) {
+	s.respondToInvitation(c, true)
+}
+
+func (s *Server) DeclineInvitationHandler(c *gin.Context) {
+	s.respondToInvitation(c, false)
+}
+
+func (s *Server) respondToInvitation(c *gin.Context, accept bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Friend invited successfully"})
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID format"})
+		return
+	}
+
+	challengeManager := challenges.NewChallengeManager(s.db)
+	if err := challengeManager.RespondToInvitation(userUUID, invitationID, accept); err != nil {
+		respondInvitationError(c, err)
+		return
+	}
+
+	if accept {
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+	}
+}
+
+func respondInvitationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, custom_error.ErrNotFriends):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only invite your friends"})
+	case errors.Is(err, custom_error.ErrBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot invite this user"})
+	case errors.Is(err, custom_error.ErrChallengeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge is not assigned to you today"})
+	case errors.Is(err, custom_error.ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+	case errors.Is(err, custom_error.ErrAlreadyAssigned):
+		c.JSON(http.StatusConflict, gin.H{"error": "Friend already has this challenge today"})
+	case errors.Is(err, custom_error.ErrAlreadyInvited):
+		c.JSON(http.StatusConflict, gin.H{"error": "Friend was already invited to this challenge"})
+	case errors.Is(err, custom_error.ErrInvitationNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
 }
 
 func (s *Server) GetDailyChallengeProgress(c *gin.Context) {
@@ -168,3 +257,31 @@ func (s *Server) GetDailyChallengeProgress(c *gin.Context) {
 
 	c.JSON(http.StatusOK, progress)
 }
+
+func (s *Server) GetPeriodicChallengesHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	challengeManager := challenges.NewChallengeManager(s.db)
+	// Progress is evaluated when steps or runs are uploaded, reading it changes nothing
+	progress, err := challengeManager.GetPeriodicProgress(userUUID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrSettingsNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Settings not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve periodic challenges"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
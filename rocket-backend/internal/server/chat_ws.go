@@ -14,18 +14,30 @@ import (
 )
 
 type ChatHub struct {
-	clients    map[*websocket.Conn]bool
-	broadcast  chan []byte
-	register   chan *websocket.Conn
+	clients    map[*websocket.Conn]uuid.UUID
+	broadcast  chan chatBroadcast
+	register   chan chatClient
 	unregister chan *websocket.Conn
 	mu         sync.Mutex
 }
 
+// chatClient is a connection with the user on the other end.
+type chatClient struct {
+	conn   *websocket.Conn
+	userID uuid.UUID
+}
+
+// chatBroadcast is an event for all clients except the users hiding its author.
+type chatBroadcast struct {
+	message    []byte
+	hiddenFrom map[uuid.UUID]bool
+}
+
 func NewChatHub() *ChatHub {
 	return &ChatHub{
-		clients:    make(map[*websocket.Conn]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *websocket.Conn),
+		clients:    make(map[*websocket.Conn]uuid.UUID),
+		broadcast:  make(chan chatBroadcast),
+		register:   make(chan chatClient),
 		unregister: make(chan *websocket.Conn),
 	}
 }
@@ -33,9 +45,9 @@ func NewChatHub() *ChatHub {
 func (hub *ChatHub) Run() {
 	for {
 		select {
-		case conn := <-hub.register:
+		case client := <-hub.register:
 			hub.mu.Lock()
-			hub.clients[conn] = true
+			hub.clients[client.conn] = client.userID
 			hub.mu.Unlock()
 		case conn := <-hub.unregister:
 			hub.mu.Lock()
@@ -44,10 +56,13 @@ func (hub *ChatHub) Run() {
 				conn.Close()
 			}
 			hub.mu.Unlock()
-		case message := <-hub.broadcast:
+		case event := <-hub.broadcast:
 			hub.mu.Lock()
-			for conn := range hub.clients {
-				err := conn.WriteMessage(websocket.TextMessage, message)
+			for conn, userID := range hub.clients {
+				if event.hiddenFrom[userID] {
+					continue
+				}
+				err := conn.WriteMessage(websocket.TextMessage, event.message)
 				if err != nil {
 					conn.Close()
 					delete(hub.clients, conn)
@@ -86,7 +101,7 @@ func (s *Server) ChatWebSocketHandler(hub *ChatHub) gin.HandlerFunc {
 			logger.Error("WebSocket upgrade error: ", err)
 			return
 		}
-		hub.register <- conn
+		hub.register <- chatClient{conn: conn, userID: userUUID}
 
 		defer func() {
 			hub.unregister <- conn
@@ -141,7 +156,7 @@ func (s *Server) ChatWebSocketHandler(hub *ChatHub) gin.HandlerFunc {
 					"reactions": count,
 				}
 				outBytes, _ := json.Marshal(reactionEvent)
-				hub.broadcast <- outBytes
+				hub.broadcast <- chatBroadcast{message: outBytes, hiddenFrom: s.usersHiding(userUUID)}
 			default:
 				var incomingMsg struct {
 					Message string `json:"message"`
@@ -168,8 +183,23 @@ func (s *Server) ChatWebSocketHandler(hub *ChatHub) gin.HandlerFunc {
 				}
 				outBytes, _ := json.Marshal(outgoing)
 				logger.Info("WebSocket: broadcasting chat message:", outgoing)
-				hub.broadcast <- outBytes
+				hub.broadcast <- chatBroadcast{message: outBytes, hiddenFrom: s.usersHiding(userUUID)}
 			}
 		}
 	}
 }
+
+// usersHiding returns the users who blocked or muted the author. Should that fail,
+// the event goes to everyone rather than to no one.
+func (s *Server) usersHiding(authorID uuid.UUID) map[uuid.UUID]bool {
+	ids, err := s.db.GetUsersHiding(authorID)
+	if err != nil {
+		logger.Error("Failed to load users hiding chat author: ", err)
+		return nil
+	}
+	hidden := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	return hidden
+}
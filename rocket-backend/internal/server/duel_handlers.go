@@ -0,0 +1,179 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/duels"
+	"rocket-backend/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (s *Server) CreateDuelHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var dto types.CreateDuelDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	duelManager := duels.NewDuelManager(s.db)
+	duel, err := duelManager.Create(userUUID, dto)
+	if err != nil {
+		respondDuelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, duel)
+}
+
+func (s *Server) GetDuelsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	duelManager := duels.NewDuelManager(s.db)
+	open, err := duelManager.Open(userUUID)
+	if err != nil {
+		respondDuelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, open)
+}
+
+func (s *Server) GetDuelHistoryHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	duelManager := duels.NewDuelManager(s.db)
+	history, err := duelManager.History(userUUID)
+	if err != nil {
+		respondDuelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (s *Server) GetDuelHandler(c *gin.Context) {
+	userUUID, duelID, ok := duelRequest(c)
+	if !ok {
+		return
+	}
+
+	duelManager := duels.NewDuelManager(s.db)
+	duel, err := duelManager.Get(userUUID, duelID)
+	if err != nil {
+		respondDuelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, duel)
+}
+
+func (s *Server) AcceptDuelHandler(c *gin.Context) {
+	userUUID, duelID, ok := duelRequest(c)
+	if !ok {
+		return
+	}
+
+	duelManager := duels.NewDuelManager(s.db)
+	duel, err := duelManager.Accept(userUUID, duelID)
+	if err != nil {
+		respondDuelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, duel)
+}
+
+func (s *Server) DeclineDuelHandler(c *gin.Context) {
+	userUUID, duelID, ok := duelRequest(c)
+	if !ok {
+		return
+	}
+
+	duelManager := duels.NewDuelManager(s.db)
+	if err := duelManager.Decline(userUUID, duelID); err != nil {
+		respondDuelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Duel declined"})
+}
+
+// duelRequest reads the authenticated user and the duel ID from the path.
+func duelRequest(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	duelID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duel ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userUUID, duelID, true
+}
+
+func respondDuelError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, custom_error.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, custom_error.ErrDuelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Duel not found"})
+	case errors.Is(err, custom_error.ErrNotFriends):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only duel your friends"})
+	case errors.Is(err, custom_error.ErrBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot duel this user"})
+	case errors.Is(err, custom_error.ErrInvalidDuel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, custom_error.ErrDuelNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Duel is no longer pending"})
+	case errors.Is(err, custom_error.ErrInsufficientPoints):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough rocket points for the stake"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
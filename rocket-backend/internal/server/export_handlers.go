@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/export"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/auth"
+	"rocket-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestDataExportHandler starts building an archive of everything stored about the
+// user. The download link is only part of this response, it works once the export is
+// ready until it expires.
+func (s *Server) RequestDataExportHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate download link"})
+		return
+	}
+	dataExport := types.DataExport{
+		ID:        uuid.New(),
+		UserID:    userUUID,
+		Status:    types.ExportStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.db.CreateDataExport(dataExport, auth.HashOpaqueToken(token)); err != nil {
+		if errors.Is(err, custom_error.ErrExportInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An export is already being prepared"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+		}
+		return
+	}
+
+	// Replicas that stop halfway leave the export to the data_exports job
+	go func() {
+		if err := export.NewExporter(s.db).Process(dataExport.ID); err != nil {
+			logger.Error(fmt.Sprintf("Data export %s failed", dataExport.ID), err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"export":       dataExport,
+		"download_url": "/api/v1/exports/" + token,
+		"valid_for":    fmt.Sprintf("%d hours after the export is ready", int(export.LinkTTL.Hours())),
+	})
+}
+
+// GetDataExportHandler reports the state of the user's latest export.
+func (s *Server) GetDataExportHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	dataExport, err := s.db.GetLatestDataExport(userUUID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrExportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No export requested"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load export"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"export": dataExport})
+}
+
+// DownloadDataExportHandler sends the archive of the export with the token from the
+// download link. It needs no login, so the link also works in a browser.
+func (s *Server) DownloadDataExportHandler(c *gin.Context) {
+	dataExport, archive, err := s.db.GetDataExportArchive(auth.HashOpaqueToken(c.Param("token")))
+	if err != nil {
+		if errors.Is(err, custom_error.ErrExportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Export not found or expired"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load export"})
+		}
+		return
+	}
+
+	switch dataExport.Status {
+	case types.ExportStatusPending:
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is not ready yet"})
+		return
+	case types.ExportStatusFailed:
+		c.JSON(http.StatusGone, gin.H{"error": "Export failed, please request a new one"})
+		return
+	}
+
+	filename := fmt.Sprintf("rocket-export-%s.zip", dataExport.CreatedAt.Format(time.DateOnly))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
@@ -45,6 +45,16 @@ func (s *Server) AddFriendHandler(c *gin.Context) {
 		return
 	}
 
+	blocked, err := s.isBlockedEitherWay(userUUID, friendID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": custom_error.ErrFailedToSave.Error()})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot follow this user"})
+		return
+	}
+
 	err = s.db.AddFriend(userUUID, friendID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": custom_error.ErrFailedToSave.Error()})
@@ -156,6 +166,9 @@ func (s *Server) GetFollowingHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
 		return
 	}
+	if s.hiddenByBlock(c, userUUID) {
+		return
+	}
 
 	// Get the list of users this user is following
 	following, err := s.db.GetFriends(userUUID)
@@ -202,6 +215,9 @@ func (s *Server) GetFollowersHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
 		return
 	}
+	if s.hiddenByBlock(c, userUUID) {
+		return
+	}
 
 	followers, err := s.db.GetFollowers(userUUID)
 	if err != nil {
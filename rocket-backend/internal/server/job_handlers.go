@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Runs returned per job by the run history endpoint
+const jobRunsLimit = 50
+
+// AdminGetJobsHealthHandler answers with 503 while any job is failing or overdue,
+// so it can be wired into monitoring directly.
+func (s *Server) AdminGetJobsHealthHandler(c *gin.Context) {
+	health, err := s.scheduler.Health()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job health"})
+		return
+	}
+
+	status, code := "up", http.StatusOK
+	for _, job := range health {
+		if job.Status != types.JobHealthOK {
+			status, code = "degraded", http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(code, gin.H{"status": status, "jobs": health})
+}
+
+func (s *Server) AdminGetJobRunsHandler(c *gin.Context) {
+	runs, err := s.scheduler.Runs(c.Param("name"), jobRunsLimit)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+// AdminRunJobHandler runs a job right away and waits for it to finish.
+func (s *Server) AdminRunJobHandler(c *gin.Context) {
+	run, err := s.scheduler.RunNow(c.Param("name"))
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, custom_error.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, custom_error.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is already running"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run job"})
+	}
+}
@@ -1,82 +1,208 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
-	"strings"
 	"os"
+	"strings"
+	"time"
 
+	"rocket-backend/internal/types"
 	"rocket-backend/pkg/auth"
+	"rocket-backend/pkg/logger"
 
-	_ "github.com/joho/godotenv/autoload"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	_ "github.com/joho/godotenv/autoload"
 )
 
 func (s *Server) AuthMiddleware() gin.HandlerFunc {
-	authService := auth.NewAuthService(s.jwtSecret)
-
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		var tokenString string
-
-		if authHeader != "" {
-			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			cookie, err := c.Cookie("jwt_token")
-			if err != nil || cookie == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header or jwt_token cookie required"})
-				c.Abort()
-				return
-			}
-			tokenString = cookie
+		if !s.authenticate(c) {
+			return
 		}
+		c.Next()
+	}
+}
+
+// authenticate validates the token of the request and stores the user and role in the
+// context. On failure it aborts the request and returns false.
+func (s *Server) authenticate(c *gin.Context) bool {
+	authService := s.authService
+
+	authHeader := c.GetHeader("Authorization")
+	var tokenString string
 
-		token, err := authService.ParseToken(tokenString)
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	if authHeader != "" {
+		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+	} else {
+		cookie, err := c.Cookie("jwt_token")
+		if err != nil || cookie == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header or jwt_token cookie required"})
 			c.Abort()
-			return
+			return false
 		}
+		tokenString = cookie
+	}
 
-		userUUID, err := authService.ValidateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
-			return
+	if strings.HasPrefix(tokenString, auth.PersonalTokenPrefix) {
+		return s.authenticatePersonalToken(c, tokenString)
+	}
+	if strings.HasPrefix(tokenString, auth.OAuthAccessTokenPrefix) {
+		return s.authenticateOAuthToken(c, tokenString)
+	}
+
+	token, err := authService.ParseToken(tokenString)
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return false
+	}
+
+	userUUID, err := authService.ValidateToken(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return false
+	}
+
+	// Tokens without an ID predate revocation and cannot be checked, so they are refused
+	claims, err := authService.Claims(token)
+	tokenID, idErr := uuid.Parse(claims.ID)
+	if err != nil || idErr != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return false
+	}
+
+	// Also fails for deleted users, their credentials are gone
+	valid, err := s.db.IsAccessTokenValid(userUUID, tokenID, claims.Version, claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+		c.Abort()
+		return false
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return false
+	}
+
+	if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+		if err := s.db.TouchSession(sessionID, c.ClientIP()); err != nil {
+			logger.Error("Failed to update session activity", err)
 		}
+		c.Set("sessionID", sessionID.String())
+	}
 
-		// Check if the user exists in the database
-		_, err = s.db.GetUserByID(userUUID)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User does not exist"})
-			c.Abort()
+	c.Set("userID", userUUID.String())
+	c.Set("role", claims.Role)
+	return true
+}
+
+// RequireRole only lets users through whose role grants at least the required one.
+// It has to run after AuthMiddleware.
+func (s *Server) RequireRole(required string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.authorize(c, required) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		c.Set("userID", userUUID.String())
+// authorize checks the role from the token against the stored one as well,
+// so a demotion takes effect before the user's token expires.
+func (s *Server) authorize(c *gin.Context, required string) bool {
+	role := c.GetString("role")
+	if types.HasRole(role, required) {
+		userID, err := uuid.Parse(c.GetString("userID"))
+		if err == nil {
+			stored, err := s.db.GetUserRole(userID)
+			if err == nil && types.HasRole(stored, required) {
+				return true
+			}
+		}
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	c.Abort()
+	return false
+}
+
+// AdminMiddleware accepts either the API key, for scripts and operators, or the token
+// of an admin. Only requests with a token are attributed to a user.
+func (s *Server) AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("X-API-KEY") != "" {
+			if !checkAPIKey(c) {
+				return
+			}
+		} else if !s.authenticate(c) || !s.authorize(c, types.RoleAdmin) {
+			return
+		}
 		c.Next()
 	}
 }
 
 func (s *Server) APIKeyMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		expectedAPIKey := os.Getenv("API_KEY")
-		sentApiKey := c.GetHeader("X-API-KEY")
-
-		// Debug-Logs
-		if expectedAPIKey == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "API key not configured"})
-			c.Abort()
+		if !checkAPIKey(c) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		if sentApiKey != expectedAPIKey || sentApiKey == "" {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Invalid or missing API key",
-			})
-			c.Abort()
-			return
+func checkAPIKey(c *gin.Context) bool {
+	expectedAPIKey := os.Getenv("API_KEY")
+	sentApiKey := c.GetHeader("X-API-KEY")
+
+	if expectedAPIKey == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API key not configured"})
+		c.Abort()
+		return false
+	}
+
+	if sentApiKey != expectedAPIKey || sentApiKey == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Invalid or missing API key",
+		})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// Paths whose last part is a secret, it is left out of the access log
+var redactedPathPrefixes = []string{"/api/v1/exports/"}
+
+// accessLogFormatter writes gin's default access log line without the secrets in
+// redactedPathPrefixes.
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	for _, prefix := range redactedPathPrefixes {
+		if strings.HasPrefix(param.Path, prefix) {
+			param.Path = prefix + "[redacted]"
 		}
+	}
 
-		c.Next()
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
 	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		param.Path,
+		param.ErrorMessage,
+	)
 }
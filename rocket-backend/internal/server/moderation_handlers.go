@@ -0,0 +1,33 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ModerationDeleteChatMessageHandler removes a chat message of any user.
+func (s *Server) ModerationDeleteChatMessageHandler(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return
+	}
+
+	if err := s.db.DeleteChatMessage(messageID); err != nil {
+		if errors.Is(err, custom_error.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
+		}
+		return
+	}
+
+	logger.Info("Chat message removed by moderator", "messageID", messageID, "by", c.GetString("userID"))
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully"})
+}
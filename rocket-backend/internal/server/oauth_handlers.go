@@ -0,0 +1,485 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/internal/validation"
+	"rocket-backend/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Partner apps use the authorization code flow with PKCE (RFC 6749, RFC 7636). The app
+// sends the user to our frontend with its authorization request, the frontend asks the
+// user for consent through the /protected/oauth/authorize routes and sends the user back
+// with a code, which the partner exchanges at /oauth/token. The endpoints partners call
+// directly answer with the error codes of RFC 6749.
+
+// AdminGetOAuthClientsHandler lists the registered partner apps.
+func (s *Server) AdminGetOAuthClientsHandler(c *gin.Context) {
+	clients, err := s.db.GetOAuthClients()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clients"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": clients, "scopes": types.TokenScopes})
+}
+
+// AdminCreateOAuthClientHandler registers a partner app. The secret of confidential
+// clients is only part of this response.
+func (s *Server) AdminCreateOAuthClientHandler(c *gin.Context) {
+	var req types.CreateOAuthClientDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	fields := validation.FieldErrors{}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(types.TokenScopes, scope) {
+			fields["scopes"] = fmt.Sprintf("unknown scope %q", scope)
+		}
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			fields["redirect_uris"] = fmt.Sprintf("%q must be https, a loopback address or an app scheme, without fragment", uri)
+		}
+	}
+	if err := fields.Err(); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+
+	clientID, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+	slices.Sort(req.Scopes)
+	client := types.OAuthClient{
+		ID:           clientID[:24],
+		Name:         req.Name,
+		RedirectURIs: slices.Compact(req.RedirectURIs),
+		Scopes:       slices.Compact(req.Scopes),
+		Confidential: req.Confidential,
+		CreatedAt:    time.Now(),
+	}
+	// Requests with the API key have no user
+	if adminID, err := uuid.Parse(c.GetString("userID")); err == nil {
+		client.CreatedBy = &adminID
+	}
+	response := gin.H{"client": client}
+	if req.Confidential {
+		secret, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+			return
+		}
+		client.SecretHash = auth.HashOpaqueToken(secret)
+		response["client_secret"] = secret
+	}
+
+	if err := s.db.CreateOAuthClient(client); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// AdminDeleteOAuthClientHandler removes a partner app, all its tokens stop working.
+func (s *Server) AdminDeleteOAuthClientHandler(c *gin.Context) {
+	if err := s.db.DeleteOAuthClient(c.Param("id")); err != nil {
+		if errors.Is(err, custom_error.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted"})
+}
+
+// GetOAuthAuthorizationHandler checks an authorization request for the consent screen
+// and tells whether the user already allowed everything it asks for.
+func (s *Server) GetOAuthAuthorizationHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	var req types.OAuthAuthorizeDTO
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+
+	client, scopes, ok := s.checkAuthorizeRequest(c, &req)
+	if !ok {
+		return
+	}
+	granted, err := s.db.GetOAuthConsent(userUUID, client.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load consent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client":           gin.H{"client_id": client.ID, "name": client.Name},
+		"scopes":           scopes,
+		"consent_required": !isSubset(scopes, granted),
+	})
+}
+
+// OAuthAuthorizeHandler answers an authorization request with the decision of the user
+// and returns where to send the user back to.
+func (s *Server) OAuthAuthorizeHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	var req types.OAuthAuthorizeDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+
+	client, scopes, ok := s.checkAuthorizeRequest(c, &req)
+	if !ok {
+		return
+	}
+	if !req.Approve {
+		c.JSON(http.StatusOK, gin.H{"redirect_to": oauthRedirect(req.RedirectURI, map[string]string{
+			"error":             "access_denied",
+			"error_description": "The user denied access",
+			"state":             req.State,
+		})})
+		return
+	}
+
+	code, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize"})
+		return
+	}
+	pending := types.OAuthCode{
+		ClientID:      client.ID,
+		UserID:        userUUID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+	}
+	if err := s.db.CreateOAuthCode(auth.HashOpaqueToken(code), pending, time.Now().Add(auth.OAuthCodeTTL)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": oauthRedirect(req.RedirectURI, map[string]string{
+		"code":  code,
+		"state": req.State,
+	})})
+}
+
+// GetOAuthConsentsHandler lists the apps the user gave access.
+func (s *Server) GetOAuthConsentsHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	consents, err := s.db.GetOAuthConsents(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load apps"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"apps": consents})
+}
+
+// RevokeOAuthConsentHandler takes back the access of an app, its tokens stop working
+// right away.
+func (s *Server) RevokeOAuthConsentHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if err := s.db.RevokeOAuthConsent(userUUID, c.Param("client_id")); err != nil {
+		if errors.Is(err, custom_error.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Access revoked"})
+}
+
+// OAuthTokenHandler is the token endpoint of partner apps. It exchanges authorization
+// codes and refresh tokens, the old refresh token stops working.
+func (s *Server) OAuthTokenHandler(c *gin.Context) {
+	// Tokens must not end up in caches (RFC 6749, section 5.1)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := s.authenticateOAuthClient(c)
+	if !ok {
+		return
+	}
+
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		code, err := s.db.ConsumeOAuthCode(auth.HashOpaqueToken(c.PostForm("code")), client.ID)
+		if err != nil {
+			if errors.Is(err, custom_error.ErrInvalidGrant) {
+				oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid or expired code")
+			} else {
+				oauthError(c, http.StatusInternalServerError, "server_error", "Failed to exchange code")
+			}
+			return
+		}
+		if c.PostForm("redirect_uri") != code.RedirectURI {
+			oauthError(c, http.StatusBadRequest, "invalid_grant", "Redirect URI does not match the authorization request")
+			return
+		}
+		if !auth.VerifyPKCE(c.PostForm("code_verifier"), code.CodeChallenge) {
+			oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid code verifier")
+			return
+		}
+
+		s.issueOAuthTokens(c, func(accessHash, refreshHash string, accessExpiresAt, refreshExpiresAt time.Time) (types.OAuthToken, error) {
+			token := types.OAuthToken{
+				ID:               uuid.New(),
+				ClientID:         client.ID,
+				UserID:           code.UserID,
+				Scopes:           code.Scopes,
+				AccessExpiresAt:  accessExpiresAt,
+				RefreshExpiresAt: refreshExpiresAt,
+			}
+			return token, s.db.CreateOAuthToken(token, accessHash, refreshHash)
+		})
+
+	case "refresh_token":
+		refreshHash := auth.HashOpaqueToken(c.PostForm("refresh_token"))
+		s.issueOAuthTokens(c, func(accessHash, newRefreshHash string, accessExpiresAt, refreshExpiresAt time.Time) (types.OAuthToken, error) {
+			return s.db.RefreshOAuthToken(refreshHash, client.ID, accessHash, newRefreshHash, accessExpiresAt, refreshExpiresAt)
+		})
+
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code and refresh_token are supported")
+	}
+}
+
+// OAuthRevokeHandler lets a partner app end its grant with its access or refresh token
+// (RFC 7009). Unknown tokens are not an error.
+func (s *Server) OAuthRevokeHandler(c *gin.Context) {
+	client, ok := s.authenticateOAuthClient(c)
+	if !ok {
+		return
+	}
+	if err := s.db.RevokeOAuthToken(auth.HashOpaqueToken(c.PostForm("token")), client.ID); err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to revoke token")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// issueOAuthTokens generates an access and refresh token, stores their hashes with
+// store and responds with them.
+func (s *Server) issueOAuthTokens(c *gin.Context, store func(accessHash, refreshHash string, accessExpiresAt, refreshExpiresAt time.Time) (types.OAuthToken, error)) {
+	accessToken, err := auth.GenerateOAuthAccessToken()
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate token")
+		return
+	}
+	refreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate token")
+		return
+	}
+
+	now := time.Now()
+	token, err := store(auth.HashOpaqueToken(accessToken), auth.HashOpaqueToken(refreshToken),
+		now.Add(auth.OAuthAccessTokenTTL), now.Add(auth.OAuthRefreshTokenTTL))
+	if err != nil {
+		if errors.Is(err, custom_error.ErrInvalidGrant) {
+			oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid or expired refresh token")
+		} else {
+			oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue token")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(auth.OAuthAccessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         strings.Join(token.Scopes, " "),
+	})
+}
+
+// authenticateOAuthClient identifies the client calling the token endpoints, with HTTP
+// basic auth or client_id and client_secret in the form. Public clients only send their
+// ID. On failure it responds and returns false.
+func (s *Server) authenticateOAuthClient(c *gin.Context) (types.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if !basic {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := s.db.GetOAuthClient(clientID)
+	if err != nil && !errors.Is(err, custom_error.ErrClientNotFound) {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to authenticate client")
+		return client, false
+	}
+	valid := err == nil
+	if client.Confidential {
+		valid = valid && subtle.ConstantTimeCompare([]byte(auth.HashOpaqueToken(secret)), []byte(client.SecretHash)) == 1
+	} else {
+		valid = valid && secret == ""
+	}
+	if !valid {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return client, false
+	}
+	return client, true
+}
+
+// checkAuthorizeRequest validates an authorization request and returns the client and
+// the scopes it asks for. Without a valid client and redirect URI the user cannot be
+// sent back, otherwise the response also says where to send the user with the error.
+// On failure it responds and returns false.
+func (s *Server) checkAuthorizeRequest(c *gin.Context, req *types.OAuthAuthorizeDTO) (types.OAuthClient, []string, bool) {
+	client, err := s.db.GetOAuthClient(req.ClientID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrClientNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown client"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load client"})
+		}
+		return client, nil, false
+	}
+	// The redirect URI may only be left out when there is no choice
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Redirect URI is not registered for this client"})
+		return client, nil, false
+	}
+
+	fail := func(code, description string) (types.OAuthClient, []string, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": description,
+			"redirect_to": oauthRedirect(req.RedirectURI, map[string]string{
+				"error":             code,
+				"error_description": description,
+				"state":             req.State,
+			}),
+		})
+		return client, nil, false
+	}
+	if req.ResponseType != "code" {
+		return fail("unsupported_response_type", "Only the code response type is supported")
+	}
+	// A S256 challenge is always 43 characters of base64url
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		return fail("invalid_request", "PKCE with the S256 method is required")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = slices.Clone(client.Scopes)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return fail("invalid_scope", fmt.Sprintf("The client may not ask for the %s scope", scope))
+		}
+	}
+	slices.Sort(scopes)
+	return client, slices.Compact(scopes), true
+}
+
+// authenticateOAuthToken is authenticate for access tokens of OAuth clients. On failure
+// it aborts the request and returns false.
+func (s *Server) authenticateOAuthToken(c *gin.Context, secret string) bool {
+	token, err := s.db.AuthenticateOAuthToken(auth.HashOpaqueToken(secret))
+	if err != nil {
+		if errors.Is(err, custom_error.ErrTokenNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+		}
+		c.Abort()
+		return false
+	}
+
+	if !s.grantScoped(c, token.UserID, token.Scopes, "App tokens cannot be used here") {
+		return false
+	}
+	c.Set("oauthClientID", token.ClientID)
+	return true
+}
+
+// validRedirectURI allows https URIs, http on loopback addresses for desktop apps and
+// private schemes like com.example.app:/callback for mobile apps (RFC 8252).
+func validRedirectURI(raw string) bool {
+	uri, err := url.Parse(raw)
+	if err != nil || strings.ContainsAny(raw, " \t\n") || uri.Fragment != "" || uri.Scheme == "" {
+		return false
+	}
+	switch uri.Scheme {
+	case "https":
+		return uri.Host != ""
+	case "http":
+		host := uri.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return strings.Contains(uri.Scheme, ".")
+	}
+}
+
+// oauthRedirect adds params to a registered redirect URI, leaving out empty ones.
+func oauthRedirect(redirectURI string, params map[string]string) string {
+	uri, err := url.Parse(redirectURI)
+	if err != nil {
+		// Registered URIs were checked when the client was created
+		return redirectURI
+	}
+	query := uri.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	uri.RawQuery = query.Encode()
+	return uri.String()
+}
+
+// oauthError responds with an error of RFC 6749, section 5.2.
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+func isSubset(scopes, granted []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
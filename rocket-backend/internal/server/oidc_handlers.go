@@ -0,0 +1,215 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/internal/validation"
+	"rocket-backend/pkg/auth"
+	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// How long the user has to log in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCProvidersHandler lists the identity providers users can log in with.
+func (s *Server) OIDCProvidersHandler(c *gin.Context) {
+	names := make([]string, 0, len(s.oidcProviders))
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// OIDCLoginHandler redirects to the provider's login page.
+func (s *Server) OIDCLoginHandler(c *gin.Context) {
+	provider, ok := s.oidcProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+		return
+	}
+
+	state, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	codeVerifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	pending := types.OIDCState{
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		DeviceName:   c.Query("device_name"),
+	}
+	if err := s.db.SaveOIDCState(auth.HashOpaqueToken(state), pending, time.Now().Add(oidcStateTTL)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Provider %s is unavailable", provider.Name()), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Provider unavailable"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallbackHandler completes a login at the provider. Known identities log in to
+// their account, new ones are linked to the account with the same verified email or
+// get a new account.
+func (s *Server) OIDCCallbackHandler(c *gin.Context) {
+	provider, ok := s.oidcProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+		return
+	}
+
+	// Apple posts the result as a form, the others redirect with a query
+	param := func(key string) string {
+		if value := c.Query(key); value != "" {
+			return value
+		}
+		return c.PostForm(key)
+	}
+	if param("error") != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was cancelled or denied"})
+		return
+	}
+	if param("code") == "" || param("state") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code and state required"})
+		return
+	}
+
+	pending, err := s.db.ConsumeOIDCState(auth.HashOpaqueToken(param("state")), provider.Name())
+	if err != nil {
+		if errors.Is(err, custom_error.ErrInvalidOIDCState) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login, please try again"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), param("code"), pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Login with %s failed", provider.Name()), err)
+		if errors.Is(err, oidc.ErrInvalidIdentity) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login with provider failed"})
+		} else {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Provider unavailable"})
+		}
+		return
+	}
+
+	creds, ok := s.accountForIdentity(c, provider.Name(), identity)
+	if !ok {
+		return
+	}
+	s.finishLogin(c, creds, pending.DeviceName)
+}
+
+// accountForIdentity finds or creates the account of a provider's user. On failure
+// it responds and returns false.
+func (s *Server) accountForIdentity(c *gin.Context, provider string, identity oidc.Identity) (types.Credentials, bool) {
+	creds, err := s.db.GetUserByOIDCIdentity(provider, identity.Subject)
+	if err == nil {
+		return creds, true
+	}
+	if !errors.Is(err, custom_error.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return creds, false
+	}
+
+	// Without a verified address the identity could belong to anyone's account
+	if identity.Email == "" || !identity.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The provider did not share a verified email address"})
+		return creds, false
+	}
+
+	err = s.db.CheckEmail(identity.Email)
+	switch {
+	case errors.Is(err, custom_error.ErrEmailAlreadyExists):
+		creds, err = s.db.GetUserByEmail(identity.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return creds, false
+		}
+		if !creds.EmailVerified {
+			// Whoever registered the address without verifying it loses access
+			if _, err := s.db.RevokeAllTokens(creds.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return creds, false
+			}
+		}
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
+		return creds, false
+	default:
+		creds = types.Credentials{
+			ID:        uuid.New(),
+			Email:     strings.ToLower(identity.Email),
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+		creds.LastLogin = creds.CreatedAt
+		if !s.createAccount(c, creds, s.availableUsername(identity)) {
+			return creds, false
+		}
+	}
+
+	if err := s.db.LinkOIDCIdentity(creds.ID, provider, identity.Subject, identity.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account"})
+		return creds, false
+	}
+	creds, err = s.db.GetCredentialsByID(creds.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return creds, false
+	}
+	return creds, true
+}
+
+// availableUsername derives a username from the provider's profile, adding digits
+// when it is taken.
+func (s *Server) availableUsername(identity oidc.Identity) string {
+	base := validation.SanitizeUsername(identity.Name)
+	if base == "" {
+		local, _, _ := strings.Cut(identity.Email, "@")
+		base = validation.SanitizeUsername(local)
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for range 5 {
+		if err := s.db.CheckUsername(candidate, uuid.Nil); err == nil {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%04d", base, rand.IntN(10000))
+	}
+	return base[:min(len(base), validation.UsernameMaxLength-8)] + strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/internal/validation"
+	"rocket-backend/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// Tokens a user can have at once
+	maxPersonalTokens = 20
+	// Lifetime of tokens created without expires_in_days
+	defaultPersonalTokenDays = 90
+)
+
+// GetPersonalTokensHandler lists the personal access tokens of the user, without
+// their secrets.
+func (s *Server) GetPersonalTokensHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	tokens, err := s.db.GetPersonalAccessTokens(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens, "scopes": types.TokenScopes})
+}
+
+// CreatePersonalTokenHandler creates a personal access token. The token is only part
+// of this response, afterwards just its prefix is known.
+func (s *Server) CreatePersonalTokenHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var req types.CreatePersonalTokenDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(types.TokenScopes, scope) {
+			respondInvalid(c, validation.FieldErrors{"scopes": fmt.Sprintf("unknown scope %q", scope)})
+			return
+		}
+	}
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultPersonalTokenDays
+	}
+
+	secret, err := auth.GeneratePersonalToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	slices.Sort(req.Scopes)
+	now := time.Now()
+	token := types.PersonalAccessToken{
+		ID:        uuid.New(),
+		UserID:    userUUID,
+		Name:      req.Name,
+		Prefix:    secret[:len(auth.PersonalTokenPrefix)+6],
+		Scopes:    slices.Compact(req.Scopes),
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, days),
+	}
+
+	if err := s.db.CreatePersonalAccessToken(token, auth.HashOpaqueToken(secret), maxPersonalTokens); err != nil {
+		if errors.Is(err, custom_error.ErrLimitReached) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("At most %d tokens are allowed, delete one first", maxPersonalTokens)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": secret, "details": token})
+}
+
+// DeletePersonalTokenHandler revokes a personal access token right away.
+func (s *Server) DeletePersonalTokenHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	tokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := s.db.DeletePersonalAccessToken(userUUID, tokenID); err != nil {
+		if errors.Is(err, custom_error.ErrTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token deleted"})
+}
+
+// scoped registers a route that personal access tokens and OAuth clients with the scope
+// may call. All other routes only accept logins.
+func (s *Server) scoped(group *gin.RouterGroup, method, relativePath, scope string, handler gin.HandlerFunc) {
+	s.routeScopes[method+" "+path.Join(group.BasePath(), relativePath)] = scope
+	group.Handle(method, relativePath, handler)
+}
+
+// authenticatePersonalToken is authenticate for personal access tokens. On failure it
+// aborts the request and returns false.
+func (s *Server) authenticatePersonalToken(c *gin.Context, secret string) bool {
+	token, err := s.db.AuthenticatePersonalAccessToken(auth.HashOpaqueToken(secret))
+	if err != nil {
+		if errors.Is(err, custom_error.ErrTokenNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+		}
+		c.Abort()
+		return false
+	}
+
+	if !s.grantScoped(c, token.UserID, token.Scopes, "Personal access tokens cannot be used here") {
+		return false
+	}
+	c.Set("personalTokenID", token.ID.String())
+	return true
+}
+
+// grantScoped lets a token with scopes act as its user on routes added with scoped.
+// Tokens act as a plain user, moderation and admin routes stay with logins. On failure
+// it aborts the request with unscoped as error and returns false.
+func (s *Server) grantScoped(c *gin.Context, userID uuid.UUID, scopes []string, unscoped string) bool {
+	scope, ok := s.routeScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": unscoped})
+		c.Abort()
+		return false
+	}
+	if !slices.Contains(scopes, scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token lacks the %s scope", scope)})
+		c.Abort()
+		return false
+	}
+
+	c.Set("userID", userID.String())
+	c.Set("role", types.RoleUser)
+	return true
+}
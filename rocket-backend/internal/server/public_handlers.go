@@ -3,9 +3,10 @@ package server
 import (
 	"errors"
 	"net/http"
-	"rocket-backend/pkg/auth"
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
+	"rocket-backend/internal/validation"
+	"rocket-backend/pkg/auth"
 	"rocket-backend/pkg/logger"
 	"time"
 
@@ -24,9 +25,13 @@ func (s *Server) HealthHandler(c *gin.Context) {
 }
 
 func (s *Server) LoginHandler(c *gin.Context) {
-	var creds types.Credentials
+	var creds types.LoginDTO
 	if err := c.ShouldBindJSON(&creds); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		respondInvalid(c, err)
+		return
+	}
+
+	if !s.checkLoginLockout(c, creds.Email) {
 		return
 	}
 
@@ -41,25 +46,96 @@ func (s *Server) LoginHandler(c *gin.Context) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(storedCreds.Password), []byte(creds.Password)); err != nil {
+		s.recordLoginFailure(creds.Email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
-	authService := auth.NewAuthService(s.jwtSecret)
-	tokenString, err := authService.GenerateToken(storedCreds.ID)
+	s.finishLogin(c, storedCreds, creds.DeviceName)
+}
+
+// finishLogin continues after the first factor: users with two-factor authentication
+// get a challenge token for the second step, everyone else is logged in.
+func (s *Server) finishLogin(c *gin.Context, creds types.Credentials, deviceName string) {
+	twoFactor, err := s.db.GetTwoFactor(creds.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		authService := s.authService
+		challengeToken, err := authService.GenerateChallengeToken(creds.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challengeToken,
+			"expires_in":          int(auth.ChallengeTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	s.completeLogin(c, creds, deviceName)
+}
+
+// completeLogin starts the session of an authenticated user and responds with its tokens.
+func (s *Server) completeLogin(c *gin.Context, creds types.Credentials, deviceName string) {
+	// Logging in during the grace period of a deleted account restores it
+	restored, err := s.db.RestoreUser(creds.ID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrAccountDeleted) {
+			c.JSON(http.StatusGone, gin.H{"error": "Account has been deleted"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore account"})
+		}
+		return
+	}
+
+	body, err := s.issueTokens(c, creds, deviceName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	if err := s.db.UpdateLastLogin(creds.ID); err != nil {
+		logger.Error("Failed to update last login", err)
+	}
+	// Only now, a correct password alone must not reset the count for second factor guesses
+	if err := s.db.ResetLoginFailures(creds.Email); err != nil {
+		logger.Error("Failed to reset login failures", err)
+	}
+	body["email_verified"] = creds.EmailVerified
+	if restored {
+		body["account_restored"] = true
+	}
 
-	c.SetCookie("jwt_token", tokenString, 3600*72, "/", "", true, true)
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	c.JSON(http.StatusOK, body)
 }
 
 func (s *Server) RegisterHandler(c *gin.Context) {
 	var registerDto types.RegisterDTO
 	if err := c.ShouldBindJSON(&registerDto); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		respondInvalid(c, err)
+		return
+	}
+
+	fields := validation.FieldErrors{}
+	email, err := validation.NormalizeEmail(registerDto.Email)
+	if err != nil {
+		fields["email"] = err.Error()
+	}
+	username, err := validation.NormalizeUsername(registerDto.Username)
+	if err != nil {
+		fields["username"] = err.Error()
+	} else if !s.checkUsernameAvailable(c, fields, "username", username, uuid.Nil) {
+		return
+	}
+	if err := s.passwordPolicy.Check(registerDto.Password, registerDto.Username, registerDto.Email); err != nil {
+		fields["password"] = err.Error()
+	}
+	if err := fields.Err(); err != nil {
+		respondInvalid(c, err)
 		return
 	}
 
@@ -69,7 +145,7 @@ func (s *Server) RegisterHandler(c *gin.Context) {
 		return
 	}
 
-	if err := s.db.CheckEmail(registerDto.Email); err != nil {
+	if err := s.db.CheckEmail(email); err != nil {
 		if errors.Is(err, custom_error.ErrEmailAlreadyExists) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
 		} else {
@@ -80,21 +156,36 @@ func (s *Server) RegisterHandler(c *gin.Context) {
 
 	var creds types.Credentials
 	creds.ID = uuid.New()
-	creds.Email = registerDto.Email
+	creds.Email = email
 	creds.Password = string(hashedPassword)
 	creds.CreatedAt = time.Now().Format(time.RFC3339)
 	creds.LastLogin = creds.CreatedAt
 
+	if !s.createAccount(c, creds, username) {
+		return
+	}
+
+	// The account works without verification, a lost email must not fail the registration
+	if err := s.sendEmailToken(creds, types.EmailTokenVerify); err != nil {
+		logger.Error("Failed to send verification email", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+}
+
+// createAccount saves the credentials and sets up the profile and settings of a new
+// user. On failure it responds and returns false.
+func (s *Server) createAccount(c *gin.Context, creds types.Credentials, username string) bool {
 	if err := s.db.SaveCredentials(creds); err != nil {
 		logger.Error("Failed to save credentials", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save credentials"})
-		return
+		return false
 	}
 
 	var user types.User
 	user.ID = creds.ID
-	user.Username = registerDto.Username
-	user.Email = registerDto.Email
+	user.Username = username
+	user.Email = creds.Email
 	user.RocketPoints = 0
 
 	if err := s.db.SaveUserProfile(user); err != nil {
@@ -103,7 +194,7 @@ func (s *Server) RegisterHandler(c *gin.Context) {
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		}
-		return
+		return false
 	}
 
 	var settings types.Settings
@@ -118,13 +209,7 @@ func (s *Server) RegisterHandler(c *gin.Context) {
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		}
-		return
+		return false
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
-}
-
-func (s *Server) LogoutHandler(c *gin.Context) {
-	c.SetCookie("jwt_token", "", -1, "/", "", true, true)
-	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	return true
 }
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"rocket-backend/internal/database"
+	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Failed logins in a row before an account is locked, every further failure doubles the lock
+var loginLockout = ratelimit.Lockout{Threshold: 5, Base: time.Minute, Max: time.Hour}
+
+// rateLimitKey picks the client a request is counted for, empty skips the limit.
+type rateLimitKey func(c *gin.Context) string
+
+// byIP counts requests per client address.
+func byIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// byUser counts requests per authenticated user, after AuthMiddleware.
+func byUser(c *gin.Context) string {
+	return c.GetString("userID")
+}
+
+// byAccount counts requests per email in the JSON body, wherever they come from.
+func byAccount(c *gin.Context) string {
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	// The handler binds the body again
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(body.Email))
+}
+
+// newRateLimiter sets up the limiter from RATE_LIMIT_STORE: "memory" counts per replica,
+// "postgres" shares the buckets between replicas. RATE_LIMIT_ENABLED=false turns it off.
+func newRateLimiter(db database.Service) *ratelimit.Limiter {
+	if os.Getenv("RATE_LIMIT_ENABLED") == "false" {
+		return nil
+	}
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		return ratelimit.New(ratelimit.NewMemoryStore())
+	case "postgres":
+		return ratelimit.New(ratelimit.StoreFunc(db.TakeRateLimitToken))
+	default:
+		logger.Fatal("Unknown RATE_LIMIT_STORE " + store)
+		return nil
+	}
+}
+
+// trustedProxies returns the proxies from TRUSTED_PROXIES whose X-Forwarded-For header
+// names the client. Without any the client is the address the request comes from.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// SetRateLimiter replaces the rate limiter, nil turns rate limiting off.
+func (s *Server) SetRateLimiter(limiter *ratelimit.Limiter) {
+	s.limiter.Store(limiter)
+}
+
+// RateLimit lets requests through while the client has tokens left under the policy
+// and answers 429 with a Retry-After header otherwise.
+func (s *Server) RateLimit(policy ratelimit.Policy, key rateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limiter := s.limiter.Load()
+		if limiter == nil {
+			c.Next()
+			return
+		}
+		client := key(c)
+		if client == "" {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(policy, client)
+		if err != nil {
+			// A broken store must not lock everyone out
+			logger.Error("Failed to check rate limit", err)
+			c.Next()
+			return
+		}
+		if !result.Allowed {
+			tooManyRequests(c, "Too many requests, try again later", result.RetryAfter)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// tooManyRequests responds with 429 and tells the client when to retry.
+func tooManyRequests(c *gin.Context, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": max(seconds, 1)})
+}
+
+// checkLoginLockout refuses logins to an email that failed too often. On failure it
+// responds and returns false.
+func (s *Server) checkLoginLockout(c *gin.Context, email string) bool {
+	locked, err := s.db.GetLoginLockout(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return false
+	}
+	if locked > 0 {
+		tooManyRequests(c, "Too many failed login attempts, try again later", locked)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login for the email, the lockout it may cause
+// applies from the next attempt.
+func (s *Server) recordLoginFailure(email string) {
+	if locked, err := s.db.RecordLoginFailure(email, loginLockout); err != nil {
+		logger.Error("Failed to record login failure", err)
+	} else if locked > 0 {
+		logger.Info("Locked logins after repeated failures", "email", email, "duration", locked)
+	}
+}
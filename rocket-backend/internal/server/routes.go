@@ -2,13 +2,24 @@ package server
 
 import (
 	"net/http"
+	"time"
+
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/ratelimit"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: accessLogFormatter, Output: s.accessLog}), gin.Recovery())
+	s.routeScopes = map[string]string{}
+	// Otherwise anyone could pick their address for the rate limits with X-Forwarded-For
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		logger.Fatal("Invalid TRUSTED_PROXIES", err)
+	}
 	// r.Use(s.APIKeyMiddleware())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
@@ -17,41 +28,122 @@ func (s *Server) RegisterRoutes() http.Handler {
 		AllowCredentials: true, // Enable cookies/auth
 	}))
 
+	// Token buckets per route group: Burst requests at once, then Limit per Period
+	var (
+		authPolicy          = ratelimit.Policy{Name: "auth", Limit: 30, Period: time.Minute, Burst: 10}
+		registerPolicy      = ratelimit.Policy{Name: "register", Limit: 10, Period: time.Hour, Burst: 5}
+		loginPolicy         = ratelimit.Policy{Name: "login", Limit: 10, Period: 15 * time.Minute, Burst: 5}
+		passwordResetPolicy = ratelimit.Policy{Name: "password-reset", Limit: 3, Period: time.Hour, Burst: 3}
+		userPolicy          = ratelimit.Policy{Name: "user", Limit: 600, Period: time.Minute, Burst: 100}
+		exportPolicy        = ratelimit.Policy{Name: "export", Limit: 3, Period: 24 * time.Hour, Burst: 3}
+	)
+
+	r.GET("/.well-known/jwks.json", s.JWKSHandler)
+
 	api := r.Group("/api/v1")
 	{
 		chatHub := NewChatHub()
 		go chatHub.Run()
 
 		api.GET("/health", s.HealthHandler)
-		api.POST("/register", s.RegisterHandler)
-		api.POST("/login", s.LoginHandler)
 		api.POST("/logout", s.LogoutHandler)
+		api.GET("/oidc/providers", s.OIDCProvidersHandler)
 
+		// Everything that checks credentials or tokens is limited per address, logins
+		// and reset emails also per account so rotating addresses does not help
+		authRoutes := api.Group("")
+		authRoutes.Use(s.RateLimit(authPolicy, byIP))
+		{
+			authRoutes.POST("/register", s.RateLimit(registerPolicy, byIP), s.RegisterHandler)
+			authRoutes.POST("/login", s.RateLimit(loginPolicy, byAccount), s.LoginHandler)
+			authRoutes.POST("/login/2fa", s.TwoFactorLoginHandler)
+			authRoutes.POST("/refresh", s.RefreshHandler)
+			authRoutes.GET("/oidc/:provider/login", s.OIDCLoginHandler)
+			authRoutes.GET("/oidc/:provider/callback", s.OIDCCallbackHandler)
+			authRoutes.POST("/oidc/:provider/callback", s.OIDCCallbackHandler)
+			authRoutes.POST("/verify-email", s.VerifyEmailHandler)
+			authRoutes.POST("/password-reset/request", s.RateLimit(passwordResetPolicy, byAccount), s.RequestPasswordResetHandler)
+			authRoutes.POST("/password-reset/confirm", s.ConfirmPasswordResetHandler)
+			authRoutes.POST("/oauth/token", s.OAuthTokenHandler)
+			authRoutes.POST("/oauth/revoke", s.OAuthRevokeHandler)
+			authRoutes.GET("/exports/:token", s.DownloadDataExportHandler)
+		}
+
+		// Routes added with s.scoped also take personal access tokens and OAuth access
+		// tokens that carry the scope
 		protected := api.Group("/protected")
-		protected.Use(s.AuthMiddleware())
+		protected.Use(s.AuthMiddleware(), s.RateLimit(userPolicy, byUser))
 		{
 			protected.GET("/", s.AuthenticatedHandler)
-			protected.POST("/updateSteps", s.UpdateStepsHandler)
+			protected.POST("/logout-all", s.LogoutAllHandler)
+			protected.GET("/sessions", s.GetSessionsHandler)
+			protected.DELETE("/sessions/:id", s.RevokeSessionHandler)
+			protected.POST("/verify-email/resend", s.ResendVerificationHandler)
+			protected.GET("/2fa", s.GetTwoFactorHandler)
+			protected.POST("/2fa/enroll", s.EnrollTwoFactorHandler)
+			protected.POST("/2fa/confirm", s.ConfirmTwoFactorHandler)
+			protected.POST("/2fa/recovery-codes", s.RegenerateRecoveryCodesHandler)
+			protected.POST("/2fa/disable", s.DisableTwoFactorHandler)
+			protected.GET("/tokens", s.GetPersonalTokensHandler)
+			protected.POST("/tokens", s.CreatePersonalTokenHandler)
+			protected.DELETE("/tokens/:id", s.DeletePersonalTokenHandler)
+			protected.GET("/oauth/authorize", s.GetOAuthAuthorizationHandler)
+			protected.POST("/oauth/authorize", s.OAuthAuthorizeHandler)
+			protected.GET("/oauth/apps", s.GetOAuthConsentsHandler)
+			protected.DELETE("/oauth/apps/:client_id", s.RevokeOAuthConsentHandler)
+			s.scoped(protected, "POST", "/updateSteps", types.ScopeStepsWrite, s.UpdateStepsHandler)
 
 			//protected.POST("/settings/update", s.UpdateSettings)
-			protected.GET("/settings", s.GetSettingsHandler)
+			s.scoped(protected, "GET", "/settings", types.ScopeProfileRead, s.GetSettingsHandler)
 			protected.POST("/settings/step-goal", s.UpdateStepGoalHandler)
 			protected.POST("/settings/image", s.UpdateImageHandler)
 			protected.DELETE("/settings/image", s.DeleteImageHandler)
 			protected.POST("/settings/userinfo", s.UpdateUserInfoHandler)
 
-			protected.GET("/user", s.GetUserHandler)
+			s.scoped(protected, "GET", "/user", types.ScopeProfileRead, s.GetUserHandler)
 			protected.DELETE("/user", s.DeleteUserHandler)
+			protected.POST("/user/export", s.RateLimit(exportPolicy, byUser), s.RequestDataExportHandler)
+			protected.GET("/user/export", s.GetDataExportHandler)
 			protected.GET("/user/:name", s.GetUserByNameHandler)
-			protected.POST("/user/statistics", s.GetUserStatisticsHandler)
+			s.scoped(protected, "POST", "/user/statistics", types.ScopeStepsRead, s.GetUserStatisticsHandler)
 			protected.POST("/user/image", s.GetUserImageHandler)
-			protected.GET("/user/rocketpoints", s.GetRocketPointsHandler)
+			s.scoped(protected, "GET", "/user/rocketpoints", types.ScopeProfileRead, s.GetRocketPointsHandler)
 			protected.GET("/users", s.GetAllUsersHandler)
 
-			protected.GET("/challenges/new", s.GetDailyChallengesHandler)
+			s.scoped(protected, "GET", "/challenges/new", types.ScopeChallengesRead, s.GetDailyChallengesHandler)
 			protected.POST("/challenges/complete", s.CompleteChallengeHandler)
-			protected.GET("/challenges/progress", s.GetDailyChallengeProgress)
+			s.scoped(protected, "GET", "/challenges/progress", types.ScopeChallengesRead, s.GetDailyChallengeProgress)
 			protected.POST("/challenges/invite", s.InviteFriendChallenge)
+			protected.GET("/challenges/invitations/incoming", s.GetIncomingInvitationsHandler)
+			protected.GET("/challenges/invitations/outgoing", s.GetOutgoingInvitationsHandler)
+			protected.POST("/challenges/invitations/:id/accept", s.AcceptInvitationHandler)
+			protected.POST("/challenges/invitations/:id/decline", s.DeclineInvitationHandler)
+			protected.GET("/challenges/periodic", s.GetPeriodicChallengesHandler)
+			protected.GET("/challenges/featured", s.GetFeaturedChallengesHandler)
+
+			protected.POST("/duels", s.CreateDuelHandler)
+			protected.GET("/duels", s.GetDuelsHandler)
+			protected.GET("/duels/history", s.GetDuelHistoryHandler)
+			protected.GET("/duels/:id", s.GetDuelHandler)
+			protected.POST("/duels/:id/accept", s.AcceptDuelHandler)
+			protected.POST("/duels/:id/decline", s.DeclineDuelHandler)
+
+			protected.POST("/teams", s.CreateTeamHandler)
+			protected.GET("/teams", s.GetTeamsHandler)
+			protected.GET("/teams/:id", s.GetTeamHandler)
+			protected.DELETE("/teams/:id", s.DeleteTeamHandler)
+			protected.POST("/teams/:id/members", s.AddTeamMemberHandler)
+			protected.DELETE("/teams/:id/members/:name", s.RemoveTeamMemberHandler)
+			protected.POST("/teams/:id/challenges", s.CreateTeamChallengeHandler)
+			protected.GET("/teams/:id/challenges", s.GetTeamChallengesHandler)
+
+			s.scoped(protected, "GET", "/streaks", types.ScopeProfileRead, s.GetStreakHandler)
+			protected.POST("/streaks/freeze", s.BuyStreakFreezeHandler)
+
+			protected.GET("/shop/items", s.GetShopItemsHandler)
+			protected.POST("/shop/items/:id/redeem", s.RedeemShopItemHandler)
+			protected.GET("/shop/inventory", s.GetInventoryHandler)
+			protected.GET("/shop/purchases", s.GetPurchaseHistoryHandler)
 
 			protected.GET("/ranking/users", s.GetUserRankingHandler)
 			protected.GET("/ranking/friends", s.GetFriendsRankedHandler)
@@ -62,18 +154,61 @@ func (s *Server) RegisterRoutes() http.Handler {
 			protected.GET("/followers/:id", s.GetFollowersHandler)
 			protected.GET("/following/:id", s.GetFollowingHandler)
 
-			protected.POST("/runs", s.UploadRunHandler)
-			protected.GET("/runs", s.GetAllRunsHandler)
-			protected.DELETE("/runs/:id", s.DeleteRunHandler)
-			protected.POST("/runs/plan", s.PlanRunHandler)
-			protected.GET("/runs/plan", s.GetPlannedRunHandler)
-			protected.DELETE("/runs/plan/:id", s.DeletePlannedRunHandler)
+			protected.GET("/blocks", s.GetBlockedUsersHandler)
+			protected.POST("/blocks", s.BlockUserHandler)
+			protected.DELETE("/blocks/:name", s.UnblockUserHandler)
+			protected.GET("/mutes", s.GetMutedUsersHandler)
+			protected.POST("/mutes", s.MuteUserHandler)
+			protected.DELETE("/mutes/:name", s.UnmuteUserHandler)
+
+			s.scoped(protected, "POST", "/runs", types.ScopeRunsWrite, s.UploadRunHandler)
+			s.scoped(protected, "GET", "/runs", types.ScopeRunsRead, s.GetAllRunsHandler)
+			s.scoped(protected, "DELETE", "/runs/:id", types.ScopeRunsWrite, s.DeleteRunHandler)
+			s.scoped(protected, "POST", "/runs/plan", types.ScopeRunsWrite, s.PlanRunHandler)
+			s.scoped(protected, "GET", "/runs/plan", types.ScopeRunsRead, s.GetPlannedRunHandler)
+			s.scoped(protected, "DELETE", "/runs/plan/:id", types.ScopeRunsWrite, s.DeletePlannedRunHandler)
 
 			protected.GET("/activites", s.GetActivityHandler)
 
 			protected.GET("/ws/chat", s.ChatWebSocketHandler(chatHub))
 			protected.GET("/chat/history", s.GetChatHistoryHandler)
 		}
+
+		moderation := api.Group("/moderation")
+		moderation.Use(s.AuthMiddleware(), s.RequireRole(types.RoleModerator))
+		{
+			moderation.DELETE("/chat/:id", s.ModerationDeleteChatMessageHandler)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(s.AdminMiddleware())
+		{
+			admin.GET("/users", s.AdminGetUsersHandler)
+			admin.PUT("/users/:id/role", s.AdminUpdateUserRoleHandler)
+			admin.DELETE("/users/:id", s.AdminDeleteUserHandler)
+
+			admin.GET("/shop/items", s.AdminGetShopItemsHandler)
+			admin.POST("/shop/items", s.AdminCreateShopItemHandler)
+			admin.PUT("/shop/items/:id", s.AdminUpdateShopItemHandler)
+			admin.DELETE("/shop/items/:id", s.AdminDeleteShopItemHandler)
+
+			admin.GET("/challenges", s.AdminGetChallengesHandler)
+			admin.POST("/challenges", s.AdminCreateChallengeHandler)
+			admin.PUT("/challenges/:id", s.AdminUpdateChallengeHandler)
+			admin.DELETE("/challenges/:id", s.AdminRetireChallengeHandler)
+			admin.GET("/challenges/audit", s.AdminGetChallengeAuditLogHandler)
+			admin.GET("/featured", s.AdminGetFeaturedChallengesHandler)
+			admin.PUT("/featured/:date/:slot", s.AdminSetFeaturedChallengeHandler)
+			admin.DELETE("/featured/:date/:slot", s.AdminRemoveFeaturedChallengeHandler)
+
+			admin.GET("/jobs", s.AdminGetJobsHealthHandler)
+			admin.GET("/jobs/:name/runs", s.AdminGetJobRunsHandler)
+			admin.POST("/jobs/:name/run", s.AdminRunJobHandler)
+
+			admin.GET("/oauth/clients", s.AdminGetOAuthClientsHandler)
+			admin.POST("/oauth/clients", s.AdminCreateOAuthClientHandler)
+			admin.DELETE("/oauth/clients/:id", s.AdminDeleteOAuthClientHandler)
+		}
 	}
 
 	return r
@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"strings"
 
+	"rocket-backend/internal/challenges"
 	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -42,6 +44,10 @@ func (s *Server) UploadRunHandler(c *gin.Context) {
 	message := "Completed a " + fmt.Sprintf("%.2f", runData.Distance) + " km run in " + runData.Duration + " minutes"
 	err = s.db.SaveActivity(userUUID, message)
 
+	if _, err := challenges.NewChallengeManager(s.db).UpdatePeriodicProgress(userUUID); err != nil {
+		logger.Error("Failed to update periodic challenge progress", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Run data uploaded successfully"})
 }
 
@@ -2,31 +2,88 @@ package server
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 
 	"rocket-backend/internal/database"
+	"rocket-backend/internal/jobs"
+	"rocket-backend/internal/validation"
+	"rocket-backend/pkg/auth"
+	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/mailer"
+	"rocket-backend/pkg/oidc"
+	"rocket-backend/pkg/ratelimit"
 )
 
 type Server struct {
 	port      int
 	db        database.Service
-	jwtSecret string
+	scheduler *jobs.Scheduler
+	mailer    mailer.Mailer
+	// Signs and verifies the JWTs of logins
+	authService *auth.AuthService
+	// Identity providers for logging in, by name
+	oidcProviders map[string]*oidc.Provider
+	// Swappable while serving, nil when rate limiting is off
+	limiter        atomic.Pointer[ratelimit.Limiter]
+	passwordPolicy validation.PasswordPolicy
+	// Scope personal access tokens need per "METHOD /path" route, see scoped
+	routeScopes map[string]string
+	// How long deleted accounts can be restored by logging in, 0 erases them right away
+	deletionGracePeriod time.Duration
+	// Where requests are logged, gin's default writer when nil
+	accessLog io.Writer
 }
 
 func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
-	jwtSecret := os.Getenv("JWT_SECRET")
 
+	keys, err := auth.KeySetFromEnv()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	providers, err := oidc.ProvidersFromEnv()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	passwordPolicy, err := validation.PasswordPolicyFromEnv()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	gracePeriod, err := deletionGracePeriodFromEnv()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	db := database.New()
 	NewServer := &Server{
-		port:      port,
-		db:        database.New(),
-		jwtSecret: jwtSecret,
+		port:                port,
+		db:                  db,
+		authService:         auth.NewAuthServiceWithKeys(keys),
+		scheduler:           newScheduler(db),
+		mailer:              mail,
+		oidcProviders:       map[string]*oidc.Provider{},
+		passwordPolicy:      passwordPolicy,
+		deletionGracePeriod: gracePeriod,
 	}
+	for _, provider := range providers {
+		NewServer.AddOIDCProvider(provider)
+	}
+	NewServer.SetRateLimiter(newRateLimiter(db))
 
 	// Declare Server config
 	server := &http.Server{
@@ -37,13 +94,79 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// Replicas can all run the scheduler, JOBS_ENABLED=false turns it off for this one
+	if os.Getenv("JOBS_ENABLED") != "false" {
+		NewServer.scheduler.Start()
+		server.RegisterOnShutdown(NewServer.scheduler.Stop)
+	}
+
 	return server
 }
 
 func NewServerWithDB(db database.Service, port int, jwtSecret string) *Server {
-	return &Server{
-		port:      port,
-		db:        db, // Inject the passed DB implementation.
-		jwtSecret: jwtSecret,
+	s := &Server{
+		port:          port,
+		db:            db, // Inject the passed DB implementation.
+		authService:   auth.NewAuthService(jwtSecret),
+		scheduler:     newScheduler(db),
+		mailer:        mailer.NewMemoryMailer(),
+		oidcProviders: map[string]*oidc.Provider{},
+		// Without a breached password list, SetPasswordPolicy adds one
+		passwordPolicy:      validation.NewPasswordPolicy(8, nil),
+		deletionGracePeriod: defaultDeletionGracePeriod,
+	}
+	s.SetRateLimiter(ratelimit.New(ratelimit.NewMemoryStore()))
+	return s
+}
+
+// SetMailer replaces the mailer, e.g. to inspect the emails sent in tests.
+func (s *Server) SetMailer(m mailer.Mailer) {
+	if m != nil {
+		s.mailer = m
 	}
 }
+
+// SetPasswordPolicy replaces the rules for new passwords.
+func (s *Server) SetPasswordPolicy(policy validation.PasswordPolicy) {
+	s.passwordPolicy = policy
+}
+
+// SetDeletionGracePeriod changes how long deleted accounts can be restored, 0 erases
+// them right away.
+func (s *Server) SetDeletionGracePeriod(d time.Duration) {
+	s.deletionGracePeriod = d
+}
+
+// SetAccessLog changes where requests are logged, e.g. to inspect the log in tests.
+func (s *Server) SetAccessLog(w io.Writer) {
+	s.accessLog = w
+}
+
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// deletionGracePeriodFromEnv reads ACCOUNT_DELETION_GRACE_DAYS, 30 days if unset.
+func deletionGracePeriodFromEnv() (time.Duration, error) {
+	value := os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")
+	if value == "" {
+		return defaultDeletionGracePeriod, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid ACCOUNT_DELETION_GRACE_DAYS %q", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// newScheduler sets up the maintenance jobs without starting them.
+func newScheduler(db database.Service) *jobs.Scheduler {
+	scheduler := jobs.NewScheduler(db)
+	if err := jobs.RegisterDefaultJobs(scheduler, db); err != nil {
+		logger.Fatal(err)
+	}
+	return scheduler
+}
+
+// AddOIDCProvider enables logging in with an identity provider.
+func (s *Server) AddOIDCProvider(provider *oidc.Provider) {
+	s.oidcProviders[provider.Name()] = provider
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetSessionsHandler lists the devices the user is logged in on.
+func (s *Server) GetSessionsHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	sessions, err := s.db.GetSessions(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sessions"})
+		return
+	}
+
+	current := c.GetString("sessionID")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.String() == current
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSessionHandler logs the user out on one device.
+func (s *Server) RevokeSessionHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID format"})
+		return
+	}
+
+	if err := s.db.RevokeSession(userUUID, sessionID); err != nil {
+		if errors.Is(err, custom_error.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		}
+		return
+	}
+
+	if sessionID.String() == c.GetString("sessionID") {
+		c.SetCookie("jwt_token", "", -1, "/", "", true, true)
+		c.SetCookie("refresh_token", "", -1, refreshCookiePath, "", true, true)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// currentDeviceName returns the device name of the request's session, so a new
+// session for the same device keeps it.
+func (s *Server) currentDeviceName(c *gin.Context, userID uuid.UUID) string {
+	current := c.GetString("sessionID")
+	if current == "" {
+		return ""
+	}
+	sessions, err := s.db.GetSessions(userID)
+	if err != nil {
+		return ""
+	}
+	for _, session := range sessions {
+		if session.ID.String() == current {
+			return session.DeviceName
+		}
+	}
+	return ""
+}
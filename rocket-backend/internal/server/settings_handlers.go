@@ -1,10 +1,14 @@
 package server
 
 import (
+	"errors"
 	"io"
 	"net/http"
+	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
+	"rocket-backend/internal/validation"
 	"rocket-backend/pkg/logger"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -170,6 +174,46 @@ func (s *Server) UpdateUserInfoHandler(c *gin.Context) {
 		return
 	}
 
+	// Check every field first, a rejected one must not leave the others half applied
+	user, err := s.db.GetUserByID(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+	fields := validation.FieldErrors{}
+	if req.Name != nil {
+		name, err := validation.NormalizeUsername(*req.Name)
+		if err != nil {
+			fields["name"] = err.Error()
+		} else if !s.checkUsernameAvailable(c, fields, "name", name, userUUID) {
+			return
+		}
+		req.Name = &name
+	}
+	if req.Email != nil {
+		email, err := validation.NormalizeEmail(*req.Email)
+		if err != nil {
+			fields["email"] = err.Error()
+		} else if !strings.EqualFold(email, user.Email) {
+			if err := s.db.CheckEmail(email); errors.Is(err, custom_error.ErrEmailAlreadyExists) {
+				fields["email"] = "is already registered"
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
+				return
+			}
+		}
+		req.Email = &email
+	}
+	if req.NewPassword != nil {
+		if err := s.passwordPolicy.Check(*req.NewPassword, user.Username, user.Email); err != nil {
+			fields["newPassword"] = err.Error()
+		}
+	}
+	if err := fields.Err(); err != nil {
+		respondInvalid(c, err)
+		return
+	}
+
 	// Update name
 	if req.Name != nil {
 		if err := s.db.UpdateUserName(userUUID, *req.Name); err != nil {
@@ -184,6 +228,11 @@ func (s *Server) UpdateUserInfoHandler(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email"})
 			return
 		}
+		if creds, err := s.db.GetCredentialsByID(userUUID); err == nil && !creds.EmailVerified {
+			if err := s.sendEmailToken(creds, types.EmailTokenVerify); err != nil {
+				logger.Error("Failed to send verification email", err)
+			}
+		}
 	}
 
 	// Update password
@@ -206,6 +255,26 @@ func (s *Server) UpdateUserInfoHandler(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 			return
 		}
+
+		// Whoever got hold of the old password loses access, this client gets a new login
+		deviceName := s.currentDeviceName(c, userUUID)
+		if _, err := s.db.RevokeAllTokens(userUUID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+			return
+		}
+		creds, err := s.db.GetCredentialsByID(userUUID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+			return
+		}
+		body, err := s.issueTokens(c, creds, deviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		body["message"] = "User info updated successfully"
+		c.JSON(http.StatusOK, body)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User info updated successfully"})
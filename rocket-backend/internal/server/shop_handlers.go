@@ -0,0 +1,184 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/shop"
+	"rocket-backend/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (s *Server) GetShopItemsHandler(c *gin.Context) {
+	items, err := s.db.GetShopItems(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shop items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func (s *Server) RedeemShopItemHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID format"})
+		return
+	}
+
+	shopManager := shop.NewShopManager(s.db)
+	purchase, err := shopManager.Redeem(userUUID, itemID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else if errors.Is(err, custom_error.ErrInsufficientPoints) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough rocket points"})
+		} else if errors.Is(err, custom_error.ErrLimitReached) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Item limit reached"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem item"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, purchase)
+}
+
+func (s *Server) GetInventoryHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	inventory, err := s.db.GetInventory(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inventory"})
+		return
+	}
+
+	c.JSON(http.StatusOK, inventory)
+}
+
+func (s *Server) GetPurchaseHistoryHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	purchases, err := s.db.GetPurchaseHistory(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve purchase history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, purchases)
+}
+
+func (s *Server) AdminGetShopItemsHandler(c *gin.Context) {
+	items, err := s.db.GetShopItems(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shop items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func (s *Server) AdminCreateShopItemHandler(c *gin.Context) {
+	var itemDTO types.ShopItemDTO
+	if err := c.ShouldBindJSON(&itemDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	itemID, err := s.db.CreateShopItem(shop.ItemFromDTO(itemDTO))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shop item"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": itemID})
+}
+
+func (s *Server) AdminUpdateShopItemHandler(c *gin.Context) {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID format"})
+		return
+	}
+
+	var itemDTO types.ShopItemDTO
+	if err := c.ShouldBindJSON(&itemDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	item := shop.ItemFromDTO(itemDTO)
+	item.ID = itemID
+	if err := s.db.UpdateShopItem(item); err != nil {
+		if errors.Is(err, custom_error.ErrItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shop item"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shop item updated successfully"})
+}
+
+// AdminDeleteShopItemHandler only retires the item so purchase history and inventories stay intact.
+func (s *Server) AdminDeleteShopItemHandler(c *gin.Context) {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID format"})
+		return
+	}
+
+	item, err := s.db.GetShopItemByID(itemID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shop item"})
+		}
+		return
+	}
+
+	item.Active = false
+	if err := s.db.UpdateShopItem(*item); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retire shop item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shop item retired successfully"})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/streaks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (s *Server) GetStreakHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	streakManager := streaks.NewStreakManager(s.db)
+	streak, err := streakManager.GetStreak(userUUID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrSettingsNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Settings not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve streak"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, streak)
+}
+
+func (s *Server) BuyStreakFreezeHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	streakManager := streaks.NewStreakManager(s.db)
+	purchase, err := streakManager.BuyFreeze(userUUID)
+	if err != nil {
+		if errors.Is(err, custom_error.ErrItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Streak freezes are currently not available"})
+		} else if errors.Is(err, custom_error.ErrInsufficientPoints) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "Not enough rocket points"})
+		} else if errors.Is(err, custom_error.ErrLimitReached) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Maximum number of streak freezes reached"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to buy streak freeze"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, purchase)
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/teams"
+	"rocket-backend/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (s *Server) CreateTeamHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var dto types.CreateTeamDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	team, err := teamManager.Create(userUUID, dto)
+	if err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+func (s *Server) GetTeamsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	list, err := teamManager.List(userUUID)
+	if err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (s *Server) GetTeamHandler(c *gin.Context) {
+	userUUID, teamID, ok := teamRequest(c)
+	if !ok {
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	team, err := teamManager.Get(userUUID, teamID)
+	if err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+func (s *Server) DeleteTeamHandler(c *gin.Context) {
+	userUUID, teamID, ok := teamRequest(c)
+	if !ok {
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	if err := teamManager.Disband(userUUID, teamID); err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team disbanded"})
+}
+
+func (s *Server) AddTeamMemberHandler(c *gin.Context) {
+	userUUID, teamID, ok := teamRequest(c)
+	if !ok {
+		return
+	}
+
+	var dto types.AddTeamMemberDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	team, err := teamManager.AddMember(userUUID, teamID, dto.Username)
+	if err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+func (s *Server) RemoveTeamMemberHandler(c *gin.Context) {
+	userUUID, teamID, ok := teamRequest(c)
+	if !ok {
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	if err := teamManager.RemoveMember(userUUID, teamID, c.Param("name")); err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+func (s *Server) CreateTeamChallengeHandler(c *gin.Context) {
+	userUUID, teamID, ok := teamRequest(c)
+	if !ok {
+		return
+	}
+
+	var dto types.CreateTeamChallengeDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	challenge, err := teamManager.CreateChallenge(userUUID, teamID, dto)
+	if err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, challenge)
+}
+
+func (s *Server) GetTeamChallengesHandler(c *gin.Context) {
+	userUUID, teamID, ok := teamRequest(c)
+	if !ok {
+		return
+	}
+
+	teamManager := teams.NewTeamManager(s.db)
+	challenges, err := teamManager.Challenges(userUUID, teamID)
+	if err != nil {
+		respondTeamError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, challenges)
+}
+
+// teamRequest reads the authenticated user and the team ID from the path.
+func teamRequest(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	teamID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userUUID, teamID, true
+}
+
+func respondTeamError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, custom_error.ErrTeamNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+	case errors.Is(err, custom_error.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, custom_error.ErrNotFriends):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Teams can only be formed with friends"})
+	case errors.Is(err, custom_error.ErrBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot add this user to a team"})
+	case errors.Is(err, custom_error.ErrNotTeamOwner):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the team owner can do this"})
+	case errors.Is(err, custom_error.ErrTeamFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "Team is full"})
+	case errors.Is(err, custom_error.ErrTeamTooSmall):
+		c.JSON(http.StatusConflict, gin.H{"error": "Team challenges need at least two members"})
+	case errors.Is(err, custom_error.ErrInvalidTeamChallenge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/auth"
+	"rocket-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// The refresh cookie is only sent to the API, never to other paths of the domain
+const refreshCookiePath = "/api/v1"
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// issueTokens starts a new session for the user on the requesting device: an access
+// token and the session's first refresh token. It sets the cookies and returns the
+// response body.
+func (s *Server) issueTokens(c *gin.Context, creds types.Credentials, deviceName string) (gin.H, error) {
+	refreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	session := types.Session{
+		UserID:     creds.ID,
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+	}
+	sessionID, err := s.db.CreateSession(session, auth.HashOpaqueToken(refreshToken), time.Now().Add(auth.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokenResponse(c, creds, sessionID, refreshToken)
+}
+
+func (s *Server) tokenResponse(c *gin.Context, creds types.Credentials, sessionID uuid.UUID, refreshToken string) (gin.H, error) {
+	authService := s.authService
+	tokenString, err := authService.GenerateToken(creds.ID, auth.TokenClaims{
+		Role:      creds.Role,
+		Version:   creds.TokenVersion,
+		SessionID: sessionID.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetCookie("jwt_token", tokenString, int(auth.AccessTokenTTL.Seconds()), "/", "", true, true)
+	c.SetCookie("refresh_token", refreshToken, int(auth.RefreshTokenTTL.Seconds()), refreshCookiePath, "", true, true)
+	return gin.H{
+		"token":         tokenString,
+		"refresh_token": refreshToken,
+		"expires_in":    int(auth.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// presentedRefreshToken reads the refresh token from the body, falling back to the cookie.
+func presentedRefreshToken(c *gin.Context) string {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err == nil && req.RefreshToken != "" {
+		return req.RefreshToken
+	}
+	cookie, _ := c.Cookie("refresh_token")
+	return cookie
+}
+
+// RefreshHandler exchanges a refresh token for a new access and refresh token.
+func (s *Server) RefreshHandler(c *gin.Context) {
+	refreshToken := presentedRefreshToken(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token required"})
+		return
+	}
+
+	newRefreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	creds, sessionID, err := s.db.RotateRefreshToken(auth.HashOpaqueToken(refreshToken), auth.HashOpaqueToken(newRefreshToken), time.Now().Add(auth.RefreshTokenTTL), c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_error.ErrInvalidRefreshToken), errors.Is(err, custom_error.ErrRefreshTokenReused), errors.Is(err, custom_error.ErrUserNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	body, err := s.tokenResponse(c, creds, sessionID, newRefreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// LogoutHandler revokes the presented access and refresh token. It also works with
+// expired or missing tokens, so clients can always log out.
+func (s *Server) LogoutHandler(c *gin.Context) {
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		tokenString, _ = c.Cookie("jwt_token")
+	}
+	if tokenString != "" {
+		authService := s.authService
+		if token, err := authService.ParseToken(tokenString); err == nil && token.Valid {
+			userID, errUser := authService.ValidateToken(token)
+			claims, errClaims := authService.Claims(token)
+			tokenID, errID := uuid.Parse(claims.ID)
+			if errUser == nil && errClaims == nil && errID == nil {
+				if err := s.db.RevokeAccessToken(userID, tokenID, claims.ExpiresAt); err != nil {
+					logger.Error("Failed to revoke access token on logout", err)
+				}
+			}
+		}
+	}
+
+	if refreshToken := presentedRefreshToken(c); refreshToken != "" {
+		if err := s.db.RevokeRefreshToken(auth.HashOpaqueToken(refreshToken)); err != nil {
+			logger.Error("Failed to revoke refresh token on logout", err)
+		}
+	}
+
+	c.SetCookie("jwt_token", "", -1, "/", "", true, true)
+	c.SetCookie("refresh_token", "", -1, refreshCookiePath, "", true, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAllHandler revokes every token of the user, on all devices.
+func (s *Server) LogoutAllHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if _, err := s.db.RevokeAllTokens(userUUID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.SetCookie("jwt_token", "", -1, "/", "", true, true)
+	c.SetCookie("refresh_token", "", -1, refreshCookiePath, "", true, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out on all devices"})
+}
+
+// JWKSHandler publishes the public keys access tokens are signed with, so other
+// services can verify them. It is empty while tokens are signed with a HS256 secret.
+func (s *Server) JWKSHandler(c *gin.Context) {
+	// Short enough that a rotated key shows up before it signs anything
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, s.authService.Keys().JWKS())
+}
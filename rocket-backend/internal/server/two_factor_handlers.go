@@ -0,0 +1,332 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/auth"
+	"rocket-backend/pkg/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Issuer shown in authenticator apps
+const totpIssuer = "Rocket"
+
+// How long after logging in users without a password can change their two-factor
+// settings without a code
+const freshLoginWindow = 5 * time.Minute
+
+// GetTwoFactorHandler tells whether two-factor authentication is on and how many
+// recovery codes are left.
+func (s *Server) GetTwoFactorHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	twoFactor, err := s.db.GetTwoFactor(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	if twoFactor == nil || !twoFactor.Enabled {
+		c.JSON(http.StatusOK, types.TwoFactor{})
+		return
+	}
+
+	c.JSON(http.StatusOK, twoFactor)
+}
+
+// EnrollTwoFactorHandler creates a new secret for an authenticator app. It only takes
+// effect once ConfirmTwoFactorHandler got a code for it, so an existing setup keeps
+// working until then.
+func (s *Server) EnrollTwoFactorHandler(c *gin.Context) {
+	userUUID, ok := s.requireReauthentication(c)
+	if !ok {
+		return
+	}
+
+	creds, err := s.db.GetCredentialsByID(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := s.db.SavePendingTwoFactorSecret(userUUID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(secret, totpIssuer, creds.Email),
+	})
+}
+
+// ConfirmTwoFactorHandler activates the enrolled secret with a code from the app and
+// returns the recovery codes. They are shown this one time only.
+func (s *Server) ConfirmTwoFactorHandler(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	var req types.TwoFactorCodeDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	twoFactor, err := s.db.GetTwoFactor(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load two-factor settings"})
+		return
+	}
+	if twoFactor == nil || twoFactor.PendingSecret == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "No pending enrollment"})
+		return
+	}
+	step, ok := totp.Validate(twoFactor.PendingSecret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := s.db.ConfirmTwoFactor(userUUID, step, hashes); err != nil {
+		if errors.Is(err, custom_error.ErrNoPendingEnrollment) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No pending enrollment"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodesHandler replaces all recovery codes, e.g. when they ran low.
+func (s *Server) RegenerateRecoveryCodesHandler(c *gin.Context) {
+	userUUID, ok := s.requireReauthentication(c)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := s.db.ReplaceRecoveryCodes(userUUID, hashes); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactorHandler turns two-factor authentication off.
+func (s *Server) DisableTwoFactorHandler(c *gin.Context) {
+	userUUID, ok := s.requireReauthentication(c)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DisableTwoFactor(userUUID); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// TwoFactorLoginHandler is the second login step: it takes the challenge token from
+// LoginHandler and a code from the authenticator app or a recovery code.
+func (s *Server) TwoFactorLoginHandler(c *gin.Context) {
+	var req types.TwoFactorLoginDTO
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Challenge token and either code or recovery code required"})
+		return
+	}
+
+	authService := s.authService
+	userID, err := authService.ParseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+
+	creds, err := s.db.GetCredentialsByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+	// Wrong codes count towards the same lockout as wrong passwords
+	if !s.checkLoginLockout(c, creds.Email) {
+		return
+	}
+
+	twoFactor, err := s.db.GetTwoFactor(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if twoFactor == nil || !twoFactor.Enabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		return
+	}
+
+	var accepted bool
+	if req.Code != "" {
+		if step, ok := totp.Validate(twoFactor.Secret, req.Code, time.Now()); ok {
+			// Fails for a code that was already used, someone may have watched it being typed
+			accepted, err = s.db.UseTwoFactorStep(userID, step)
+		}
+	} else {
+		accepted, err = s.db.UseRecoveryCode(userID, auth.HashOpaqueToken(totp.NormalizeRecoveryCode(req.RecoveryCode)))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if !accepted {
+		s.recordLoginFailure(creds.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	s.completeLogin(c, creds, req.DeviceName)
+}
+
+// requireReauthentication confirms the user before sensitive changes with the current
+// password. Accounts without a password can use a current authenticator code or a login
+// less than freshLoginWindow ago instead. On failure it responds and returns false.
+func (s *Server) requireReauthentication(c *gin.Context) (uuid.UUID, bool) {
+	userUUID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return uuid.Nil, false
+	}
+	var req types.ReauthenticationDTO
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return uuid.Nil, false
+	}
+
+	creds, err := s.db.GetCredentialsByID(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return uuid.Nil, false
+	}
+
+	if creds.Password != "" {
+		if req.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Current password required"})
+			return uuid.Nil, false
+		}
+		ok, err := s.db.CheckUserPassword(userUUID, req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check current password"})
+			return uuid.Nil, false
+		}
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password incorrect"})
+			return uuid.Nil, false
+		}
+		return userUUID, true
+	}
+
+	if req.Code != "" {
+		ok, err := s.checkTwoFactorCode(userUUID, req.Code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check code"})
+			return uuid.Nil, false
+		}
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+			return uuid.Nil, false
+		}
+		return userUUID, true
+	}
+
+	fresh, err := s.isFreshLogin(c, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check session"})
+		return uuid.Nil, false
+	}
+	if !fresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Log in again or send a code from your authenticator app"})
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
+
+// checkTwoFactorCode reports whether code is a current, unused code for the user's
+// enabled authenticator app.
+func (s *Server) checkTwoFactorCode(userID uuid.UUID, code string) (bool, error) {
+	twoFactor, err := s.db.GetTwoFactor(userID)
+	if err != nil || twoFactor == nil || !twoFactor.Enabled {
+		return false, err
+	}
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.db.UseTwoFactorStep(userID, step)
+}
+
+// isFreshLogin reports whether the request's session was logged into less than
+// freshLoginWindow ago. Personal and OAuth tokens have no session and never are.
+func (s *Server) isFreshLogin(c *gin.Context, userID uuid.UUID) (bool, error) {
+	sessionID, err := uuid.Parse(c.GetString("sessionID"))
+	if err != nil {
+		return false, nil
+	}
+	sessions, err := s.db.GetSessions(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			return time.Since(session.CreatedAt) < freshLoginWindow, nil
+		}
+	}
+	return false, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashOpaqueToken(code)
+	}
+	return codes, hashes, nil
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, custom_error.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor settings"})
+	}
+}
@@ -3,10 +3,14 @@ package server
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"net/http"
+	"rocket-backend/internal/challenges"
 	"rocket-backend/internal/custom_error"
 	"rocket-backend/internal/types"
 	"rocket-backend/pkg/logger"
+	"rocket-backend/pkg/mailer"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -36,6 +40,11 @@ func (s *Server) UpdateStepsHandler(c *gin.Context) {
 		return
 	}
 
+	// Weekly and monthly challenges are fed by the steps, a failure there must not fail the upload
+	if _, err := challenges.NewChallengeManager(s.db).UpdatePeriodicProgress(userUUID); err != nil {
+		logger.Error("Failed to update periodic challenge progress", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Daily Steps saved"})
 }
 
@@ -60,6 +69,9 @@ func (s *Server) GetUserStatisticsHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
 		return
 	}
+	if s.hiddenByBlock(c, userUUID) {
+		return
+	}
 
 	stats, err := s.db.GetUserStatistics(userUUID)
 	if err != nil {
@@ -90,6 +102,9 @@ func (s *Server) GetUserImageHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id format"})
 		return
 	}
+	if s.hiddenByBlock(c, userUUID) {
+		return
+	}
 
 	img, err := s.db.GetUserImage(userUUID)
 	if err != nil {
@@ -232,6 +247,11 @@ func (s *Server) GetUserByNameHandler(c *gin.Context) {
 		return
 	}
 
+	// Users who blocked the viewer look as if they did not exist
+	if s.hiddenByBlock(c, userID) {
+		return
+	}
+
 	user, err := s.db.GetUserByID(userID)
 	if err != nil {
 		if errors.Is(err, custom_error.ErrUserNotFound) {
@@ -264,6 +284,8 @@ func (s *Server) GetUserByNameHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, userWithImage)
 }
 
+// DeleteUserHandler deactivates the account of the user and erases it once the grace
+// period is over. Logging in before then restores the account.
 func (s *Server) DeleteUserHandler(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -277,7 +299,20 @@ func (s *Server) DeleteUserHandler(c *gin.Context) {
 		return
 	}
 
-	err = s.db.DeleteUser(userUUID)
+	if s.deletionGracePeriod <= 0 {
+		if err := s.db.DeleteUser(userUUID); err != nil {
+			if errors.Is(err, custom_error.ErrUserNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+		return
+	}
+
+	eraseAfter, err := s.db.DeactivateUser(userUUID, time.Now().Add(s.deletionGracePeriod))
 	if err != nil {
 		if errors.Is(err, custom_error.ErrUserNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -286,6 +321,22 @@ func (s *Server) DeleteUserHandler(c *gin.Context) {
 		}
 		return
 	}
+	if _, err := s.db.RevokeAllTokens(userUUID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+		return
+	}
+
+	// The account is deactivated either way, a missing email must not undo that
+	if creds, err := s.db.GetCredentialsByID(userUUID); err != nil {
+		logger.Error("Failed to load credentials for deletion email", err)
+	} else if err := s.mailer.Send(mailer.Message{
+		To:      creds.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf("Your Rocket account has been deactivated and will be deleted with all its data on %s.\n\n"+
+			"Changed your mind? Log in before then to restore it.\n", eraseAfter.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		logger.Error("Failed to send deletion email", err)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Account deactivated", "erase_after": eraseAfter})
 }
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// respondInvalid answers 400 and lists what is wrong per field when it is known,
+// e.g. {"error": "Invalid request", "fields": {"username": "is reserved"}}.
+func respondInvalid(c *gin.Context, err error) {
+	var fields validation.FieldErrors
+	if !errors.As(err, &fields) {
+		fields = validation.BindingErrors(err)
+	}
+	if len(fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "fields": fields})
+}
+
+// checkUsernameAvailable records in fields when someone other than userID already has
+// the username. On a database failure it responds and returns false.
+func (s *Server) checkUsernameAvailable(c *gin.Context, fields validation.FieldErrors, field string, username string, userID uuid.UUID) bool {
+	err := s.db.CheckUsername(username, userID)
+	if errors.Is(err, custom_error.ErrUsernameTaken) {
+		fields[field] = "is already taken"
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check username"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package shop
+
+import (
+	"fmt"
+
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/streaks"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type ShopManager struct {
+	db database.Service
+}
+
+func NewShopManager(db database.Service) *ShopManager {
+	return &ShopManager{db: db}
+}
+
+// Redeem spends the user's rocket points on the given catalog item.
+func (sm *ShopManager) Redeem(userID uuid.UUID, itemID uuid.UUID) (*types.Purchase, error) {
+	item, err := sm.db.GetShopItemByID(itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Category == types.ItemCategoryStreakFreeze {
+		// Freezes are stored on the streak, so it has to exist and be up to date
+		if _, err := streaks.NewStreakManager(sm.db).Evaluate(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	purchase, err := sm.db.PurchaseShopItem(userID, item.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Shop item redeemed", "userID", userID, "item", item.Name)
+	_ = sm.db.SaveActivity(userID, fmt.Sprintf("🛍️ Redeemed %s in the rocket shop", item.Name))
+
+	return purchase, nil
+}
+
+// ItemFromDTO applies an admin request onto an item, keeping the item active unless told otherwise.
+func ItemFromDTO(dto types.ShopItemDTO) types.ShopItem {
+	item := types.ShopItem{
+		Name:        dto.Name,
+		Description: dto.Description,
+		Category:    dto.Category,
+		Price:       dto.Price,
+		MaxPerUser:  dto.MaxPerUser,
+		Active:      true,
+	}
+	if dto.Active != nil {
+		item.Active = *dto.Active
+	}
+	return item
+}
@@ -0,0 +1,170 @@
+package streaks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type StreakManager struct {
+	db  database.Service
+	now func() time.Time
+}
+
+func NewStreakManager(db database.Service) *StreakManager {
+	return &StreakManager{db: db, now: time.Now}
+}
+
+// Evaluate brings the stored streak up to date by checking every finished day
+// since the last evaluation against the user's step goal. Missed days consume
+// a streak freeze if one is available, otherwise the streak is broken.
+func (sm *StreakManager) Evaluate(userID uuid.UUID) (*types.Streak, error) {
+	today := dateOnly(sm.now().In(time.Local))
+	yesterday := today.AddDate(0, 0, -1)
+
+	streak, err := sm.db.GetStreak(userID)
+	if err != nil {
+		if !errors.Is(err, custom_error.ErrStreakNotFound) {
+			return nil, err
+		}
+		// Start tracking from today on, there is no history to judge yet
+		streak = &types.Streak{UserID: userID, LastEvaluated: yesterday}
+		if err := sm.db.SaveStreak(*streak); err != nil {
+			return nil, err
+		}
+		return streak, nil
+	}
+
+	from := dateOnly(streak.LastEvaluated).AddDate(0, 0, 1)
+	if from.After(yesterday) {
+		return streak, nil
+	}
+
+	stepGoal, err := sm.stepGoal(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := sm.db.GetStepsInRange(userID, from, yesterday)
+	if err != nil {
+		return nil, err
+	}
+
+	for day := from; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		if steps[day.Format("2006-01-02")] >= stepGoal {
+			streak.CurrentStreak++
+			if streak.CurrentStreak > streak.LongestStreak {
+				streak.LongestStreak = streak.CurrentStreak
+			}
+			continue
+		}
+
+		if streak.CurrentStreak == 0 {
+			continue
+		}
+
+		if streak.Freezes > 0 {
+			streak.Freezes--
+			if err := sm.db.SaveActivity(userID, fmt.Sprintf("🧊 Used a streak freeze to keep a %d-day streak alive", streak.CurrentStreak)); err != nil {
+				logger.Error("Failed to save streak freeze activity", err)
+			}
+			continue
+		}
+
+		if err := sm.db.SaveActivity(userID, fmt.Sprintf("💔 Lost a %d-day step goal streak", streak.CurrentStreak)); err != nil {
+			logger.Error("Failed to save lost streak activity", err)
+		}
+		streak.CurrentStreak = 0
+	}
+
+	streak.LastEvaluated = yesterday
+	if err := sm.db.SaveStreak(*streak); err != nil {
+		return nil, err
+	}
+
+	return streak, nil
+}
+
+// GetStreak evaluates the streak and adds today's progress on top of it.
+func (sm *StreakManager) GetStreak(userID uuid.UUID) (*types.StreakDTO, error) {
+	streak, err := sm.Evaluate(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stepGoal, err := sm.stepGoal(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stepsToday, err := sm.db.GetDailySteps(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+	}
+
+	dto := &types.StreakDTO{
+		CurrentStreak: streak.CurrentStreak,
+		LongestStreak: streak.LongestStreak,
+		Freezes:       streak.Freezes,
+		StepGoal:      stepGoal,
+		StepsToday:    stepsToday,
+		GoalMetToday:  stepsToday >= stepGoal,
+	}
+
+	if dto.GoalMetToday {
+		dto.CurrentStreak++
+		if dto.CurrentStreak > dto.LongestStreak {
+			dto.LongestStreak = dto.CurrentStreak
+		}
+	}
+
+	return dto, nil
+}
+
+// BuyFreeze exchanges rocket points for the streak freeze listed in the shop.
+func (sm *StreakManager) BuyFreeze(userID uuid.UUID) (*types.Purchase, error) {
+	// Make sure the streak row exists and missed days are settled before adding a freeze
+	if _, err := sm.Evaluate(userID); err != nil {
+		return nil, err
+	}
+
+	items, err := sm.db.GetShopItems(false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.Category == types.ItemCategoryStreakFreeze {
+			purchase, err := sm.db.PurchaseShopItem(userID, item.ID)
+			if err != nil {
+				return nil, err
+			}
+			logger.Info("Streak freeze purchased", "userID", userID)
+			return purchase, nil
+		}
+	}
+
+	return nil, custom_error.ErrItemNotFound
+}
+
+func (sm *StreakManager) stepGoal(userID uuid.UUID) (int, error) {
+	settings, err := sm.db.GetSettingsByUserID(userID)
+	if err != nil {
+		return 0, err
+	}
+	return settings.StepGoal, nil
+}
+
+// dateOnly returns the calendar day of t at midnight in the server's time zone, which
+// is also the one the daily steps are dated in. Dates read from the database keep
+// their day, whatever zone the driver put them in.
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
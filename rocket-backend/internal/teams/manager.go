@@ -0,0 +1,335 @@
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rocket-backend/internal/custom_error"
+	"rocket-backend/internal/database"
+	"rocket-backend/internal/types"
+	"rocket-backend/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Largest team a user can put together, the owner included
+	MaxTeamSize = 10
+	// Smallest team that can set challenges, the owner included
+	MinTeamSize = 2
+	// Most points a member can earn with a team challenge per day it runs
+	MaxTeamPointsPerDay = 20
+	// Most points a user can earn with team challenges in a week
+	TeamPointsPerWeek = 300
+)
+
+var metricUnits = map[string]string{
+	types.MetricSteps:       "steps",
+	types.MetricRunDistance: "km",
+}
+
+// Team challenges ask every member for at least this much a day
+var minDailyTargets = map[string]float64{
+	types.MetricSteps:       5000,
+	types.MetricRunDistance: 2,
+}
+
+// Points a member earns for every unit of their share of the target
+var pointsPerUnit = map[string]float64{
+	types.MetricSteps:       1.0 / 500,
+	types.MetricRunDistance: 5,
+}
+
+type TeamManager struct {
+	db  database.Service
+	now func() time.Time
+}
+
+func NewTeamManager(db database.Service) *TeamManager {
+	return &TeamManager{db: db, now: time.Now}
+}
+
+// Create forms a team out of the owner and some of their friends who did not block them.
+func (tm *TeamManager) Create(ownerID uuid.UUID, dto types.CreateTeamDTO) (*types.Team, error) {
+	if len(dto.Members)+1 > MaxTeamSize {
+		return nil, custom_error.ErrTeamFull
+	}
+
+	memberIDs := make([]uuid.UUID, 0, len(dto.Members))
+	for _, name := range dto.Members {
+		memberID, err := tm.friendByName(ownerID, name)
+		if err != nil {
+			return nil, err
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+
+	teamID, err := tm.db.CreateTeam(dto.Name, ownerID, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	return tm.db.GetTeamByID(teamID)
+}
+
+// Get returns a team the user is a member of.
+func (tm *TeamManager) Get(userID, teamID uuid.UUID) (*types.Team, error) {
+	team, err := tm.db.GetTeamByID(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember(team, userID) {
+		// Don't reveal teams the user is not part of
+		return nil, custom_error.ErrTeamNotFound
+	}
+	return team, nil
+}
+
+func (tm *TeamManager) List(userID uuid.UUID) ([]types.Team, error) {
+	return tm.db.GetTeamsForUser(userID)
+}
+
+// AddMember lets the owner add one of their friends who did not block them.
+func (tm *TeamManager) AddMember(ownerID, teamID uuid.UUID, username string) (*types.Team, error) {
+	team, err := tm.owned(ownerID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if len(team.Members) >= MaxTeamSize {
+		return nil, custom_error.ErrTeamFull
+	}
+
+	memberID, err := tm.friendByName(ownerID, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := tm.db.AddTeamMember(teamID, memberID); err != nil {
+		return nil, err
+	}
+	return tm.db.GetTeamByID(teamID)
+}
+
+// RemoveMember removes a member. The owner can remove anyone, members can only leave themselves.
+// The owner leaving disbands the team.
+func (tm *TeamManager) RemoveMember(userID, teamID uuid.UUID, username string) error {
+	team, err := tm.Get(userID, teamID)
+	if err != nil {
+		return err
+	}
+
+	memberID, err := tm.db.GetUserIDByName(username)
+	if err != nil {
+		return fmt.Errorf("%w: %v", custom_error.ErrUserNotFound, err)
+	}
+	if memberID != userID && team.OwnerID != userID {
+		return custom_error.ErrNotTeamOwner
+	}
+
+	if memberID == team.OwnerID {
+		return tm.db.DeleteTeam(teamID)
+	}
+	return tm.db.RemoveTeamMember(teamID, memberID)
+}
+
+// Disband deletes the team together with its challenges.
+func (tm *TeamManager) Disband(ownerID, teamID uuid.UUID) error {
+	if _, err := tm.owned(ownerID, teamID); err != nil {
+		return err
+	}
+	return tm.db.DeleteTeam(teamID)
+}
+
+// CreateChallenge sets a new community goal for the team's current members, starting
+// today. The reward follows from the target every member has to contribute.
+func (tm *TeamManager) CreateChallenge(ownerID, teamID uuid.UUID, dto types.CreateTeamChallengeDTO) (*types.TeamChallenge, error) {
+	team, err := tm.owned(ownerID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	members := len(team.Members)
+	if members < MinTeamSize {
+		return nil, custom_error.ErrTeamTooSmall
+	}
+
+	minTarget := minDailyTargets[dto.Metric] * float64(members*dto.DurationDays)
+	if dto.Target < minTarget {
+		return nil, fmt.Errorf("%w: the target must be at least %g %s for %d members over %d days",
+			custom_error.ErrInvalidTeamChallenge, minTarget, metricUnits[dto.Metric], members, dto.DurationDays)
+	}
+
+	now := tm.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	challenge := types.TeamChallenge{
+		TeamID:     teamID,
+		Text:       dto.Text,
+		Metric:     dto.Metric,
+		Parameters: types.ChallengeParameters{Target: dto.Target, Unit: metricUnits[dto.Metric]},
+		Points:     teamChallengeReward(dto.Metric, dto.Target, members, dto.DurationDays),
+		StartsOn:   today,
+		EndsOn:     today.AddDate(0, 0, dto.DurationDays-1),
+		Status:     types.TeamChallengeActive,
+	}
+
+	id, err := tm.db.CreateTeamChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+	challenge.ID = id
+	challenge.Contributions = []types.TeamContribution{}
+	return &challenge, nil
+}
+
+// Challenges returns the team's challenges with every member's contribution.
+// Challenges that reached their target are completed and rewarded on the way.
+func (tm *TeamManager) Challenges(userID, teamID uuid.UUID) ([]types.TeamChallenge, error) {
+	team, err := tm.Get(userID, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenges, err := tm.db.GetTeamChallenges(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := tm.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for i := range challenges {
+		challenge := &challenges[i]
+		if err := tm.measure(challenge, today); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case challenge.CompletedAt != nil:
+			challenge.Status = types.TeamChallengeCompleted
+		case challenge.Progress >= challenge.Parameters.Target:
+			if err := tm.complete(team, challenge); err != nil {
+				return nil, err
+			}
+		case today.After(challenge.EndsOn):
+			challenge.Status = types.TeamChallengeExpired
+		default:
+			challenge.Status = types.TeamChallengeActive
+		}
+	}
+
+	return challenges, nil
+}
+
+// teamChallengeReward is what every member earns for their share of the target.
+func teamChallengeReward(metric string, target float64, members, days int) int {
+	points := int(target / float64(members) * pointsPerUnit[metric])
+	return min(points, MaxTeamPointsPerDay*days)
+}
+
+// measure sums up the steps or runs inside the challenge window of every member the
+// challenge was set for, leaving out what they recorded before it was set.
+func (tm *TeamManager) measure(challenge *types.TeamChallenge, today time.Time) error {
+	to := challenge.EndsOn
+	if today.Before(to) {
+		to = today
+	}
+
+	members, err := tm.db.GetTeamChallengeMembers(challenge.ID)
+	if err != nil {
+		return err
+	}
+
+	challenge.Progress = 0
+	challenge.Contributions = make([]types.TeamContribution, 0, len(members))
+	for _, member := range members {
+		var amount float64
+		switch challenge.Metric {
+		case types.MetricRunDistance:
+			distance, err := tm.db.GetRunDistanceInRange(member.UserID, challenge.StartsOn, to)
+			if err != nil {
+				logger.Error("Failed to sum up runs for team challenge", err)
+				return fmt.Errorf("%w: %v", custom_error.ErrFailedToRetrieveData, err)
+			}
+			amount = distance
+		default:
+			steps, err := tm.db.GetStepsInRange(member.UserID, challenge.StartsOn, to)
+			if err != nil {
+				return err
+			}
+			for _, daySteps := range steps {
+				amount += float64(daySteps)
+			}
+		}
+		amount = max(amount-member.Baseline, 0)
+
+		challenge.Progress += amount
+		challenge.Contributions = append(challenge.Contributions, types.TeamContribution{
+			UserID:   member.UserID,
+			Username: member.Username,
+			Amount:   amount,
+		})
+	}
+	return nil
+}
+
+func (tm *TeamManager) complete(team *types.Team, challenge *types.TeamChallenge) error {
+	rewarded, err := tm.db.CompleteTeamChallenge(challenge.ID, TeamPointsPerWeek)
+	if errors.Is(err, custom_error.ErrAlreadyCompleted) {
+		// Completed concurrently, the members already got their points
+		challenge.Status = types.TeamChallengeCompleted
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	completedAt := tm.now()
+	challenge.Status = types.TeamChallengeCompleted
+	challenge.CompletedAt = &completedAt
+
+	message := fmt.Sprintf("🚀 Team %s completed: %s", team.Name, challenge.Text)
+	for _, userID := range rewarded {
+		_ = tm.db.SaveActivity(userID, message)
+	}
+	return nil
+}
+
+func (tm *TeamManager) owned(userID, teamID uuid.UUID) (*types.Team, error) {
+	team, err := tm.Get(userID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team.OwnerID != userID {
+		return nil, custom_error.ErrNotTeamOwner
+	}
+	return team, nil
+}
+
+func (tm *TeamManager) friendByName(ownerID uuid.UUID, name string) (uuid.UUID, error) {
+	friendID, err := tm.db.GetUserIDByName(name)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", custom_error.ErrUserNotFound, err)
+	}
+	isFriend, err := tm.db.IsFriend(ownerID, friendID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !isFriend {
+		return uuid.Nil, fmt.Errorf("%w: %s", custom_error.ErrNotFriends, name)
+	}
+
+	blocked, err := tm.db.IsBlocked(friendID, ownerID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if blocked {
+		return uuid.Nil, custom_error.ErrBlocked
+	}
+	return friendID, nil
+}
+
+func isMember(team *types.Team, userID uuid.UUID) bool {
+	for _, member := range team.Members {
+		if member.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,110 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	VerificationSteps       = "steps"
+	VerificationRunDistance = "run_distance"
+	VerificationSelfReport  = "self_report"
+)
+
+const (
+	CategorySteps       = "steps"
+	CategoryRunning     = "running"
+	CategoryStrength    = "strength"
+	CategoryMindfulness = "mindfulness"
+	CategoryNutrition   = "nutrition"
+)
+
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+const (
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+const (
+	MetricSteps        = "steps"
+	MetricRunDistance  = "run_distance"
+	MetricStepGoalDays = "step_goal_days"
+)
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationExpired  = "expired"
+)
+
+const (
+	AuditCreate    = "create"
+	AuditUpdate    = "update"
+	AuditRetire    = "retire"
+	AuditFeature   = "feature"
+	AuditUnfeature = "unfeature"
+)
+
+type ChallengeParameters struct {
+	Target float64 `json:"target"`
+	Unit   string  `json:"unit"`
+}
+
 type Challenge struct {
-	ID     string `json:"id"`
-	Text   string `json:"text"`
-	Points int    `json:"points"`
+	ID           string               `json:"id"`
+	Text         string               `json:"text"`
+	Points       int                  `json:"points"`
+	Category     string               `json:"category"`
+	Difficulty   string               `json:"difficulty"`
+	Verification string               `json:"verification"`
+	Parameters   *ChallengeParameters `json:"parameters,omitempty"`
+}
+
+// CatalogChallenge is a challenge as seen by admins, including its scheduling.
+type CatalogChallenge struct {
+	Challenge
+	Active         bool       `json:"active"`
+	AvailableFrom  *time.Time `json:"available_from,omitempty"`
+	AvailableUntil *time.Time `json:"available_until,omitempty"`
+	ManagedByAdmin bool       `json:"managed_by_admin"`
+}
+
+type FeaturedChallenge struct {
+	Date      time.Time `json:"date"`
+	Slot      int       `json:"slot"`
+	Challenge Challenge `json:"challenge"`
+}
+
+type ChallengeAuditEntry struct {
+	ID          string          `json:"id"`
+	ChallengeID string          `json:"challenge_id"`
+	Action      string          `json:"action"`
+	Actor       string          `json:"actor"`
+	Details     json.RawMessage `json:"details,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// PeriodicChallenge runs for a whole week or month instead of a single day.
+type PeriodicChallenge struct {
+	ID         string              `json:"id"`
+	Text       string              `json:"text"`
+	Period     string              `json:"period"`
+	Metric     string              `json:"metric"`
+	Parameters ChallengeParameters `json:"parameters"`
+	Points     int                 `json:"points"`
+}
+
+type PeriodicChallengeProgress struct {
+	PeriodicChallenge
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	Progress    float64    `json:"progress"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
@@ -6,10 +6,81 @@ import (
 	"github.com/google/uuid"
 )
 
-type RegisterDTO struct {
-	Email    string `json:"email"`
-	Username string `json:"username"`
+type LoginDTO struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	// DeviceName labels the session in the session list, e.g. "Pixel 8"
+	DeviceName string `json:"device_name"`
+}
+
+type VerifyEmailDTO struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type PasswordResetRequestDTO struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type PasswordResetConfirmDTO struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ReauthenticationDTO confirms a sensitive account change with the current password.
+// Accounts without a password, signed up with an identity provider, send a code from
+// their authenticator app instead or nothing right after logging in.
+type ReauthenticationDTO struct {
 	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type TwoFactorCodeDTO struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginDTO completes a login with either a TOTP code or a recovery code.
+type TwoFactorLoginDTO struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+	DeviceName     string `json:"device_name"`
+}
+
+// CreatePersonalTokenDTO creates a personal access token, it expires after 90 days
+// unless ExpiresInDays says otherwise.
+type CreatePersonalTokenDTO struct {
+	Name          string   `json:"name" binding:"required,max=64"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+// CreateOAuthClientDTO registers a partner app. Confidential clients get a secret,
+// public ones only use PKCE.
+type CreateOAuthClientDTO struct {
+	Name         string   `json:"name" binding:"required,max=64"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,max=10"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+	Confidential bool     `json:"confidential"`
+}
+
+// OAuthAuthorizeDTO is the authorization request of a client, as passed on by the app
+// that shows the consent screen. Approve only matters when answering it.
+type OAuthAuthorizeDTO struct {
+	ResponseType        string `json:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+	Approve             bool   `json:"approve" form:"-"`
+}
+
+// RegisterDTO is checked further by the rules in the validation package.
+type RegisterDTO struct {
+	Email    string `json:"email" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
 type UpdateStepsDTO struct {
@@ -25,8 +96,49 @@ type GetImageDTO struct {
 }
 
 type CompleteChallengesDTO struct {
-	ChallengeID  uuid.UUID `json:"challenge_id" binding:"required"`
-	RocketPoints int       `json:"rocket_points" binding:"required"`
+	ChallengeID uuid.UUID `json:"challenge_id" binding:"required"`
+	// Value is the self-reported result, e.g. seconds held in a plank
+	Value *float64 `json:"value"`
+}
+
+type CreateDuelDTO struct {
+	OpponentName string   `json:"opponent_name" binding:"required"`
+	Type         string   `json:"type" binding:"required,oneof=most_steps first_to_distance"`
+	Target       *float64 `json:"target"`
+	DurationDays int      `json:"duration_days" binding:"required,min=1,max=30"`
+	Stake        int      `json:"stake" binding:"min=0"`
+}
+
+type CreateTeamDTO struct {
+	Name    string   `json:"name" binding:"required,max=64"`
+	Members []string `json:"members"`
+}
+
+type AddTeamMemberDTO struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type CreateTeamChallengeDTO struct {
+	Text         string  `json:"text" binding:"required"`
+	Metric       string  `json:"metric" binding:"required,oneof=steps run_distance"`
+	Target       float64 `json:"target" binding:"required,gt=0"`
+	DurationDays int     `json:"duration_days" binding:"required,min=1,max=31"`
+}
+
+type CatalogChallengeDTO struct {
+	Text           string               `json:"text" binding:"required"`
+	Points         int                  `json:"points" binding:"required,min=1"`
+	Category       string               `json:"category" binding:"required"`
+	Difficulty     string               `json:"difficulty" binding:"required"`
+	Verification   string               `json:"verification" binding:"required"`
+	Parameters     *ChallengeParameters `json:"parameters"`
+	Active         *bool                `json:"active"`
+	AvailableFrom  *string              `json:"available_from" binding:"omitempty,datetime=2006-01-02"`
+	AvailableUntil *string              `json:"available_until" binding:"omitempty,datetime=2006-01-02"`
+}
+
+type FeatureChallengeDTO struct {
+	ChallengeID uuid.UUID `json:"challenge_id" binding:"required"`
 }
 
 type StepStatistic struct {
@@ -89,3 +201,25 @@ type DailyChallengeProgress struct {
 	Completed int `json:"completed"`
 	Total     int `json:"total"`
 }
+
+type StreakDTO struct {
+	CurrentStreak int  `json:"current_streak"`
+	LongestStreak int  `json:"longest_streak"`
+	Freezes       int  `json:"streak_freezes"`
+	StepGoal      int  `json:"step_goal"`
+	StepsToday    int  `json:"steps_today"`
+	GoalMetToday  bool `json:"goal_met_today"`
+}
+
+type ShopItemDTO struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Category    string `json:"category" binding:"required,oneof=profile_frame streak_freeze challenge_slot badge"`
+	Price       int    `json:"price" binding:"min=0"`
+	MaxPerUser  *int   `json:"max_per_user" binding:"omitempty,min=1"`
+	Active      *bool  `json:"active"`
+}
+
+type UpdateRoleDTO struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
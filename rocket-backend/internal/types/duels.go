@@ -0,0 +1,42 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DuelMostSteps       = "most_steps"
+	DuelFirstToDistance = "first_to_distance"
+)
+
+const (
+	DuelPending   = "pending"
+	DuelDeclined  = "declined"
+	DuelExpired   = "expired"
+	DuelActive    = "active"
+	DuelFinished  = "finished"
+	DuelCancelled = "cancelled"
+)
+
+type Duel struct {
+	ID                 uuid.UUID  `json:"id"`
+	ChallengerID       uuid.UUID  `json:"challenger_id"`
+	ChallengerName     string     `json:"challenger_name"`
+	OpponentID         uuid.UUID  `json:"opponent_id"`
+	OpponentName       string     `json:"opponent_name"`
+	Type               string     `json:"type"`
+	Target             *float64   `json:"target,omitempty"`
+	DurationDays       int        `json:"duration_days"`
+	Stake              int        `json:"stake"`
+	Status             string     `json:"status"`
+	ChallengerProgress float64    `json:"challenger_progress"`
+	OpponentProgress   float64    `json:"opponent_progress"`
+	WinnerID           *uuid.UUID `json:"winner_id,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ExpiresAt          time.Time  `json:"expires_at"`
+	StartsAt           *time.Time `json:"starts_at,omitempty"`
+	EndsAt             *time.Time `json:"ends_at,omitempty"`
+	FinishedAt         *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+const (
+	JobHealthOK      = "ok"
+	JobHealthFailing = "failing"
+	JobHealthOverdue = "overdue"
+)
+
+type JobRun struct {
+	ID           uuid.UUID  `json:"id"`
+	JobName      string     `json:"job_name"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	Instance     string     `json:"instance"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+type JobHealth struct {
+	Name          string     `json:"name"`
+	Schedule      string     `json:"schedule"`
+	Status        string     `json:"status"`
+	LastRun       *JobRun    `json:"last_run,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	NextRunAt     time.Time  `json:"next_run_at"`
+}
@@ -1,18 +1,43 @@
 package types
 
 import (
+	"encoding/json"
 	"github.com/google/uuid"
 	"time"
 )
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders the roles, every role includes the permissions of the ones below it
+var roleRanks = map[string]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+// HasRole reports whether role grants at least the permissions of required.
+func HasRole(role, required string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
 type Credentials struct {
 	ID        uuid.UUID `json:"id"`
 	Email     string    `json:"email"`
 	Password  string    `json:"password"`
 	CreatedAt string    `json:"created_at"`
 	LastLogin string    `json:"last_login"`
+	Role      string    `json:"-"`
+	// TokenVersion is embedded in access tokens, bumping it revokes all of them
+	TokenVersion  int  `json:"-"`
+	EmailVerified bool `json:"-"`
 }
 
+// Purposes of the single-use tokens sent by email
+const (
+	EmailTokenVerify = "verify_email"
+	EmailTokenReset  = "reset_password"
+)
+
 type User struct {
 	ID           uuid.UUID `json:"id"`
 	Username     string    `json:"username"`
@@ -20,6 +45,147 @@ type User struct {
 	RocketPoints int       `json:"rocket_points"`
 }
 
+// Session is one login of a user, i.e. one device.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"-"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// TwoFactor is the two-factor authentication state of a user.
+type TwoFactor struct {
+	UserID            uuid.UUID  `json:"-"`
+	Secret            string     `json:"-"`
+	PendingSecret     string     `json:"-"`
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep      int64      `json:"-"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// Scopes of personal access tokens and OAuth clients, each opens a few routes
+const (
+	ScopeStepsRead      = "steps:read"
+	ScopeStepsWrite     = "steps:write"
+	ScopeRunsRead       = "runs:read"
+	ScopeRunsWrite      = "runs:write"
+	ScopeProfileRead    = "profile:read"
+	ScopeChallengesRead = "challenges:read"
+)
+
+var TokenScopes = []string{ScopeStepsRead, ScopeStepsWrite, ScopeRunsRead, ScopeRunsWrite, ScopeProfileRead, ScopeChallengesRead}
+
+// PersonalAccessToken is a token a user created for scripts and integrations.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// OAuthClient is a partner app that may ask users for access to their data.
+type OAuthClient struct {
+	ID           string     `json:"client_id"`
+	Name         string     `json:"name"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Scopes       []string   `json:"scopes"`
+	Confidential bool       `json:"confidential"`
+	SecretHash   string     `json:"-"`
+	CreatedBy    *uuid.UUID `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// OAuthConsent is what a user allowed a client.
+type OAuthConsent struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// OAuthCode is an authorization code waiting to be exchanged for tokens.
+type OAuthCode struct {
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+}
+
+// OAuthToken is a grant of a client, its access and refresh token are only stored hashed.
+type OAuthToken struct {
+	ID               uuid.UUID
+	ClientID         string
+	UserID           uuid.UUID
+	Scopes           []string
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+}
+
+// Statuses of a data export
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+// DataExport is an archive of everything stored about a user, built in the background.
+type DataExport struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	Status     string     `json:"status"`
+	Size       int        `json:"size,omitempty"` // Of the archive in bytes, once ready
+	Attempts   int        `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	ExpiresAt  *time.Time `json:"expires_at"` // Of the download link
+}
+
+// ExportSection is one kind of data of a user as JSON array of flat records.
+type ExportSection struct {
+	Name    string
+	Records json.RawMessage
+}
+
+// Kinds of user blocks. Blocked users cannot interact with the blocker at all, muted
+// ones are only hidden from the muter's feeds.
+const (
+	BlockKindBlock = "block"
+	BlockKindMute  = "mute"
+)
+
+// BlockedUser is a user someone blocked or muted.
+type BlockedUser struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCState is a login through an identity provider waiting for its callback.
+type OIDCState struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	DeviceName   string
+}
+
+// UserWithRole is a user as seen by admins.
+type UserWithRole struct {
+	User
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Settings struct {
 	ID       uuid.UUID `json:"id"`
 	UserId   uuid.UUID `json:"user_id"`
@@ -46,3 +212,63 @@ type ActivityWithUser struct {
 	Time    time.Time `json:"time"`
 	Message string    `json:"message"`
 }
+
+type Streak struct {
+	UserID        uuid.UUID `json:"user_id"`
+	CurrentStreak int       `json:"current_streak"`
+	LongestStreak int       `json:"longest_streak"`
+	Freezes       int       `json:"freezes"`
+	LastEvaluated time.Time `json:"last_evaluated"`
+}
+
+const (
+	ItemCategoryProfileFrame  = "profile_frame"
+	ItemCategoryStreakFreeze  = "streak_freeze"
+	ItemCategoryChallengeSlot = "challenge_slot"
+	ItemCategoryBadge         = "badge"
+)
+
+type ShopItem struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	Price       int       `json:"price"`
+	MaxPerUser  *int      `json:"max_per_user"`
+	Active      bool      `json:"active"`
+}
+
+type InventoryItem struct {
+	Item       ShopItem  `json:"item"`
+	Quantity   int       `json:"quantity"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+type Purchase struct {
+	ID          uuid.UUID `json:"id"`
+	ItemID      uuid.UUID `json:"item_id"`
+	ItemName    string    `json:"item_name"`
+	Price       int       `json:"price"`
+	PurchasedAt time.Time `json:"purchased_at"`
+}
+
+type ChallengeHistoryEntry struct {
+	ChallengeID string    `json:"challenge_id"`
+	Category    string    `json:"category"`
+	Date        time.Time `json:"date"`
+	Completed   bool      `json:"completed"`
+}
+
+type ChallengeInvitation struct {
+	ID            uuid.UUID  `json:"id"`
+	InviterID     uuid.UUID  `json:"inviter_id"`
+	InviterName   string     `json:"inviter_name"`
+	InviteeID     uuid.UUID  `json:"invitee_id"`
+	InviteeName   string     `json:"invitee_name"`
+	ChallengeID   uuid.UUID  `json:"challenge_id"`
+	ChallengeText string     `json:"challenge_text"`
+	Date          time.Time  `json:"date"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TeamChallengeActive    = "active"
+	TeamChallengeCompleted = "completed"
+	TeamChallengeExpired   = "expired"
+)
+
+type Team struct {
+	ID        uuid.UUID    `json:"id"`
+	Name      string       `json:"name"`
+	OwnerID   uuid.UUID    `json:"owner_id"`
+	Members   []TeamMember `json:"members"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type TeamMember struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type TeamChallenge struct {
+	ID            uuid.UUID           `json:"id"`
+	TeamID        uuid.UUID           `json:"team_id"`
+	Text          string              `json:"text"`
+	Metric        string              `json:"metric"`
+	Parameters    ChallengeParameters `json:"parameters"`
+	Points        int                 `json:"points"`
+	StartsOn      time.Time           `json:"starts_on"`
+	EndsOn        time.Time           `json:"ends_on"`
+	Status        string              `json:"status"`
+	CompletedAt   *time.Time          `json:"completed_at,omitempty"`
+	Progress      float64             `json:"progress"`
+	Contributions []TeamContribution  `json:"contributions"`
+}
+
+// TeamChallengeMember is a user a team challenge was set for. Baseline is what they
+// recorded on the first day before the challenge was set.
+type TeamChallengeMember struct {
+	UserID   uuid.UUID
+	Username string
+	Baseline float64
+}
+
+// TeamContribution is one member's share of a team challenge's progress.
+type TeamContribution struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Amount   float64   `json:"amount"`
+}
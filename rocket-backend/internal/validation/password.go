@@ -0,0 +1,98 @@
+package validation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bcrypt ignores everything after 72 bytes
+const PasswordMaxBytes = 72
+
+// PasswordPolicy decides which new passwords are accepted.
+type PasswordPolicy struct {
+	MinLength int
+	// Lower case passwords known from data breaches, nil skips the check
+	breached map[string]bool
+}
+
+// NewPasswordPolicy returns a policy refusing passwords shorter than minLength and
+// the given breached passwords, compared without case.
+func NewPasswordPolicy(minLength int, breached []string) PasswordPolicy {
+	policy := PasswordPolicy{MinLength: minLength}
+	if len(breached) > 0 {
+		policy.breached = make(map[string]bool, len(breached))
+		for _, password := range breached {
+			policy.breached[strings.ToLower(password)] = true
+		}
+	}
+	return policy
+}
+
+// PasswordPolicyFromEnv loads the breached passwords from BREACHED_PASSWORDS_FILE,
+// one per line, or from the list shipped in internal/validation when it is unset.
+func PasswordPolicyFromEnv() (PasswordPolicy, error) {
+	path := os.Getenv("BREACHED_PASSWORDS_FILE")
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return PasswordPolicy{}, err
+		}
+		path = filepath.Join(wd, "internal", "validation", "breached_passwords.txt")
+	}
+	breached, err := LoadBreachedPasswords(path)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+	return NewPasswordPolicy(8, breached), nil
+}
+
+// LoadBreachedPasswords reads a password list with one password per line. Empty lines
+// and lines starting with # are skipped.
+func LoadBreachedPasswords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	var passwords []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords = append(passwords, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return passwords, nil
+}
+
+// Check returns why the password is not accepted, nil if it is. personal holds the
+// username and email of the account, the password must not just repeat them.
+func (p PasswordPolicy) Check(password string, personal ...string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("must be at least %d characters", p.MinLength)
+	}
+	if len(password) > PasswordMaxBytes {
+		return fmt.Errorf("must be at most %d bytes", PasswordMaxBytes)
+	}
+	lower := strings.ToLower(password)
+	if p.breached[lower] {
+		return errors.New("is too common, it appeared in a data breach")
+	}
+	for _, value := range personal {
+		value = strings.ToLower(value)
+		local, _, _ := strings.Cut(value, "@")
+		if value != "" && (lower == value || lower == local) {
+			return errors.New("must not be your username or email")
+		}
+	}
+	return nil
+}
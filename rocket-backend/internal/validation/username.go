@@ -0,0 +1,65 @@
+package validation
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 32
+)
+
+// Letters, digits, dots, dashes and underscores, starting and ending with a letter or digit
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+
+// Names that could pass for the app or its staff in chats and rankings
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "support": true,
+	"help": true, "moderator": true, "mod": true, "staff": true, "team": true,
+	"rocket": true, "official": true, "security": true, "api": true, "null": true,
+	"undefined": true, "anonymous": true, "deleted": true, "me": true, "you": true,
+}
+
+// NormalizeUsername checks the username rules and returns it trimmed. The case is
+// kept for display, uniqueness is checked without it.
+func NormalizeUsername(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) < UsernameMinLength || len(name) > UsernameMaxLength {
+		return "", errors.New("must be 3 to 32 characters")
+	}
+	if !usernamePattern.MatchString(name) {
+		return "", errors.New("may only contain letters, digits, '.', '-' and '_' and must start and end with a letter or digit")
+	}
+	if strings.Contains(name, "..") {
+		return "", errors.New("must not contain '..'")
+	}
+	if reservedUsernames[strings.ToLower(name)] {
+		return "", errors.New("is reserved")
+	}
+	return name, nil
+}
+
+// SanitizeUsername derives a valid username from free text like a display name, it
+// returns "" when nothing usable is left.
+func SanitizeUsername(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	name := strings.Trim(b.String(), ".-_")
+	for strings.Contains(name, "..") {
+		name = strings.ReplaceAll(name, "..", ".")
+	}
+	if len(name) > UsernameMaxLength-4 {
+		// Leaves room for the digits added when the name is taken
+		name = strings.TrimRight(name[:UsernameMaxLength-4], ".-_")
+	}
+	if _, err := NormalizeUsername(name); err != nil {
+		return ""
+	}
+	return name
+}
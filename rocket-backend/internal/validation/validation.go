@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldErrors maps JSON fields of a request to what is wrong with them.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field, message := range e {
+		fields = append(fields, field+" "+message)
+	}
+	sort.Strings(fields)
+	return "invalid " + strings.Join(fields, ", ")
+}
+
+// Err returns nil when no field failed, so callers can return it directly.
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func init() {
+	// Name fields like the JSON the client sent, not like the Go struct
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// BindingErrors turns the errors of gin's request binding into field errors. Bodies
+// that are not JSON at all give nil.
+func BindingErrors(err error) FieldErrors {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	fields := FieldErrors{}
+	for _, fe := range invalid {
+		fields[fe.Field()] = bindingMessage(fe)
+	}
+	return fields
+}
+
+func bindingMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	default:
+		return "is invalid"
+	}
+}
+
+// NormalizeEmail checks the syntax of a bare address like "jane@example.com" and
+// returns it trimmed and in lower case, the form emails are stored and compared in.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if len(email) > 254 {
+		return "", errors.New("must be at most 254 characters")
+	}
+	// ParseAddress also accepts "Jane <jane@example.com>", only the address itself is wanted
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", errors.New("must be a valid email address")
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", errors.New("must be a valid email address")
+	}
+	return email, nil
+}
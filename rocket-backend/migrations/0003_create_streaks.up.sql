@@ -0,0 +1,9 @@
+CREATE TABLE streaks (
+    user_id UUID PRIMARY KEY,
+    current_streak INT NOT NULL DEFAULT 0,
+    longest_streak INT NOT NULL DEFAULT 0,
+    freezes INT NOT NULL DEFAULT 0,
+    last_evaluated DATE NOT NULL, -- Last fully evaluated day, today is never evaluated
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CHECK (freezes >= 0)
+);
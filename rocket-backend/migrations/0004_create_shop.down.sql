@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS shop_purchases CASCADE;
+DROP TABLE IF EXISTS user_inventory CASCADE;
+DROP TABLE IF EXISTS shop_items CASCADE;
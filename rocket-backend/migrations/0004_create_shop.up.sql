@@ -0,0 +1,39 @@
+CREATE TABLE shop_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    category VARCHAR(32) NOT NULL,
+    price INT NOT NULL,
+    max_per_user INT, -- NULL means unlimited
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (category IN ('profile_frame', 'streak_freeze', 'challenge_slot', 'badge')),
+    CHECK (price >= 0)
+);
+
+CREATE TABLE user_inventory (
+    user_id UUID NOT NULL,
+    item_id UUID NOT NULL,
+    quantity INT NOT NULL DEFAULT 1,
+    acquired_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, item_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES shop_items (id) ON DELETE CASCADE
+);
+
+CREATE TABLE shop_purchases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    user_id UUID NOT NULL,
+    item_id UUID NOT NULL,
+    price INT NOT NULL, -- Price paid at the time of purchase
+    purchased_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES shop_items (id) ON DELETE CASCADE
+);
+
+INSERT INTO shop_items (name, description, category, price, max_per_user) VALUES
+    ('Streak Freeze', 'Keeps your step goal streak alive for one missed day', 'streak_freeze', 500, 2),
+    ('Golden Frame', 'A shiny golden frame for your profile picture', 'profile_frame', 2000, 1),
+    ('Rocket Frame', 'A frame with rockets circling your profile picture', 'profile_frame', 3500, 1),
+    ('Extra Challenge Slot', 'Adds one more custom challenge slot', 'challenge_slot', 1500, 3),
+    ('Early Adopter Badge', 'Show everyone you were here first', 'badge', 1000, 1);
@@ -0,0 +1,4 @@
+ALTER TABLE challenges
+    DROP CONSTRAINT IF EXISTS challenges_verification_type_check,
+    DROP COLUMN IF EXISTS target_value,
+    DROP COLUMN IF EXISTS verification_type;
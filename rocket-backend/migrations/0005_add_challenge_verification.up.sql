@@ -0,0 +1,11 @@
+ALTER TABLE challenges
+    ADD COLUMN verification_type VARCHAR(32) NOT NULL DEFAULT 'self_report',
+    ADD COLUMN target_value REAL,
+    ADD CONSTRAINT challenges_verification_type_check CHECK (verification_type IN ('steps', 'run_distance', 'self_report'));
+
+-- Give the already loaded catalog its machine-checkable criteria
+UPDATE challenges SET verification_type = 'steps', target_value = 10000 WHERE id = '550e8400-e29b-41d4-a716-446655440001';
+UPDATE challenges SET target_value = 60 WHERE id = '550e8400-e29b-41d4-a716-446655440007';
+UPDATE challenges SET target_value = 120 WHERE id = '550e8400-e29b-41d4-a716-44665544002a';
+UPDATE challenges SET target_value = 30 WHERE id = '550e8400-e29b-41d4-a716-446655440033';
+UPDATE challenges SET target_value = 60 WHERE id = '550e8400-e29b-41d4-a716-446655440036';
@@ -0,0 +1,7 @@
+ALTER TABLE challenges
+    DROP CONSTRAINT IF EXISTS challenges_target_check,
+    DROP CONSTRAINT IF EXISTS challenges_difficulty_check,
+    DROP CONSTRAINT IF EXISTS challenges_category_check,
+    DROP COLUMN IF EXISTS target_unit,
+    DROP COLUMN IF EXISTS difficulty,
+    DROP COLUMN IF EXISTS category;
@@ -0,0 +1,19 @@
+ALTER TABLE challenges
+    ADD COLUMN category VARCHAR(32) NOT NULL DEFAULT 'strength',
+    ADD COLUMN difficulty VARCHAR(16) NOT NULL DEFAULT 'medium',
+    ADD COLUMN target_unit VARCHAR(32),
+    ADD CONSTRAINT challenges_category_check CHECK (category IN ('steps', 'running', 'strength', 'mindfulness', 'nutrition')),
+    ADD CONSTRAINT challenges_difficulty_check CHECK (difficulty IN ('easy', 'medium', 'hard')),
+    ADD CONSTRAINT challenges_target_check CHECK ((target_value IS NULL) = (target_unit IS NULL));
+
+-- Backfill existing rows, challenges.json is synced over them on the next start
+UPDATE challenges SET target_unit = 'steps' WHERE verification_type = 'steps' AND target_value IS NOT NULL;
+UPDATE challenges SET target_unit = 'km' WHERE verification_type = 'run_distance' AND target_value IS NOT NULL;
+UPDATE challenges SET target_unit = 'seconds' WHERE verification_type = 'self_report' AND target_value IS NOT NULL;
+UPDATE challenges SET category = 'steps' WHERE verification_type = 'steps';
+UPDATE challenges SET category = 'running' WHERE verification_type = 'run_distance';
+UPDATE challenges SET difficulty = CASE
+    WHEN points_reward <= 20 THEN 'easy'
+    WHEN points_reward <= 30 THEN 'medium'
+    ELSE 'hard'
+END;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_periodic_challenges CASCADE;
+DROP TABLE IF EXISTS periodic_challenges CASCADE;
@@ -0,0 +1,37 @@
+-- Challenges spanning a whole week or month. They live next to the daily
+-- challenges so the daily rollover never touches them.
+CREATE TABLE periodic_challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    description TEXT NOT NULL,
+    period VARCHAR(16) NOT NULL,
+    metric VARCHAR(32) NOT NULL,
+    target_value REAL NOT NULL,
+    target_unit VARCHAR(16) NOT NULL,
+    points_reward INT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    CHECK (period IN ('weekly', 'monthly')),
+    CHECK (metric IN ('steps', 'run_distance', 'step_goal_days')),
+    CHECK (target_value > 0),
+    CHECK (points_reward > 0)
+);
+
+CREATE TABLE user_periodic_challenges (
+    user_id UUID NOT NULL,
+    challenge_id UUID NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    progress REAL NOT NULL DEFAULT 0,
+    is_completed BOOLEAN NOT NULL DEFAULT FALSE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (user_id, challenge_id, period_start),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (challenge_id) REFERENCES periodic_challenges (id) ON DELETE CASCADE,
+    CHECK (period_end >= period_start)
+);
+
+INSERT INTO periodic_challenges (id, description, period, metric, target_value, target_unit, points_reward)
+VALUES
+    ('b1a7c2d4-0000-4000-8000-000000000001', 'Run 30 km this week', 'weekly', 'run_distance', 30, 'km', 150),
+    ('b1a7c2d4-0000-4000-8000-000000000002', 'Walk 70000 steps this week', 'weekly', 'steps', 70000, 'steps', 100),
+    ('b1a7c2d4-0000-4000-8000-000000000003', 'Hit your step goal on 20 days this month', 'monthly', 'step_goal_days', 20, 'days', 300),
+    ('b1a7c2d4-0000-4000-8000-000000000004', 'Run 100 km this month', 'monthly', 'run_distance', 100, 'km', 400);
@@ -0,0 +1,29 @@
+CREATE TABLE duels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    challenger_id UUID NOT NULL,
+    opponent_id UUID NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    target_value REAL, -- Distance in km for first_to_distance, unused for most_steps
+    duration_days INT NOT NULL,
+    stake INT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    challenger_progress REAL NOT NULL DEFAULT 0, -- Final results, written when the duel is settled
+    opponent_progress REAL NOT NULL DEFAULT 0,
+    winner_id UUID, -- NULL for a draw
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (challenger_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (opponent_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (winner_id) REFERENCES users (id) ON DELETE SET NULL,
+    CHECK (challenger_id <> opponent_id),
+    CHECK (type IN ('most_steps', 'first_to_distance')),
+    CHECK (type <> 'first_to_distance' OR target_value > 0),
+    CHECK (status IN ('pending', 'declined', 'active', 'finished')),
+    CHECK (duration_days > 0),
+    CHECK (stake >= 0)
+);
+
+CREATE INDEX idx_duels_challenger ON duels (challenger_id, status);
+CREATE INDEX idx_duels_opponent ON duels (opponent_id, status);
@@ -0,0 +1,18 @@
+CREATE TABLE challenge_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    inviter_id UUID NOT NULL,
+    invitee_id UUID NOT NULL,
+    challenge_id UUID NOT NULL,
+    date DATE NOT NULL DEFAULT CURRENT_DATE, -- The day the challenge is shared for, invites expire afterwards
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    responded_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (inviter_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (invitee_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (challenge_id) REFERENCES challenges (id) ON DELETE CASCADE,
+    CHECK (inviter_id <> invitee_id),
+    CHECK (status IN ('pending', 'accepted', 'declined', 'expired')),
+    UNIQUE (inviter_id, invitee_id, challenge_id, date)
+);
+
+CREATE INDEX idx_challenge_invitations_invitee ON challenge_invitations (invitee_id, status);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS team_challenges CASCADE;
+DROP TABLE IF EXISTS team_members CASCADE;
+DROP TABLE IF EXISTS teams CASCADE;
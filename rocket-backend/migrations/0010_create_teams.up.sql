@@ -0,0 +1,37 @@
+CREATE TABLE teams (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    name VARCHAR(64) NOT NULL,
+    owner_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE team_members (
+    team_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    joined_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id),
+    FOREIGN KEY (team_id) REFERENCES teams (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE team_challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    team_id UUID NOT NULL,
+    description TEXT NOT NULL,
+    metric VARCHAR(32) NOT NULL,
+    target_value REAL NOT NULL,
+    target_unit VARCHAR(16) NOT NULL,
+    points_reward INT NOT NULL, -- Awarded to every member on completion
+    starts_on DATE NOT NULL DEFAULT CURRENT_DATE,
+    ends_on DATE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (team_id) REFERENCES teams (id) ON DELETE CASCADE,
+    CHECK (metric IN ('steps', 'run_distance')),
+    CHECK (target_value > 0),
+    CHECK (points_reward >= 0),
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX idx_team_members_user ON team_members (user_id);
+CREATE INDEX idx_team_challenges_team ON team_challenges (team_id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS challenge_audit_log CASCADE;
+DROP TABLE IF EXISTS featured_challenges CASCADE;
+
+ALTER TABLE challenges
+    DROP CONSTRAINT IF EXISTS challenges_availability_check,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS managed_by_admin,
+    DROP COLUMN IF EXISTS available_until,
+    DROP COLUMN IF EXISTS available_from,
+    DROP COLUMN IF EXISTS active;
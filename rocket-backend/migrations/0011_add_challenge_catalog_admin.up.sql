@@ -0,0 +1,33 @@
+-- Challenges edited through the admin API are no longer overwritten by challenges.json
+ALTER TABLE challenges
+    ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN available_from DATE,
+    ADD COLUMN available_until DATE,
+    ADD COLUMN managed_by_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    ADD CONSTRAINT challenges_availability_check CHECK (
+        available_from IS NULL OR available_until IS NULL OR available_until >= available_from
+    );
+
+-- "Challenge of the day" slots, handed out to every user on that day
+CREATE TABLE featured_challenges (
+    date DATE NOT NULL,
+    slot SMALLINT NOT NULL,
+    challenge_id UUID NOT NULL,
+    PRIMARY KEY (date, slot),
+    UNIQUE (date, challenge_id),
+    FOREIGN KEY (challenge_id) REFERENCES challenges (id) ON DELETE CASCADE,
+    CHECK (slot BETWEEN 1 AND 3)
+);
+
+CREATE TABLE challenge_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    challenge_id UUID NOT NULL, -- No foreign key, the log outlives deleted challenges
+    action VARCHAR(16) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    details JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (action IN ('create', 'update', 'retire', 'feature', 'unfeature'))
+);
+
+CREATE INDEX idx_challenge_audit_log_challenge ON challenge_audit_log (challenge_id, created_at);
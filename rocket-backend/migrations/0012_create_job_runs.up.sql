@@ -0,0 +1,14 @@
+CREATE TABLE job_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    job_name VARCHAR(64) NOT NULL,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    instance VARCHAR(255) NOT NULL, -- Replica that executed the run
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (job_name, scheduled_for), -- Every slot runs once across all replicas
+    CHECK (status IN ('running', 'succeeded', 'failed'))
+);
+
+CREATE INDEX idx_job_runs_job_started ON job_runs (job_name, started_at DESC);
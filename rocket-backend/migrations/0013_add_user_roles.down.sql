@@ -0,0 +1,3 @@
+ALTER TABLE credentials
+    DROP CONSTRAINT IF EXISTS credentials_role_check,
+    DROP COLUMN IF EXISTS role;
@@ -0,0 +1,3 @@
+ALTER TABLE credentials
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+    ADD CONSTRAINT credentials_role_check CHECK (role IN ('user', 'moderator', 'admin'));
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS revoked_tokens CASCADE;
+DROP TABLE IF EXISTS refresh_tokens CASCADE;
+ALTER TABLE credentials DROP COLUMN IF EXISTS token_version;
@@ -0,0 +1,24 @@
+-- Bumped to invalidate every access token of a user at once
+ALTER TABLE credentials ADD COLUMN token_version INT NOT NULL DEFAULT 0;
+
+CREATE TABLE refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    user_id UUID NOT NULL,
+    family_id UUID NOT NULL, -- All tokens rotated from the same login
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    replaced_by UUID,
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE
+);
+
+CREATE TABLE revoked_tokens (
+    token_id UUID PRIMARY KEY, -- jti of the access token
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- The entry is useless once the token expired
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens (user_id);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
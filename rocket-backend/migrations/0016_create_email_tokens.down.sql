@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS email_tokens;
+
+ALTER TABLE credentials DROP COLUMN IF EXISTS email_verified;
@@ -0,0 +1,15 @@
+ALTER TABLE credentials ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE email_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    user_id UUID NOT NULL,
+    purpose VARCHAR(16) NOT NULL CHECK (purpose IN ('verify_email', 'reset_password')),
+    email VARCHAR(255) NOT NULL, -- Address the token was sent to
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_email_tokens_user ON email_tokens (user_id, purpose);
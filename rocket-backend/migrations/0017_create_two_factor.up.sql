@@ -0,0 +1,18 @@
+CREATE TABLE two_factor (
+    user_id UUID PRIMARY KEY,
+    secret VARCHAR(64), -- Active TOTP secret, NULL until the first enrollment is confirmed
+    pending_secret VARCHAR(64), -- Secret of an enrollment that still needs a code to confirm it
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT NOT NULL DEFAULT 0, -- Time step of the last accepted code, against replays
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    user_id UUID NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes (user_id);
@@ -0,0 +1,22 @@
+-- Accounts created through a provider have no password until they reset it
+CREATE TABLE oidc_identities (
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL, -- The provider's ID of the user
+    user_id UUID NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_oidc_identities_user ON oidc_identities (user_id);
+
+-- Login attempts between the redirect to the provider and the callback
+CREATE TABLE oidc_states (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    device_name VARCHAR(64) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_credentials_email_lower;
+DROP INDEX IF EXISTS idx_users_username_lower;
@@ -0,0 +1,8 @@
+-- Usernames and emails are unique regardless of case, which also serves the lookups
+-- by LOWER(...). Accounts that only differ in case must be merged or renamed first.
+CREATE UNIQUE INDEX idx_users_username_lower ON users (LOWER(username));
+CREATE UNIQUE INDEX idx_credentials_email_lower ON credentials (LOWER(email));
+
+-- New emails are stored in lower case, bring the existing ones in line
+UPDATE credentials SET email = LOWER(email) WHERE email <> LOWER(email);
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
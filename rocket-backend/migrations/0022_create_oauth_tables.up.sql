@@ -0,0 +1,54 @@
+-- Partner apps that may ask users for access, registered by admins. Public clients
+-- (e.g. watch apps, which cannot keep a secret) have no secret and rely on PKCE alone.
+CREATE TABLE oauth_clients (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    secret_hash VARCHAR(64),
+    redirect_uris TEXT NOT NULL, -- Space separated, matched exactly
+    scopes TEXT NOT NULL, -- Space separated, the most the client may ask for
+    created_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES credentials (id) ON DELETE SET NULL
+);
+
+-- What a user allowed a client, asking again is only needed for more scopes
+CREATE TABLE oauth_consents (
+    user_id UUID NOT NULL,
+    client_id VARCHAR(64) NOT NULL,
+    scopes TEXT NOT NULL,
+    granted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, client_id),
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients (id) ON DELETE CASCADE
+);
+
+-- Short lived and used once, deleted when exchanged
+CREATE TABLE oauth_authorization_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,
+    user_id UUID NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients (id) ON DELETE CASCADE
+);
+
+-- One row per grant, both hashes are replaced when the refresh token is used
+CREATE TABLE oauth_tokens (
+    id UUID PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,
+    user_id UUID NOT NULL,
+    scopes TEXT NOT NULL,
+    access_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    access_expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    refresh_expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_oauth_tokens_user_client ON oauth_tokens (user_id, client_id);
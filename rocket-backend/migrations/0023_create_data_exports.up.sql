@@ -0,0 +1,20 @@
+-- Archives of everything stored about a user, kept until their download link expires
+CREATE TABLE data_exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- Of the download link
+    archive BYTEA, -- The ZIP file, set once it is ready
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE, -- Of the latest attempt, stale ones are retried
+    finished_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    FOREIGN KEY (user_id) REFERENCES credentials (id) ON DELETE CASCADE,
+    CHECK (status IN ('pending', 'ready', 'failed'))
+);
+
+-- Users get at most one export built at a time
+CREATE UNIQUE INDEX idx_data_exports_pending ON data_exports (user_id) WHERE status = 'pending';
+CREATE INDEX idx_data_exports_user ON data_exports (user_id, created_at DESC);
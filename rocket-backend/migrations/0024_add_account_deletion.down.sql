@@ -0,0 +1,15 @@
+-- Messages without an author cannot be kept
+DELETE FROM chat_messages WHERE user_id IS NULL;
+ALTER TABLE chat_messages
+    ALTER COLUMN user_id SET NOT NULL,
+    DROP CONSTRAINT chat_messages_user_id_fkey,
+    ADD CONSTRAINT chat_messages_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE users
+    DROP CONSTRAINT users_id_fkey,
+    ADD CONSTRAINT users_id_fkey FOREIGN KEY (id) REFERENCES credentials (id);
+
+DROP INDEX IF EXISTS idx_credentials_erase_after;
+ALTER TABLE credentials
+    DROP COLUMN erase_after,
+    DROP COLUMN deactivated_at;
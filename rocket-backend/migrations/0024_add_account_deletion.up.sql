@@ -0,0 +1,31 @@
+-- Deleted accounts are deactivated first and erased once their grace period is over
+ALTER TABLE credentials
+    ADD COLUMN deactivated_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN erase_after TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_credentials_erase_after ON credentials (erase_after) WHERE erase_after IS NOT NULL;
+
+-- Erasing the credentials takes the profile and everything hanging off it along
+ALTER TABLE users
+    DROP CONSTRAINT users_id_fkey,
+    ADD CONSTRAINT users_id_fkey FOREIGN KEY (id) REFERENCES credentials (id) ON DELETE CASCADE;
+
+-- activities was created with a second foreign key to users without ON DELETE CASCADE,
+-- which made deleting anyone with activities fail
+DO $$
+DECLARE
+    c RECORD;
+BEGIN
+    FOR c IN
+        SELECT conname FROM pg_constraint
+        WHERE conrelid = 'activities'::regclass AND contype = 'f' AND confdeltype <> 'c'
+    LOOP
+        EXECUTE 'ALTER TABLE activities DROP CONSTRAINT ' || quote_ident(c.conname);
+    END LOOP;
+END $$;
+
+-- Messages of erased users stay in the chat without an author
+ALTER TABLE chat_messages
+    ALTER COLUMN user_id DROP NOT NULL,
+    DROP CONSTRAINT chat_messages_user_id_fkey,
+    ADD CONSTRAINT chat_messages_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL;
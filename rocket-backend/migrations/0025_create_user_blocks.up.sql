@@ -0,0 +1,15 @@
+-- A user either blocks or mutes another one, blocking a muted user replaces the mute
+CREATE TABLE user_blocks (
+    user_id UUID NOT NULL,
+    blocked_id UUID NOT NULL,
+    kind VARCHAR(8) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, blocked_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users (id) ON DELETE CASCADE,
+    CHECK (user_id <> blocked_id),
+    CHECK (kind IN ('block', 'mute'))
+);
+
+-- Chat broadcasts look up who hides an author
+CREATE INDEX idx_user_blocks_blocked ON user_blocks (blocked_id);
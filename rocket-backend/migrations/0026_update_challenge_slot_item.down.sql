@@ -0,0 +1,3 @@
+UPDATE shop_items
+SET description = 'Adds one more custom challenge slot'
+WHERE category = 'challenge_slot' AND description = 'Adds one more daily challenge, starting the day after purchase';
@@ -0,0 +1,4 @@
+-- Extra challenge slots add a daily challenge from the next day on
+UPDATE shop_items
+SET description = 'Adds one more daily challenge, starting the day after purchase'
+WHERE category = 'challenge_slot' AND description = 'Adds one more custom challenge slot';
@@ -0,0 +1,6 @@
+UPDATE duels SET status = 'declined' WHERE status = 'expired';
+
+ALTER TABLE duels DROP CONSTRAINT duels_status_check;
+ALTER TABLE duels ADD CONSTRAINT duels_status_check CHECK (status IN ('pending', 'declined', 'active', 'finished'));
+
+ALTER TABLE duels DROP COLUMN expires_at;
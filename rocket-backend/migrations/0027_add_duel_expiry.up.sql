@@ -0,0 +1,9 @@
+-- Pending duels expire when the opponent does not respond in time
+ALTER TABLE duels ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE duels SET expires_at = created_at + INTERVAL '3 days';
+
+ALTER TABLE duels ALTER COLUMN expires_at SET NOT NULL;
+
+ALTER TABLE duels DROP CONSTRAINT duels_status_check;
+ALTER TABLE duels ADD CONSTRAINT duels_status_check CHECK (status IN ('pending', 'declined', 'expired', 'active', 'finished'));
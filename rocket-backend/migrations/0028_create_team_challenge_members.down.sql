@@ -0,0 +1 @@
+DROP TABLE IF EXISTS team_challenge_members CASCADE;
@@ -0,0 +1,17 @@
+-- The members a team challenge was set for, they alone contribute and get the reward
+CREATE TABLE team_challenge_members (
+    challenge_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    baseline REAL NOT NULL DEFAULT 0, -- Recorded on the first day before the challenge was set, does not count
+    points_awarded INT, -- NULL until the challenge is completed, may be cut by the weekly limit
+    PRIMARY KEY (challenge_id, user_id),
+    FOREIGN KEY (challenge_id) REFERENCES team_challenges (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_challenge_members_user ON team_challenge_members (user_id);
+
+INSERT INTO team_challenge_members (challenge_id, user_id, points_awarded)
+SELECT c.id, m.user_id, CASE WHEN c.completed_at IS NOT NULL THEN c.points_reward END
+FROM team_challenges c
+JOIN team_members m ON m.team_id = c.team_id;
@@ -0,0 +1,4 @@
+UPDATE duels SET status = 'finished' WHERE status = 'cancelled';
+
+ALTER TABLE duels DROP CONSTRAINT duels_status_check;
+ALTER TABLE duels ADD CONSTRAINT duels_status_check CHECK (status IN ('pending', 'declined', 'expired', 'active', 'finished'));
@@ -0,0 +1,4 @@
+-- Active duels are cancelled with both stakes refunded when one user blocks the other
+-- or erases their account
+ALTER TABLE duels DROP CONSTRAINT duels_status_check;
+ALTER TABLE duels ADD CONSTRAINT duels_status_check CHECK (status IN ('pending', 'declined', 'expired', 'active', 'finished', 'cancelled'));
@@ -1,49 +1,116 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 type AuthService struct {
-	JwtSecret string
+	keys *KeySet
 }
 
+// NewAuthService returns an AuthService signing with a HS256 secret and the default
+// issuer and audience, NewAuthServiceWithKeys allows other keys.
 func NewAuthService(jwtSecret string) *AuthService {
-	return &AuthService{JwtSecret: jwtSecret}
+	key, err := NewHMACKey(jwtSecret)
+	if err != nil {
+		// Still refuses every token, the empty key is never accepted
+		return &AuthService{keys: &KeySet{Issuer: DefaultIssuer, Audience: DefaultAudience, keys: map[string]Key{}}}
+	}
+	keys, _ := NewKeySet(DefaultIssuer, DefaultAudience, key)
+	return NewAuthServiceWithKeys(keys)
+}
+
+func NewAuthServiceWithKeys(keys *KeySet) *AuthService {
+	return &AuthService{keys: keys}
+}
+
+// Keys returns the keys tokens are signed and verified with.
+func (a *AuthService) Keys() *KeySet {
+	return a.keys
+}
+
+// Role assumed for tokens issued before roles were added to the claims
+const DefaultRole = "user"
+
+// Access tokens are short-lived, clients renew them with a refresh token
+const AccessTokenTTL = 15 * time.Minute
+
+// TokenClaims are the claims the server relies on besides the user ID.
+type TokenClaims struct {
+	// ID identifies the token on the revocation list, it is set by GenerateToken
+	ID string
+	// Version has to match the user's token version, bumping it revokes all tokens at once
+	Version int
+	Role    string
+	// SessionID ties the token to a login, revoking the session revokes the token
+	SessionID string
+	ExpiresAt time.Time
 }
 
-func (a *AuthService) GenerateToken(userID uuid.UUID) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// GenerateToken issues an access token carrying the given claims. ID and ExpiresAt are filled in.
+func (a *AuthService) GenerateToken(userID uuid.UUID, claims TokenClaims) (string, error) {
+	return a.sign(jwt.MapClaims{
+		"sub":     userID.String(),
 		"user_id": userID.String(),
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
-	})
+		"role":    claims.Role,
+		"ver":     claims.Version,
+		"sid":     claims.SessionID,
+	}, AccessTokenTTL)
+}
 
-	tokenString, err := token.SignedString([]byte(a.JwtSecret))
-	if err != nil {
-		return "", err
+// sign adds the standard claims and signs the token with the current signing key.
+func (a *AuthService) sign(claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	key := a.keys.signing
+	if !key.CanSign() {
+		return "", errors.New("no signing key configured")
 	}
+	now := time.Now()
+	claims["jti"] = uuid.New().String()
+	claims["iss"] = a.keys.Issuer
+	claims["aud"] = a.keys.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
 
-	return tokenString, nil
+	token := jwt.NewWithClaims(key.method(), claims)
+	// Verifiers pick the key by its ID, which is what makes rotation possible
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signingKey())
 }
 
+// ParseToken verifies the signature and the standard claims of a token. Besides
+// expiry, issuer and audience it requires the iat and jti claims.
 func (a *AuthService) ParseToken(tokenString string) (*jwt.Token, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(a.JwtSecret), nil
-	})
+	token, err := jwt.Parse(tokenString, a.keys.keyFunc,
+		jwt.WithValidMethods(a.keys.algorithms()),
+		jwt.WithIssuer(a.keys.Issuer),
+		jwt.WithAudience(a.keys.Audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return token, err
+	}
 
-	return token, err
+	claims := token.Claims.(jwt.MapClaims)
+	if _, ok := claims["iat"].(float64); !ok {
+		token.Valid = false
+		return token, errors.New("token has no iat claim")
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		token.Valid = false
+		return token, errors.New("token has no jti claim")
+	}
+	return token, nil
 }
 
 func (a *AuthService) ValidateToken(token *jwt.Token) (uuid.UUID, error) {
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := claims["user_id"].(string)
+		userID, _ := claims["user_id"].(string)
 		userUUID, err := uuid.Parse(userID)
 		if err != nil {
 			return uuid.Nil, fmt.Errorf("invalid user ID format")
@@ -52,3 +119,37 @@ func (a *AuthService) ValidateToken(token *jwt.Token) (uuid.UUID, error) {
 	}
 	return uuid.Nil, fmt.Errorf("invalid token claims")
 }
+
+// TokenRole returns the role claim of a validated token.
+func (a *AuthService) TokenRole(token *jwt.Token) string {
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if role, ok := claims["role"].(string); ok && role != "" {
+			return role
+		}
+	}
+	return DefaultRole
+}
+
+// Claims returns the claims of a validated token.
+func (a *AuthService) Claims(token *jwt.Token) (TokenClaims, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return TokenClaims{}, fmt.Errorf("invalid token claims")
+	}
+	// Challenge tokens and the like are signed with the same secret but grant no access
+	if _, ok := claims["purpose"]; ok {
+		return TokenClaims{}, fmt.Errorf("not an access token")
+	}
+
+	result := TokenClaims{Role: a.TokenRole(token)}
+	result.ID, _ = claims["jti"].(string)
+	result.SessionID, _ = claims["sid"].(string)
+	// JSON numbers decode as float64
+	if version, ok := claims["ver"].(float64); ok {
+		result.Version = int(version)
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		result.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	return result, nil
+}
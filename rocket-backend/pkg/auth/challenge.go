@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Users with two-factor authentication get a challenge token after their password,
+// to present together with their code. It is no access token.
+const ChallengeTokenTTL = 5 * time.Minute
+
+const challengePurpose = "2fa"
+
+// GenerateChallengeToken issues a challenge token for the second login step.
+func (a *AuthService) GenerateChallengeToken(userID uuid.UUID) (string, error) {
+	return a.sign(jwt.MapClaims{
+		"sub":     userID.String(),
+		"user_id": userID.String(),
+		"purpose": challengePurpose,
+	}, ChallengeTokenTTL)
+}
+
+// ParseChallengeToken validates a challenge token and returns its user.
+func (a *AuthService) ParseChallengeToken(tokenString string) (uuid.UUID, error) {
+	token, err := a.ParseToken(tokenString)
+	if err != nil || !token.Valid {
+		return uuid.Nil, fmt.Errorf("invalid challenge token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != challengePurpose {
+		return uuid.Nil, fmt.Errorf("invalid challenge token")
+	}
+	return a.ValidateToken(token)
+}
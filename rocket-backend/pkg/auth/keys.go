@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims every token carries unless configured otherwise
+const (
+	DefaultIssuer   = "rocket-backend"
+	DefaultAudience = "rocket-app"
+)
+
+// Algorithms keys can be used with
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// RSA keys shorter than this are refused
+const minRSABits = 2048
+
+// Key signs or verifies tokens. Its ID is the RFC 7638 thumbprint, so every replica
+// names the same key the same way without further configuration.
+type Key struct {
+	ID        string
+	Algorithm string
+	secret    []byte
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+// NewHMACKey returns a HS256 key with the secret, which both signs and verifies.
+func NewHMACKey(secret string) (Key, error) {
+	if secret == "" {
+		return Key{}, errors.New("HMAC secret must not be empty")
+	}
+	key := Key{Algorithm: AlgorithmHS256, secret: []byte(secret)}
+	key.ID = thumbprint(map[string]string{"k": base64.RawURLEncoding.EncodeToString(key.secret), "kty": "oct"})
+	return key, nil
+}
+
+// NewKey returns a key for a RSA or Ed25519 key. Private keys sign and verify, public
+// keys only verify.
+func NewKey(raw any) (Key, error) {
+	var key Key
+	switch k := raw.(type) {
+	case *rsa.PrivateKey:
+		key.private, key.public = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.private, key.public = k, k.Public()
+	case *rsa.PublicKey:
+		key.public = k
+	case ed25519.PublicKey:
+		key.public = k
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", raw)
+	}
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return Key{}, fmt.Errorf("RSA keys need at least %d bits", minRSABits)
+		}
+		key.Algorithm = AlgorithmRS256
+	case ed25519.PublicKey:
+		key.Algorithm = AlgorithmEdDSA
+	}
+	key.ID = thumbprint(key.jwk(false))
+	return key, nil
+}
+
+// ParseKeyPEM reads a RSA or Ed25519 key in PEM format, private keys as PKCS #8 or
+// PKCS #1, public keys as PKIX or PKCS #1.
+func ParseKeyPEM(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block found")
+	}
+
+	var raw any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		raw, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		raw, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		raw, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		raw, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	return NewKey(raw)
+}
+
+// LoadKeyFile reads a key with ParseKeyPEM.
+func LoadKeyFile(path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	key, err := ParseKeyPEM(data)
+	if err != nil {
+		return Key{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// CanSign tells whether the key can sign tokens, not just verify them.
+func (k Key) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k Key) signingKey() any {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.private
+}
+
+func (k Key) verificationKey() any {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.public
+}
+
+// jwk returns the public key as JSON Web Key (RFC 7517), with the members of RFC 7638
+// only unless full is set. HMAC keys have no public part and return nil.
+func (k Key) jwk(full bool) map[string]string {
+	var jwk map[string]string
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk = map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		jwk = map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(public),
+		}
+	default:
+		return nil
+	}
+	if full {
+		jwk["kid"] = k.ID
+		jwk["alg"] = k.Algorithm
+		jwk["use"] = "sig"
+	}
+	return jwk
+}
+
+// thumbprint hashes the required members of a JWK, json.Marshal sorts them as RFC 7638 asks.
+func thumbprint(members map[string]string) string {
+	raw, _ := json.Marshal(members)
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens are accepted
+// from. Rotating keys without downtime takes three deployments: first add the new key
+// for verification everywhere, then sign with it while the old key only verifies, and
+// once the tokens of the old key expired remove it.
+type KeySet struct {
+	Issuer   string
+	Audience string
+	signing  Key
+	keys     map[string]Key
+}
+
+// NewKeySet returns a key set signing with signing and also accepting tokens of the
+// verification keys.
+func NewKeySet(issuer, audience string, signing Key, verification ...Key) (*KeySet, error) {
+	if !signing.CanSign() {
+		return nil, errors.New("the signing key needs its private part")
+	}
+	set := &KeySet{Issuer: issuer, Audience: audience, signing: signing, keys: map[string]Key{signing.ID: signing}}
+	for _, key := range verification {
+		if _, ok := set.keys[key.ID]; ok {
+			continue
+		}
+		set.keys[key.ID] = key
+	}
+	return set, nil
+}
+
+// KeySetFromEnv configures the keys from the environment. JWT_SIGNING_KEY_FILE names a
+// PEM file with the RSA or Ed25519 private key to sign with, without it tokens are
+// signed with JWT_SECRET using HS256. Comma separated JWT_VERIFICATION_KEY_FILES and
+// JWT_VERIFICATION_SECRETS are further keys tokens are accepted from.
+func KeySetFromEnv() (*KeySet, error) {
+	issuer := envOr("JWT_ISSUER", DefaultIssuer)
+	audience := envOr("JWT_AUDIENCE", DefaultAudience)
+
+	var signing Key
+	var err error
+	if path := os.Getenv("JWT_SIGNING_KEY_FILE"); path != "" {
+		signing, err = LoadKeyFile(path)
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		signing, err = NewHMACKey(secret)
+	} else {
+		err = errors.New("JWT_SIGNING_KEY_FILE or JWT_SECRET is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var verification []Key
+	for _, path := range splitList(os.Getenv("JWT_VERIFICATION_KEY_FILES")) {
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+	for _, secret := range splitList(os.Getenv("JWT_VERIFICATION_SECRETS")) {
+		key, err := NewHMACKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+	return NewKeySet(issuer, audience, signing, verification...)
+}
+
+// SigningKeyID returns the ID of the key new tokens are signed with.
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+// JWKS is the JSON Web Key Set (RFC 7517) with the public keys of a key set.
+type JWKS struct {
+	Keys []map[string]string `json:"keys"`
+}
+
+// JWKS returns the public keys for other services to verify tokens with. HMAC keys
+// are secret and left out.
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []map[string]string{}}
+	if jwk := s.signing.jwk(true); jwk != nil {
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	ids := slices.Sorted(maps.Keys(s.keys))
+	for _, id := range ids {
+		if id == s.signing.ID {
+			continue
+		}
+		if jwk := s.keys[id].jwk(true); jwk != nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// algorithms lists the algorithms of all keys, only these are accepted in tokens.
+func (s *KeySet) algorithms() []string {
+	var algorithms []string
+	for _, key := range s.keys {
+		if !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	return algorithms
+}
+
+// keyFunc finds the key a token was signed with by its kid header. The algorithm has
+// to be the key's, so a RSA public key cannot be passed off as HMAC secret.
+func (s *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verificationKey(), nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
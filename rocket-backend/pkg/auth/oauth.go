@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+)
+
+// Lifetimes of what the OAuth endpoints hand out to partner apps
+const (
+	OAuthCodeTTL         = 10 * time.Minute
+	OAuthAccessTokenTTL  = time.Hour
+	OAuthRefreshTokenTTL = 90 * 24 * time.Hour
+)
+
+// Access tokens of OAuth clients start with this, like personal access tokens with theirs
+const OAuthAccessTokenPrefix = "rko_"
+
+// GenerateOAuthAccessToken returns a new access token for an OAuth client.
+func GenerateOAuthAccessToken() (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return OAuthAccessTokenPrefix + token, nil
+}
+
+// VerifyPKCE checks a code verifier against the S256 challenge sent with the
+// authorization request (RFC 7636).
+func VerifyPKCE(verifier, challenge string) bool {
+	// 43 to 128 characters, shorter verifiers are too easy to guess
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// Refresh tokens are rotated on every use, this is how long an unused one lasts
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// How long the links of verification and password reset emails work
+const (
+	EmailVerificationTTL = 48 * time.Hour
+	PasswordResetTTL     = time.Hour
+)
+
+// GenerateOpaqueToken returns a new random token, as used for refresh tokens and
+// email links. Only its hash is meant to be stored.
+func GenerateOpaqueToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Personal access tokens start with this, so they are easy to tell apart from JWTs
+// and to find when they leak into code
+const PersonalTokenPrefix = "rkt_"
+
+// GeneratePersonalToken returns a new personal access token.
+func GeneratePersonalToken() (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalTokenPrefix + token, nil
+}
+
+// HashOpaqueToken hashes an opaque token for storage and lookup.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes every email as an .eml file into a directory, so they can be
+// opened with a mail client during local development.
+type FileMailer struct {
+	dir string
+	seq atomic.Uint64
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("mail directory not configured")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	// The sequence keeps names unique when several emails are sent within the same nanosecond
+	name := fmt.Sprintf("%d-%d-%s.eml", time.Now().UnixNano(), m.seq.Add(1), sanitize(msg.To))
+	if err := os.WriteFile(filepath.Join(m.dir, name), format("rocket@localhost", msg), 0o644); err != nil {
+		return fmt.Errorf("failed to write email to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+func sanitize(address string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, address)
+}
@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Issuers of the well-known providers, so only client credentials need configuring
+var knownIssuers = map[string]string{
+	"google": "https://accounts.google.com",
+	"apple":  "https://appleid.apple.com",
+}
+
+// ProvidersFromEnv reads the providers listed in OIDC_PROVIDERS, e.g. "google,github".
+// Each one needs OIDC_<NAME>_CLIENT_ID and OIDC_<NAME>_CLIENT_SECRET, other providers
+// also OIDC_<NAME>_ISSUER. Callbacks go to OIDC_REDIRECT_BASE_URL/<name>/callback.
+func ProvidersFromEnv() ([]*Provider, error) {
+	var providers []*Provider
+	redirectBase := strings.TrimSuffix(os.Getenv("OIDC_REDIRECT_BASE_URL"), "/")
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  fmt.Sprintf("%s/%s/callback", redirectBase, name),
+		}
+		if config.ClientID == "" {
+			return nil, fmt.Errorf("%sCLIENT_ID is not set", prefix)
+		}
+
+		if name == "github" {
+			config = GitHub(config)
+		} else if config.Issuer == "" {
+			config.Issuer = knownIssuers[name]
+			if config.Issuer == "" {
+				return nil, fmt.Errorf("%sISSUER is not set", prefix)
+			}
+		}
+		providers = append(providers, NewProvider(config))
+	}
+	return providers, nil
+}
+
+// GitHub configures GitHub, which speaks OAuth2 but not OpenID Connect. The user is
+// read from its API and only the verified primary address is used.
+func GitHub(config Config) Config {
+	config.Issuer = ""
+	config.AuthURL = "https://github.com/login/oauth/authorize"
+	config.TokenURL = "https://github.com/login/oauth/access_token"
+	config.Scopes = []string{"read:user", "user:email"}
+	config.identity = githubIdentity("https://api.github.com")
+	return config
+}
+
+func githubIdentity(apiURL string) func(ctx context.Context, client *http.Client, accessToken string) (Identity, error) {
+	get := func(ctx context.Context, client *http.Client, accessToken, path string, target any) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		return doJSON(client, req, target)
+	}
+
+	return func(ctx context.Context, client *http.Client, accessToken string) (Identity, error) {
+		var user struct {
+			ID    int64  `json:"id"`
+			Login string `json:"login"`
+			Name  string `json:"name"`
+		}
+		if err := get(ctx, client, accessToken, "/user", &user); err != nil {
+			return Identity{}, err
+		}
+		if user.ID == 0 {
+			return Identity{}, fmt.Errorf("%w: no user", ErrInvalidIdentity)
+		}
+
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := get(ctx, client, accessToken, "/user/emails", &emails); err != nil {
+			return Identity{}, err
+		}
+
+		identity := Identity{Subject: fmt.Sprint(user.ID), Name: user.Name}
+		if identity.Name == "" {
+			identity.Name = user.Login
+		}
+		for _, email := range emails {
+			if email.Primary {
+				identity.Email = email.Email
+				identity.EmailVerified = email.Verified
+			}
+		}
+		return identity, nil
+	}
+}
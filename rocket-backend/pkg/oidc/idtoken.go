@@ -0,0 +1,137 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Unknown key IDs trigger a refetch of the keys, at most this often
+const keyRefreshInterval = time.Minute
+
+type keySet struct {
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// verifyIDToken checks signature, issuer, audience, expiry and nonce of an ID token.
+func (p *Provider) verifyIDToken(ctx context.Context, idToken, nonce string) (Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, d.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIdentity, err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return Identity{}, fmt.Errorf("%w: wrong nonce", ErrInvalidIdentity)
+	}
+
+	identity := Identity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// Apple sends the flag as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	if identity.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: no subject", ErrInvalidIdentity)
+	}
+	return identity, nil
+}
+
+// key returns the signing key with the given ID, fetching the provider's keys if needed.
+func (p *Provider) key(ctx context.Context, jwksURI, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		if key, ok := p.keys.keys[kid]; ok {
+			return key, nil
+		}
+		if time.Since(p.keys.fetched) < keyRefreshInterval {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := doJSON(p.client, req, &set); err != nil {
+		return nil, fmt.Errorf("fetching keys failed: %w", err)
+	}
+
+	keys := &keySet{keys: map[string]*rsa.PublicKey{}, fetched: time.Now()}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		if key, err := parseRSAKey(k); err == nil {
+			keys.keys[k.Kid] = key
+		}
+	}
+	p.keys = keys
+
+	key, ok := keys.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+func parseRSAKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func randomString(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,217 @@
+// Package oidc implements the authorization code flow of OpenID Connect, with PKCE,
+// for logging in with external identity providers.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidIdentity is returned when the provider's answer cannot be trusted.
+var ErrInvalidIdentity = errors.New("invalid identity from provider")
+
+// Config configures a provider. Providers implementing OpenID Connect only need the
+// issuer, their endpoints are discovered. Plain OAuth2 providers such as GitHub
+// set the endpoints and an identity source instead.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// Only for providers without discovery
+	AuthURL  string
+	TokenURL string
+	// identity reads the user from a plain OAuth2 provider with the access token
+	identity func(ctx context.Context, client *http.Client, accessToken string) (Identity, error)
+}
+
+// Identity is the user as the provider knows them.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is a configured identity provider. It is safe for concurrent use.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+type discovery struct {
+	Issuer        string `json:"issuer"`
+	AuthEndpoint  string `json:"authorization_endpoint"`
+	TokenEndpoint string `json:"token_endpoint"`
+	JWKSURI       string `json:"jwks_uri"`
+}
+
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 && config.identity == nil {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// NewCodeVerifier returns a PKCE code verifier for one login attempt.
+func NewCodeVerifier() (string, error) {
+	return randomString(32)
+}
+
+// NewNonce returns a value that ties an ID token to one login attempt.
+func NewNonce() (string, error) {
+	return randomString(16)
+}
+
+// AuthCodeURL returns where to send the user to log in.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	authURL, _, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if p.config.identity == nil {
+		query.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(authURL, "?") {
+		separator = "&"
+	}
+	return authURL + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code and returns the verified identity of the user.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error) {
+	_, tokenURL, err := p.endpoints(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+	}
+	if err := doJSON(p.client, req, &tokens); err != nil {
+		return Identity{}, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if tokens.Error != "" {
+		return Identity{}, fmt.Errorf("%w: %s", ErrInvalidIdentity, tokens.Error)
+	}
+
+	if p.config.identity != nil {
+		if tokens.AccessToken == "" {
+			return Identity{}, fmt.Errorf("%w: no access token", ErrInvalidIdentity)
+		}
+		return p.config.identity(ctx, p.client, tokens.AccessToken)
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: no ID token", ErrInvalidIdentity)
+	}
+	return p.verifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// endpoints returns the authorization and token endpoint, discovering them on first use.
+func (p *Provider) endpoints(ctx context.Context) (string, string, error) {
+	if p.config.Issuer == "" {
+		return p.config.AuthURL, p.config.TokenURL, nil
+	}
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return d.AuthEndpoint, d.TokenEndpoint, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	if err := doJSON(p.client, req, &d); err != nil {
+		return nil, fmt.Errorf("discovery of %s failed: %w", p.config.Name, err)
+	}
+	// Prevents a compromised discovery document from impersonating another issuer
+	if d.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("discovery of %s returned issuer %q", p.config.Name, d.Issuer)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func doJSON(client *http.Client, req *http.Request, target any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("unexpected response from %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Policy is a token bucket: Burst requests are allowed at once, after that the bucket
+// refills with Limit requests per Period.
+type Policy struct {
+	// Keeps the buckets of different policies apart when they share a key
+	Name   string
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// Result tells whether a request may pass and otherwise how long to wait.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets. Take removes a token from the bucket under key.
+type Store interface {
+	Take(key string, policy Policy) (Result, error)
+}
+
+// StoreFunc adapts a function, e.g. a database method, to a Store.
+type StoreFunc func(key string, policy Policy) (Result, error)
+
+func (f StoreFunc) Take(key string, policy Policy) (Result, error) {
+	return f(key, policy)
+}
+
+// rate returns the refill rate in tokens per second.
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// Take refills a bucket that held tokens elapsed ago and tries to take one token from
+// it. It returns the tokens left in the bucket, stores persist those.
+func (p Policy) Take(tokens float64, elapsed time.Duration) (float64, Result) {
+	tokens = math.Min(float64(p.Burst), tokens+elapsed.Seconds()*p.rate())
+	if tokens >= 1 {
+		tokens--
+		return tokens, Result{Allowed: true, Remaining: int(tokens)}
+	}
+	wait := time.Duration((1 - tokens) / p.rate() * float64(time.Second))
+	return tokens, Result{RetryAfter: wait}
+}
+
+// FullAfter returns how long a bucket holding tokens takes to fill up again.
+func (p Policy) FullAfter(tokens float64) time.Duration {
+	return time.Duration((float64(p.Burst) - tokens) / p.rate() * float64(time.Second))
+}
+
+// Limiter checks requests against policies.
+type Limiter struct {
+	store Store
+}
+
+func New(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Allow takes a token from the bucket of key under the policy.
+func (l *Limiter) Allow(policy Policy, key string) (Result, error) {
+	return l.store.Take(policy.Name+":"+key, policy)
+}
+
+// Lockout blocks an account after Threshold failed logins in a row. The first lock
+// lasts Base and every further failure doubles it, up to Max.
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+// Duration returns how long the account stays locked after the given number of
+// failures, zero while it is below the threshold.
+func (l Lockout) Duration(failures int) time.Duration {
+	if failures < l.Threshold {
+		return 0
+	}
+	d := l.Base
+	for i := l.Threshold; i < failures && d < l.Max; i++ {
+		d *= 2
+	}
+	return min(d, l.Max)
+}
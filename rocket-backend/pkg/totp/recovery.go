@@ -0,0 +1,42 @@
+package totp
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// Number of recovery codes handed out at once
+const RecoveryCodeCount = 10
+
+// Unambiguous lower case characters, no 0/o or 1/l
+const recoveryAlphabet = "23456789abcdefghijkmnpqrstuvwxyz"
+
+// GenerateRecoveryCodes returns new single-use codes like "k7m2p-x9qrt".
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for j, c := range raw {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			// 256 is a multiple of the alphabet's 32 characters, so there is no bias
+			b.WriteByte(recoveryAlphabet[int(c)%len(recoveryAlphabet)])
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode undoes what users tend to do when typing a code.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Join(strings.Fields(code), ""))
+	if len(code) == 10 && !strings.Contains(code, "-") {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}